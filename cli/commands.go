@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/elmasy-com/columbus/client"
+	mdns "github.com/miekg/dns"
+)
+
+// command is a subcommand of the CLI.
+// fn is called for every input, stat is called once without input.
+type command struct {
+	usage  string
+	header []string
+	fn     func(ctx context.Context, c *client.Client, input string) result
+	noArgs bool
+}
+
+var commands = map[string]command{
+	"lookup":  {usage: "lookup [domain...]\tPrint the subdomains of the domains", header: []string{"domain", "fqdn"}, fn: lookup},
+	"history": {usage: "history [domain...]\tPrint the records of the subdomains", header: []string{"fqdn", "type", "value", "time"}, fn: history},
+	"starts":  {usage: "starts [domain...]\tPrint the domains starting with the given string", header: []string{"query", "domain"}, fn: starts},
+	"tld":     {usage: "tld [domain...]\tPrint the known TLDs of the domains (eg.: \"example\")", header: []string{"domain", "tld"}, fn: tld},
+	"insert":  {usage: "insert [domain...]\tInsert the domains", header: []string{"domain", "status"}, fn: insert},
+	"stat":    {usage: "stat\t\tPrint the statistic of the database", header: []string{"date", "total", "updated", "valid"}, fn: stat, noArgs: true},
+}
+
+// commandNames is the order of the commands in the usage.
+var commandNames = []string{"lookup", "history", "starts", "tld", "insert", "stat"}
+
+// fqdn returns the hostname of sub in domain d.
+func fqdn(sub string, d string) string {
+
+	if sub == "" {
+		return d
+	}
+
+	return sub + "." + d
+}
+
+func lookup(ctx context.Context, c *client.Client, d string) result {
+
+	subs, err := c.Lookup(ctx, d, *days)
+	if err != nil {
+		return result{Input: d, Err: err}
+	}
+
+	r := result{Input: d, JSON: map[string]any{"domain": d, "subdomains": subs}}
+
+	for i := range subs {
+		if *format == formatCSV {
+			r.Rows = append(r.Rows, []string{d, fqdn(subs[i], d)})
+		} else {
+			r.Rows = append(r.Rows, []string{fqdn(subs[i], d)})
+		}
+	}
+
+	return r
+}
+
+func history(ctx context.Context, c *client.Client, d string) result {
+
+	hs, err := c.History(ctx, d, *days)
+	if err != nil {
+		return result{Input: d, Err: err}
+	}
+
+	r := result{Input: d, JSON: map[string]any{"domain": d, "history": hs}}
+
+	for i := range hs {
+		for _, rec := range hs[i].Records {
+			r.Rows = append(r.Rows, []string{hs[i].Domain, mdns.TypeToString[rec.Type], rec.Value, strconv.FormatInt(rec.Time, 10)})
+		}
+	}
+
+	return r
+}
+
+func starts(ctx context.Context, c *client.Client, q string) result {
+
+	ds, err := c.Starts(ctx, q)
+	if err != nil {
+		return result{Input: q, Err: err}
+	}
+
+	r := result{Input: q, JSON: map[string]any{"query": q, "domains": ds}}
+
+	for i := range ds {
+		if *format == formatCSV {
+			r.Rows = append(r.Rows, []string{q, ds[i]})
+		} else {
+			r.Rows = append(r.Rows, []string{ds[i]})
+		}
+	}
+
+	return r
+}
+
+func tld(ctx context.Context, c *client.Client, d string) result {
+
+	tlds, err := c.TLD(ctx, d)
+	if err != nil {
+		return result{Input: d, Err: err}
+	}
+
+	r := result{Input: d, JSON: map[string]any{"domain": d, "tlds": tlds}}
+
+	for i := range tlds {
+		if *format == formatCSV {
+			r.Rows = append(r.Rows, []string{d, tlds[i]})
+		} else {
+			r.Rows = append(r.Rows, []string{d + "." + tlds[i]})
+		}
+	}
+
+	return r
+}
+
+func insert(ctx context.Context, c *client.Client, d string) result {
+
+	err := c.Insert(ctx, d)
+	if err != nil {
+		return result{Input: d, Err: err}
+	}
+
+	return result{Input: d, Rows: [][]string{{d, "ok"}}, JSON: map[string]any{"domain": d, "status": "ok"}}
+}
+
+func stat(ctx context.Context, c *client.Client, _ string) result {
+
+	s, err := c.Stat(ctx)
+	if err != nil {
+		return result{Input: "stat", Err: err}
+	}
+
+	r := result{Input: "stat", JSON: s}
+
+	if *format == formatCSV {
+		r.Rows = [][]string{{strconv.FormatInt(s.Date, 10), strconv.FormatInt(s.Total, 10), strconv.FormatInt(s.Updated, 10), strconv.FormatInt(s.Valid, 10)}}
+		return r
+	}
+
+	r.Rows = [][]string{
+		{"date", strconv.FormatInt(s.Date, 10)},
+		{"total", strconv.FormatInt(s.Total, 10)},
+		{"updated", strconv.FormatInt(s.Updated, 10)},
+		{"valid", strconv.FormatInt(s.Valid, 10)},
+	}
+
+	return r
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/elmasy-com/columbus/client"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the config file of the CLI.
+type Config struct {
+	URL    string `yaml:"URL"`
+	APIKey string `yaml:"APIKey"`
+}
+
+// defaultConfigPath returns the default path of the config file (eg.: ~/.config/columbus/config.yaml).
+func defaultConfigPath() string {
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(dir, "columbus", "config.yaml")
+}
+
+// parseConfig parses the config file in path.
+// If path is the default path and the file does not exist, returns the default config.
+func parseConfig(path string, isDefault bool) (*Config, error) {
+
+	c := &Config{URL: client.DefaultURL}
+
+	if path == "" {
+		return c, nil
+	}
+
+	out, err := os.ReadFile(path)
+	if err != nil {
+		if isDefault && errors.Is(err, fs.ErrNotExist) {
+			return c, nil
+		}
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	err = yaml.Unmarshal(out, c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal %s: %w", path, err)
+	}
+
+	if c.URL == "" {
+		c.URL = client.DefaultURL
+	}
+
+	return c, nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/elmasy-com/columbus/client"
+)
+
+var (
+	BuildDate   string
+	BuildCommit string
+	configPath  = flag.String("config", "", "Path to the config file (default: "+defaultConfigPath()+")")
+	apiURL      = flag.String("url", "", "URL of the Columbus API, overrides the config file (default: "+client.DefaultURL+")")
+	format      = flag.String("format", formatText, "Output format: text, json (JSON Lines) or csv")
+	days        = flag.Int("days", 0, "Days option of lookup and history: -1 every subdomain, 0 every subdomain with a record, n subdomains with a record in the last n days")
+	workers     = flag.Int("workers", 4, "Number of concurrent requests with multiple inputs")
+	rateLimit   = flag.Float64("rate", 10, "Maximum number of requests per second")
+	version     = flag.Bool("version", false, "Print current version")
+)
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [options] <command> [args...]\n\n", os.Args[0])
+	fmt.Fprintf(flag.CommandLine.Output(), "Commands:\n")
+	for _, n := range commandNames {
+		fmt.Fprintf(flag.CommandLine.Output(), "  %s\n", commands[n].usage)
+	}
+	fmt.Fprintf(flag.CommandLine.Output(), "\nIf no argument given, the inputs are read from the standard input, one per line.\n")
+	fmt.Fprintf(flag.CommandLine.Output(), "Exit codes: 0 success, 1 error, 2 invalid usage, 3 not found.\n\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+
+	flag.Usage = usage
+	flag.Parse()
+
+	if *version {
+		fmt.Printf("Build date: %s\n", BuildDate)
+		fmt.Printf("Git Commit: %s\n", BuildCommit)
+		os.Exit(exitOK)
+	}
+
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(exitUsage)
+	}
+
+	cmd, ok := commands[flag.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown command: %s\n", flag.Arg(0))
+		os.Exit(exitUsage)
+	}
+
+	if *workers < 1 {
+		fmt.Fprintf(os.Stderr, "Invalid -workers: %d\n", *workers)
+		os.Exit(exitUsage)
+	}
+
+	path, isDefault := *configPath, false
+	if path == "" {
+		path, isDefault = defaultConfigPath(), true
+	}
+
+	conf, err := parseConfig(path, isDefault)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to parse config: %s\n", err)
+		os.Exit(exitUsage)
+	}
+
+	if *apiURL != "" {
+		conf.URL = *apiURL
+	}
+
+	p, err := newPrinter(os.Stdout, *format, cmd.header)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(exitUsage)
+	}
+
+	c := client.New(conf.URL, client.WithAPIKey(conf.APIKey), client.WithRateLimit(*rateLimit, *workers), client.WithUserAgent("columbus-cli"))
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	var inputs <-chan string
+
+	if cmd.noArgs {
+		inputs = readInputs([]string{""}, nil)
+	} else {
+		inputs = readInputs(flag.Args()[1:], os.Stdin)
+	}
+
+	code := run(ctx, inputs, *workers, p, func(ctx context.Context, input string) result { return cmd.fn(ctx, c, input) })
+
+	os.Exit(code)
+}
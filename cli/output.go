@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Output formats.
+const (
+	formatText = "text"
+	formatJSON = "json"
+	formatCSV  = "csv"
+)
+
+// result is the result of a command for an input.
+type result struct {
+	Input string
+	Rows  [][]string // Rows in the text and CSV output
+	JSON  any        // Value in the JSON output
+	Err   error
+}
+
+// printer writes the results in a format.
+type printer struct {
+	format string
+	header []string
+	w      io.Writer
+	csv    *csv.Writer
+	json   *json.Encoder
+}
+
+// newPrinter returns a printer that writes to w.
+// header is the header of the CSV output.
+func newPrinter(w io.Writer, format string, header []string) (*printer, error) {
+
+	p := &printer{format: format, header: header, w: w}
+
+	switch format {
+	case formatText:
+	case formatJSON:
+		p.json = json.NewEncoder(w)
+		p.json.SetEscapeHTML(false)
+	case formatCSV:
+		p.csv = csv.NewWriter(w)
+		if err := p.csv.Write(header); err != nil {
+			return nil, err
+		}
+		p.csv.Flush()
+	default:
+		return nil, fmt.Errorf("unknown format: %s", format)
+	}
+
+	return p, nil
+}
+
+// Print writes the successful result r.
+// The text output is the rows separated by spaces, the JSON output is one value per line (JSON Lines).
+func (p *printer) Print(r result) error {
+
+	switch p.format {
+	case formatJSON:
+		return p.json.Encode(r.JSON)
+	case formatCSV:
+		for i := range r.Rows {
+			if err := p.csv.Write(r.Rows[i]); err != nil {
+				return err
+			}
+		}
+		p.csv.Flush()
+		return p.csv.Error()
+	default:
+		for i := range r.Rows {
+			if _, err := fmt.Fprintln(p.w, strings.Join(r.Rows[i], " ")); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
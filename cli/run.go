@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/elmasy-com/columbus/fault"
+)
+
+// Exit codes.
+const (
+	exitOK       = 0
+	exitError    = 1
+	exitUsage    = 2
+	exitNotFound = 3
+)
+
+// readInputs sends the inputs to the returned channel.
+// If args is empty, the inputs are read from r line by line, empty lines and lines starting with "#" are skipped.
+func readInputs(args []string, r io.Reader) <-chan string {
+
+	inputs := make(chan string)
+
+	go func() {
+
+		defer close(inputs)
+
+		if len(args) > 0 {
+			for i := range args {
+				inputs <- args[i]
+			}
+			return
+		}
+
+		s := bufio.NewScanner(r)
+
+		for s.Scan() {
+
+			l := strings.TrimSpace(s.Text())
+			if l == "" || strings.HasPrefix(l, "#") {
+				continue
+			}
+
+			inputs <- l
+		}
+
+		if err := s.Err(); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to read input: %s\n", err)
+		}
+	}()
+
+	return inputs
+}
+
+// run calls fn with every input on workers goroutines and prints the results in the order of the inputs.
+// The errors are printed to the standard error.
+//
+// Returns exitOK if every input succeeded, exitNotFound if the failed inputs are not found only, exitError otherwise.
+func run(ctx context.Context, inputs <-chan string, workers int, p *printer, fn func(ctx context.Context, input string) result) int {
+
+	type job struct {
+		input string
+		res   chan result
+	}
+
+	jobs := make(chan job)
+	order := make(chan chan result, workers*2)
+
+	wg := new(sync.WaitGroup)
+
+	for i := 0; i < workers; i++ {
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				j.res <- fn(ctx, j.input)
+			}
+		}()
+	}
+
+	go func() {
+
+		for in := range inputs {
+
+			j := job{input: in, res: make(chan result, 1)}
+
+			order <- j.res
+			jobs <- j
+		}
+
+		close(jobs)
+		close(order)
+	}()
+
+	var notFound, failed bool
+
+	for res := range order {
+
+		r := <-res
+
+		switch {
+		case r.Err == nil:
+			if err := p.Print(r); err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to write output: %s\n", err)
+				failed = true
+			}
+		case errors.Is(r.Err, fault.ErrNotFound):
+			fmt.Fprintf(os.Stderr, "%s: not found\n", r.Input)
+			notFound = true
+		default:
+			fmt.Fprintf(os.Stderr, "%s: %s\n", r.Input, r.Err)
+			failed = true
+		}
+	}
+
+	wg.Wait()
+
+	switch {
+	case failed:
+		return exitError
+	case notFound:
+		return exitNotFound
+	default:
+		return exitOK
+	}
+}
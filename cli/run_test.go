@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elmasy-com/columbus/client"
+	"github.com/elmasy-com/columbus/fault"
+)
+
+// fakeLookup returns the input as a row after a delay that reverses the order of completion.
+func fakeLookup(_ context.Context, input string) result {
+
+	switch input {
+	case "notfound.com":
+		return result{Input: input, Err: &client.Error{StatusCode: 404, Fault: fault.ErrNotFound}}
+	case "error.com":
+		return result{Input: input, Err: errors.New("failed")}
+	}
+
+	time.Sleep(time.Duration(10-len(input)) * 5 * time.Millisecond)
+
+	return result{Input: input, Rows: [][]string{{input, "www." + input}}, JSON: map[string]string{"domain": input}}
+}
+
+func TestRun(t *testing.T) {
+
+	cases := []struct {
+		Input  string
+		Format string
+		Want   string
+		Code   int
+	}{
+		{"a.com\nbb.com\n# comment\n\nccc.com\n", formatText, "a.com www.a.com\nbb.com www.bb.com\nccc.com www.ccc.com\n", exitOK},
+		{"a.com\nnotfound.com\nbb.com\n", formatCSV, "domain,fqdn\na.com,www.a.com\nbb.com,www.bb.com\n", exitNotFound},
+		{"a.com\nerror.com\nnotfound.com\n", formatJSON, "{\"domain\":\"a.com\"}\n", exitError},
+	}
+
+	for i := range cases {
+
+		out := new(bytes.Buffer)
+
+		p, err := newPrinter(out, cases[i].Format, []string{"domain", "fqdn"})
+		if err != nil {
+			t.Fatalf("case %d: %s", i, err)
+		}
+
+		code := run(context.Background(), readInputs(nil, strings.NewReader(cases[i].Input)), 4, p, fakeLookup)
+
+		if code != cases[i].Code {
+			t.Errorf("case %d: want exit code %d, got %d", i, cases[i].Code, code)
+		}
+
+		if out.String() != cases[i].Want {
+			t.Errorf("case %d: unexpected output:\n%s", i, out.String())
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Record is a DNS record of a domain.
+type Record struct {
+	Type      uint16            `json:"type"`
+	Value     string            `json:"value"`
+	Time      int64             `json:"time"`
+	FirstSeen int64             `json:"firstSeen,omitempty"`
+	LastSeen  int64             `json:"lastSeen,omitempty"`
+	Removed   int64             `json:"removed,omitempty"`
+	Priority  uint16            `json:"priority,omitempty"`
+	Target    string            `json:"target,omitempty"`
+	Params    map[string]string `json:"params,omitempty"`
+}
+
+// History is a FQDN with the records returned by History().
+type History struct {
+	Domain  string   `json:"domain"`
+	Records []Record `json:"records"`
+}
+
+// Reverse is a FQDN with the matching records returned by the reverse lookups.
+type Reverse struct {
+	Domain  string   `json:"domain"`
+	Records []Record `json:"records"`
+}
+
+// DiffChange is a changed FQDN in a Diff.
+// Status is "added", "removed" or "changed".
+type DiffChange struct {
+	Domain  string   `json:"domain"`
+	Status  string   `json:"status"`
+	Added   []Record `json:"added,omitempty"`
+	Removed []Record `json:"removed,omitempty"`
+}
+
+// Diff is the changes of a domain in a time window returned by Diff().
+type Diff struct {
+	Domain  string       `json:"domain"`
+	Since   int64        `json:"since"`
+	Until   int64        `json:"until"`
+	Changes []DiffChange `json:"changes"`
+}
+
+// CTLog is the state of a CT log in the Statistic.
+type CTLog struct {
+	Name  string `json:"name"`
+	Index int64  `json:"index"`
+	Size  int64  `json:"size"`
+}
+
+// Statistic is the statistic of the database returned by Stat().
+type Statistic struct {
+	Date    int64   `json:"date"`
+	Total   int64   `json:"total"`
+	Updated int64   `json:"updated"`
+	Valid   int64   `json:"valid"`
+	CTLogs  []CTLog `json:"ctlogs"`
+}
+
+// daysQuery returns the query with days.
+// days 0 is the default of the API, so it is omitted.
+func daysQuery(days int) url.Values {
+
+	q := url.Values{}
+
+	if days != 0 {
+		q.Set("days", strconv.Itoa(days))
+	}
+
+	return q
+}
+
+// pageQuery adds skip and limit to q.
+// The zero values are omitted to use the defaults of the API.
+func pageQuery(q url.Values, skip int64, limit int64) url.Values {
+
+	if skip != 0 {
+		q.Set("skip", strconv.FormatInt(skip, 10))
+	}
+
+	if limit != 0 {
+		q.Set("limit", strconv.FormatInt(limit, 10))
+	}
+
+	return q
+}
+
+// Lookup returns the subdomains of domain d (eg.: "www", "mail").
+// The apex is returned as an empty string.
+// days is the same as in the API: 0 returns every subdomain with a record, -1 every subdomain,
+// n returns the subdomains with a record found in the previous n days.
+func (c *Client) Lookup(ctx context.Context, d string, days int) ([]string, error) {
+	return c.getList(ctx, "/api/lookup/"+escape(d), daysQuery(days))
+}
+
+// Starts returns the domains starting with d (eg.: "reddit" -> "reddit", "redditmedia").
+func (c *Client) Starts(ctx context.Context, d string) ([]string, error) {
+	return c.getList(ctx, "/api/starts/"+escape(d), nil)
+}
+
+// TLD returns the TLDs of domain d (eg.: "example" -> "com", "org").
+func (c *Client) TLD(ctx context.Context, d string) ([]string, error) {
+	return c.getList(ctx, "/api/tld/"+escape(d), nil)
+}
+
+// History returns the FQDNs of domain d with every record.
+// days is the same as in Lookup().
+func (c *Client) History(ctx context.Context, d string, days int) ([]History, error) {
+
+	var hs []History
+
+	err := c.get(ctx, "/api/history/"+escape(d), daysQuery(days), &hs)
+
+	return hs, err
+}
+
+// Search returns the hostnames matching query q.
+// skip and limit paginate the result, 0 uses the defaults of the API.
+func (c *Client) Search(ctx context.Context, q string, skip int64, limit int64) ([]string, error) {
+
+	return c.getList(ctx, "/api/search", pageQuery(url.Values{"q": {q}}, skip, limit))
+}
+
+// Diff returns the FQDNs and records of domain d added, removed or changed between since and until.
+// The time can be a date, a RFC3339 time, a Unix timestamp or relative (eg.: "7d"), empty uses the defaults of the API.
+func (c *Client) Diff(ctx context.Context, d string, since string, until string) (*Diff, error) {
+
+	q := url.Values{}
+
+	if since != "" {
+		q.Set("since", since)
+	}
+
+	if until != "" {
+		q.Set("until", until)
+	}
+
+	diff := new(Diff)
+
+	err := c.get(ctx, "/api/diff/"+escape(d), q, diff)
+	if err != nil {
+		return nil, err
+	}
+
+	return diff, nil
+}
+
+// Export returns the FQDNs of domain d with the records in format "csv" or "ndjson".
+// days is the same as in Lookup().
+func (c *Client) Export(ctx context.Context, d string, format string, days int) ([]byte, error) {
+
+	q := daysQuery(days)
+	q.Set("format", format)
+
+	return c.do(ctx, http.MethodGet, "/api/export/"+escape(d), q, false)
+}
+
+// reverse returns the result of the reverse lookup route path.
+func (c *Client) reverse(ctx context.Context, path string, days int, skip int64, limit int64) ([]Reverse, error) {
+
+	var rs []Reverse
+
+	err := c.get(ctx, path, pageQuery(daysQuery(days), skip, limit), &rs)
+
+	return rs, err
+}
+
+// Reverse returns the FQDNs with an A or AAAA record pointing to the address or into the CIDR ip (eg.: "203.0.113.0/24").
+// days is the same as in Lookup(), skip and limit paginate the result.
+func (c *Client) Reverse(ctx context.Context, ip string, days int, skip int64, limit int64) ([]Reverse, error) {
+
+	// The CIDR is /api/reverse/:ip/:bits
+	return c.reverse(ctx, "/api/reverse/"+strings.Replace(escape(ip), "%2F", "/", 1), days, skip, limit)
+}
+
+// ReverseCNAME returns the FQDNs with a CNAME record pointing to target or to a subdomain of target.
+func (c *Client) ReverseCNAME(ctx context.Context, target string, days int, skip int64, limit int64) ([]Reverse, error) {
+	return c.reverse(ctx, "/api/reverse/cname/"+escape(target), days, skip, limit)
+}
+
+// ReverseMX returns the FQDNs with an MX record pointing to target or to a subdomain of target.
+func (c *Client) ReverseMX(ctx context.Context, target string, days int, skip int64, limit int64) ([]Reverse, error) {
+	return c.reverse(ctx, "/api/reverse/mx/"+escape(target), days, skip, limit)
+}
+
+// ReverseNS returns the FQDNs with an NS record pointing to target or to a subdomain of target.
+func (c *Client) ReverseNS(ctx context.Context, target string, days int, skip int64, limit int64) ([]Reverse, error) {
+	return c.reverse(ctx, "/api/reverse/ns/"+escape(target), days, skip, limit)
+}
+
+// Insert submits domain d to the database.
+// The domain is inserted if it has a valid DNS record.
+func (c *Client) Insert(ctx context.Context, d string) error {
+
+	_, err := c.do(ctx, http.MethodPut, "/api/insert/"+escape(d), nil, false)
+
+	return err
+}
+
+// Stat returns the newest statistic of the database.
+func (c *Client) Stat(ctx context.Context) (*Statistic, error) {
+
+	s := new(Statistic)
+
+	err := c.get(ctx, "/api/stat", nil, s)
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// StatUpdater returns the number of pending domains in every priority class of the records updater.
+func (c *Client) StatUpdater(ctx context.Context) (map[string]int, error) {
+
+	var m map[string]int
+
+	err := c.get(ctx, "/api/stat/updater", nil, &m)
+
+	return m, err
+}
+
+// ToolsTLD returns the TLD of fqdn (eg.: "www.example.co.uk" -> "co.uk").
+func (c *Client) ToolsTLD(ctx context.Context, fqdn string) (string, error) {
+	return c.getResult(ctx, "/api/tools/tld/"+escape(fqdn))
+}
+
+// ToolsDomain returns the domain of fqdn (eg.: "www.example.co.uk" -> "example.co.uk").
+func (c *Client) ToolsDomain(ctx context.Context, fqdn string) (string, error) {
+	return c.getResult(ctx, "/api/tools/domain/"+escape(fqdn))
+}
+
+// ToolsSubdomain returns the subdomain of fqdn (eg.: "www.example.co.uk" -> "www").
+func (c *Client) ToolsSubdomain(ctx context.Context, fqdn string) (string, error) {
+	return c.getResult(ctx, "/api/tools/subdomain/"+escape(fqdn))
+}
+
+// ToolsIsValid returns whether fqdn is a valid domain.
+func (c *Client) ToolsIsValid(ctx context.Context, fqdn string) (bool, error) {
+
+	if c.text {
+
+		body, err := c.do(ctx, http.MethodGet, "/api/tools/isvalid/"+escape(fqdn), nil, true)
+
+		return string(body) == "true", err
+	}
+
+	var r struct {
+		Result bool `json:"result"`
+	}
+
+	err := c.get(ctx, "/api/tools/isvalid/"+escape(fqdn), nil, &r)
+
+	return r.Result, err
+}
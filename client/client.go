@@ -0,0 +1,379 @@
+/*
+client package is the Go client of the Columbus API.
+
+	c := client.New(client.DefaultURL)
+
+	subs, err := c.Lookup(ctx, "example.com", 0)
+	if errors.Is(err, fault.ErrNotFound) {
+		...
+	}
+
+The errors returned by the API are *Error values, that unwrap to the fault value of the error message,
+so errors.Is() can be used with the fault values (eg.: fault.ErrInvalidDomain).
+*/
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elmasy-com/columbus/fault"
+)
+
+// DefaultURL is the URL of the public Columbus instance.
+const DefaultURL = "https://columbus.elmasy.com"
+
+// Client is a client of the Columbus API, safe for concurrent use.
+// Create it with New().
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	userAgent  string
+	apiKey     string
+	text       bool
+	retries    int
+	backoff    time.Duration
+	limiter    *limiter
+}
+
+// Option configures the Client in New().
+type Option func(c *Client)
+
+// WithHTTPClient sets the HTTP client (default: http.DefaultClient).
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.httpClient = hc }
+}
+
+// WithUserAgent sets the User-Agent header (default: "columbus-client").
+func WithUserAgent(ua string) Option {
+	return func(c *Client) { c.userAgent = ua }
+}
+
+// WithAPIKey sets the API key sent in the "X-Api-Key" header.
+func WithAPIKey(key string) Option {
+	return func(c *Client) { c.apiKey = key }
+}
+
+// WithText sets the client to request the "text/plain" format instead of JSON where the route supports it.
+// The results are the same, only the format on the wire is different.
+func WithText() Option {
+	return func(c *Client) { c.text = true }
+}
+
+// WithRetries sets the number of retries after a 429 or 5xx response and the delay before the first retry (default: 3 and 1 second).
+// The delay is doubled after every retry, a Retry-After header in the response overrides it.
+// Set n to 0 to disable the retries.
+func WithRetries(n int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.retries = n
+		c.backoff = backoff
+	}
+}
+
+// WithRateLimit limits the requests to rps per second with bursts of burst requests (default: 10 and 10).
+// Set rps to 0 to disable the rate limiter.
+func WithRateLimit(rps float64, burst int) Option {
+	return func(c *Client) {
+		if rps <= 0 {
+			c.limiter = nil
+		} else {
+			c.limiter = newLimiter(rps, burst)
+		}
+	}
+}
+
+// New returns a Client for the Columbus instance at baseURL (eg.: DefaultURL).
+func New(baseURL string, opts ...Option) *Client {
+
+	c := &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: http.DefaultClient,
+		userAgent:  "columbus-client",
+		retries:    3,
+		backoff:    time.Second,
+		limiter:    newLimiter(10, 10),
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// Error is an error response of the API.
+// Error unwraps to the fault value of the message (eg.: fault.ErrNotFound), so errors.Is() can be used with the fault values.
+type Error struct {
+	StatusCode int                 // HTTP status code of the response
+	Message    string              // Error message in the response, can be empty
+	Fault      fault.ColumbusError // Fault value of the message or the status code, empty if unknown
+}
+
+func (e *Error) Error() string {
+
+	if e.Message == "" {
+		return fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+
+	return fmt.Sprintf("%d %s", e.StatusCode, e.Message)
+}
+
+func (e *Error) Unwrap() error {
+
+	if e.Fault.Err == "" {
+		return nil
+	}
+
+	return e.Fault
+}
+
+// faults are the fault values returned by the API.
+var faults = []fault.ColumbusError{
+	fault.ErrInvalidDomain,
+	fault.ErrPublicSuffix,
+	fault.ErrInvalidDays,
+	fault.ErrTLDOnly,
+	fault.ErrGetPartsFailed,
+	fault.ErrNotFound,
+	fault.ErrBlocked,
+	fault.ErrGatewayTimeout,
+	fault.ErrBadGateway,
+	fault.ErrUnavailable,
+	fault.ErrInvalidIP,
+	fault.ErrInvalidType,
+	fault.ErrInvalidQuery,
+	fault.ErrQueryTooExpensive,
+	fault.ErrInvalidTime,
+	fault.ErrInvalidFormat,
+	fault.ErrMissingAPIKey,
+	fault.ErrInvalidAPIKey,
+	fault.ErrNotAdmin,
+	fault.ErrInvalidSkip,
+	fault.ErrInvalidLimit,
+	fault.ErrInvalidRegex,
+	fault.ErrRegexTooLong,
+	fault.ErrInvalidBody,
+	fault.ErrSecretEmpty,
+	fault.ErrInvalidURL,
+	fault.ErrInvalidEvent,
+	fault.ErrInvalidCompression,
+	fault.ErrInternal,
+}
+
+// newError returns the Error of a response with code and body.
+// The body is the JSON error ({"error": "...", "code": "..."}) or the text message.
+// The fault is matched by the code of the JSON error, or by the message if the code is missing (eg.: older servers, text body).
+func newError(code int, body []byte) *Error {
+
+	e := &Error{StatusCode: code}
+
+	var ce fault.ColumbusError
+
+	if json.Unmarshal(body, &ce) == nil {
+		e.Message = ce.Err
+	} else {
+		e.Message = strings.TrimSpace(string(body))
+	}
+
+	if ce.Code != "" {
+		for _, f := range faults {
+			if ce.Code == f.Code {
+				e.Fault = f
+				return e
+			}
+		}
+	}
+
+	// The message can have details after the fault (eg.: "invalid query: unexpected ')'")
+	for _, f := range faults {
+		if e.Message == f.Err || strings.HasPrefix(e.Message, f.Err+":") {
+			e.Fault = f
+			return e
+		}
+	}
+
+	switch code {
+	case http.StatusNotFound:
+		e.Fault = fault.ErrNotFound
+	case http.StatusForbidden:
+		e.Fault = fault.ErrBlocked
+	case http.StatusBadGateway:
+		e.Fault = fault.ErrBadGateway
+	case http.StatusServiceUnavailable:
+		e.Fault = fault.ErrUnavailable
+	case http.StatusGatewayTimeout:
+		e.Fault = fault.ErrGatewayTimeout
+	}
+
+	return e
+}
+
+// retryable returns whether a response with code should be retried.
+func retryable(code int) bool {
+	return code == http.StatusTooManyRequests || code >= 500 && code != http.StatusNotImplemented
+}
+
+// retryAfter returns the delay in the Retry-After header of resp in seconds, or def if missing.
+func retryAfter(resp *http.Response, def time.Duration) time.Duration {
+
+	s, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || s < 0 {
+		return def
+	}
+
+	return time.Duration(s) * time.Second
+}
+
+// sleep waits d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// do sends a request with method to path with the query q and returns the body of the successful response.
+// If text is true, requests the "text/plain" format.
+// Retries the request after a 429 or 5xx response or a network error.
+func (c *Client) do(ctx context.Context, method string, path string, q url.Values, text bool) ([]byte, error) {
+
+	u := c.baseURL + path
+	if len(q) > 0 {
+		u += "?" + q.Encode()
+	}
+
+	backoff := c.backoff
+
+	for attempt := 0; ; attempt++ {
+
+		if c.limiter != nil {
+			if err := c.limiter.Wait(ctx); err != nil {
+				return nil, err
+			}
+		}
+
+		req, err := http.NewRequestWithContext(ctx, method, u, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		req.Header.Set("User-Agent", c.userAgent)
+		if c.apiKey != "" {
+			req.Header.Set("X-Api-Key", c.apiKey)
+		}
+		if text {
+			req.Header.Set("Accept", "text/plain")
+		} else {
+			req.Header.Set("Accept", "application/json")
+		}
+
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+
+			if ctx.Err() != nil || attempt >= c.retries {
+				return nil, err
+			}
+
+			if err := sleep(ctx, backoff); err != nil {
+				return nil, err
+			}
+
+			backoff *= 2
+			continue
+		}
+
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read body: %w", err)
+		}
+
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return body, nil
+		}
+
+		if !retryable(resp.StatusCode) || attempt >= c.retries {
+			return nil, newError(resp.StatusCode, body)
+		}
+
+		if err := sleep(ctx, retryAfter(resp, backoff)); err != nil {
+			return nil, err
+		}
+
+		backoff *= 2
+	}
+}
+
+// get sends a GET request to path and decodes the JSON response into v.
+func (c *Client) get(ctx context.Context, path string, q url.Values, v any) error {
+
+	body, err := c.do(ctx, http.MethodGet, path, q, false)
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return nil
+}
+
+// getList sends a GET request to path and returns the list in the response.
+// In text mode the list is one item per line.
+func (c *Client) getList(ctx context.Context, path string, q url.Values) ([]string, error) {
+
+	if !c.text {
+
+		var l []string
+
+		err := c.get(ctx, path, q, &l)
+
+		return l, err
+	}
+
+	body, err := c.do(ctx, http.MethodGet, path, q, true)
+	if err != nil {
+		return nil, err
+	}
+
+	// The API responds 404 to an empty list, an empty body is the apex only (eg.: [""] in Lookup())
+	return strings.Split(string(body), "\n"), nil
+}
+
+// getResult sends a GET request to the tools route path and returns the result.
+func (c *Client) getResult(ctx context.Context, path string) (string, error) {
+
+	if c.text {
+
+		body, err := c.do(ctx, http.MethodGet, path, nil, true)
+
+		return string(body), err
+	}
+
+	var r struct {
+		Result string `json:"result"`
+	}
+
+	err := c.get(ctx, path, nil, &r)
+
+	return r.Result, err
+}
+
+// escape escapes the path parameter p.
+func escape(p string) string {
+	return url.PathEscape(p)
+}
@@ -0,0 +1,203 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/elmasy-com/columbus/fault"
+	"github.com/elmasy-com/columbus/server/router"
+	"github.com/gin-gonic/gin"
+)
+
+func TestMain(m *testing.M) {
+
+	gin.SetMode(gin.TestMode)
+
+	os.Exit(m.Run())
+}
+
+// newServer returns a httptest server with the real router.
+// The routes that query the database are tested with invalid parameters only, the database is not connected.
+func newServer(t *testing.T) *httptest.Server {
+
+	srv := httptest.NewServer(router.New())
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestTools(t *testing.T) {
+
+	srv := newServer(t)
+	ctx := context.Background()
+
+	for _, c := range []*Client{New(srv.URL), New(srv.URL, WithText())} {
+
+		tld, err := c.ToolsTLD(ctx, "www.example.co.uk")
+		if err != nil || tld != "co.uk" {
+			t.Fatalf("ToolsTLD: want co.uk, got %q, %v", tld, err)
+		}
+
+		d, err := c.ToolsDomain(ctx, "www.example.co.uk")
+		if err != nil || d != "example.co.uk" {
+			t.Fatalf("ToolsDomain: want example.co.uk, got %q, %v", d, err)
+		}
+
+		sub, err := c.ToolsSubdomain(ctx, "www.example.co.uk")
+		if err != nil || sub != "www" {
+			t.Fatalf("ToolsSubdomain: want www, got %q, %v", sub, err)
+		}
+
+		valid, err := c.ToolsIsValid(ctx, "www.example.co.uk")
+		if err != nil || !valid {
+			t.Fatalf("ToolsIsValid: want true, got %v, %v", valid, err)
+		}
+
+		valid, err = c.ToolsIsValid(ctx, "inv@lid")
+		if err != nil || valid {
+			t.Fatalf("ToolsIsValid: want false, got %v, %v", valid, err)
+		}
+
+		_, err = c.ToolsTLD(ctx, "inv@lid")
+		if !errors.Is(err, fault.ErrInvalidDomain) {
+			t.Fatalf("ToolsTLD: want ErrInvalidDomain, got %v", err)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+
+	srv := newServer(t)
+	ctx := context.Background()
+
+	for _, c := range []*Client{New(srv.URL), New(srv.URL, WithText())} {
+
+		_, err := c.Lookup(ctx, "example.com", -2)
+		if !errors.Is(err, fault.ErrInvalidDays) {
+			t.Fatalf("Lookup: want ErrInvalidDays, got %v", err)
+		}
+
+		_, err = c.Lookup(ctx, "inv@lid", 0)
+		if !errors.Is(err, fault.ErrInvalidDomain) {
+			t.Fatalf("Lookup: want ErrInvalidDomain, got %v", err)
+		}
+
+		var e *Error
+		if !errors.As(err, &e) || e.StatusCode != http.StatusBadRequest {
+			t.Fatalf("Lookup: want *Error with 400, got %#v", err)
+		}
+
+		_, err = c.Starts(ctx, "abc")
+		if !errors.Is(err, fault.ErrInvalidDomain) {
+			t.Fatalf("Starts: want ErrInvalidDomain, got %v", err)
+		}
+
+		_, err = c.TLD(ctx, "inv@lid")
+		if !errors.Is(err, fault.ErrInvalidDomain) {
+			t.Fatalf("TLD: want ErrInvalidDomain, got %v", err)
+		}
+
+		_, err = c.History(ctx, "example.com", -2)
+		if !errors.Is(err, fault.ErrInvalidDays) {
+			t.Fatalf("History: want ErrInvalidDays, got %v", err)
+		}
+
+		_, err = c.Search(ctx, "(", 0, 0)
+		if !errors.Is(err, fault.ErrInvalidQuery) {
+			t.Fatalf("Search: want ErrInvalidQuery, got %v", err)
+		}
+
+		_, err = c.Diff(ctx, "example.com", "invalid", "")
+		if !errors.Is(err, fault.ErrInvalidTime) {
+			t.Fatalf("Diff: want ErrInvalidTime, got %v", err)
+		}
+
+		_, err = c.Export(ctx, "example.com", "parquet", 0)
+		if !errors.Is(err, fault.ErrInvalidFormat) {
+			t.Fatalf("Export: want ErrInvalidFormat, got %v", err)
+		}
+
+		_, err = c.Reverse(ctx, "10.0.0.0/4", 0, 0, 0)
+		if !errors.Is(err, fault.ErrInvalidIP) {
+			t.Fatalf("Reverse: want ErrInvalidIP, got %v", err)
+		}
+
+		_, err = c.ReverseCNAME(ctx, "inv@lid", 0, 0, 0)
+		if !errors.Is(err, fault.ErrInvalidDomain) {
+			t.Fatalf("ReverseCNAME: want ErrInvalidDomain, got %v", err)
+		}
+	}
+}
+
+func TestRetry(t *testing.T) {
+
+	var n atomic.Int32
+
+	r := router.New()
+
+	// Fail the first two requests
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch n.Add(1) {
+		case 1:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case 2:
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			r.ServeHTTP(w, req)
+		}
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL, WithRetries(2, time.Millisecond))
+
+	tld, err := c.ToolsTLD(context.Background(), "example.com")
+	if err != nil || tld != "com" {
+		t.Fatalf("want com, got %q, %v", tld, err)
+	}
+
+	if n.Load() != 3 {
+		t.Fatalf("want 3 requests, got %d", n.Load())
+	}
+
+	// No retries
+	n.Store(1)
+
+	_, err = New(srv.URL, WithRetries(0, time.Millisecond)).ToolsTLD(context.Background(), "example.com")
+	if !errors.Is(err, fault.ErrBadGateway) {
+		t.Fatalf("want ErrBadGateway, got %v", err)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+
+	srv := newServer(t)
+
+	c := New(srv.URL, WithRateLimit(20, 1))
+
+	start := time.Now()
+
+	for i := 0; i < 3; i++ {
+		if _, err := c.ToolsIsValid(context.Background(), "example.com"); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	// The first request is allowed by the burst, the next two wait 50ms each
+	if d := time.Since(start); d < 90*time.Millisecond {
+		t.Fatalf("rate limiter not applied, 3 requests took %s", d)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := New(srv.URL, WithRateLimit(0.001, 1)).ToolsIsValid(ctx, "example.com"); err == nil {
+		t.Fatalf("expected error with cancelled context")
+	}
+}
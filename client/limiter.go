@@ -0,0 +1,61 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// limiter is a token bucket rate limiter.
+// The bucket holds up to burst tokens and refilled with rps tokens per second.
+type limiter struct {
+	m      sync.Mutex
+	rps    float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newLimiter(rps float64, burst int) *limiter {
+
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &limiter{rps: rps, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// reserve takes a token and returns the time to wait before using it.
+func (l *limiter) reserve() time.Duration {
+
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	now := time.Now()
+
+	l.tokens += now.Sub(l.last).Seconds() * l.rps
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+
+	l.tokens--
+
+	if l.tokens >= 0 {
+		return 0
+	}
+
+	// The token is taken in advance, the bucket is negative until refilled
+	return time.Duration(-l.tokens / l.rps * float64(time.Second))
+}
+
+// Wait blocks until a request is allowed or ctx is done.
+func (l *limiter) Wait(ctx context.Context) error {
+
+	d := l.reserve()
+	if d == 0 {
+		return nil
+	}
+
+	return sleep(ctx, d)
+}
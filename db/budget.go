@@ -0,0 +1,100 @@
+package db
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/elmasy-com/elnet/dns"
+)
+
+// wildcardProbes is the number of queries charged for a dns.IsWildcard() check, it queries up to 5 random names.
+const wildcardProbes = 5
+
+// queryBudget limits the DNS queries sent by this process, nil means unlimited (see SetDNSBudget()).
+var queryBudget atomic.Pointer[dnsBudget]
+
+// dnsBudget is a token bucket to limit the number of DNS queries per second.
+// The bucket can hold one second of queries.
+type dnsBudget struct {
+	m      sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+// newDNSBudget creates a dnsBudget with rate queries per second.
+// If rate is 0 or less, the budget is unlimited.
+func newDNSBudget(rate int) *dnsBudget {
+
+	return &dnsBudget{rate: float64(rate), tokens: float64(rate), last: time.Now()}
+}
+
+// reserve reserves n queries and returns the time to wait before the queries.
+func (b *dnsBudget) reserve(n int) time.Duration {
+
+	if b.rate <= 0 {
+		return 0
+	}
+
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	now := time.Now()
+
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.rate {
+		b.tokens = b.rate
+	}
+	b.last = now
+
+	b.tokens -= float64(n)
+
+	if b.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// wait blocks until n queries are available.
+// Returns false if ctx is done before.
+func (b *dnsBudget) wait(ctx context.Context, n int) bool {
+
+	w := b.reserve(n)
+	if w == 0 {
+		return ctx.Err() == nil
+	}
+
+	return sleepContext(ctx, w)
+}
+
+// SetDNSBudget limits the DNS queries sent by this process (the record updates and the wildcard checks) to rate queries per second.
+// Every query is charged when it is sent. If rate is 0 or less, the queries are unlimited.
+func SetDNSBudget(rate int) {
+	queryBudget.Store(newDNSBudget(rate))
+}
+
+// dnsWait blocks until n queries are available in the budget of the process.
+// Returns false if ctx is done before.
+func dnsWait(ctx context.Context, n int) bool {
+
+	b := queryBudget.Load()
+	if b == nil {
+		return ctx.Err() == nil
+	}
+
+	return b.wait(ctx, n)
+}
+
+// IsWildcard is dns.IsWildcard() with the queries charged to the DNS budget of the process (see SetDNSBudget()).
+// If ctx is done before the check, returns ctx.Err().
+func IsWildcard(ctx context.Context, name string, t uint16) (bool, error) {
+
+	if !dnsWait(ctx, wildcardProbes) {
+		return false, ctx.Err()
+	}
+
+	return dns.IsWildcard(name, t)
+}
@@ -0,0 +1,387 @@
+package db
+
+import (
+	"container/list"
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/elmasy-com/columbus/fault"
+	"github.com/elmasy-com/elnet/dns"
+)
+
+// CacheBackend is the storage of the lookup cache (see DomainsDomainsCached()).
+// The entries are keyed by the apex domain (eg.: "example.com") and the days parameter of DomainsDomains().
+//
+// The default is the in-process MemoryCache, a shared backend (eg.: Redis) can be used to share the cache between the server instances.
+// The implementations must be safe for concurrent use and must not modify the stored slices.
+type CacheBackend interface {
+	Get(apex string, days int) ([]Domain, bool) // Returns the Domains of apex with days, false if not cached or expired
+	Set(apex string, days int, ds []Domain)     // Stores ds for apex with days
+	Invalidate(apex string)                     // Removes every entry of apex
+	Len() int                                   // Returns the number of entries
+}
+
+// CacheStatistic is the metrics of the lookup cache.
+type CacheStatistic struct {
+	Enabled       bool  `json:"enabled"`
+	Entries       int   `json:"entries"`       // Number of cached results
+	Hits          int64 `json:"hits"`          // Results served from the cache
+	Misses        int64 `json:"misses"`        // Results queried from the database
+	Shared        int64 `json:"shared"`        // Results shared with a concurrent identical query
+	Invalidations int64 `json:"invalidations"` // Apexes invalidated by a change
+}
+
+// cacheKey is the key of an in-flight query.
+type cacheKey struct {
+	apex string
+	days int
+}
+
+// cacheCall is an in-flight query, the concurrent identical queries wait for it.
+// stale is set if apex changed during the query, the result must not be cached.
+type cacheCall struct {
+	done  chan struct{}
+	ds    []Domain
+	err   error
+	stale bool
+}
+
+// CacheQueryTimeout is the deadline of a shared query.
+// The query runs without the context of the callers, a cancelled caller must not fail the others waiting for the result.
+var CacheQueryTimeout = 30 * time.Second
+
+var (
+	cacheBackend CacheBackend // The lookup cache is disabled if nil
+
+	cacheMu    sync.Mutex
+	cacheCalls = make(map[cacheKey]*cacheCall)
+
+	cacheHits          atomic.Int64
+	cacheMisses        atomic.Int64
+	cacheShared        atomic.Int64
+	cacheInvalidations atomic.Int64
+)
+
+// CacheSetBackend sets the backend of the lookup cache. If b is nil, the cache is disabled.
+// Must be called before serving the requests.
+func CacheSetBackend(b CacheBackend) {
+	cacheBackend = b
+}
+
+// CacheStatistics returns the metrics of the lookup cache.
+func CacheStatistics() CacheStatistic {
+
+	s := CacheStatistic{
+		Enabled:       cacheBackend != nil,
+		Hits:          cacheHits.Load(),
+		Misses:        cacheMisses.Load(),
+		Shared:        cacheShared.Load(),
+		Invalidations: cacheInvalidations.Load(),
+	}
+
+	if cacheBackend != nil {
+		s.Entries = cacheBackend.Len()
+	}
+
+	return s
+}
+
+// cacheInvalidate removes the cached results of apex and marks the in-flight queries of apex as stale.
+func cacheInvalidate(apex string) {
+
+	if cacheBackend == nil {
+		return
+	}
+
+	cacheMu.Lock()
+	for k, c := range cacheCalls {
+		if k.apex == apex {
+			c.stale = true
+		}
+	}
+	cacheMu.Unlock()
+
+	cacheBackend.Invalidate(apex)
+	cacheInvalidations.Add(1)
+}
+
+// domainsClone returns a copy of ds with copied Records, the caller can sort or modify it.
+func domainsClone(ds []Domain) []Domain {
+
+	if ds == nil {
+		return nil
+	}
+
+	c := make([]Domain, len(ds))
+	copy(c, ds)
+
+	for i := range c {
+		if c[i].Records != nil {
+			c[i].Records = append([]Record(nil), c[i].Records...)
+		}
+	}
+
+	return c
+}
+
+// DomainsDomainsCached is DomainsDomains() with the lookup cache.
+// The result is cached for the apex of d and days, and invalidated if a FQDN or record of the apex is inserted, removed or refreshed (see CacheInvalidateWorker()).
+// The concurrent identical queries are de-duplicated, only one of them queries the database.
+// The shared query is not cancelled with ctx (see CacheQueryTimeout), ctx stops only the waiting of this call.
+//
+// Returns true if the result is not queried by this call (a cache hit or shared with a concurrent query).
+// The returned slice is a copy, the caller can modify it.
+// If the cache is disabled, returns the result of DomainsDomains().
+func DomainsDomainsCached(ctx context.Context, d string, days int) ([]Domain, bool, error) {
+
+	if cacheBackend == nil {
+		ds, err := DomainsDomains(ctx, d, days)
+		return ds, false, err
+	}
+
+	if !dns.IsValid(d) {
+		return nil, false, fault.ErrInvalidDomain
+	}
+
+	p := dns.GetParts(dns.Clean(d))
+	if p == nil || p.TLD == "" {
+		return nil, false, fault.ErrGetPartsFailed
+	}
+	if p.Domain == "" {
+		return nil, false, fault.ErrTLDOnly
+	}
+	if days < -1 {
+		return nil, false, fault.ErrInvalidDays
+	}
+
+	k := cacheKey{apex: p.Domain + "." + p.TLD, days: days}
+
+	if ds, ok := cacheBackend.Get(k.apex, k.days); ok {
+		cacheHits.Add(1)
+		return domainsClone(ds), true, nil
+	}
+
+	cacheMu.Lock()
+
+	if c, ok := cacheCalls[k]; ok {
+
+		cacheMu.Unlock()
+		cacheShared.Add(1)
+
+		return cacheWait(ctx, c, true)
+	}
+
+	c := &cacheCall{done: make(chan struct{})}
+	cacheCalls[k] = c
+
+	cacheMu.Unlock()
+	cacheMisses.Add(1)
+
+	go cacheQuery(context.WithoutCancel(ctx), k, c)
+
+	return cacheWait(ctx, c, false)
+}
+
+// cacheWait waits for the result of c or until ctx is done.
+// shared is returned as the second value.
+func cacheWait(ctx context.Context, c *cacheCall, shared bool) ([]Domain, bool, error) {
+
+	select {
+	case <-c.done:
+	case <-ctx.Done():
+		return nil, false, ctx.Err()
+	}
+
+	if c.err != nil {
+		return nil, false, c.err
+	}
+
+	return domainsClone(c.ds), shared, nil
+}
+
+// cacheQuery queries the Domains of k with CacheQueryTimeout, stores the result in the cache and closes c.done.
+func cacheQuery(ctx context.Context, k cacheKey, c *cacheCall) {
+
+	ctx, cancel := context.WithTimeout(ctx, CacheQueryTimeout)
+	defer cancel()
+
+	c.ds, c.err = DomainsDomains(ctx, k.apex, k.days)
+
+	if c.err == nil {
+		cacheBackend.Set(k.apex, k.days, c.ds)
+	}
+
+	cacheMu.Lock()
+	delete(cacheCalls, k)
+	stale := c.stale
+	cacheMu.Unlock()
+
+	// The apex changed while querying, the stored result can be outdated
+	if stale {
+		cacheBackend.Invalidate(k.apex)
+	}
+
+	close(c.done)
+}
+
+// CacheInvalidateWorker invalidates the cached results of the apexes changed by any process (the server, the scanner or the DNS server).
+// The changes are read from the "events" collection, it is the only path of the invalidation (including the changes of this process).
+// The cached results can be stale until the event is read (about a second).
+//
+// Returns immediately if the cache is disabled. This function blocks until ctx is done.
+func CacheInvalidateWorker(ctx context.Context) {
+
+	if cacheBackend == nil {
+		return
+	}
+
+	last, err := EventsLastSeq(ctx)
+	for err != nil {
+
+		slog.ErrorContext(ctx, "cache: failed to get the last event", "error", err)
+
+		if !sleepContext(ctx, 10*time.Second) {
+			return
+		}
+
+		last, err = EventsLastSeq(ctx)
+	}
+
+	r := &EventReader{Last: last}
+
+	for {
+
+		es, err := r.Next(ctx, 1000)
+		if err != nil {
+
+			if ctx.Err() != nil {
+				return
+			}
+
+			slog.ErrorContext(ctx, "cache: failed to get events", "error", err)
+
+			if !sleepContext(ctx, 10*time.Second) {
+				return
+			}
+
+			continue
+		}
+
+		for i := range es {
+			cacheInvalidate(es[i].Apex)
+		}
+
+		if len(es) == 0 && !sleepContext(ctx, time.Second) {
+			return
+		}
+	}
+}
+
+// MemoryCache is an in-process CacheBackend with LRU eviction and expiration.
+type MemoryCache struct {
+	m     sync.Mutex
+	size  int
+	ttl   time.Duration
+	lru   *list.List // The front is the most recently used entry
+	index map[string]map[int]*list.Element
+}
+
+type memoryCacheEntry struct {
+	apex    string
+	days    int
+	ds      []Domain
+	expires time.Time
+}
+
+// NewMemoryCache creates a new MemoryCache that holds up to size results for ttl.
+func NewMemoryCache(size int, ttl time.Duration) *MemoryCache {
+
+	return &MemoryCache{
+		size:  size,
+		ttl:   ttl,
+		lru:   list.New(),
+		index: make(map[string]map[int]*list.Element),
+	}
+}
+
+// remove removes e from the cache. The caller must hold the lock.
+func (c *MemoryCache) remove(e *list.Element) {
+
+	en := e.Value.(*memoryCacheEntry)
+
+	c.lru.Remove(e)
+
+	delete(c.index[en.apex], en.days)
+	if len(c.index[en.apex]) == 0 {
+		delete(c.index, en.apex)
+	}
+}
+
+func (c *MemoryCache) Get(apex string, days int) ([]Domain, bool) {
+
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	e, ok := c.index[apex][days]
+	if !ok {
+		return nil, false
+	}
+
+	en := e.Value.(*memoryCacheEntry)
+
+	if time.Now().After(en.expires) {
+		c.remove(e)
+		return nil, false
+	}
+
+	c.lru.MoveToFront(e)
+
+	return en.ds, true
+}
+
+func (c *MemoryCache) Set(apex string, days int, ds []Domain) {
+
+	if c.size <= 0 {
+		return
+	}
+
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	if e, ok := c.index[apex][days]; ok {
+		c.remove(e)
+	}
+
+	for c.lru.Len() >= c.size {
+		c.remove(c.lru.Back())
+	}
+
+	e := c.lru.PushFront(&memoryCacheEntry{apex: apex, days: days, ds: ds, expires: time.Now().Add(c.ttl)})
+
+	if c.index[apex] == nil {
+		c.index[apex] = make(map[int]*list.Element)
+	}
+
+	c.index[apex][days] = e
+}
+
+func (c *MemoryCache) Invalidate(apex string) {
+
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	for _, e := range c.index[apex] {
+		c.remove(e)
+	}
+}
+
+func (c *MemoryCache) Len() int {
+
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	return c.lru.Len()
+}
@@ -0,0 +1,87 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryCache(t *testing.T) {
+
+	c := NewMemoryCache(2, time.Hour)
+
+	c.Set("example.com", -1, []Domain{{Sub: "www"}})
+	c.Set("example.com", 0, []Domain{{Sub: "api"}})
+
+	if ds, ok := c.Get("example.com", -1); !ok || len(ds) != 1 || ds[0].Sub != "www" {
+		t.Fatalf("unexpected result: %v, %v", ds, ok)
+	}
+
+	// example.com/0 is the least recently used
+	c.Set("example.org", -1, nil)
+
+	if _, ok := c.Get("example.com", 0); ok {
+		t.Fatalf("least recently used entry is not evicted")
+	}
+
+	if c.Len() != 2 {
+		t.Fatalf("want 2 entries, got %d", c.Len())
+	}
+
+	c.Invalidate("example.com")
+
+	if _, ok := c.Get("example.com", -1); ok {
+		t.Fatalf("invalidated entry is returned")
+	}
+
+	if _, ok := c.Get("example.org", -1); !ok {
+		t.Fatalf("entry of other apex is invalidated")
+	}
+
+	c = NewMemoryCache(10, -time.Second)
+	c.Set("example.com", -1, nil)
+
+	if _, ok := c.Get("example.com", -1); ok || c.Len() != 0 {
+		t.Fatalf("expired entry is returned")
+	}
+}
+
+func TestCacheInvalidate(t *testing.T) {
+
+	CacheSetBackend(NewMemoryCache(10, time.Hour))
+	defer CacheSetBackend(nil)
+
+	cacheBackend.Set("example.com", -1, nil)
+
+	c := &cacheCall{done: make(chan struct{})}
+	cacheCalls[cacheKey{apex: "example.com", days: 0}] = c
+	defer delete(cacheCalls, cacheKey{apex: "example.com", days: 0})
+
+	cacheInvalidate("example.org")
+
+	if c.stale {
+		t.Fatalf("in-flight query of other apex is stale")
+	}
+
+	cacheInvalidate("example.com")
+
+	if !c.stale {
+		t.Fatalf("in-flight query is not stale")
+	}
+
+	if cacheBackend.Len() != 0 {
+		t.Fatalf("entry is not invalidated")
+	}
+}
+
+func TestDomainsClone(t *testing.T) {
+
+	ds := []Domain{{Sub: "www", Records: []Record{{Type: 1, Value: "192.0.2.2"}, {Type: 1, Value: "192.0.2.1"}}}}
+
+	c := domainsClone(ds)
+	c[0].Sub = "api"
+	c[0].Records[0].Value = "192.0.2.3"
+
+	if ds[0].Sub != "www" || ds[0].Records[0].Value != "192.0.2.2" {
+		t.Fatalf("original is modified: %v", ds)
+	}
+}
@@ -11,38 +11,72 @@ import (
 var (
 	Client *mongo.Client
 
-	Domains    *mongo.Collection // The main collection to store the entries
-	NotFound   *mongo.Collection // Store domains that not found by Lookup
-	TopList    *mongo.Collection // Store and count successful lookups
-	CTLogs     *mongo.Collection // Store informations about CT Logs
-	Statistics *mongo.Collection // Store statistics history
+	Domains       *mongo.Collection // The main collection to store the entries
+	NotFound      *mongo.Collection // Store domains that not found by Lookup
+	TopList       *mongo.Collection // Store and count successful lookups
+	CTLogs        *mongo.Collection // Store informations about CT Logs
+	Statistics    *mongo.Collection // Store statistics history
+	Queue         *mongo.Collection // Store the durable queue of the records updater
+	DeadLetter    *mongo.Collection // Store the failed items of the durable queue
+	Events        *mongo.Collection // Store the change events (new FQDN, new/removed record)
+	Subscriptions *mongo.Collection // Store the webhook subscriptions
+	Deliveries    *mongo.Collection // Store the webhook delivery logs
+	Migrations    *mongo.Collection // Store the applied schema migrations
+	Counters      *mongo.Collection // Store the sequence of the events and the cursors of the event readers
 )
 
 // Connect connects to the database using the standard Connection URI.
-func Connect(uri string) error {
+func Connect(ctx context.Context, uri string) error {
 
 	var err error
 
-	Client, err = mongo.Connect(context.TODO(), options.Client().ApplyURI(uri))
+	Client, err = mongo.Connect(ctx, options.Client().ApplyURI(uri))
 	if err != nil {
 		return fmt.Errorf("connect: %w", err)
 	}
 
-	err = Client.Ping(context.Background(), nil)
+	err = Client.Ping(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("ping: %w", err)
 	}
 
-	Domains = Client.Database("columbus").Collection("domains")
-	NotFound = Client.Database("columbus").Collection("notFound")
-	TopList = Client.Database("columbus").Collection("topList")
-	CTLogs = Client.Database("columbus").Collection("ctlogs")
-	Statistics = Client.Database("columbus").Collection("statistics")
+	Use("columbus")
 
 	return nil
 }
 
+// Use sets the collections to the collections of database name of Client.
+// Connect() uses the "columbus" database, the tests can use a different one.
+func Use(name string) {
+
+	d := Client.Database(name)
+
+	Domains = d.Collection("domains")
+	NotFound = d.Collection("notFound")
+	TopList = d.Collection("topList")
+	CTLogs = d.Collection("ctlogs")
+	Statistics = d.Collection("statistics")
+	Queue = d.Collection("updateQueue")
+	DeadLetter = d.Collection("updateDeadLetter")
+	Events = d.Collection("events")
+	Subscriptions = d.Collection("subscriptions")
+	Deliveries = d.Collection("deliveries")
+	Migrations = d.Collection("migrations")
+	Counters = d.Collection("counters")
+}
+
+// Ping checks the connection to the database.
+// Returns an error if not connected.
+func Ping(ctx context.Context) error {
+
+	if Client == nil {
+		return fmt.Errorf("not connected")
+	}
+
+	return Client.Ping(ctx, nil)
+}
+
 // Disconnect gracefully disconnect from the database.
-func Disconnect() error {
-	return Client.Disconnect(context.Background())
+func Disconnect(ctx context.Context) error {
+	return Client.Disconnect(ctx)
 }
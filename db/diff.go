@@ -0,0 +1,196 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/elmasy-com/columbus/fault"
+	"github.com/elmasy-com/elnet/dns"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// DiffStatus is the change of a FQDN in a time window.
+type DiffStatus string
+
+const (
+	DiffAdded   DiffStatus = "added"   // The FQDN inserted in the window or every record of the FQDN first seen in the window
+	DiffRemoved DiffStatus = "removed" // Every record of the FQDN removed, the last one in the window
+	DiffChanged DiffStatus = "changed" // Some records added or removed in the window
+)
+
+// DiffSchema is a changed FQDN returned by DomainsDiff().
+// Added is the records first seen or restored in the window, Removed is the records removed in the window.
+type DiffSchema struct {
+	Domain  string     `json:"domain"`
+	Status  DiffStatus `json:"status"`
+	Added   []Record   `json:"added,omitempty"`
+	Removed []Record   `json:"removed,omitempty"`
+}
+
+// diffDomain returns the changes of d between since and until (inclusive).
+// A FQDN inserted in the window is added, even without records.
+// Returns false if d is not changed in the window.
+func diffDomain(d *Domain, since int64, until int64) (DiffSchema, bool) {
+
+	var (
+		diff        = DiffSchema{Domain: d.String()}
+		created     = d.Created >= since && d.Created <= until
+		existBefore = d.Created != 0 && d.Created < since // The FQDN or a record found before since
+		allRemoved  = len(d.Records) > 0
+	)
+
+	for i := range d.Records {
+
+		if d.Records[i].First() < since {
+			existBefore = true
+		}
+
+		// A record removed and restored in the window is in both lists
+		if d.Records[i].AddedIn(since, until) {
+			diff.Added = append(diff.Added, d.Records[i])
+		}
+
+		if d.Records[i].RemovedIn(since, until) {
+			diff.Removed = append(diff.Removed, d.Records[i])
+		}
+
+		if r := d.Records[i].Removed; r == 0 || r > until {
+			allRemoved = false
+		}
+	}
+
+	switch {
+	case created:
+		diff.Status = DiffAdded
+	case len(diff.Added) == 0 && len(diff.Removed) == 0:
+		return diff, false
+	case len(diff.Added) > 0 && !existBefore:
+		diff.Status = DiffAdded
+	case allRemoved && len(diff.Removed) > 0 && len(diff.Added) == 0:
+		diff.Status = DiffRemoved
+	default:
+		diff.Status = DiffChanged
+	}
+
+	return diff, true
+}
+
+// DomainsDiff returns the FQDNs of domain d that changed between since and until (Unix timestamps, inclusive).
+// The changes are based on the "created" field of the FQDN and the "firstSeen", "removed" and "removals" fields of the records.
+// The result is sorted by domain.
+//
+// If d has a subdomain, removes it before the query.
+//
+// If d is invalid return fault.ErrInvalidDomain.
+// If failed to get parts of d because of d is just a TLD, returns fault.ErrTLDOnly.
+// If failed to get parts of d, returns fault.ErrGetPartsFailed.
+// If until is before since, returns fault.ErrInvalidTime.
+func DomainsDiff(ctx context.Context, d string, since int64, until int64) ([]DiffSchema, error) {
+
+	if !dns.IsValid(d) {
+		return nil, fault.ErrInvalidDomain
+	}
+
+	if until < since {
+		return nil, fault.ErrInvalidTime
+	}
+
+	p := dns.GetParts(dns.Clean(d))
+	if p == nil || p.TLD == "" {
+		return nil, fault.ErrGetPartsFailed
+	}
+	if p.Domain == "" {
+		return nil, fault.ErrTLDOnly
+	}
+
+	window := bson.M{"$gte": since, "$lte": until}
+
+	filter := bson.M{"domain": p.Domain, "tld": p.TLD, "$or": bson.A{
+		bson.M{"created": window},
+		bson.M{"records": bson.M{"$elemMatch": bson.M{"$or": bson.A{
+			bson.M{"firstSeen": window},
+			bson.M{"firstSeen": bson.M{"$exists": false}, "time": window},
+			bson.M{"removed": window},
+			bson.M{"removals": bson.M{"$elemMatch": bson.M{"$or": bson.A{bson.M{"removed": window}, bson.M{"restored": window}}}}},
+		}}}},
+	}}
+
+	cursor, err := Domains.Find(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	diffs := make([]DiffSchema, 0)
+
+	for cursor.Next(ctx) {
+
+		dom := new(Domain)
+
+		err = cursor.Decode(dom)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode: %w", err)
+		}
+
+		if diff, ok := diffDomain(dom, since, until); ok {
+			diffs = append(diffs, diff)
+		}
+	}
+
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("cursor failed: %w", err)
+	}
+
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].Domain < diffs[j].Domain })
+
+	return diffs, nil
+}
+
+// DiffWindow parses the since and until times of a diff (see ParseTime()).
+// If since is empty, it is 7 days before now. If until is empty, it is now.
+func DiffWindow(since string, until string) (int64, int64, error) {
+
+	var (
+		s   = time.Now().AddDate(0, 0, -7).Unix()
+		u   = time.Now().Unix()
+		err error
+	)
+
+	if since != "" {
+		s, err = ParseTime(since)
+		if err != nil {
+			return 0, 0, fault.ErrInvalidTime
+		}
+	}
+
+	if until != "" {
+		u, err = ParseTime(until)
+		if err != nil {
+			return 0, 0, fault.ErrInvalidTime
+		}
+	}
+
+	if u < s {
+		return 0, 0, fault.ErrInvalidTime
+	}
+
+	return s, u, nil
+}
+
+// migrateDomainsCreated sets the missing "created" field of the domains.
+// The time of the insert is not stored, so it is the earliest of the ObjectID time and the first seen times of the records.
+func migrateDomainsCreated(ctx context.Context) error {
+
+	update := mongo.Pipeline{{{Key: "$set", Value: bson.M{"created": bson.M{"$min": bson.A{
+		bson.M{"$toLong": bson.M{"$divide": bson.A{bson.M{"$toLong": bson.M{"$toDate": "$_id"}}, 1000}}},
+		bson.M{"$min": "$records.firstSeen"},
+		bson.M{"$min": "$records.time"},
+	}}}}}}
+
+	_, err := Domains.UpdateMany(ctx, bson.M{"created": bson.M{"$exists": false}}, update)
+
+	return err
+}
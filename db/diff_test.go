@@ -0,0 +1,69 @@
+package db
+
+import (
+	"testing"
+
+	mdns "github.com/miekg/dns"
+)
+
+func TestDiffDomain(t *testing.T) {
+
+	const (
+		since = 1000
+		until = 2000
+	)
+
+	cases := []struct {
+		name    string
+		created int64
+		records []Record
+		want    DiffStatus
+		added   int
+		removed int
+	}{
+		{"new", 0, []Record{{Type: mdns.TypeA, Value: "203.0.113.1", FirstSeen: 1500, Time: 1900}}, DiffAdded, 1, 0},
+		{"gone", 0, []Record{{Type: mdns.TypeA, Value: "203.0.113.1", FirstSeen: 500, Time: 1200, Removed: 1300}}, DiffRemoved, 0, 1},
+		{"swap", 0, []Record{
+			{Type: mdns.TypeA, Value: "203.0.113.1", FirstSeen: 500, Time: 1200, Removed: 1300},
+			{Type: mdns.TypeA, Value: "203.0.113.2", FirstSeen: 1300, Time: 1900},
+		}, DiffChanged, 1, 1},
+		{"partial", 0, []Record{
+			{Type: mdns.TypeA, Value: "203.0.113.1", FirstSeen: 500, Time: 1900},
+			{Type: mdns.TypeAAAA, Value: "2001:db8::1", FirstSeen: 500, Time: 1200, Removed: 1300},
+		}, DiffChanged, 0, 1},
+		{"legacy", 0, []Record{{Type: mdns.TypeA, Value: "203.0.113.1", Time: 1500}}, DiffAdded, 1, 0},
+		{"flap", 0, []Record{{Type: mdns.TypeA, Value: "203.0.113.1", FirstSeen: 500, Time: 1900, Removals: []RecordRemoval{{Removed: 1200, Restored: 1300}}}}, DiffChanged, 1, 1},
+		{"restored", 0, []Record{{Type: mdns.TypeA, Value: "203.0.113.1", FirstSeen: 500, Time: 1900, Removals: []RecordRemoval{{Removed: 800, Restored: 1300}}}}, DiffChanged, 1, 0},
+		{"norecord", 1500, nil, DiffAdded, 0, 0},
+		{"known", 500, []Record{{Type: mdns.TypeA, Value: "203.0.113.1", FirstSeen: 1500, Time: 1900}}, DiffChanged, 1, 0},
+		{"created", 1200, []Record{{Type: mdns.TypeA, Value: "203.0.113.1", FirstSeen: 1500, Time: 1900}}, DiffAdded, 1, 0},
+	}
+
+	for i := range cases {
+
+		d := &Domain{Domain: "example", TLD: "com", Sub: cases[i].name, Created: cases[i].created, Records: cases[i].records}
+
+		diff, ok := diffDomain(d, since, until)
+		if !ok {
+			t.Fatalf("FAIL: %s: not changed\n", cases[i].name)
+		}
+
+		if diff.Status != cases[i].want || len(diff.Added) != cases[i].added || len(diff.Removed) != cases[i].removed {
+			t.Fatalf("FAIL: %s: want %s +%d -%d, got %s +%d -%d\n", cases[i].name, cases[i].want, cases[i].added, cases[i].removed, diff.Status, len(diff.Added), len(diff.Removed))
+		}
+	}
+
+	unchanged := []*Domain{
+		{Domain: "example", TLD: "com", Sub: "old", Records: []Record{{Type: mdns.TypeA, Value: "203.0.113.1", FirstSeen: 500, Time: 1900}}},
+		{Domain: "example", TLD: "com", Sub: "later", Records: []Record{{Type: mdns.TypeA, Value: "203.0.113.1", FirstSeen: 2500, Time: 2600}}},
+		{Domain: "example", TLD: "com", Sub: "empty"},
+		{Domain: "example", TLD: "com", Sub: "oldempty", Created: 500},
+		{Domain: "example", TLD: "com", Sub: "oldflap", Records: []Record{{Type: mdns.TypeA, Value: "203.0.113.1", FirstSeen: 100, Time: 1900, Removals: []RecordRemoval{{Removed: 200, Restored: 300}}}}},
+	}
+
+	for i := range unchanged {
+		if diff, ok := diffDomain(unchanged[i], since, until); ok {
+			t.Fatalf("FAIL: %s: changed: %#v\n", unchanged[i].Sub, diff)
+		}
+	}
+}
@@ -0,0 +1,310 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/elmasy-com/elnet/dns"
+	mdns "github.com/miekg/dns"
+)
+
+// RecordTypes is the list of record types queried by RecordsUpdate().
+var RecordTypes = []uint16{
+	mdns.TypeA,
+	mdns.TypeAAAA,
+	mdns.TypeCAA,
+	mdns.TypeCNAME,
+	mdns.TypeDNAME,
+	mdns.TypeMX,
+	mdns.TypeNS,
+	mdns.TypeSOA,
+	mdns.TypeSRV,
+	mdns.TypeTXT,
+	mdns.TypeHTTPS,
+	mdns.TypeSVCB,
+	mdns.TypeDS,
+	mdns.TypeDNSKEY,
+	mdns.TypeNAPTR,
+	mdns.TypeSSHFP,
+}
+
+// ErrDNSFailure is returned when the DNS servers failed to answer (eg.: SERVFAIL, timeout).
+// The update can be retried later.
+var ErrDNSFailure = errors.New("DNS failure")
+
+// TLSAPrefix is prepended to the domain when querying the TLSA record in RecordsUpdate().
+const TLSAPrefix = "_443._tcp."
+
+// RecordTypeString returns the string representation of type t (eg.: "A", "HTTPS").
+// If t is unknown, returns "TYPE" + t (eg.: "TYPE65280").
+func RecordTypeString(t uint16) string {
+
+	if s, ok := mdns.TypeToString[t]; ok {
+		return s
+	}
+
+	return "TYPE" + strconv.Itoa(int(t))
+}
+
+// rdata returns the presentation format of rr without the header (eg.: "1 . alpn=h2").
+func rdata(rr mdns.RR) string {
+
+	return strings.TrimPrefix(rr.String(), rr.Header().String())
+}
+
+// RecordsFromRR converts rr to a list of Record.
+// The Time field is not set.
+//
+// The Value of the types known by the previous versions (A, AAAA, CAA, CNAME, DNAME, MX, NS, SOA, SRV and TXT)
+// uses the same format as before to not duplicate the records already in the database.
+// The other types uses the presentation format of the RDATA.
+//
+// Returns nil if rr is not supported (eg.: PTR).
+func RecordsFromRR(rr mdns.RR) []Record {
+
+	switch v := rr.(type) {
+	case *mdns.A:
+		return []Record{{Type: mdns.TypeA, Value: v.A.String()}}
+	case *mdns.AAAA:
+		return []Record{{Type: mdns.TypeAAAA, Value: v.AAAA.String()}}
+	case *mdns.CAA:
+		return []Record{{
+			Type:   mdns.TypeCAA,
+			Value:  fmt.Sprintf("%d %s %s", v.Flag, v.Tag, v.Value),
+			Params: map[string]string{"flag": strconv.Itoa(int(v.Flag)), "tag": v.Tag, "value": v.Value},
+		}}
+	case *mdns.CNAME:
+		return []Record{{Type: mdns.TypeCNAME, Value: v.Target, Target: v.Target}}
+	case *mdns.DNAME:
+		return []Record{{Type: mdns.TypeDNAME, Value: v.Target, Target: v.Target}}
+	case *mdns.MX:
+		return []Record{{Type: mdns.TypeMX, Value: fmt.Sprintf("%d %s", v.Preference, v.Mx), Priority: v.Preference, Target: v.Mx}}
+	case *mdns.NS:
+		return []Record{{Type: mdns.TypeNS, Value: v.Ns, Target: v.Ns}}
+	case *mdns.SOA:
+		return []Record{{Type: mdns.TypeSOA, Value: fmt.Sprintf("%s %s %d %d %d %d %d", v.Ns, v.Mbox, v.Serial, v.Refresh, v.Retry, v.Expire, v.Minttl), Target: v.Ns}}
+	case *mdns.SRV:
+		return []Record{{
+			Type:     mdns.TypeSRV,
+			Value:    fmt.Sprintf("%d %d %d %s", v.Priority, v.Weight, v.Port, v.Target),
+			Priority: v.Priority,
+			Target:   v.Target,
+			Params:   map[string]string{"weight": strconv.Itoa(int(v.Weight)), "port": strconv.Itoa(int(v.Port))},
+		}}
+	case *mdns.TXT:
+		rs := make([]Record, 0, len(v.Txt))
+		for i := range v.Txt {
+			rs = append(rs, Record{Type: mdns.TypeTXT, Value: v.Txt[i]})
+		}
+		return rs
+	case *mdns.HTTPS:
+		return []Record{svcbRecord(mdns.TypeHTTPS, rdata(v), &v.SVCB)}
+	case *mdns.SVCB:
+		return []Record{svcbRecord(mdns.TypeSVCB, rdata(v), v)}
+	case *mdns.TLSA:
+		return []Record{{
+			Type:  mdns.TypeTLSA,
+			Value: rdata(v),
+			Params: map[string]string{
+				"usage":        strconv.Itoa(int(v.Usage)),
+				"selector":     strconv.Itoa(int(v.Selector)),
+				"matchingType": strconv.Itoa(int(v.MatchingType)),
+				"certificate":  v.Certificate,
+			},
+		}}
+	case *mdns.DS:
+		return []Record{{
+			Type:  mdns.TypeDS,
+			Value: rdata(v),
+			Params: map[string]string{
+				"keyTag":     strconv.Itoa(int(v.KeyTag)),
+				"algorithm":  strconv.Itoa(int(v.Algorithm)),
+				"digestType": strconv.Itoa(int(v.DigestType)),
+				"digest":     v.Digest,
+			},
+		}}
+	case *mdns.DNSKEY:
+		return []Record{{
+			Type:  mdns.TypeDNSKEY,
+			Value: rdata(v),
+			Params: map[string]string{
+				"flags":     strconv.Itoa(int(v.Flags)),
+				"protocol":  strconv.Itoa(int(v.Protocol)),
+				"algorithm": strconv.Itoa(int(v.Algorithm)),
+				"publicKey": v.PublicKey,
+			},
+		}}
+	case *mdns.NAPTR:
+		return []Record{{
+			Type:     mdns.TypeNAPTR,
+			Value:    rdata(v),
+			Priority: v.Order,
+			Target:   v.Replacement,
+			Params: map[string]string{
+				"preference": strconv.Itoa(int(v.Preference)),
+				"flags":      v.Flags,
+				"service":    v.Service,
+				"regexp":     v.Regexp,
+			},
+		}}
+	case *mdns.SSHFP:
+		return []Record{{
+			Type:  mdns.TypeSSHFP,
+			Value: rdata(v),
+			Params: map[string]string{
+				"algorithm":   strconv.Itoa(int(v.Algorithm)),
+				"type":        strconv.Itoa(int(v.Type)),
+				"fingerprint": v.FingerPrint,
+			},
+		}}
+	default:
+		return nil
+	}
+}
+
+// svcbRecord creates a Record from the SVCB/HTTPS record v.
+// The SvcParams are stored in Params with the key name (eg.: "alpn": "h2,h3").
+func svcbRecord(t uint16, value string, v *mdns.SVCB) Record {
+
+	r := Record{Type: t, Value: value, Priority: v.Priority, Target: v.Target}
+
+	if len(v.Value) > 0 {
+
+		r.Params = make(map[string]string, len(v.Value))
+
+		for i := range v.Value {
+			r.Params[v.Value[i].Key().String()] = v.Value[i].String()
+		}
+	}
+
+	return r
+}
+
+// errWildcard is returned by queryType() if the name is a wildcard for the type or failed to check it.
+var errWildcard = errors.New("wildcard")
+
+// queryResult is the result of a DNS query used in queryContext().
+type queryResult struct {
+	rr  []mdns.RR
+	err error
+}
+
+// queryContext queries the type t for name with the dns.DefaultServers.
+// The query is charged to the DNS budget of the process, waits for the budget before the query.
+// Returns ctx.Err() if ctx is done before the answer.
+// The query is not cancelled, it finishes in the background (limited by the timeout of the servers).
+func queryContext(ctx context.Context, name string, t uint16) ([]mdns.RR, error) {
+
+	if !dnsWait(ctx, 1) {
+		return nil, ctx.Err()
+	}
+
+	c := make(chan queryResult, 1)
+
+	go func() {
+		rr, err := dns.DefaultServers.TryQuery(name, t)
+		c <- queryResult{rr: rr, err: err}
+	}()
+
+	select {
+	case r := <-c:
+		return r.rr, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// queryType queries the type t for name with the dns.DefaultServers.
+// If name is a wildcard for type t (or failed to check it), returns errWildcard.
+func queryType(ctx context.Context, name string, t uint16) ([]Record, error) {
+
+	rr, err := queryContext(ctx, name, t)
+	if err != nil || len(rr) == 0 {
+		return nil, err
+	}
+
+	wc, err := IsWildcard(ctx, name, t)
+	if err != nil || wc {
+		// Ignore error and assume that name is a wildcard
+		return nil, errWildcard
+	}
+
+	rs := make([]Record, 0, len(rr))
+
+	for i := range rr {
+		for _, r := range RecordsFromRR(rr[i]) {
+			if !recordsContains(rs, r.Type, r.Value) {
+				rs = append(rs, r)
+			}
+		}
+	}
+
+	return rs, nil
+}
+
+// queryRecords queries every type in RecordTypes and the TLSA record for the HTTPS port (TLSAPrefix + d) of d.
+// The TLSA record's Target is the name of the queried TLSA record.
+//
+// Returns the records and the list of types that answered successfully (the records of the other types are unknown).
+// Failed queries and wildcards are skipped, but if every query failed, returns the last error.
+// If the domain does not exist, returns dns.ErrName and every type.
+// If ctx is done, returns ctx.Err().
+func queryRecords(ctx context.Context, d string) ([]Record, []uint16, error) {
+
+	var (
+		rs      []Record
+		types   []uint16
+		lastErr error
+		failed  int
+	)
+
+	for _, t := range RecordTypes {
+
+		r, err := queryType(ctx, d, t)
+		if err != nil {
+
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, nil, ctxErr
+			}
+
+			if errors.Is(err, dns.ErrName) {
+				return nil, append(RecordTypes[:len(RecordTypes):len(RecordTypes)], mdns.TypeTLSA), err
+			}
+
+			if !errors.Is(err, errWildcard) {
+				lastErr = err
+				failed++
+			}
+
+			continue
+		}
+
+		rs = append(rs, r...)
+		types = append(types, t)
+	}
+
+	if failed == len(RecordTypes) {
+		return nil, nil, lastErr
+	}
+
+	// Underscored names are service names, dont query the TLSA of them
+	if !strings.HasPrefix(d, "_") {
+
+		tlsa, err := queryType(ctx, TLSAPrefix+d, mdns.TypeTLSA)
+		if ctx.Err() != nil {
+			return nil, nil, ctx.Err()
+		}
+		if err == nil || errors.Is(err, dns.ErrName) {
+			for i := range tlsa {
+				tlsa[i].Target = TLSAPrefix + d
+				rs = append(rs, tlsa[i])
+			}
+			types = append(types, mdns.TypeTLSA)
+		}
+	}
+
+	return rs, types, nil
+}
@@ -4,7 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"os"
+	"regexp"
 	"strings"
 	"time"
 
@@ -37,11 +37,13 @@ func (d *FastDomain) String() string {
 
 // Domains is the schema used in the "domains" collection.
 type Domain struct {
-	Domain  string   `bson:"domain" json:"domain"`
-	TLD     string   `bson:"tld" json:"tld"`
-	Sub     string   `bson:"sub" json:"sub"`
-	Updated int64    `bson:"updated" json:"updated"`
-	Records []Record `bson:"records,omitempty" json:"records,omitempty"`
+	Domain     string   `bson:"domain" json:"domain"`
+	TLD        string   `bson:"tld" json:"tld"`
+	Sub        string   `bson:"sub" json:"sub"`
+	Updated    int64    `bson:"updated" json:"updated"`
+	Created    int64    `bson:"created,omitempty" json:"-"`    // Time of the insert, set by migration for the older documents
+	NextUpdate int64    `bson:"nextUpdate,omitempty" json:"-"` // Time of the next scheduled refresh
+	Records    []Record `bson:"records,omitempty" json:"records,omitempty"`
 }
 
 // Returns the full hostname (eg.: sub.domain.tld).
@@ -59,55 +61,163 @@ func (d *Domain) FullDomain() string {
 	return strings.Join([]string{d.Domain, d.TLD}, ".")
 }
 
+// DomainsModified returns the time of the last modification of ds in Unix timestamp.
+// The time is the latest of the "updated" and "created" fields and the last seen and removed times of the records.
+// Returns 0 if ds is empty.
+func DomainsModified(ds []Domain) int64 {
+
+	var t int64
+
+	for i := range ds {
+
+		if ds[i].Updated > t {
+			t = ds[i].Updated
+		}
+
+		// A new FQDN without records is not updated yet
+		if ds[i].Created > t {
+			t = ds[i].Created
+		}
+
+		for ii := range ds[i].Records {
+
+			if l := ds[i].Records[ii].Last(); l > t {
+				t = l
+			}
+
+			if ds[i].Records[ii].Removed > t {
+				t = ds[i].Records[ii].Removed
+			}
+		}
+	}
+
+	return t
+}
+
 // DomainsInsert inserts the given domain d to the *domains* database.
 // Checks if d is valid, do a Clean() and then splits into sub|domain|tld parts.
 //
 // Returns true if d is new and inserted into the database.
-// If domain is invalid, returns fault.ErrInvalidDomain.
-// If failed to get parts of d (eg.: d is a TLD), returns ault.ErrGetPartsFailed.
+// If d is new, emits an EventNewFQDN event.
+// d is validated with DomainsValidate(), returns its error (fault.ErrInvalidDomain, fault.ErrPublicSuffix or fault.ErrGetPartsFailed).
 //
 // NOTE: Use RecordsUpdate() after Insert()!
-func DomainsInsert(d string) (bool, error) {
+func DomainsInsert(ctx context.Context, d string) (bool, error) {
 
-	if !validator.Domain(d) {
-		return false, fault.ErrInvalidDomain
+	d, err := DomainsValidate(d)
+	if err != nil {
+		return false, err
 	}
 
-	d = dns.Clean(d)
-
 	p := dns.GetParts(d)
-	if p == nil || p.Domain == "" || p.TLD == "" {
-		return false, fault.ErrGetPartsFailed
-	}
 
 	doc := bson.D{{Key: "domain", Value: p.Domain}, {Key: "tld", Value: p.TLD}, {Key: "sub", Value: p.Sub}}
 
 	// UpdateOne will insert the document with $setOnInsert + upsert or do nothing
-	res, err := Domains.UpdateOne(context.TODO(), doc, bson.M{"$setOnInsert": doc}, options.Update().SetUpsert(true))
+	res, err := Domains.UpdateOne(ctx, doc, bson.M{"$setOnInsert": domainsNewDoc(doc)}, options.Update().SetUpsert(true))
 	if err != nil {
 		return false, fmt.Errorf("failed to update: %w", err)
 	}
 
+	if res.UpsertedCount != 0 {
+		eventEmit(ctx, EventNewFQDN, d, nil)
+	}
+
 	return res.UpsertedCount != 0, nil
 }
 
+// domainsNewDoc returns the fields of a new domain document identified by doc, used in $setOnInsert.
+func domainsNewDoc(doc bson.D) bson.D {
+
+	n := make(bson.D, len(doc), len(doc)+1)
+	copy(n, doc)
+
+	return append(n, bson.E{Key: "created", Value: time.Now().Unix()})
+}
+
+// DomainsValidate validates and Clean() d, used by DomainsInsert() and before DomainsInsertMany().
+// Returns the cleaned d.
+//
+// If d is invalid, returns fault.ErrInvalidDomain.
+// If d is a public suffix (eg.: "co.uk"), returns fault.ErrPublicSuffix.
+// If failed to get parts of d, returns fault.ErrGetPartsFailed.
+func DomainsValidate(d string) (string, error) {
+
+	if !validator.Domain(d) {
+		return "", fault.ErrInvalidDomain
+	}
+
+	d = dns.Clean(d)
+
+	p := dns.GetParts(d)
+	if p == nil || p.TLD == "" {
+		return "", fault.ErrGetPartsFailed
+	}
+	if p.Domain == "" {
+		return "", fault.ErrPublicSuffix
+	}
+
+	return d, nil
+}
+
+// DomainsInsertMany inserts the domains ds to the *domains* database in one bulk write.
+// The domains must be validated with DomainsValidate().
+// Emits an EventNewFQDN event for every new domain.
+//
+// Returns the number of new domains.
+//
+// NOTE: Use RecordsUpdate() after InsertMany()!
+func DomainsInsertMany(ctx context.Context, ds []string) (int64, error) {
+
+	if len(ds) == 0 {
+		return 0, nil
+	}
+
+	models := make([]mongo.WriteModel, 0, len(ds))
+
+	for i := range ds {
+
+		p := dns.GetParts(ds[i])
+		if p == nil || p.Domain == "" || p.TLD == "" {
+			return 0, fmt.Errorf("%s: %w", ds[i], fault.ErrGetPartsFailed)
+		}
+
+		doc := bson.D{{Key: "domain", Value: p.Domain}, {Key: "tld", Value: p.TLD}, {Key: "sub", Value: p.Sub}}
+
+		models = append(models, mongo.NewUpdateOneModel().SetFilter(doc).SetUpdate(bson.M{"$setOnInsert": domainsNewDoc(doc)}).SetUpsert(true))
+	}
+
+	res, err := Domains.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	if err != nil {
+		return 0, fmt.Errorf("failed to bulk write: %w", err)
+	}
+
+	for i := range res.UpsertedIDs {
+		eventEmit(ctx, EventNewFQDN, ds[i], nil)
+	}
+
+	return res.UpsertedCount, nil
+}
+
 // DomainsInsertWithRecord inserts the given domain d to the *domains* database IF d has at least one valid record.
 // Checks if d is valid, do a Clean() and search for records. If found at least one valid record, insert into the database.
 // This function always updates the "updated" field, regardless of the records.
+// Schedules the next refresh of d in the "nextUpdate" field.
 //
 // This function returns if domain d is updated recently.
-// This function ignores common DNS errors (eg.: NXDOMAIN).
+// This function ignores NXDOMAIN.
+// If the DNS servers failed to answer, returns ErrDNSFailure.
 //
 // If domain is invalid, returns fault.ErrInvalidDomain.
 // If failed to get parts of d (eg.: d is a TLD), returns ault.ErrGetPartsFailed.
-func DomainsInsertWithRecord(d string, ignoreUpdated bool) error {
+func DomainsInsertWithRecord(ctx context.Context, d string, ignoreUpdated bool) error {
 
 	d = dns.Clean(d)
 
 	if !ignoreUpdated {
 
 		// DomainsUpdatedRecently check if d is valid.
-		updated, err := DomainsUpdatedRecently(d)
+		updated, err := DomainsUpdatedRecently(ctx, d)
 		if err != nil {
 			return err
 		}
@@ -117,33 +227,45 @@ func DomainsInsertWithRecord(d string, ignoreUpdated bool) error {
 		}
 	}
 
-	records, err := dns.QueryAll(d)
-	if err != nil && !errors.Is(err, dns.ErrName) && !errors.Is(err, dns.ErrServerFailure) &&
-		!os.IsTimeout(err) && !errors.Is(err, dns.ErrRefused) {
+	records, types, err := queryRecords(ctx, d)
+	if err != nil && !errors.Is(err, dns.ErrName) {
+
+		// The context is done (eg.: the client disconnected), not a DNS failure
+		if ctx.Err() != nil {
+			return err
+		}
+
+		// NXDOMAIN is not an error, every other error means that the DNS servers failed to answer (eg.: SERVFAIL, timeout)
 
-		// Ignore common errors
+		return fmt.Errorf("%w: %s", ErrDNSFailure, err)
+	}
 
-		return fmt.Errorf("failed to update: %w", err)
+	// d may be already in the database
+	err = recordsMarkRemoved(ctx, d, records, types)
+	if err != nil {
+		return fmt.Errorf("failed to mark removed records: %w", err)
 	}
 
 	if len(records) == 0 {
 		return nil
 	}
 
-	_, err = DomainsInsert(d)
+	_, err = DomainsInsert(ctx, d)
 	if err != nil {
-		return fmt.Errorf("failed to insert domain: %s", err)
+		return fmt.Errorf("failed to insert domain: %w", err)
 	}
 
 	for i := range records {
 
-		_, err = RecordsInsert(d, records[i].Type, records[i].Value)
+		_, err = RecordsInsert(ctx, d, records[i])
 		if err != nil {
 			return fmt.Errorf("failed to insert record: %w", err)
 		}
 	}
 
-	return nil
+	RecordsRefreshed(ctx, d)
+
+	return domainsScheduleNext(ctx, d)
 }
 
 // DomainsLookup validate, Clean() and query the DB and returns a list subdomains only (eg.: "wwww", "mail").
@@ -157,9 +279,9 @@ func DomainsInsertWithRecord(d string, ignoreUpdated bool) error {
 // If failed to get parts of d because of d is just a TLD, returns fault.ErrTLDOnly.
 // If failed to get parts of d, returns fault.ErrGetPartsFailed.
 // If days if < -1, returns fault.ErrInvalidDays.
-func DomainsLookup(d string, days int) ([]string, error) {
+func DomainsLookup(ctx context.Context, d string, days int) ([]string, error) {
 
-	ds, err := DomainsDomains(d, days)
+	ds, err := DomainsDomains(ctx, d, days)
 	if err != nil {
 		return nil, err
 	}
@@ -184,9 +306,9 @@ func DomainsLookup(d string, days int) ([]string, error) {
 // If failed to get parts of d because of d is just a TLD, returns fault.ErrTLDOnly.
 // If failed to get parts of d, returns fault.ErrGetPartsFailed.
 // If days if < -1, returns fault.ErrInvalidDays.
-func DomainsLookupFull(d string, days int) ([]string, error) {
+func DomainsLookupFull(ctx context.Context, d string, days int) ([]string, error) {
 
-	ds, err := DomainsDomains(d, days)
+	ds, err := DomainsDomains(ctx, d, days)
 	if err != nil {
 		return nil, err
 	}
@@ -211,7 +333,7 @@ func DomainsLookupFull(d string, days int) ([]string, error) {
 // If failed to get parts of d because of d is just a TLD, returns fault.ErrTLDOnly.
 // If failed to get parts of d, returns fault.ErrGetPartsFailed.
 // If days if < -1, returns fault.ErrInvalidDays.
-func DomainsDomains(d string, days int) ([]Domain, error) {
+func DomainsDomains(ctx context.Context, d string, days int) ([]Domain, error) {
 
 	if !dns.IsValid(d) {
 		return nil, fault.ErrInvalidDomain
@@ -243,21 +365,21 @@ func DomainsDomains(d string, days int) ([]Domain, error) {
 	}
 
 	// Use Find() to find every shard of the domain
-	cursor, err := Domains.Find(context.TODO(), doc)
+	cursor, err := Domains.Find(ctx, doc)
 	if err != nil {
-		return nil, fmt.Errorf("failed to find: %s", err)
+		return nil, fmt.Errorf("failed to find: %w", err)
 	}
-	defer cursor.Close(context.TODO())
+	defer cursor.Close(ctx)
 
 	var doms []Domain
 
-	for cursor.Next(context.TODO()) {
+	for cursor.Next(ctx) {
 
 		r := new(Domain)
 
 		err = cursor.Decode(r)
 		if err != nil {
-			return nil, fmt.Errorf("failed to decode: %s", err)
+			return nil, fmt.Errorf("failed to decode: %w", err)
 		}
 
 		doms = append(doms, *r)
@@ -275,24 +397,24 @@ func DomainsDomains(d string, days int) ([]Domain, error) {
 // Domain d must be a valid Second Level Domain (eg.: "example").
 //
 // NOTE: This function not validate and Clean() d!
-func DomainsTLD(d string) ([]string, error) {
+func DomainsTLD(ctx context.Context, d string) ([]string, error) {
 
 	// Use Find() to find every shard of the domain
-	cursor, err := Domains.Find(context.TODO(), bson.M{"domain": d})
+	cursor, err := Domains.Find(ctx, bson.M{"domain": d})
 	if err != nil {
-		return nil, fmt.Errorf("failed to find: %s", err)
+		return nil, fmt.Errorf("failed to find: %w", err)
 	}
-	defer cursor.Close(context.TODO())
+	defer cursor.Close(ctx)
 
 	var tlds []string
 
-	for cursor.Next(context.TODO()) {
+	for cursor.Next(ctx) {
 
 		var r FastDomain
 
 		err = cursor.Decode(&r)
 		if err != nil {
-			return nil, fmt.Errorf("failed to decode: %s", err)
+			return nil, fmt.Errorf("failed to decode: %w", err)
 		}
 
 		tlds = slices.AppendUnique(tlds, r.TLD)
@@ -311,7 +433,7 @@ func DomainsTLD(d string) ([]string, error) {
 // This function validate with IsValidSLD() and Clean().
 //
 // Returns fault.ErrInvalidDomain is d is not a valid Second Level Domain.
-func DomainsStarts(d string) ([]string, error) {
+func DomainsStarts(ctx context.Context, d string) ([]string, error) {
 
 	if !dns.IsValidSLD(d) {
 		return nil, fault.ErrInvalidDomain
@@ -319,24 +441,24 @@ func DomainsStarts(d string) ([]string, error) {
 
 	d = dns.Clean(d)
 
-	filter := bson.M{"domain": bson.M{"$regex": fmt.Sprintf("^%s", d)}}
+	filter := bson.M{"domain": bson.M{"$regex": "^" + regexp.QuoteMeta(d)}}
 
 	// Use Find() to find every shard of the domain
-	cursor, err := Domains.Find(context.TODO(), filter)
+	cursor, err := Domains.Find(ctx, filter)
 	if err != nil {
-		return nil, fmt.Errorf("failed to find: %s", err)
+		return nil, fmt.Errorf("failed to find: %w", err)
 	}
-	defer cursor.Close(context.TODO())
+	defer cursor.Close(ctx)
 
 	var domains []string
 
-	for cursor.Next(context.TODO()) {
+	for cursor.Next(ctx) {
 
 		var r FastDomain
 
 		err = cursor.Decode(&r)
 		if err != nil {
-			return nil, fmt.Errorf("failed to decode: %s", err)
+			return nil, fmt.Errorf("failed to decode: %w", err)
 		}
 
 		domains = slices.AppendUnique(domains, r.Domain)
@@ -359,7 +481,7 @@ func DomainsStarts(d string) ([]string, error) {
 // If failed to get parts of d because of d is just a TLD, returns fault.ErrTLDOnly.
 // If failed to get parts of d, returns fault.ErrGetPartsFailed.
 // If days if < -1, returns fault.ErrInvalidDays.
-func DomainsRecords(d string, days int) ([]Record, error) {
+func DomainsRecords(ctx context.Context, d string, days int) ([]Record, error) {
 
 	if !dns.IsValid(d) {
 		return nil, fault.ErrInvalidDomain
@@ -388,21 +510,21 @@ func DomainsRecords(d string, days int) ([]Record, error) {
 	}
 
 	// Use Find() to find every shard of the domain
-	cursor, err := Domains.Find(context.TODO(), doc)
+	cursor, err := Domains.Find(ctx, doc)
 	if err != nil {
-		return nil, fmt.Errorf("failed to find: %s", err)
+		return nil, fmt.Errorf("failed to find: %w", err)
 	}
-	defer cursor.Close(context.TODO())
+	defer cursor.Close(ctx)
 
 	var records = make([]Record, 0)
 
-	for cursor.Next(context.TODO()) {
+	for cursor.Next(ctx) {
 
 		r := new(Domain)
 
 		err = cursor.Decode(r)
 		if err != nil {
-			return nil, fmt.Errorf("failed to decode: %s", err)
+			return nil, fmt.Errorf("failed to decode: %w", err)
 		}
 
 		records = append(records, r.Records...)
@@ -420,7 +542,7 @@ func DomainsRecords(d string, days int) ([]Record, error) {
 // If d is invalid return fault.ErrInvalidDomain.
 // If failed to get parts of d because of d is just a TLD, returns fault.ErrTLDOnly.
 // If failed to get parts of d, returns fault.ErrGetPartsFailed.
-func DomainsUpdateUpdatedTime(d string) error {
+func DomainsUpdateUpdatedTime(ctx context.Context, d string) error {
 
 	if !validator.Domain(d) {
 		return fault.ErrInvalidDomain
@@ -440,7 +562,7 @@ func DomainsUpdateUpdatedTime(d string) error {
 
 	up := bson.D{{Key: "$set", Value: bson.D{{Key: "updated", Value: time.Now().Unix()}}}}
 
-	_, err := Domains.UpdateOne(context.TODO(), filter, up)
+	_, err := Domains.UpdateOne(ctx, filter, up)
 
 	return err
 }
@@ -452,7 +574,7 @@ func DomainsUpdateUpdatedTime(d string) error {
 // If d is invalid return fault.ErrInvalidDomain.
 // If failed to get parts of d because of d is just a TLD, returns fault.ErrTLDOnly.
 // If failed to get parts of d, returns fault.ErrGetPartsFailed.
-func DomainsUpdatedRecently(d string) (bool, error) {
+func DomainsUpdatedRecently(ctx context.Context, d string) (bool, error) {
 
 	if !validator.Domain(d) {
 		return false, fault.ErrInvalidDomain
@@ -472,7 +594,7 @@ func DomainsUpdatedRecently(d string) (bool, error) {
 
 	dom := new(Domain)
 
-	err := Domains.FindOne(context.TODO(), filter).Decode(dom)
+	err := Domains.FindOne(ctx, filter).Decode(dom)
 
 	if err == nil {
 		return true, nil
@@ -484,3 +606,44 @@ func DomainsUpdatedRecently(d string) (bool, error) {
 
 	return false, err
 }
+
+// DomainsExport calls fn with every FQDN in tld (eg.: "com", "co.uk").
+// If tld is empty, calls fn with every FQDN in the database.
+// The domains are streamed from the database, so the result is not held in the memory.
+//
+// Stops at the first error returned by fn and returns it.
+func DomainsExport(ctx context.Context, tld string, fn func(d *Domain) error) error {
+
+	filter := bson.M{}
+
+	if tld != "" {
+		filter["tld"] = dns.Clean(tld)
+	}
+
+	cursor, err := Domains.Find(ctx, filter, options.Find().SetBatchSize(1000))
+	if err != nil {
+		return fmt.Errorf("failed to find: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+
+		d := new(Domain)
+
+		err = cursor.Decode(d)
+		if err != nil {
+			return fmt.Errorf("failed to decode: %w", err)
+		}
+
+		err = fn(d)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := cursor.Err(); err != nil {
+		return fmt.Errorf("cursor failed: %w", err)
+	}
+
+	return nil
+}
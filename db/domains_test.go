@@ -0,0 +1,28 @@
+package db
+
+import (
+	"testing"
+)
+
+func TestDomainsModified(t *testing.T) {
+
+	if m := DomainsModified(nil); m != 0 {
+		t.Fatalf("empty: want 0, got %d", m)
+	}
+
+	ds := []Domain{
+		{Sub: "www", Updated: 100, Records: []Record{{Type: 1, Time: 150}}},
+		{Sub: "api", Updated: 120, Records: []Record{{Type: 1, LastSeen: 130, Time: 130, Removed: 200}}},
+		{Sub: "mail", Updated: 180},
+	}
+
+	if m := DomainsModified(ds); m != 200 {
+		t.Fatalf("want 200, got %d", m)
+	}
+
+	ds = append(ds, Domain{Sub: "new", Created: 250})
+
+	if m := DomainsModified(ds); m != 250 {
+		t.Fatalf("new FQDN: want 250, got %d", m)
+	}
+}
@@ -0,0 +1,150 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DumpCollection is a collection included in the dump of the dataset.
+type DumpCollection struct {
+	Name  string   // Name of the collection
+	Key   []string // Fields that identify a document, used to restore the documents idempotently
+	Since []string // Fields compared to the timestamp in an incremental dump, empty if the collection is always dumped in full
+}
+
+// DumpCollections are the collections of the dataset in the order of the dump.
+// The queue, events, subscriptions and migrations are not part of the dataset.
+var DumpCollections = []DumpCollection{
+	{Name: "domains", Key: []string{"domain", "tld", "sub"}, Since: []string{"updated", "created"}}, // A new domain without records is never updated
+	{Name: "ctlogs", Key: []string{"name"}},
+	{Name: "topList", Key: []string{"domain"}},
+	{Name: "notFound", Key: []string{"domain"}},
+	{Name: "statistics", Key: []string{"date"}, Since: []string{"date"}},
+}
+
+// collection returns the collection of c.
+func (c *DumpCollection) collection() *mongo.Collection {
+
+	switch c.Name {
+	case "domains":
+		return Domains
+	case "ctlogs":
+		return CTLogs
+	case "topList":
+		return TopList
+	case "notFound":
+		return NotFound
+	case "statistics":
+		return Statistics
+	default:
+		return nil
+	}
+}
+
+// DumpFind calls fn with every document of collection c.
+// If since is not 0 and c.Since is set, only the documents with any of the c.Since fields after since are returned.
+// The document is valid only until fn returns.
+//
+// Stops at the first error returned by fn and returns it.
+func DumpFind(ctx context.Context, c DumpCollection, since int64, fn func(doc bson.Raw) error) error {
+
+	coll := c.collection()
+	if coll == nil {
+		return fmt.Errorf("unknown collection: %s", c.Name)
+	}
+
+	filter := bson.M{}
+
+	if since != 0 && len(c.Since) > 0 {
+
+		or := make(bson.A, 0, len(c.Since))
+
+		for i := range c.Since {
+			or = append(or, bson.M{c.Since[i]: bson.M{"$gt": since}})
+		}
+
+		filter["$or"] = or
+	}
+
+	cursor, err := coll.Find(ctx, filter, options.Find().SetBatchSize(1000))
+	if err != nil {
+		return fmt.Errorf("failed to find: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+
+		err = fn(cursor.Current)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := cursor.Err(); err != nil {
+		return fmt.Errorf("cursor failed: %w", err)
+	}
+
+	return nil
+}
+
+// DumpRestore writes the documents docs to collection c in one bulk write.
+// The documents are matched by c.Key and replaced or inserted, so restoring the same documents again does not create duplicates.
+// The "_id" field of the documents is dropped, the existing documents keep their _id.
+//
+// Returns the number of inserted and replaced documents.
+func DumpRestore(ctx context.Context, c DumpCollection, docs []bson.Raw) (int64, int64, error) {
+
+	coll := c.collection()
+	if coll == nil {
+		return 0, 0, fmt.Errorf("unknown collection: %s", c.Name)
+	}
+
+	if len(docs) == 0 {
+		return 0, 0, nil
+	}
+
+	models := make([]mongo.WriteModel, 0, len(docs))
+
+	for i := range docs {
+
+		elems, err := docs[i].Elements()
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid document: %w", err)
+		}
+
+		doc := make(bson.D, 0, len(elems))
+
+		for _, e := range elems {
+			if e.Key() != "_id" {
+				doc = append(doc, bson.E{Key: e.Key(), Value: e.Value()})
+			}
+		}
+
+		filter := make(bson.D, 0, len(c.Key))
+
+		for _, k := range c.Key {
+
+			v, err := docs[i].LookupErr(k)
+			if err != nil {
+				// A missing key (eg.: the empty "sub" of an apex domain) matches the missing field
+				filter = append(filter, bson.E{Key: k, Value: bson.M{"$exists": false}})
+				continue
+			}
+
+			filter = append(filter, bson.E{Key: k, Value: v})
+		}
+
+		models = append(models, mongo.NewReplaceOneModel().SetFilter(filter).SetReplacement(doc).SetUpsert(true))
+	}
+
+	res, err := coll.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to bulk write: %w", err)
+	}
+
+	return res.UpsertedCount, res.ModifiedCount, nil
+}
@@ -0,0 +1,227 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/elmasy-com/elnet/dns"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// EventType is the type of a change event.
+type EventType string
+
+const (
+	EventNewFQDN       EventType = "newFQDN"       // New FQDN is inserted
+	EventNewRecord     EventType = "newRecord"     // New record is found for a FQDN
+	EventRemovedRecord EventType = "removedRecord" // A refresh no longer returned a record
+
+	// EventRefreshedFQDN is an internal event, the updated time of a FQDN or the times of its records changed without other change.
+	// It is used to invalidate the lookup caches, it is not sent to the subscriptions or streamed.
+	EventRefreshedFQDN EventType = "refreshedFQDN"
+)
+
+// IsValid returns whether t is a known public EventType (EventRefreshedFQDN is internal).
+func (t EventType) IsValid() bool {
+	return t == EventNewFQDN || t == EventNewRecord || t == EventRemovedRecord
+}
+
+// Event is the schema used in the "events" collection.
+//
+// Domain is the FQDN, Apex is the domain without the subdomain (eg.: "example.com").
+// Record is set for EventNewRecord and EventRemovedRecord.
+// Seq is assigned by the database from the "events" counter, the events must be read in Seq order (see EventReader).
+// The ID is created by the emitting process (server, scanner or DNS proxy), so it is not ordered.
+// Created is used by the TTL index, the events are removed after EventsRetention.
+type Event struct {
+	ID      primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Seq     int64              `bson:"seq" json:"seq"`
+	Type    EventType          `bson:"type" json:"type"`
+	Domain  string             `bson:"domain" json:"domain"`
+	Apex    string             `bson:"apex" json:"apex"`
+	Record  *Record            `bson:"record,omitempty" json:"record,omitempty"`
+	Time    int64              `bson:"time" json:"time"`
+	Created time.Time          `bson:"created" json:"-"`
+}
+
+var (
+	// EventsRetention is the time to keep the events, set in the TTL index by the migration (see migrateEventsIndexes()).
+	EventsRetention = 30 * 24 * time.Hour
+
+	// EventsGapTimeout is the time to wait for a missing Seq before the reader skips it.
+	// The Seq is assigned before the event is inserted, so a reader can see a later event before an earlier one.
+	// A Seq missing longer than this is lost (eg.: the insert failed) or removed by the TTL index.
+	EventsGapTimeout = 10 * time.Second
+)
+
+// eventsCounter is the ID of the document in the "counters" collection that holds the last assigned Seq.
+const eventsCounter = "events"
+
+// eventsNextSeq returns the next Seq from the "events" counter.
+func eventsNextSeq(ctx context.Context) (int64, error) {
+
+	var c struct {
+		Seq int64 `bson:"seq"`
+	}
+
+	err := Counters.FindOneAndUpdate(ctx,
+		bson.M{"_id": eventsCounter},
+		bson.M{"$inc": bson.M{"seq": int64(1)}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)).Decode(&c)
+
+	return c.Seq, err
+}
+
+// eventEmit inserts a new event with type t for FQDN d into the "events" collection.
+// r is the changed record, can be nil.
+//
+// The error is printed only, a failed event must not fail the insert/update.
+// The event is inserted even if ctx is cancelled, the change is already stored.
+// The server reads the event to invalidate its lookup cache of the apex (see CacheInvalidateWorker()).
+func eventEmit(ctx context.Context, t EventType, d string, r *Record) {
+
+	ctx = context.WithoutCancel(ctx)
+
+	d = dns.Clean(d)
+
+	p := dns.GetParts(d)
+	if p == nil || p.Domain == "" || p.TLD == "" {
+		return
+	}
+
+	now := time.Now()
+
+	e := Event{Type: t, Domain: d, Apex: p.Domain + "." + p.TLD, Record: r, Time: now.Unix(), Created: now}
+
+	var err error
+
+	e.Seq, err = eventsNextSeq(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to get the sequence of event", "type", t, "domain", d, "error", err)
+		return
+	}
+
+	_, err = Events.InsertOne(ctx, e)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to insert event", "type", t, "domain", d, "error", err)
+	}
+}
+
+// EventsAfter returns up to limit events with Seq greater than after in ascending order.
+// Use EventReader to read the events without missing the ones inserted out of order.
+func EventsAfter(ctx context.Context, after int64, limit int64) ([]Event, error) {
+
+	cursor, err := Events.Find(ctx, bson.M{"seq": bson.M{"$gt": after}}, options.Find().SetSort(bson.M{"seq": 1}).SetLimit(limit))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	es := make([]Event, 0)
+
+	for cursor.Next(ctx) {
+
+		e := new(Event)
+
+		err = cursor.Decode(e)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode: %w", err)
+		}
+
+		es = append(es, *e)
+	}
+
+	return es, cursor.Err()
+}
+
+// EventsLastSeq returns the last assigned Seq, or 0 if no event was emitted.
+func EventsLastSeq(ctx context.Context) (int64, error) {
+
+	var c struct {
+		Seq int64 `bson:"seq"`
+	}
+
+	err := Counters.FindOne(ctx, bson.M{"_id": eventsCounter}).Decode(&c)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return 0, err
+	}
+
+	return c.Seq, nil
+}
+
+// EventsCursorGet returns the Seq of the last event processed by reader name (eg.: "webhook").
+// If the cursor is not stored, returns false.
+func EventsCursorGet(ctx context.Context, name string) (int64, bool, error) {
+
+	var c struct {
+		Seq int64 `bson:"seq"`
+	}
+
+	err := Counters.FindOne(ctx, bson.M{"_id": "cursor." + name}).Decode(&c)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+
+	return c.Seq, true, nil
+}
+
+// EventsCursorSet stores seq as the last event processed by reader name.
+func EventsCursorSet(ctx context.Context, name string, seq int64) error {
+
+	_, err := Counters.UpdateOne(ctx, bson.M{"_id": "cursor." + name}, bson.M{"$set": bson.M{"seq": seq}}, options.Update().SetUpsert(true))
+
+	return err
+}
+
+// EventReader reads the events in Seq order.
+// Last is the Seq of the last returned event.
+//
+// If a Seq is missing, the reader waits EventsGapTimeout for the event before skips it.
+type EventReader struct {
+	Last     int64
+	gapSince time.Time
+}
+
+// Next returns up to limit events after Last and moves Last to the last returned event.
+func (r *EventReader) Next(ctx context.Context, limit int64) ([]Event, error) {
+
+	es, err := EventsAfter(ctx, r.Last, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.advance(es, time.Now()), nil
+}
+
+// advance returns the events of es (sorted by Seq) that can be read at now and moves Last.
+// The events after a missing Seq are held back until the gap is filled or older than EventsGapTimeout.
+func (r *EventReader) advance(es []Event, now time.Time) []Event {
+
+	for i := range es {
+
+		if es[i].Seq != r.Last+1 {
+
+			if r.gapSince.IsZero() {
+				r.gapSince = now
+			}
+
+			if now.Sub(r.gapSince) < EventsGapTimeout {
+				return es[:i]
+			}
+		}
+
+		r.gapSince = time.Time{}
+		r.Last = es[i].Seq
+	}
+
+	return es
+}
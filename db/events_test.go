@@ -0,0 +1,55 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEventReader(t *testing.T) {
+
+	now := time.Unix(1000, 0)
+
+	r := &EventReader{Last: 1}
+
+	// Seq 3 is not inserted yet, 4 is held back
+	es := r.advance([]Event{{Seq: 2}, {Seq: 4}}, now)
+
+	if len(es) != 1 || r.Last != 2 {
+		t.Fatalf("want Seq 2 only, got %v (last: %d)", es, r.Last)
+	}
+
+	// The gap is filled
+	es = r.advance([]Event{{Seq: 3}, {Seq: 4}}, now.Add(time.Second))
+
+	if len(es) != 2 || r.Last != 4 {
+		t.Fatalf("want Seq 3 and 4, got %v (last: %d)", es, r.Last)
+	}
+
+	// Seq 5 is lost, 6 is held back until EventsGapTimeout
+	if es = r.advance([]Event{{Seq: 6}}, now); len(es) != 0 || r.Last != 4 {
+		t.Fatalf("want nothing, got %v (last: %d)", es, r.Last)
+	}
+
+	if es = r.advance([]Event{{Seq: 6}}, now.Add(EventsGapTimeout)); len(es) != 1 || r.Last != 6 {
+		t.Fatalf("want Seq 6 after the timeout, got %v (last: %d)", es, r.Last)
+	}
+
+	// A new gap waits again
+	if es = r.advance([]Event{{Seq: 8}}, now.Add(EventsGapTimeout+time.Second)); len(es) != 0 || r.Last != 6 {
+		t.Fatalf("want nothing, got %v (last: %d)", es, r.Last)
+	}
+}
+
+func TestSubscriptionWants(t *testing.T) {
+
+	all := Subscription{}
+	fqdn := Subscription{Events: []EventType{EventNewFQDN}}
+
+	if !all.Wants(EventRemovedRecord) || !fqdn.Wants(EventNewFQDN) || fqdn.Wants(EventNewRecord) {
+		t.Fatalf("invalid subscription filter")
+	}
+
+	if all.Wants(EventRefreshedFQDN) {
+		t.Fatalf("internal event is sent")
+	}
+}
@@ -0,0 +1,283 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Migration is a versioned step to upgrade the database schema.
+// The steps must be idempotent, a step interrupted before recorded in the "migrations" collection is run again.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(ctx context.Context) error
+}
+
+// MigrationSchema is the schema used in the "migrations" collection.
+type MigrationSchema struct {
+	Version int    `bson:"_id" json:"version"`
+	Name    string `bson:"name" json:"name"`
+	Applied int64  `bson:"applied" json:"applied"`
+}
+
+// MigrationStatus is the state of a migration step.
+// Applied is 0 if the step is pending.
+type MigrationStatus struct {
+	Version int    `json:"version"`
+	Name    string `json:"name"`
+	Applied int64  `json:"applied,omitempty"`
+}
+
+// MigrationSteps is the ordered list of the migration steps.
+// New steps must be appended with a higher version, never modify or remove an existing step.
+var MigrationSteps = []Migration{
+	{Version: 1, Name: "create indexes on domains", Up: migrateDomainsIndexes},
+	{Version: 2, Name: "create indexes on topList, notFound, ctlogs and statistics", Up: migrateMiscIndexes},
+	{Version: 3, Name: "create indexes on updateQueue and updateDeadLetter", Up: migrateQueueIndexes},
+	{Version: 4, Name: "create indexes on events and deliveries", Up: migrateEventsIndexes},
+	{Version: 5, Name: "rename Updated field in statistics", Up: migrateStatisticsUpdated},
+	{Version: 6, Name: "set firstSeen and lastSeen of records", Up: migrateRecordsSeen},
+	{Version: 7, Name: "set ip of A and AAAA records", Up: migrateRecordsIP},
+	{Version: 8, Name: "set targetKey of CNAME, MX and NS records", Up: migrateRecordsTarget},
+	{Version: 9, Name: "set created of domains", Up: migrateDomainsCreated},
+}
+
+// MigrationsStatus returns the status of every known migration step in order.
+func MigrationsStatus(ctx context.Context) ([]MigrationStatus, error) {
+
+	cursor, err := Migrations.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, fmt.Errorf("find failed: %w", err)
+	}
+
+	var applied []MigrationSchema
+
+	err = cursor.All(ctx, &applied)
+	if err != nil {
+		return nil, fmt.Errorf("cursor failed: %w", err)
+	}
+
+	ss := make([]MigrationStatus, 0, len(MigrationSteps))
+
+	for i := range MigrationSteps {
+
+		s := MigrationStatus{Version: MigrationSteps[i].Version, Name: MigrationSteps[i].Name}
+
+		for j := range applied {
+			if applied[j].Version == s.Version {
+				s.Applied = applied[j].Applied
+				break
+			}
+		}
+
+		ss = append(ss, s)
+	}
+
+	return ss, nil
+}
+
+// MigrationsPending returns the migration steps not applied yet in order.
+func MigrationsPending(ctx context.Context) ([]Migration, error) {
+
+	ss, err := MigrationsStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var ms []Migration
+
+	for i := range ss {
+		if ss[i].Applied == 0 {
+			ms = append(ms, MigrationSteps[i])
+		}
+	}
+
+	return ms, nil
+}
+
+// MigrationsUp applies the pending migration steps in order.
+// The function f is called before every step (eg.: to log it), can be nil.
+//
+// Stops at the first failed step, the steps after it are not applied.
+func MigrationsUp(ctx context.Context, f func(m Migration)) error {
+
+	ms, err := MigrationsPending(ctx)
+	if err != nil {
+		return err
+	}
+
+	for i := range ms {
+
+		if f != nil {
+			f(ms[i])
+		}
+
+		err = ms[i].Up(ctx)
+		if err != nil {
+			return fmt.Errorf("migration %d (%s) failed: %w", ms[i].Version, ms[i].Name, err)
+		}
+
+		_, err = Migrations.InsertOne(ctx, MigrationSchema{Version: ms[i].Version, Name: ms[i].Name, Applied: time.Now().Unix()})
+		if err != nil && !mongo.IsDuplicateKeyError(err) {
+			return fmt.Errorf("failed to record migration %d: %w", ms[i].Version, err)
+		}
+	}
+
+	return nil
+}
+
+// migrateDomainsIndexes creates the indexes of the "domains" collection.
+// The compound index of {domain, tld, sub} is used by every lookup.
+func migrateDomainsIndexes(ctx context.Context) error {
+
+	_, err := Domains.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "domain", Value: 1}, {Key: "tld", Value: 1}, {Key: "sub", Value: 1}}},
+		{Keys: bson.D{{Key: "updated", Value: 1}}},
+		{Keys: bson.D{{Key: "nextUpdate", Value: 1}}},
+		{Keys: bson.D{{Key: "records.time", Value: 1}}},
+	})
+
+	return err
+}
+
+// migrateMiscIndexes creates the indexes of the "topList", "notFound", "ctlogs" and "statistics" collections.
+func migrateMiscIndexes(ctx context.Context) error {
+
+	_, err := TopList.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: bson.D{{Key: "domain", Value: 1}}})
+	if err != nil {
+		return fmt.Errorf("topList: %w", err)
+	}
+
+	_, err = NotFound.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: bson.D{{Key: "domain", Value: 1}}})
+	if err != nil {
+		return fmt.Errorf("notFound: %w", err)
+	}
+
+	_, err = CTLogs.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: bson.D{{Key: "name", Value: 1}}})
+	if err != nil {
+		return fmt.Errorf("ctlogs: %w", err)
+	}
+
+	_, err = Statistics.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: bson.D{{Key: "date", Value: 1}}})
+	if err != nil {
+		return fmt.Errorf("statistics: %w", err)
+	}
+
+	return nil
+}
+
+// migrateQueueIndexes creates the indexes of the "updateQueue" and "updateDeadLetter" collections.
+func migrateQueueIndexes(ctx context.Context) error {
+
+	_, err := Queue.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "domain", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "priority", Value: 1}, {Key: "nextAttempt", Value: 1}}},
+	})
+	if err != nil {
+		return fmt.Errorf("updateQueue: %w", err)
+	}
+
+	_, err = DeadLetter.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: bson.D{{Key: "domain", Value: 1}}, Options: options.Index().SetUnique(true)})
+	if err != nil {
+		return fmt.Errorf("updateDeadLetter: %w", err)
+	}
+
+	return nil
+}
+
+// migrateEventsIndexes creates the indexes of the "events" and "deliveries" collections.
+// The events are read in "seq" order and removed by the TTL index after EventsRetention.
+// A delivery is unique for an event and a subscription, the dispatcher claims the pending deliveries by "nextAttempt".
+func migrateEventsIndexes(ctx context.Context) error {
+
+	_, err := Events.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "seq", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "created", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(int32(EventsRetention / time.Second))},
+	})
+	if err != nil {
+		return fmt.Errorf("events: %w", err)
+	}
+
+	_, err = Deliveries.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "subscription", Value: 1}, {Key: "event", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "subscription", Value: 1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "state", Value: 1}, {Key: "nextAttempt", Value: 1}}},
+	})
+	if err != nil {
+		return fmt.Errorf("deliveries: %w", err)
+	}
+
+	return nil
+}
+
+// migrateStatisticsUpdated renames the "Updated" field to "updated" in the "statistics" collection.
+// The field was tagged with "bsn" instead of "bson", so the key depended on the driver's default naming.
+func migrateStatisticsUpdated(ctx context.Context) error {
+
+	_, err := Statistics.UpdateMany(ctx,
+		bson.M{"Updated": bson.M{"$exists": true}},
+		bson.M{"$rename": bson.M{"Updated": "updated"}})
+
+	return err
+}
+
+// migrateRecordsSeen sets the missing "firstSeen" and "lastSeen" fields of the records to "time".
+func migrateRecordsSeen(ctx context.Context) error {
+
+	filter := bson.M{"records": bson.M{"$elemMatch": bson.M{"$or": bson.A{
+		bson.M{"firstSeen": bson.M{"$exists": false}},
+		bson.M{"lastSeen": bson.M{"$exists": false}},
+	}}}}
+
+	update := mongo.Pipeline{{{Key: "$set", Value: bson.M{"records": bson.M{"$map": bson.M{
+		"input": "$records",
+		"as":    "r",
+		"in": bson.M{"$mergeObjects": bson.A{"$$r", bson.M{
+			"firstSeen": bson.M{"$ifNull": bson.A{"$$r.firstSeen", "$$r.time"}},
+			"lastSeen":  bson.M{"$ifNull": bson.A{"$$r.lastSeen", "$$r.time"}},
+		}}},
+	}}}}}}
+
+	_, err := Domains.UpdateMany(ctx, filter, update)
+
+	return err
+}
+
+// MigrationsCheck returns an error if there are pending migrations.
+func MigrationsCheck(ctx context.Context) error {
+
+	ms, err := MigrationsPending(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(ms) > 0 {
+		return fmt.Errorf("%d pending migration(s), run columbus-migrate up", len(ms))
+	}
+
+	return nil
+}
+
+// MigrationsVersion returns the highest applied migration version, or 0 if no migration applied.
+func MigrationsVersion(ctx context.Context) (int, error) {
+
+	ss, err := MigrationsStatus(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	v := 0
+
+	for i := range ss {
+		if ss[i].Applied != 0 && ss[i].Version > v {
+			v = ss[i].Version
+		}
+	}
+
+	return v, nil
+}
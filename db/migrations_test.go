@@ -0,0 +1,21 @@
+package db
+
+import "testing"
+
+func TestMigrationStepsOrder(t *testing.T) {
+
+	for i := range MigrationSteps {
+
+		if MigrationSteps[i].Up == nil {
+			t.Fatalf("FAIL: migration %d has no Up function", MigrationSteps[i].Version)
+		}
+
+		if MigrationSteps[i].Name == "" {
+			t.Fatalf("FAIL: migration %d has no name", MigrationSteps[i].Version)
+		}
+
+		if i > 0 && MigrationSteps[i].Version <= MigrationSteps[i-1].Version {
+			t.Fatalf("FAIL: migration %d is after %d", MigrationSteps[i].Version, MigrationSteps[i-1].Version)
+		}
+	}
+}
@@ -20,7 +20,7 @@ type NotFoundSchema struct {
 //
 // Returns true if d is new and inserted into the database.
 // If domain is invalid or failed to remove the subdomain, returns fault.ErrInvalidDomain.
-func NotFoundInsert(d string) (bool, error) {
+func NotFoundInsert(ctx context.Context, d string) (bool, error) {
 
 	if !validator.Domain(d) {
 		return false, fault.ErrInvalidDomain
@@ -36,7 +36,7 @@ func NotFoundInsert(d string) (bool, error) {
 	doc := bson.M{"domain": v}
 
 	// UpdateOne will insert the document with $setOnInsert + upsert or do nothing
-	res, err := NotFound.UpdateOne(context.TODO(), doc, bson.M{"$setOnInsert": doc}, options.Update().SetUpsert(true))
+	res, err := NotFound.UpdateOne(ctx, doc, bson.M{"$setOnInsert": doc}, options.Update().SetUpsert(true))
 
 	return res.UpsertedCount != 0, err
 }
@@ -0,0 +1,338 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/elmasy-com/columbus/fault"
+	"github.com/elmasy-com/elnet/dns"
+	"github.com/elmasy-com/elnet/validator"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// QueueSchema is the schema used in the "updateQueue" and "updateDeadLetter" collections.
+type QueueSchema struct {
+	Domain      string         `bson:"domain" json:"domain"`
+	Type        UpdateType     `bson:"type" json:"type"`
+	Priority    UpdatePriority `bson:"priority" json:"priority"`
+	Attempts    int            `bson:"attempts" json:"attempts"`
+	NextAttempt int64          `bson:"nextAttempt" json:"nextAttempt"`
+	LeaseUntil  int64          `bson:"leaseUntil" json:"leaseUntil"`
+	LastError   string         `bson:"lastError,omitempty" json:"lastError,omitempty"`
+	Created     int64          `bson:"created" json:"created"`
+	Failed      int64          `bson:"failed,omitempty" json:"failed,omitempty"`
+}
+
+var (
+	// QueueMaxAttempts is the number of attempts before an item is moved to the dead-letter collection.
+	QueueMaxAttempts = 8
+
+	// QueueBackoff is the delay before the first retry. The delay is doubled after every failed attempt.
+	QueueBackoff = time.Minute
+
+	// QueueMaxBackoff is the maximum delay between two attempts.
+	QueueMaxBackoff = 12 * time.Hour
+
+	// QueueLease is the time while a claimed item is hidden from the other workers.
+	// If the item is not acked/nacked before the lease expires (eg.: the server crashed), the item is claimed again.
+	QueueLease = 30 * time.Minute
+)
+
+// queueBackoff returns the delay before the next attempt after n failed attempts.
+func queueBackoff(n int) time.Duration {
+
+	b := QueueBackoff
+
+	for i := 1; i < n && b < QueueMaxBackoff; i++ {
+		b *= 2
+	}
+
+	if b > QueueMaxBackoff {
+		b = QueueMaxBackoff
+	}
+
+	return b
+}
+
+// QueueAdd adds domain d with type t and priority p to the durable queue.
+// If d is already in the queue, the higher priority and the stronger type is kept and the scheduled retry is not changed.
+//
+// If d is invalid, returns fault.ErrInvalidDomain.
+func QueueAdd(ctx context.Context, d string, t UpdateType, p UpdatePriority) error {
+
+	if !validator.Domain(d) {
+		return fault.ErrInvalidDomain
+	}
+
+	d = dns.Clean(d)
+
+	_, err := Queue.UpdateOne(ctx, bson.M{"domain": d}, queueAddUpdate(t, p), options.Update().SetUpsert(true))
+
+	return err
+}
+
+// queueAddUpdate returns the update document used to add a domain with type t and priority p to the durable queue.
+func queueAddUpdate(t UpdateType, p UpdatePriority) bson.D {
+
+	now := time.Now().Unix()
+
+	return bson.D{
+		{Key: "$setOnInsert", Value: bson.D{{Key: "attempts", Value: 0}, {Key: "nextAttempt", Value: now}, {Key: "leaseUntil", Value: int64(0)}, {Key: "created", Value: now}}},
+		{Key: "$min", Value: bson.D{{Key: "type", Value: t}, {Key: "priority", Value: p}}},
+	}
+}
+
+// QueueAddMany adds the domains ds with type t and priority p to the durable queue in one bulk write.
+// The domains must be validated and Clean()-ed (eg.: with DomainsValidate()).
+// The existing items are updated the same way as in QueueAdd().
+func QueueAddMany(ctx context.Context, ds []string, t UpdateType, p UpdatePriority) error {
+
+	if len(ds) == 0 {
+		return nil
+	}
+
+	up := queueAddUpdate(t, p)
+
+	models := make([]mongo.WriteModel, 0, len(ds))
+
+	for i := range ds {
+		models = append(models, mongo.NewUpdateOneModel().SetFilter(bson.M{"domain": ds[i]}).SetUpdate(up).SetUpsert(true))
+	}
+
+	_, err := Queue.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+
+	return err
+}
+
+// QueueClaim claims up to n items from the durable queue that are due and not leased.
+// The items are leased for QueueLease and the attempts are incremented.
+// The items are returned in priority order.
+func QueueClaim(ctx context.Context, n int) ([]QueueSchema, error) {
+
+	qs := make([]QueueSchema, 0, n)
+
+	for i := 0; i < n; i++ {
+
+		now := time.Now().Unix()
+
+		filter := bson.D{{Key: "nextAttempt", Value: bson.M{"$lte": now}}, {Key: "leaseUntil", Value: bson.M{"$lte": now}}}
+		up := bson.D{
+			{Key: "$set", Value: bson.D{{Key: "leaseUntil", Value: time.Now().Add(QueueLease).Unix()}}},
+			{Key: "$inc", Value: bson.D{{Key: "attempts", Value: 1}}},
+		}
+		opts := options.FindOneAndUpdate().SetSort(bson.D{{Key: "priority", Value: 1}, {Key: "nextAttempt", Value: 1}}).SetReturnDocument(options.After)
+
+		q := new(QueueSchema)
+
+		err := Queue.FindOneAndUpdate(ctx, filter, up, opts).Decode(q)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			break
+		}
+		if err != nil {
+			return qs, fmt.Errorf("failed to claim: %w", err)
+		}
+
+		qs = append(qs, *q)
+	}
+
+	return qs, nil
+}
+
+// QueueAck removes domain d from the durable queue after a successful update.
+func QueueAck(ctx context.Context, d string) error {
+
+	if !validator.Domain(d) {
+		return fault.ErrInvalidDomain
+	}
+
+	_, err := Queue.DeleteOne(ctx, bson.M{"domain": dns.Clean(d)})
+
+	return err
+}
+
+// QueueNack reports a failed update of domain d with error reason.
+// The next attempt is scheduled with an exponential backoff.
+// If the item reached QueueMaxAttempts, it is moved to the dead-letter collection.
+// The attempts, the error and the next attempt are updated atomically in one FindOneAndUpdate.
+//
+// If d is not in the queue (eg.: the item was not claimed from the durable queue), d is added with one failed attempt.
+func QueueNack(ctx context.Context, d string, t UpdateType, p UpdatePriority, reason error) error {
+
+	if !validator.Domain(d) {
+		return fault.ErrInvalidDomain
+	}
+
+	d = dns.Clean(d)
+
+	now := time.Now().Unix()
+
+	// delays[i] is the delay in seconds after i+1 failed attempts
+	delays := make(bson.A, 0, QueueMaxAttempts)
+	for i := 1; i <= QueueMaxAttempts; i++ {
+		delays = append(delays, int64(queueBackoff(i)/time.Second))
+	}
+
+	up := mongo.Pipeline{
+		{{Key: "$set", Value: bson.D{
+			{Key: "type", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$type", t}}}},
+			{Key: "priority", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$priority", p}}}},
+			// $max ignores the missing field, an item not claimed before has one failed attempt
+			{Key: "attempts", Value: bson.D{{Key: "$max", Value: bson.A{"$attempts", 1}}}},
+			{Key: "created", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$created", now}}}},
+			{Key: "lastError", Value: bson.D{{Key: "$literal", Value: reason.Error()}}},
+			{Key: "leaseUntil", Value: int64(0)},
+		}}},
+		{{Key: "$set", Value: bson.D{
+			{Key: "nextAttempt", Value: bson.D{{Key: "$add", Value: bson.A{
+				now,
+				bson.D{{Key: "$arrayElemAt", Value: bson.A{delays, bson.D{{Key: "$min", Value: bson.A{bson.D{{Key: "$subtract", Value: bson.A{"$attempts", 1}}}, len(delays) - 1}}}}}},
+			}}}},
+		}}},
+	}
+
+	q := new(QueueSchema)
+
+	err := Queue.FindOneAndUpdate(ctx, bson.M{"domain": d}, up, options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)).Decode(q)
+	if err != nil {
+		return fmt.Errorf("failed to update: %w", err)
+	}
+
+	if q.Attempts < QueueMaxAttempts {
+		return nil
+	}
+
+	return queueDeadLetter(ctx, q)
+}
+
+// QueueFail moves domain d to the dead-letter collection without retry, used for permanent errors (eg.: invalid domain).
+//
+// If d is not in the queue, d is added to the dead-letter collection with type t and priority p.
+func QueueFail(ctx context.Context, d string, t UpdateType, p UpdatePriority, reason error) error {
+
+	d = dns.Clean(d)
+
+	q := new(QueueSchema)
+
+	err := Queue.FindOneAndDelete(ctx, bson.M{"domain": d}).Decode(q)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		q = &QueueSchema{Domain: d, Type: t, Priority: p, Attempts: 1, Created: time.Now().Unix()}
+	} else if err != nil {
+		return fmt.Errorf("failed to delete: %w", err)
+	}
+
+	q.LastError = reason.Error()
+
+	return queueDeadLetter(ctx, q)
+}
+
+// queueDeadLetter inserts q into the dead-letter collection and removes it from the durable queue.
+func queueDeadLetter(ctx context.Context, q *QueueSchema) error {
+
+	q.Failed = time.Now().Unix()
+	q.LeaseUntil = 0
+
+	_, err := DeadLetter.ReplaceOne(ctx, bson.M{"domain": q.Domain}, q, options.Replace().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("failed to insert into dead-letter: %w", err)
+	}
+
+	_, err = Queue.DeleteOne(ctx, bson.M{"domain": q.Domain})
+
+	return err
+}
+
+// QueueCount returns the number of items in the durable queue and the number of currently leased items.
+func QueueCount(ctx context.Context) (int64, int64, error) {
+
+	total, err := Queue.CountDocuments(ctx, bson.M{})
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to count total: %w", err)
+	}
+
+	leased, err := Queue.CountDocuments(ctx, bson.M{"leaseUntil": bson.M{"$gt": time.Now().Unix()}})
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to count leased: %w", err)
+	}
+
+	return total, leased, nil
+}
+
+// DeadLetterGets returns up to limit items from the dead-letter collection starting from skip, the newest first.
+func DeadLetterGets(ctx context.Context, skip int64, limit int64) ([]QueueSchema, error) {
+
+	cursor, err := DeadLetter.Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"failed": -1}).SetSkip(skip).SetLimit(limit))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	qs := make([]QueueSchema, 0)
+
+	for cursor.Next(ctx) {
+
+		q := new(QueueSchema)
+
+		err = cursor.Decode(q)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode: %w", err)
+		}
+
+		qs = append(qs, *q)
+	}
+
+	return qs, cursor.Err()
+}
+
+// DeadLetterRetry moves domain d from the dead-letter collection back to the durable queue with zero attempts.
+//
+// If d is invalid, returns fault.ErrInvalidDomain.
+// If d is not in the dead-letter collection, returns fault.ErrNotFound.
+func DeadLetterRetry(ctx context.Context, d string) error {
+
+	if !validator.Domain(d) {
+		return fault.ErrInvalidDomain
+	}
+
+	q := new(QueueSchema)
+
+	err := DeadLetter.FindOne(ctx, bson.M{"domain": dns.Clean(d)}).Decode(q)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return fault.ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to find: %w", err)
+	}
+
+	err = QueueAdd(ctx, q.Domain, q.Type, q.Priority)
+	if err != nil {
+		return fmt.Errorf("failed to add to queue: %w", err)
+	}
+
+	return DeadLetterDelete(ctx, q.Domain)
+}
+
+// DeadLetterDelete removes domain d from the dead-letter collection.
+//
+// If d is invalid, returns fault.ErrInvalidDomain.
+// If d is not in the dead-letter collection, returns fault.ErrNotFound.
+func DeadLetterDelete(ctx context.Context, d string) error {
+
+	if !validator.Domain(d) {
+		return fault.ErrInvalidDomain
+	}
+
+	res, err := DeadLetter.DeleteOne(ctx, bson.M{"domain": dns.Clean(d)})
+	if err != nil {
+		return err
+	}
+
+	if res.DeletedCount == 0 {
+		return fault.ErrNotFound
+	}
+
+	return nil
+}
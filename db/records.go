@@ -4,32 +4,137 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"os"
+	"slices"
 	"time"
 
 	"github.com/elmasy-com/columbus/fault"
 	"github.com/elmasy-com/elnet/dns"
 	"github.com/elmasy-com/elnet/validator"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-// Record is the schema used to store a record in Domain
+// Record is the schema used to store a record in Domain.
+//
+// Value is the presentation format of the record (eg.: "10 mail.example.com." for MX).
+// Priority, Target and Params are the structured fields of the record, set only if the type has them:
+//   - Priority: the preference of MX, the priority of SRV, HTTPS and SVCB, the order of NAPTR
+//   - Target: the target of CNAME, DNAME, MX, NS, SOA (primary NS), SRV, HTTPS, SVCB and NAPTR (replacement), the queried name of TLSA
+//   - Params: every other field (eg.: "alpn" for HTTPS, "port" for SRV, "digest" for DS)
+//
+// FirstSeen is the time when the record first found, LastSeen is the time when the record last found.
+// Time is the same as LastSeen, kept for compatibility.
+// Removed is the time when a refresh first not returned the record, 0 if the record is still served.
+// Removals is the history of the previous removals, a removed record is restored if a later query returns it again.
+// The records stored before FirstSeen and LastSeen has only Time, use First() and Last().
+//
+// IP is the sortable key of the address in A and AAAA records used by DomainsReverse(), set by RecordsInsert().
+// TargetKey is the reversed target of CNAME, MX and NS records used by DomainsReverseTarget(), set by RecordsInsert().
 type Record struct {
-	Type  uint16 `bson:"type" json:"type"`
-	Value string `bson:"value" json:"value"`
-	Time  int64  `bson:"time" json:"time"`
+	Type      uint16            `bson:"type" json:"type"`
+	Value     string            `bson:"value" json:"value"`
+	Time      int64             `bson:"time" json:"time"`
+	FirstSeen int64             `bson:"firstSeen,omitempty" json:"firstSeen,omitempty"`
+	LastSeen  int64             `bson:"lastSeen,omitempty" json:"lastSeen,omitempty"`
+	Removed   int64             `bson:"removed,omitempty" json:"removed,omitempty"`
+	Removals  []RecordRemoval   `bson:"removals,omitempty" json:"removals,omitempty"`
+	Priority  uint16            `bson:"priority,omitempty" json:"priority,omitempty"`
+	Target    string            `bson:"target,omitempty" json:"target,omitempty"`
+	Params    map[string]string `bson:"params,omitempty" json:"params,omitempty"`
+	IP        string            `bson:"ip,omitempty" json:"-"`
+	TargetKey string            `bson:"targetKey,omitempty" json:"-"`
+}
+
+// RecordRemoval is a previous removal of a record that was restored later.
+type RecordRemoval struct {
+	Removed  int64 `bson:"removed" json:"removed"`   // Time when the record removed
+	Restored int64 `bson:"restored" json:"restored"` // Time when the record found again
+}
+
+// AddedIn returns whether r first found or restored between since and until (inclusive).
+func (r *Record) AddedIn(since int64, until int64) bool {
+
+	if f := r.First(); f >= since && f <= until {
+		return true
+	}
+
+	for i := range r.Removals {
+		if r.Removals[i].Restored >= since && r.Removals[i].Restored <= until {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RemovedIn returns whether r removed between since and until (inclusive), including the restored removals.
+func (r *Record) RemovedIn(since int64, until int64) bool {
+
+	if r.Removed >= since && r.Removed <= until && r.Removed != 0 {
+		return true
+	}
+
+	for i := range r.Removals {
+		if r.Removals[i].Removed >= since && r.Removals[i].Removed <= until {
+			return true
+		}
+	}
+
+	return false
+}
+
+// First returns the time when r first found.
+// If FirstSeen is not set, returns Time.
+func (r *Record) First() int64 {
+
+	if r.FirstSeen == 0 {
+		return r.Time
+	}
+
+	return r.FirstSeen
 }
 
-// RecordsInsert insert (if not exist) or updates the "date" field for record with "type" t and "value" v.
+// Last returns the time when r last found.
+// If LastSeen is not set, returns Time.
+func (r *Record) Last() int64 {
+
+	if r.LastSeen == 0 {
+		return r.Time
+	}
+
+	return r.LastSeen
+}
+
+// recordsContains returns whether a record with type t and value v is in rs.
+func recordsContains(rs []Record, t uint16, v string) bool {
+
+	for i := range rs {
+		if rs[i].Type == t && rs[i].Value == v {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RecordsInsert insert (if not exist) or updates the "time" and "lastSeen" fields for record r with the same "type" and "value".
 // This function updates the "updated" field to the current time with DomainsUpdateUpdatedTime().
-// If the same record found, updates the "time" field in element.
+// If the same record found, updates the "time", "lastSeen" and the structured fields in element.
+// If the element was removed, the removal is appended to "removals" with the restore time and "removed" is cleared.
+// If "firstSeen" is missing in element (stored by a previous version), it is set to the old "time".
 // If new record found, append it to the "records" field.
 //
-// Returns whether record with type t and value is a new record.
+// The Time, FirstSeen, LastSeen, Removed and Removals fields of r is ignored, the current time is used.
+// The IP and TargetKey fields of r are set from the value of the record.
+//
+// Returns whether record r is a new or restored record.
+// If r is new or restored, emits an EventNewRecord event.
+// A refreshed record emits no event, call RecordsRefreshed() after the inserts.
 //
 // If domain d is invalid, returns fault.ErrInvalidDomain.
 // If failed to get parts of d (eg.: d is a TLD), returns fault.ErrGetPartsFailed.
-func RecordsInsert(d string, t uint16, v string) (bool, error) {
+func RecordsInsert(ctx context.Context, d string, r Record) (bool, error) {
 
 	if !validator.Domain(d) {
 		return false, fault.ErrInvalidDomain
@@ -45,51 +150,183 @@ func RecordsInsert(d string, t uint16, v string) (bool, error) {
 	// If MatchedCount is 0, the record with "type" t and "value" r[i] is new and the new record will be appended to the array.
 	// If MatchedCount is 1, only one record is exist with "type" t and "value" v and the time for the element is updated.
 	// If MatchedCount is > 1, duplicate record found, ERROR!
-	filter := bson.D{{Key: "domain", Value: p.Domain}, {Key: "tld", Value: p.TLD}, {Key: "sub", Value: p.Sub}, {Key: "records.type", Value: t}, {Key: "records.value", Value: v}}
+	filter := bson.D{{Key: "domain", Value: p.Domain}, {Key: "tld", Value: p.TLD}, {Key: "sub", Value: p.Sub}, {Key: "records", Value: bson.M{"$elemMatch": bson.M{"type": r.Type, "value": r.Value}}}}
 
-	up := bson.D{{Key: "$set", Value: bson.D{{Key: "records.$.time", Value: time.Now().Unix()}}}}
+	r.Time = time.Now().Unix()
+	r.FirstSeen = r.Time
+	r.LastSeen = r.Time
+	r.Removed = 0
+	r.Removals = nil
+	r.IP = recordIP(r)
+	r.TargetKey = recordTargetKey(r)
 
-	result, err := Domains.UpdateOne(context.TODO(), filter, up)
-	if err != nil {
+	// The update is a pipeline to read the old "time" of the element.
+	// Values are wrapped in $literal, a value starting with "$" (eg.: TXT) is not a field path.
+	set := bson.D{
+		{Key: "time", Value: r.Time},
+		{Key: "lastSeen", Value: r.LastSeen},
+		{Key: "firstSeen", Value: bson.M{"$ifNull": bson.A{"$$this.firstSeen", "$$this.time"}}},
+		// Keep the removal in the history, the field is missing (not set) if the element is not removed
+		{Key: "removals", Value: bson.M{"$cond": bson.A{
+			bson.M{"$gt": bson.A{"$$this.removed", 0}},
+			bson.M{"$concatArrays": bson.A{
+				bson.M{"$ifNull": bson.A{"$$this.removals", bson.A{}}},
+				bson.A{bson.M{"removed": "$$this.removed", "restored": r.Time}},
+			}},
+			"$$this.removals",
+		}}},
+	}
+
+	// Fill the structured fields of the records stored before these fields
+	if r.Priority != 0 {
+		set = append(set, bson.E{Key: "priority", Value: r.Priority})
+	}
+	if r.Target != "" {
+		set = append(set, bson.E{Key: "target", Value: bson.M{"$literal": r.Target}})
+	}
+	if len(r.Params) > 0 {
+		set = append(set, bson.E{Key: "params", Value: bson.M{"$literal": r.Params}})
+	}
+	if r.IP != "" {
+		set = append(set, bson.E{Key: "ip", Value: r.IP})
+	}
+	if r.TargetKey != "" {
+		set = append(set, bson.E{Key: "targetKey", Value: r.TargetKey})
+	}
+
+	// Merge set into the element and remove the "removed" field
+	elem := bson.M{"$arrayToObject": bson.M{"$filter": bson.M{
+		"input": bson.M{"$objectToArray": bson.M{"$mergeObjects": bson.A{"$$this", set}}},
+		"as":    "kv",
+		"cond":  bson.M{"$ne": bson.A{"$$kv.k", "removed"}},
+	}}}
+
+	match := bson.M{"$and": bson.A{
+		bson.M{"$eq": bson.A{"$$this.type", r.Type}},
+		bson.M{"$eq": bson.A{"$$this.value", bson.M{"$literal": r.Value}}},
+	}}
+
+	up := bson.A{bson.M{"$set": bson.M{"records": bson.M{"$map": bson.M{
+		"input": "$records",
+		"in":    bson.M{"$cond": bson.A{match, elem, "$$this"}},
+	}}}}}
+
+	// The element before the update is returned to know whether it was removed
+	opts := options.FindOneAndUpdate().
+		SetReturnDocument(options.Before).
+		SetProjection(bson.M{"records": bson.M{"$elemMatch": bson.M{"type": r.Type, "value": r.Value}}})
+
+	var old Domain
+
+	err := Domains.FindOneAndUpdate(ctx, filter, up, opts).Decode(&old)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
 		return false, err
 	}
 
-	if result.MatchedCount == 1 {
-		return false, DomainsUpdateUpdatedTime(d)
+	if err == nil {
+
+		restored := len(old.Records) == 1 && old.Records[0].Removed != 0
+
+		if restored {
+			eventEmit(ctx, EventNewRecord, d, &r)
+		}
+
+		return restored, DomainsUpdateUpdatedTime(ctx, d)
 	}
 
 	// Append new record to "records"
 	filter = bson.D{{Key: "domain", Value: p.Domain}, {Key: "tld", Value: p.TLD}, {Key: "sub", Value: p.Sub}}
 
-	up = bson.D{{Key: "$addToSet", Value: bson.D{{Key: "records", Value: Record{Type: t, Value: v, Time: time.Now().Unix()}}}}}
+	push := bson.D{{Key: "$addToSet", Value: bson.D{{Key: "records", Value: r}}}}
 
-	result, err = Domains.UpdateOne(context.TODO(), filter, up)
+	result, err := Domains.UpdateOne(ctx, filter, push)
 	if err != nil {
 		return false, err
 	}
 
-	return result.ModifiedCount == 1, DomainsUpdateUpdatedTime(d)
+	if result.ModifiedCount == 1 {
+		eventEmit(ctx, EventNewRecord, d, &r)
+	}
+
+	return result.ModifiedCount == 1, DomainsUpdateUpdatedTime(ctx, d)
+}
+
+// recordsMarkRemoved sets the "removed" field to the current time for every record of domain d
+// that has a type in types, is not in found and not removed yet.
+//
+// types must be the list of the successfully queried types, the records of a failed type are unknown.
+// Emits an EventRemovedRecord event for every removed record.
+// If d is not in the database, does nothing.
+func recordsMarkRemoved(ctx context.Context, d string, found []Record, types []uint16) error {
+
+	if len(types) == 0 {
+		return nil
+	}
+
+	p := dns.GetParts(dns.Clean(d))
+	if p == nil || p.Domain == "" || p.TLD == "" {
+		return fault.ErrGetPartsFailed
+	}
+
+	filter := bson.D{{Key: "domain", Value: p.Domain}, {Key: "tld", Value: p.TLD}, {Key: "sub", Value: p.Sub}}
+
+	dom := new(Domain)
+
+	err := Domains.FindOne(ctx, filter, options.FindOne().SetProjection(bson.M{"records": 1})).Decode(dom)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to find: %w", err)
+	}
+
+	now := time.Now().Unix()
+
+	for i := range dom.Records {
+
+		r := dom.Records[i]
+
+		if r.Removed != 0 || !slices.Contains(types, r.Type) || recordsContains(found, r.Type, r.Value) {
+			continue
+		}
+
+		f := append(filter[:len(filter):len(filter)], bson.E{Key: "records", Value: bson.M{"$elemMatch": bson.M{"type": r.Type, "value": r.Value}}})
+
+		_, err = Domains.UpdateOne(ctx, f, bson.M{"$set": bson.M{"records.$.removed": now}})
+		if err != nil {
+			return fmt.Errorf("failed to update %s %s: %w", RecordTypeString(r.Type), r.Value, err)
+		}
+
+		r.Removed = now
+		eventEmit(ctx, EventRemovedRecord, d, &r)
+	}
+
+	return nil
 }
 
 // RecordsUpdate updates the records field for domain d if d is not update recently (in the previous hour).
+// Emits an EventRefreshedFQDN event after the update (see RecordsRefreshed()).
 // This function updates the "updated" field to the current time and the records in the database.
 // If the same record found, updates the "time" field in element.
 // If new record found, append it to the "records" field.
+// If a stored record is not returned anymore, sets the "removed" field in element (NXDOMAIN removes every record).
+// Schedules the next refresh of d in the "nextUpdate" field.
 //
 // Checks if d is a wildcard record before update.
 //
-// This function ignores the common DNS errors.
+// This function ignores NXDOMAIN.
+// If the DNS servers failed to answer, returns ErrDNSFailure.
 // If ignoreUpdated is true, ignore when was the last update based on the "updated" timestamp.
 //
 // If domain d is invalid, returns fault.ErrInvalidDomain.
 // If failed to get parts of d (eg.: d is a TLD), returns fault.ErrGetPartsFailed.
-func RecordsUpdate(d string, ignoreUpdated bool) error {
+func RecordsUpdate(ctx context.Context, d string, ignoreUpdated bool) error {
 
 	d = dns.Clean(d)
 
 	if !ignoreUpdated {
 
-		updated, err := DomainsUpdatedRecently(d)
+		updated, err := DomainsUpdatedRecently(ctx, d)
 		if err != nil {
 			return fmt.Errorf("failed to check if %s is updated recently: %w", d, err)
 		}
@@ -99,17 +336,34 @@ func RecordsUpdate(d string, ignoreUpdated bool) error {
 		}
 	}
 
-	records, err := dns.QueryAll(d)
-	if err != nil && !errors.Is(err, dns.ErrName) && !errors.Is(err, dns.ErrServerFailure) &&
-		!os.IsTimeout(err) && !errors.Is(err, dns.ErrRefused) {
+	records, types, err := queryRecords(ctx, d)
+	if err != nil && !errors.Is(err, dns.ErrName) {
+
+		// The context is done (eg.: the client disconnected), not a DNS failure
+		if ctx.Err() != nil {
+			return err
+		}
 
-		// Ignore common errors
+		// NXDOMAIN is not an error, every other error means that the DNS servers failed to answer (eg.: SERVFAIL, timeout)
 
-		return fmt.Errorf("failed to update: %w", err)
+		return fmt.Errorf("%w: %s", ErrDNSFailure, err)
+	}
+
+	err = recordsMarkRemoved(ctx, d, records, types)
+	if err != nil {
+		return fmt.Errorf("failed to mark removed records: %w", err)
 	}
 
 	if len(records) == 0 {
-		return DomainsUpdateUpdatedTime(d)
+
+		err = DomainsUpdateUpdatedTime(ctx, d)
+		if err != nil {
+			return err
+		}
+
+		RecordsRefreshed(ctx, d)
+
+		return domainsScheduleNext(ctx, d)
 	}
 
 	for i := range records {
@@ -119,11 +373,21 @@ func RecordsUpdate(d string, ignoreUpdated bool) error {
 			continue
 		}
 
-		_, err := RecordsInsert(d, records[i].Type, records[i].Value)
+		_, err := RecordsInsert(ctx, d, records[i])
 		if err != nil {
 			return fmt.Errorf("failed to insert record: %w", err)
 		}
 	}
 
-	return nil
+	RecordsRefreshed(ctx, d)
+
+	return domainsScheduleNext(ctx, d)
+}
+
+// RecordsRefreshed emits an EventRefreshedFQDN event for FQDN d.
+// The times of the records and the updated time are changed by every refresh without other event,
+// the event invalidates the lookup caches of the apex (see CacheInvalidateWorker()).
+// Call it once after the RecordsInsert() calls of d.
+func RecordsRefreshed(ctx context.Context, d string) {
+	eventEmit(ctx, EventRefreshedFQDN, d, nil)
 }
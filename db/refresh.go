@@ -0,0 +1,247 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/elmasy-com/columbus/fault"
+	"github.com/elmasy-com/elnet/dns"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	// RefreshBase is the refresh interval of a resolving FQDN with stable records in a not popular apex.
+	RefreshBase = 30 * 24 * time.Hour
+
+	// RefreshMin is the minimum refresh interval.
+	// Should not be less than the interval used in DomainsUpdatedRecently().
+	RefreshMin = 12 * time.Hour
+
+	// RefreshMax is the maximum refresh interval.
+	RefreshMax = 90 * 24 * time.Hour
+
+	// refreshLease is the "nextUpdate" set when the FQDN is sent to the updater.
+	// If the update fails or the server stops, the FQDN is refreshed again after the lease.
+	refreshLease = 24 * time.Hour
+)
+
+// refreshInterval returns the time until the next refresh of a FQDN.
+//
+// popularity is the number of lookups of the apex domain (the "count" in the "topList" collection),
+// the interval is halved for every order of magnitude.
+// changes is the number of record changes in the last RefreshBase, the interval is divided by changes+1.
+// If resolved is false (the FQDN has no served record), the interval is doubled.
+func refreshInterval(popularity int, changes int, resolved bool) time.Duration {
+
+	i := RefreshBase
+
+	if !resolved {
+		i *= 2
+	}
+
+	for n := popularity; n > 0; n /= 10 {
+		i /= 2
+	}
+
+	i /= time.Duration(changes + 1)
+
+	if i < RefreshMin {
+		i = RefreshMin
+	}
+	if i > RefreshMax {
+		i = RefreshMax
+	}
+
+	return i
+}
+
+// recordsChanges returns the number of records in rs added or removed after since.
+// The records first seen at the same time as the oldest record is not a change (eg.: the records of a new FQDN).
+func recordsChanges(rs []Record, since int64) int {
+
+	var first int64
+
+	for i := range rs {
+		if f := rs[i].First(); first == 0 || f < first {
+			first = f
+		}
+	}
+
+	n := 0
+
+	for i := range rs {
+
+		if rs[i].Removed > since {
+			n++
+		}
+
+		if f := rs[i].First(); f > since && f > first {
+			n++
+		}
+
+		// A flapping record is volatile
+		for _, rm := range rs[i].Removals {
+			if rm.Removed > since {
+				n++
+			}
+			if rm.Restored > since {
+				n++
+			}
+		}
+	}
+
+	return n
+}
+
+// recordsResolved returns whether rs contains a record that is not removed.
+func recordsResolved(rs []Record) bool {
+
+	for i := range rs {
+		if rs[i].Removed == 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// domainsScheduleNext sets the "nextUpdate" field of FQDN d based on the popularity of the apex,
+// the volatility of the records and whether d resolved.
+//
+// If d is not in the database, does nothing.
+func domainsScheduleNext(ctx context.Context, d string) error {
+
+	d = dns.Clean(d)
+
+	p := dns.GetParts(d)
+	if p == nil || p.Domain == "" || p.TLD == "" {
+		return fault.ErrGetPartsFailed
+	}
+
+	filter := bson.D{{Key: "domain", Value: p.Domain}, {Key: "tld", Value: p.TLD}, {Key: "sub", Value: p.Sub}}
+
+	dom := new(Domain)
+
+	err := Domains.FindOne(ctx, filter, options.FindOne().SetProjection(bson.M{"records": 1})).Decode(dom)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to find domain: %w", err)
+	}
+
+	top := new(TopListSchema)
+
+	err = TopList.FindOne(ctx, bson.M{"domain": p.Domain + "." + p.TLD}).Decode(top)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return fmt.Errorf("failed to find toplist: %w", err)
+	}
+
+	now := time.Now()
+
+	i := refreshInterval(top.Count, recordsChanges(dom.Records, now.Add(-RefreshBase).Unix()), recordsResolved(dom.Records))
+
+	_, err = Domains.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"nextUpdate": now.Add(i).Unix()}})
+
+	return err
+}
+
+// domainsClaimRefresh returns up to n FQDN that are due to refresh in "nextUpdate" order.
+// The FQDNs without "nextUpdate" (stored by a previous version) are returned first.
+// The "nextUpdate" of the returned FQDNs is set to refreshLease.
+func domainsClaimRefresh(ctx context.Context, n int64) ([]string, error) {
+
+	now := time.Now()
+
+	opts := options.Find().SetSort(bson.M{"nextUpdate": 1}).SetLimit(n).SetProjection(bson.M{"domain": 1, "tld": 1, "sub": 1})
+
+	// $not + $gt matches the missing fields too
+	cursor, err := Domains.Find(ctx, bson.M{"nextUpdate": bson.M{"$not": bson.M{"$gt": now.Unix()}}}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var (
+		ds  []string
+		ids bson.A
+	)
+
+	for cursor.Next(ctx) {
+
+		var d struct {
+			ID         interface{} `bson:"_id"`
+			FastDomain `bson:",inline"`
+		}
+
+		err = cursor.Decode(&d)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode: %w", err)
+		}
+
+		ds = append(ds, d.String())
+		ids = append(ids, d.ID)
+	}
+
+	if err = cursor.Err(); err != nil {
+		return nil, fmt.Errorf("cursor failed: %w", err)
+	}
+
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	_, err = Domains.UpdateMany(ctx, bson.M{"_id": bson.M{"$in": ids}}, bson.M{"$set": bson.M{"nextUpdate": now.Add(refreshLease).Unix()}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to set lease: %w", err)
+	}
+
+	return ds, nil
+}
+
+// refreshScheduler is a function created to run as goroutine in the background.
+// Sends the FQDNs due to refresh into UpdaterQueue in "nextUpdate" order.
+// Paused while the UpdaterQueue is more than half full.
+func refreshScheduler(ctx context.Context, wg *sync.WaitGroup) {
+
+	defer wg.Done()
+
+	for {
+
+		for UpdaterQueue.Len() > updaterLimit {
+			if !sleepContext(ctx, time.Second) {
+				return
+			}
+		}
+
+		ds, err := domainsClaimRefresh(ctx, 100)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			slog.Error("updater: failed to claim the domains to refresh", "error", err)
+			// Wait before the next try
+			if !sleepContext(ctx, 60*time.Second) {
+				return
+			}
+			continue
+		}
+
+		if len(ds) == 0 {
+			if !sleepContext(ctx, 60*time.Second) {
+				return
+			}
+			continue
+		}
+
+		for i := range ds {
+			UpdaterQueue.Push(UpdateableDomain{Domain: ds[i], Type: UpdateExistingDomain}, PriorityRefresh)
+		}
+	}
+}
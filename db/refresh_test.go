@@ -0,0 +1,66 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRefreshInterval(t *testing.T) {
+
+	if i := refreshInterval(0, 0, true); i != RefreshBase {
+		t.Fatalf("stable FQDN: want %s, got %s", RefreshBase, i)
+	}
+
+	if i := refreshInterval(0, 0, false); i != 2*RefreshBase {
+		t.Fatalf("not resolved FQDN: want %s, got %s", 2*RefreshBase, i)
+	}
+
+	if a, b := refreshInterval(10, 0, true), refreshInterval(1000, 0, true); a <= b {
+		t.Fatalf("popular apex is not refreshed more often: %s <= %s", a, b)
+	}
+
+	if a, b := refreshInterval(0, 0, true), refreshInterval(0, 3, true); a <= b {
+		t.Fatalf("volatile FQDN is not refreshed more often: %s <= %s", a, b)
+	}
+
+	if i := refreshInterval(1000000, 100, true); i != RefreshMin {
+		t.Fatalf("want minimum %s, got %s", RefreshMin, i)
+	}
+}
+
+func TestRecordsChanges(t *testing.T) {
+
+	rs := []Record{
+		{Type: 1, Value: "192.0.2.1", FirstSeen: 100, Removed: 300},
+		{Type: 1, Value: "192.0.2.2", FirstSeen: 100},
+		{Type: 1, Value: "192.0.2.3", FirstSeen: 300},
+		{Type: 16, Value: "legacy", Time: 50},
+	}
+
+	// The removal at 300 and the new record at 300
+	if n := recordsChanges(rs, 200); n != 2 {
+		t.Fatalf("want 2 changes, got %d", n)
+	}
+
+	// The records of a new FQDN are not changes
+	if n := recordsChanges(rs[1:2], 0); n != 0 {
+		t.Fatalf("want 0 changes, got %d", n)
+	}
+}
+
+func TestDNSBudget(t *testing.T) {
+
+	if w := newDNSBudget(0).reserve(1000); w != 0 {
+		t.Fatalf("unlimited budget waits %s", w)
+	}
+
+	b := newDNSBudget(10)
+
+	if w := b.reserve(10); w != 0 {
+		t.Fatalf("full bucket waits %s", w)
+	}
+
+	if w := b.reserve(10); w < 900*time.Millisecond || w > time.Second {
+		t.Fatalf("empty bucket: want ~1s, got %s", w)
+	}
+}
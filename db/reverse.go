@@ -0,0 +1,374 @@
+package db
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net/netip"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/elmasy-com/columbus/fault"
+	"github.com/elmasy-com/elnet/dns"
+	"github.com/elmasy-com/elnet/validator"
+	mdns "github.com/miekg/dns"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// The widest prefix accepted by DomainsReverse() for IPv4 and IPv6
+	ReverseMinBitsIPv4 = 8
+	ReverseMinBitsIPv6 = 32
+)
+
+// ReverseTargetTypes are the record types searchable by DomainsReverseTarget().
+var ReverseTargetTypes = []uint16{mdns.TypeCNAME, mdns.TypeMX, mdns.TypeNS}
+
+// ReverseSchema is a FQDN returned by DomainsReverse() with the matching records.
+type ReverseSchema struct {
+	Domain  string   `json:"domain"`
+	Records []Record `json:"records"`
+}
+
+// ipKey returns the sortable key of ip stored in the "ip" field of A and AAAA records.
+// The key is the hex encoded 16 byte form of ip, IPv4 addresses are mapped to IPv6 (::ffff:a.b.c.d).
+// The lexical order of the keys is the same as the numerical order of the addresses.
+func ipKey(ip netip.Addr) string {
+
+	b := ip.As16()
+
+	return hex.EncodeToString(b[:])
+}
+
+// recordIP returns the key of the address in the A or AAAA record r.
+// Returns an empty string if r is not an A/AAAA record or the value is not a valid address.
+func recordIP(r Record) string {
+
+	if r.Type != mdns.TypeA && r.Type != mdns.TypeAAAA {
+		return ""
+	}
+
+	ip, err := netip.ParseAddr(r.Value)
+	if err != nil {
+		return ""
+	}
+
+	return ipKey(ip.Unmap())
+}
+
+// reverseName returns the labels of name in reverse order (eg.: "www.example.com." -> "com.example.www").
+// The name is lowercased and the trailing dot is removed.
+func reverseName(name string) string {
+
+	if name == "" {
+		return ""
+	}
+
+	labels := strings.Split(dns.Clean(name), ".")
+
+	for i, j := 0, len(labels)-1; i < j; i, j = i+1, j-1 {
+		labels[i], labels[j] = labels[j], labels[i]
+	}
+
+	return strings.Join(labels, ".")
+}
+
+// recordTargetKey returns the key of the target stored in the "targetKey" field of CNAME, MX and NS records.
+// The records stored before the Target field are parsed from the Value.
+// Returns an empty string if the type of r is not in ReverseTargetTypes.
+func recordTargetKey(r Record) string {
+
+	if !slices.Contains(ReverseTargetTypes, r.Type) {
+		return ""
+	}
+
+	t := r.Target
+
+	if t == "" {
+
+		t = r.Value
+
+		// Value is "<preference> <target>"
+		if r.Type == mdns.TypeMX {
+			fs := strings.Fields(t)
+			if len(fs) != 2 {
+				return ""
+			}
+			t = fs[1]
+		}
+	}
+
+	// The root (eg.: null MX) is not a target
+	if t == "" || t == "." {
+		return ""
+	}
+
+	return reverseName(t)
+}
+
+// prefixRange returns the key of the first and the last address in prefix p.
+func prefixRange(p netip.Prefix) (string, string) {
+
+	p = p.Masked()
+
+	first := p.Addr().As16()
+	last := first
+
+	// Host bits in the 16 byte form
+	bits := p.Bits()
+	if p.Addr().Is4() {
+		bits += 96
+	}
+
+	for i := bits; i < 128; i++ {
+		last[i/8] |= 1 << (7 - i%8)
+	}
+
+	return hex.EncodeToString(first[:]), hex.EncodeToString(last[:])
+}
+
+// ParsePrefix parses s as an IP address or a CIDR (eg.: "203.0.113.1" or "203.0.113.0/24").
+// An address is parsed as a single address prefix (/32 or /128).
+//
+// If s is invalid or the prefix is wider than ReverseMinBitsIPv4/ReverseMinBitsIPv6, returns fault.ErrInvalidIP.
+func ParsePrefix(s string) (netip.Prefix, error) {
+
+	var (
+		p   netip.Prefix
+		err error
+	)
+
+	if ip, aerr := netip.ParseAddr(s); aerr == nil {
+
+		// Zones are not stored in records
+		if ip.Zone() != "" {
+			return netip.Prefix{}, fault.ErrInvalidIP
+		}
+
+		ip = ip.Unmap()
+		p = netip.PrefixFrom(ip, ip.BitLen())
+	} else {
+		p, err = netip.ParsePrefix(s)
+		if err != nil {
+			return netip.Prefix{}, fault.ErrInvalidIP
+		}
+	}
+
+	if p.Addr().Is4() && p.Bits() < ReverseMinBitsIPv4 {
+		return netip.Prefix{}, fault.ErrInvalidIP
+	}
+
+	if p.Addr().Is6() && p.Bits() < ReverseMinBitsIPv6 {
+		return netip.Prefix{}, fault.ErrInvalidIP
+	}
+
+	return p, nil
+}
+
+// DomainsReverse returns the FQDNs that has an A or AAAA record with an address in prefix p.
+// Only the matching records are returned in ReverseSchema.
+//
+// days specify, that the matching record must be found in the previous n days.
+// If days is 0 or -1, returns every record regardless of the time.
+// skip and limit are used to paginate the FQDNs, the FQDNs are in the order of the index of the matching records (not sorted).
+// The removed records are not returned.
+//
+// If days if < -1, returns fault.ErrInvalidDays.
+func DomainsReverse(ctx context.Context, p netip.Prefix, days int, skip int64, limit int64) ([]ReverseSchema, error) {
+
+	first, last := prefixRange(p)
+
+	match := bson.M{"ip": bson.M{"$gte": first, "$lte": last}}
+
+	return domainsReverseFind(ctx, match, func(r *Record) bool { return r.IP >= first && r.IP <= last }, days, skip, limit)
+}
+
+// DomainsReverseTarget returns the FQDNs that has a record with type t pointing to target or to a subdomain of target
+// (eg.: "herokudns.com" matches "example.herokudns.com").
+// A leading wildcard label is ignored in target (eg.: "*.herokudns.com" is the same as "herokudns.com").
+// Only the matching records are returned in ReverseSchema.
+//
+// days, skip and limit are the same as in DomainsReverse().
+//
+// If t is not in ReverseTargetTypes, returns fault.ErrInvalidType.
+// If target is invalid, returns fault.ErrInvalidDomain.
+// If days if < -1, returns fault.ErrInvalidDays.
+func DomainsReverseTarget(ctx context.Context, t uint16, target string, days int, skip int64, limit int64) ([]ReverseSchema, error) {
+
+	if !slices.Contains(ReverseTargetTypes, t) {
+		return nil, fault.ErrInvalidType
+	}
+
+	target = strings.TrimPrefix(target, "*.")
+
+	if !validator.Domain(target) {
+		return nil, fault.ErrInvalidDomain
+	}
+
+	key := reverseName(target)
+
+	// The anchored regex with a literal prefix can use the index
+	match := bson.M{"type": t, "targetKey": bson.M{"$regex": "^" + regexp.QuoteMeta(key) + `(\.|$)`}}
+
+	return domainsReverseFind(ctx, match, func(r *Record) bool {
+		return r.Type == t && (r.TargetKey == key || strings.HasPrefix(r.TargetKey, key+"."))
+	}, days, skip, limit)
+}
+
+// domainsReverseFind returns the FQDNs that has a record matching the $elemMatch query match.
+// The records are filtered with keep, that must do the same as match.
+// The removed records are skipped, the result is paginated with skip and limit.
+// The result is not sorted, sorting every match in memory is too expensive on the multikey index.
+func domainsReverseFind(ctx context.Context, match bson.M, keep func(r *Record) bool, days int, skip int64, limit int64) ([]ReverseSchema, error) {
+
+	if days < -1 {
+		return nil, fault.ErrInvalidDays
+	}
+
+	var cutoff int64
+
+	if days > 0 {
+		cutoff = time.Now().AddDate(0, 0, -1*days).Unix()
+		match["time"] = bson.M{"$gt": cutoff}
+	}
+
+	match["removed"] = bson.M{"$exists": false}
+
+	cursor, err := Domains.Find(ctx, bson.M{"records": bson.M{"$elemMatch": match}}, options.Find().SetSkip(skip).SetLimit(limit))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	rs := make([]ReverseSchema, 0)
+
+	for cursor.Next(ctx) {
+
+		d := new(Domain)
+
+		err = cursor.Decode(d)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode: %w", err)
+		}
+
+		r := ReverseSchema{Domain: d.String()}
+
+		for i := range d.Records {
+
+			if d.Records[i].Time <= cutoff || d.Records[i].Removed != 0 || !keep(&d.Records[i]) {
+				continue
+			}
+
+			r.Records = append(r.Records, d.Records[i])
+		}
+
+		rs = append(rs, r)
+	}
+
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("cursor failed: %w", err)
+	}
+
+	return rs, nil
+}
+
+// migrateRecordsIP creates the index of the "records.ip" field and sets the "ip" field of the A and AAAA records stored before it.
+func migrateRecordsIP(ctx context.Context) error {
+
+	_, err := Domains.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: bson.D{{Key: "records.ip", Value: 1}}})
+	if err != nil {
+		return fmt.Errorf("failed to create index: %w", err)
+	}
+
+	filter := bson.M{"records": bson.M{"$elemMatch": bson.M{
+		"type": bson.M{"$in": bson.A{mdns.TypeA, mdns.TypeAAAA}},
+		"ip":   bson.M{"$exists": false},
+	}}}
+
+	cursor, err := Domains.Find(ctx, filter)
+	if err != nil {
+		return fmt.Errorf("failed to find: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+
+		d := new(Domain)
+
+		err = cursor.Decode(d)
+		if err != nil {
+			return fmt.Errorf("failed to decode: %w", err)
+		}
+
+		for i := range d.Records {
+
+			key := recordIP(d.Records[i])
+			if key == "" || d.Records[i].IP != "" {
+				continue
+			}
+
+			_, err = Domains.UpdateOne(ctx,
+				bson.M{"domain": d.Domain, "tld": d.TLD, "sub": d.Sub},
+				bson.M{"$set": bson.M{"records.$[r].ip": key}},
+				options.Update().SetArrayFilters(options.ArrayFilters{Filters: bson.A{bson.M{"r.type": d.Records[i].Type, "r.value": d.Records[i].Value}}}))
+			if err != nil {
+				return fmt.Errorf("failed to update %s: %w", d.String(), err)
+			}
+		}
+	}
+
+	return cursor.Err()
+}
+
+// migrateRecordsTarget creates the index of the "records.type" and "records.targetKey" fields and sets the "targetKey" field
+// of the CNAME, MX and NS records stored before it.
+func migrateRecordsTarget(ctx context.Context) error {
+
+	_, err := Domains.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: bson.D{{Key: "records.type", Value: 1}, {Key: "records.targetKey", Value: 1}}})
+	if err != nil {
+		return fmt.Errorf("failed to create index: %w", err)
+	}
+
+	filter := bson.M{"records": bson.M{"$elemMatch": bson.M{
+		"type":      bson.M{"$in": ReverseTargetTypes},
+		"targetKey": bson.M{"$exists": false},
+	}}}
+
+	cursor, err := Domains.Find(ctx, filter)
+	if err != nil {
+		return fmt.Errorf("failed to find: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+
+		d := new(Domain)
+
+		err = cursor.Decode(d)
+		if err != nil {
+			return fmt.Errorf("failed to decode: %w", err)
+		}
+
+		for i := range d.Records {
+
+			key := recordTargetKey(d.Records[i])
+			if key == "" || d.Records[i].TargetKey != "" {
+				continue
+			}
+
+			_, err = Domains.UpdateOne(ctx,
+				bson.M{"domain": d.Domain, "tld": d.TLD, "sub": d.Sub},
+				bson.M{"$set": bson.M{"records.$[r].targetKey": key}},
+				options.Update().SetArrayFilters(options.ArrayFilters{Filters: bson.A{bson.M{"r.type": d.Records[i].Type, "r.value": d.Records[i].Value}}}))
+			if err != nil {
+				return fmt.Errorf("failed to update %s: %w", d.String(), err)
+			}
+		}
+	}
+
+	return cursor.Err()
+}
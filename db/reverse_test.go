@@ -0,0 +1,94 @@
+package db
+
+import (
+	"net/netip"
+	"testing"
+
+	mdns "github.com/miekg/dns"
+)
+
+func TestPrefixRange(t *testing.T) {
+
+	cases := []struct {
+		prefix string
+		first  string
+		last   string
+	}{
+		{"203.0.113.0/24", "00000000000000000000ffffcb007100", "00000000000000000000ffffcb0071ff"},
+		{"203.0.113.7/32", "00000000000000000000ffffcb007107", "00000000000000000000ffffcb007107"},
+		{"2001:db8::/32", "20010db8000000000000000000000000", "20010db8ffffffffffffffffffffffff"},
+	}
+
+	for i := range cases {
+
+		first, last := prefixRange(netip.MustParsePrefix(cases[i].prefix))
+
+		if first != cases[i].first || last != cases[i].last {
+			t.Fatalf("FAIL: %s: want %s-%s, got %s-%s\n", cases[i].prefix, cases[i].first, cases[i].last, first, last)
+		}
+	}
+}
+
+func TestRecordIPInRange(t *testing.T) {
+
+	first, last := prefixRange(netip.MustParsePrefix("203.0.113.0/24"))
+
+	for _, v := range []string{"203.0.113.0", "203.0.113.128", "203.0.113.255"} {
+
+		key := recordIP(Record{Type: mdns.TypeA, Value: v})
+
+		if key < first || key > last {
+			t.Fatalf("FAIL: %s is not in range\n", v)
+		}
+	}
+
+	for _, v := range []string{"203.0.112.255", "203.0.114.0"} {
+
+		key := recordIP(Record{Type: mdns.TypeA, Value: v})
+
+		if key >= first && key <= last {
+			t.Fatalf("FAIL: %s is in range\n", v)
+		}
+	}
+
+	if key := recordIP(Record{Type: mdns.TypeCNAME, Value: "203.0.113.1"}); key != "" {
+		t.Fatalf("FAIL: CNAME has key %s\n", key)
+	}
+}
+
+func TestParsePrefix(t *testing.T) {
+
+	for _, s := range []string{"203.0.113.1", "203.0.113.0/24", "2001:db8::1", "2001:db8::/48"} {
+		if _, err := ParsePrefix(s); err != nil {
+			t.Fatalf("FAIL: %s: %s\n", s, err)
+		}
+	}
+
+	for _, s := range []string{"", "example.com", "203.0.113.0/33", "0.0.0.0/0", "2001:db8::/16", "fe80::1%eth0"} {
+		if _, err := ParsePrefix(s); err == nil {
+			t.Fatalf("FAIL: %s: want error\n", s)
+		}
+	}
+}
+
+func TestRecordTargetKey(t *testing.T) {
+
+	cases := []struct {
+		r    Record
+		want string
+	}{
+		{Record{Type: mdns.TypeCNAME, Value: "App.HerokuDNS.com.", Target: "App.HerokuDNS.com."}, "com.herokudns.app"},
+		{Record{Type: mdns.TypeCNAME, Value: "example.herokudns.com."}, "com.herokudns.example"},
+		{Record{Type: mdns.TypeMX, Value: "10 mx1.example.com.", Priority: 10, Target: "mx1.example.com."}, "com.example.mx1"},
+		{Record{Type: mdns.TypeMX, Value: "10 mx1.example.com."}, "com.example.mx1"},
+		{Record{Type: mdns.TypeMX, Value: "0 .", Target: "."}, ""},
+		{Record{Type: mdns.TypeNS, Value: "ns1.example.net."}, "net.example.ns1"},
+		{Record{Type: mdns.TypeA, Value: "203.0.113.1"}, ""},
+	}
+
+	for i := range cases {
+		if got := recordTargetKey(cases[i].r); got != cases[i].want {
+			t.Fatalf("FAIL: %s: want %q, got %q\n", cases[i].r.Value, cases[i].want, got)
+		}
+	}
+}
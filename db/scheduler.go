@@ -0,0 +1,255 @@
+package db
+
+import (
+	"sync"
+
+	"github.com/elmasy-com/elnet/dns"
+)
+
+// UpdatePriority is the priority class of an UpdateableDomain in the Scheduler.
+// Lower value means higher priority.
+type UpdatePriority uint8
+
+const (
+	PriorityInsert  UpdatePriority = iota // Domains submitted by users (eg.: /api/insert)
+	PriorityLookup                        // Domains returned by a lookup (eg.: /api/lookup)
+	PriorityRefresh                       // Domains refreshed in the background
+	numPriorities
+)
+
+// String returns the name of the priority class.
+func (p UpdatePriority) String() string {
+
+	switch p {
+	case PriorityInsert:
+		return "insert"
+	case PriorityLookup:
+		return "lookup"
+	case PriorityRefresh:
+		return "refresh"
+	default:
+		return "unknown"
+	}
+}
+
+// schedulerEntry is a pending domain in the Scheduler.
+// The entry is shared between the classes when promoted, the class holding an entry with an other priority skips it.
+type schedulerEntry struct {
+	dom      UpdateableDomain
+	priority UpdatePriority
+}
+
+// schedulerClass is a priority class with a queue for every apex domain.
+// The apex domains are served in round-robin.
+type schedulerClass struct {
+	apexes []string
+	queues map[string][]*schedulerEntry
+	next   int
+}
+
+func (c *schedulerClass) push(apex string, e *schedulerEntry) {
+
+	q, ok := c.queues[apex]
+	if !ok {
+		c.apexes = append(c.apexes, apex)
+	}
+
+	c.queues[apex] = append(q, e)
+}
+
+// pop returns the first entry of the next apex.
+// Returns nil if the class is empty.
+func (c *schedulerClass) pop() *schedulerEntry {
+
+	if len(c.apexes) == 0 {
+		return nil
+	}
+
+	if c.next >= len(c.apexes) {
+		c.next = 0
+	}
+
+	apex := c.apexes[c.next]
+	q := c.queues[apex]
+
+	e := q[0]
+	q[0] = nil
+	q = q[1:]
+
+	if len(q) == 0 {
+		// Remove the apex, the next one is at the same index
+		delete(c.queues, apex)
+		c.apexes = append(c.apexes[:c.next], c.apexes[c.next+1:]...)
+	} else {
+		c.queues[apex] = q
+		c.next++
+	}
+
+	return e
+}
+
+// Scheduler is a bounded queue of UpdateableDomain with priority classes.
+//
+// The classes are served in strict order (PriorityInsert, PriorityLookup and than PriorityRefresh).
+// Inside a class, the apex domains are served in round-robin, so one huge zone cant starve the others.
+// The pending domains are de-duplicated: pushing a domain already pending does nothing, except if the new priority is higher,
+// than the domain is promoted to the higher class.
+type Scheduler struct {
+	m       sync.Mutex
+	cond    *sync.Cond
+	classes [numPriorities]schedulerClass
+	pending map[string]*schedulerEntry
+	limit   int
+	closed  bool
+}
+
+// NewScheduler creates a new Scheduler that can hold up to limit pending domains.
+func NewScheduler(limit int) *Scheduler {
+
+	s := &Scheduler{
+		pending: make(map[string]*schedulerEntry),
+		limit:   limit,
+	}
+
+	s.cond = sync.NewCond(&s.m)
+
+	for i := range s.classes {
+		s.classes[i].queues = make(map[string][]*schedulerEntry)
+	}
+
+	return s
+}
+
+// Push adds d to the queue with priority p.
+//
+// Returns true if d is queued or already pending.
+// Returns false if the queue is full or closed.
+// PriorityInsert is accepted even if the queue is full, user submitted domains should not be dropped.
+func (s *Scheduler) Push(d UpdateableDomain, p UpdatePriority) bool {
+
+	if d.Domain == "" {
+		return false
+	}
+
+	if p >= numPriorities {
+		p = PriorityRefresh
+	}
+
+	d.Domain = dns.Clean(d.Domain)
+
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if s.closed {
+		return false
+	}
+
+	if e, ok := s.pending[d.Domain]; ok {
+
+		// InsertNewDomain is stronger than UpdateExistingDomain
+		if d.Type < e.dom.Type {
+			e.dom.Type = d.Type
+		}
+
+		// Keep track of the durable queue to ack/nack the item
+		if d.Queued {
+			e.dom.Queued = true
+		}
+
+		if p < e.priority {
+			e.priority = p
+			s.classes[p].push(dns.GetDomain(d.Domain), e)
+			s.cond.Signal()
+		}
+
+		return true
+	}
+
+	if len(s.pending) >= s.limit && p != PriorityInsert {
+		return false
+	}
+
+	e := &schedulerEntry{dom: d, priority: p}
+
+	s.pending[d.Domain] = e
+	s.classes[p].push(dns.GetDomain(d.Domain), e)
+	s.cond.Signal()
+
+	return true
+}
+
+// Pop returns the next domain to update.
+// Blocks until a domain is available.
+//
+// Returns false if the Scheduler is closed.
+func (s *Scheduler) Pop() (UpdateableDomain, bool) {
+
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	for {
+
+		if s.closed {
+			return UpdateableDomain{}, false
+		}
+
+		for p := range s.classes {
+
+			for e := s.classes[p].pop(); e != nil; e = s.classes[p].pop() {
+
+				// The entry is promoted to an other class
+				if e.priority != UpdatePriority(p) {
+					continue
+				}
+
+				delete(s.pending, e.dom.Domain)
+
+				e.dom.Priority = e.priority
+
+				return e.dom, true
+			}
+		}
+
+		s.cond.Wait()
+	}
+}
+
+// Close closes the Scheduler.
+// The pending domains are dropped and Pop() returns false.
+func (s *Scheduler) Close() {
+
+	s.m.Lock()
+	s.closed = true
+	s.m.Unlock()
+
+	s.cond.Broadcast()
+}
+
+// Len returns the number of pending domains.
+func (s *Scheduler) Len() int {
+
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	return len(s.pending)
+}
+
+// Depths returns the number of pending domains in every priority class.
+// The key is the name of the class (eg.: "insert").
+func (s *Scheduler) Depths() map[string]int {
+
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	ds := make(map[string]int, numPriorities)
+
+	for i := range s.classes {
+		ds[UpdatePriority(i).String()] = 0
+	}
+
+	for _, e := range s.pending {
+		ds[e.priority.String()]++
+	}
+
+	return ds
+}
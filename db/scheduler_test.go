@@ -0,0 +1,90 @@
+package db
+
+import (
+	"testing"
+)
+
+func TestSchedulerPriority(t *testing.T) {
+
+	s := NewScheduler(10)
+
+	s.Push(UpdateableDomain{Domain: "refresh.example.com", Type: UpdateExistingDomain}, PriorityRefresh)
+	s.Push(UpdateableDomain{Domain: "lookup.example.com", Type: UpdateExistingDomain}, PriorityLookup)
+	s.Push(UpdateableDomain{Domain: "insert.example.com", Type: InsertNewDomain}, PriorityInsert)
+
+	for _, want := range []string{"insert.example.com", "lookup.example.com", "refresh.example.com"} {
+
+		d, ok := s.Pop()
+		if !ok {
+			t.Fatalf("FAIL: Pop() returned false\n")
+		}
+
+		if d.Domain != want {
+			t.Fatalf("FAIL: want %s, got %s\n", want, d.Domain)
+		}
+	}
+}
+
+func TestSchedulerDeduplicate(t *testing.T) {
+
+	s := NewScheduler(10)
+
+	s.Push(UpdateableDomain{Domain: "www.example.com", Type: UpdateExistingDomain}, PriorityRefresh)
+	s.Push(UpdateableDomain{Domain: "WWW.example.com.", Type: UpdateExistingDomain}, PriorityRefresh)
+	s.Push(UpdateableDomain{Domain: "www.example.com", Type: InsertNewDomain}, PriorityInsert)
+
+	if s.Len() != 1 {
+		t.Fatalf("FAIL: want 1 pending, got %d\n", s.Len())
+	}
+
+	if ds := s.Depths(); ds["insert"] != 1 || ds["refresh"] != 0 {
+		t.Fatalf("FAIL: invalid depths: %v\n", ds)
+	}
+
+	d, _ := s.Pop()
+	if d.Domain != "www.example.com" || d.Type != InsertNewDomain {
+		t.Fatalf("FAIL: invalid domain: %#v\n", d)
+	}
+
+	s.Close()
+
+	if _, ok := s.Pop(); ok {
+		t.Fatalf("FAIL: Pop() returned the stale entry of the promoted domain\n")
+	}
+}
+
+func TestSchedulerFairness(t *testing.T) {
+
+	s := NewScheduler(100)
+
+	for _, d := range []string{"a.huge.com", "b.huge.com", "c.huge.com", "d.huge.com", "www.small.com", "www.other.com"} {
+		s.Push(UpdateableDomain{Domain: d, Type: UpdateExistingDomain}, PriorityRefresh)
+	}
+
+	want := []string{"a.huge.com", "www.small.com", "www.other.com", "b.huge.com", "c.huge.com", "d.huge.com"}
+
+	for i := range want {
+
+		d, _ := s.Pop()
+		if d.Domain != want[i] {
+			t.Fatalf("FAIL: %d: want %s, got %s\n", i, want[i], d.Domain)
+		}
+	}
+}
+
+func TestSchedulerLimit(t *testing.T) {
+
+	s := NewScheduler(1)
+
+	if !s.Push(UpdateableDomain{Domain: "a.example.com"}, PriorityRefresh) {
+		t.Fatalf("FAIL: first push failed\n")
+	}
+
+	if s.Push(UpdateableDomain{Domain: "b.example.com"}, PriorityLookup) {
+		t.Fatalf("FAIL: push succeeded on a full queue\n")
+	}
+
+	if !s.Push(UpdateableDomain{Domain: "c.example.com"}, PriorityInsert) {
+		t.Fatalf("FAIL: PriorityInsert dropped on a full queue\n")
+	}
+}
@@ -0,0 +1,614 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/elmasy-com/columbus/fault"
+	"github.com/elmasy-com/elnet/dns"
+	mdns "github.com/miekg/dns"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	SearchMaxLength = 256 // Maximum length of a query
+	SearchMaxTerms  = 8   // Maximum number of terms in a query
+	SearchMinPrefix = 3   // Minimum length of the literal prefix of a wildcard domain (eg.: "exa*")
+)
+
+// searchExpr is a node in the parsed query.
+//
+// If op is "term", field, cmp and value are set.
+// The field is empty for a hostname pattern (eg.: "*.dev.*.example.com").
+// Otherwise, children are the operands of op ("and", "or", "not").
+type searchExpr struct {
+	op       string
+	children []*searchExpr
+	field    string
+	cmp      byte
+	value    string
+}
+
+// searchParser is a recursive descent parser of the query language:
+//
+//	query  = or
+//	or     = and { "OR" and }
+//	and    = unary { ["AND"] unary }
+//	unary  = "NOT" unary | "(" or ")" | term
+//	term   = pattern | field ":" value | field ">" value | field "<" value
+type searchParser struct {
+	tokens []string
+	pos    int
+	terms  int
+}
+
+// searchTokenize splits q into tokens.
+// Parenthesis are separate tokens, values can be quoted with double quotes (eg.: value:"v=spf1 -all").
+func searchTokenize(q string) ([]string, error) {
+
+	var (
+		tokens []string
+		cur    strings.Builder
+		quoted bool
+	)
+
+	for _, c := range q {
+
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case quoted:
+			cur.WriteRune(c)
+		case unicode.IsSpace(c) || c == '(' || c == ')':
+			if cur.Len() > 0 {
+				tokens = append(tokens, cur.String())
+				cur.Reset()
+			}
+			if c == '(' || c == ')' {
+				tokens = append(tokens, string(c))
+			}
+		default:
+			cur.WriteRune(c)
+		}
+	}
+
+	if quoted {
+		return nil, fmt.Errorf("%w: unterminated quote", fault.ErrInvalidQuery)
+	}
+
+	if cur.Len() > 0 {
+		tokens = append(tokens, cur.String())
+	}
+
+	return tokens, nil
+}
+
+func (p *searchParser) peek() string {
+
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+
+	return p.tokens[p.pos]
+}
+
+func (p *searchParser) parseOr() (*searchExpr, error) {
+
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	e := &searchExpr{op: "or", children: []*searchExpr{left}}
+
+	for p.peek() == "OR" {
+
+		p.pos++
+
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+
+		e.children = append(e.children, right)
+	}
+
+	if len(e.children) == 1 {
+		return left, nil
+	}
+
+	return e, nil
+}
+
+func (p *searchParser) parseAnd() (*searchExpr, error) {
+
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	e := &searchExpr{op: "and", children: []*searchExpr{left}}
+
+	for {
+
+		t := p.peek()
+
+		if t == "" || t == ")" || t == "OR" {
+			break
+		}
+
+		if t == "AND" {
+			p.pos++
+		}
+
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		e.children = append(e.children, right)
+	}
+
+	if len(e.children) == 1 {
+		return left, nil
+	}
+
+	return e, nil
+}
+
+func (p *searchParser) parseUnary() (*searchExpr, error) {
+
+	t := p.peek()
+
+	switch t {
+	case "":
+		return nil, fmt.Errorf("%w: unexpected end of query", fault.ErrInvalidQuery)
+	case "NOT":
+		p.pos++
+		e, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &searchExpr{op: "not", children: []*searchExpr{e}}, nil
+	case "(":
+		p.pos++
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("%w: missing )", fault.ErrInvalidQuery)
+		}
+		p.pos++
+		return e, nil
+	case ")", "AND", "OR":
+		return nil, fmt.Errorf("%w: unexpected %s", fault.ErrInvalidQuery, t)
+	}
+
+	p.pos++
+
+	p.terms++
+	if p.terms > SearchMaxTerms {
+		return nil, fmt.Errorf("%w: more than %d terms", fault.ErrQueryTooExpensive, SearchMaxTerms)
+	}
+
+	return parseSearchTerm(t)
+}
+
+// parseSearchTerm parses a single term (eg.: "tld:com", "updated>2023-01-01" or "*.example.com").
+func parseSearchTerm(t string) (*searchExpr, error) {
+
+	i := strings.IndexAny(t, ":<>")
+	if i < 0 {
+		return &searchExpr{op: "term", value: t}, nil
+	}
+
+	e := &searchExpr{op: "term", field: strings.ToLower(t[:i]), cmp: t[i], value: t[i+1:]}
+
+	if e.value == "" {
+		return nil, fmt.Errorf("%w: empty value for %s", fault.ErrInvalidQuery, e.field)
+	}
+
+	switch e.field {
+	case "domain", "tld", "sub", "type", "value":
+		if e.cmp != ':' {
+			return nil, fmt.Errorf("%w: %s supports only ':'", fault.ErrInvalidQuery, e.field)
+		}
+	case "updated", "seen":
+		if e.cmp == ':' {
+			return nil, fmt.Errorf("%w: %s supports only '<' and '>'", fault.ErrInvalidQuery, e.field)
+		}
+	default:
+		return nil, fmt.Errorf("%w: unknown field %q", fault.ErrInvalidQuery, e.field)
+	}
+
+	return e, nil
+}
+
+// searchPattern returns the anchored regex of the wildcard pattern p and the length of the literal prefix.
+// A label that is "*" matches exactly one label, a "*" inside a label matches any characters except dot.
+func searchPattern(p string) (string, int) {
+
+	var b strings.Builder
+
+	b.WriteString("^")
+
+	for i, l := range strings.Split(p, ".") {
+
+		if i > 0 {
+			b.WriteString(`\.`)
+		}
+
+		if l == "*" {
+			b.WriteString(`[^.]+`)
+		} else {
+			for j, part := range strings.Split(l, "*") {
+				if j > 0 {
+					b.WriteString(`[^.]*`)
+				}
+				b.WriteString(regexp.QuoteMeta(part))
+			}
+		}
+	}
+
+	b.WriteString("$")
+
+	return b.String(), strings.IndexByte(p, '*')
+}
+
+// searchMatch returns the filter of field matching the pattern v and whether the filter can use the index.
+// If v has no wildcard, it is an exact match and always anchored regardless of the length (eg.: "hp").
+// A wildcard pattern is anchored if the literal prefix is at least SearchMinPrefix long.
+func searchMatch(v string) (interface{}, bool) {
+
+	if !strings.Contains(v, "*") {
+		return v, true
+	}
+
+	re, prefix := searchPattern(v)
+
+	return bson.M{"$regex": re}, prefix >= SearchMinPrefix
+}
+
+// ParseTime parses v as a date ("2006-01-02"), a RFC3339 time, a Unix timestamp or a relative time in days/hours (eg.: "30d", "12h").
+// The relative time is subtracted from now.
+func ParseTime(v string) (int64, error) {
+
+	if t, err := time.Parse("2006-01-02", v); err == nil {
+		return t.Unix(), nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t.Unix(), nil
+	}
+
+	if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return n, nil
+	}
+
+	if len(v) > 1 {
+
+		n, err := strconv.Atoi(v[:len(v)-1])
+
+		if err == nil && n >= 0 {
+			switch v[len(v)-1] {
+			case 'd':
+				return time.Now().AddDate(0, 0, -n).Unix(), nil
+			case 'h':
+				return time.Now().Add(-time.Duration(n) * time.Hour).Unix(), nil
+			}
+		}
+	}
+
+	return 0, fmt.Errorf("%w: invalid time %q", fault.ErrInvalidQuery, v)
+}
+
+// compileHostname compiles the hostname pattern p (eg.: "*.dev.*.example.com") to a filter on the domain, tld and sub fields.
+// Returns whether the filter is anchored by the domain field.
+func compileHostname(p string) (bson.M, bool, error) {
+
+	if p == "" || p == "." {
+		return nil, false, fmt.Errorf("%w: empty hostname", fault.ErrInvalidQuery)
+	}
+
+	p = dns.Clean(p)
+
+	labels := strings.Split(p, ".")
+
+	// The longest suffix without wildcard
+	i := len(labels)
+	for i > 0 && !strings.Contains(labels[i-1], "*") {
+		i--
+	}
+
+	if i == len(labels) {
+		return nil, false, fmt.Errorf("%w: the TLD of %q is a wildcard, use domain: instead", fault.ErrInvalidQuery, p)
+	}
+
+	parts := dns.GetParts(strings.Join(labels[i:], "."))
+	if parts == nil || parts.TLD == "" {
+		return nil, false, fmt.Errorf("%w: invalid hostname %q", fault.ErrInvalidQuery, p)
+	}
+
+	var (
+		domain   interface{}
+		anchored bool
+		subs     []string
+	)
+
+	if parts.Domain != "" {
+
+		domain, anchored = parts.Domain, true
+
+		subs = labels[:i]
+		if parts.Sub != "" {
+			subs = append(subs, parts.Sub)
+		}
+
+	} else {
+
+		// The suffix is the TLD, the domain is the label with the wildcard
+		if i == 0 {
+			return nil, false, fmt.Errorf("%w: invalid hostname %q", fault.ErrInvalidQuery, p)
+		}
+
+		domain, anchored = searchMatch(labels[i-1])
+		subs = labels[:i-1]
+	}
+
+	filter := bson.M{"domain": domain, "tld": parts.TLD}
+
+	if len(subs) > 0 {
+		filter["sub"], _ = searchMatch(strings.Join(subs, "."))
+	} else {
+		filter["sub"] = ""
+	}
+
+	return filter, anchored, nil
+}
+
+// compileRecord returns the condition of the record term e used in $elemMatch.
+func compileRecord(e *searchExpr) (bson.M, error) {
+
+	switch e.field {
+	case "type":
+
+		t, ok := mdns.StringToType[strings.ToUpper(e.value)]
+		if !ok {
+			n, err := strconv.ParseUint(e.value, 10, 16)
+			if err != nil {
+				return nil, fmt.Errorf("%w: unknown record type %q", fault.ErrInvalidQuery, e.value)
+			}
+			t = uint16(n)
+		}
+
+		return bson.M{"type": t}, nil
+
+	case "value":
+
+		v, _ := searchMatch(e.value)
+
+		return bson.M{"value": v}, nil
+
+	default:
+
+		t, err := ParseTime(e.value)
+		if err != nil {
+			return nil, err
+		}
+
+		if e.cmp == '>' {
+			return bson.M{"time": bson.M{"$gt": t}}, nil
+		}
+
+		return bson.M{"time": bson.M{"$lt": t}}, nil
+	}
+}
+
+// isRecordTerm returns whether e is a condition on a record.
+func (e *searchExpr) isRecordTerm() bool {
+	return e.op == "term" && (e.field == "type" || e.field == "value" || e.field == "seen")
+}
+
+// compile returns the Mongo filter of e and whether the filter is anchored by the domain field (can use the index).
+func (e *searchExpr) compile() (bson.M, bool, error) {
+
+	switch e.op {
+	case "and":
+
+		var (
+			fs       bson.A
+			records  bson.A
+			anchored bool
+		)
+
+		// The record conditions in an AND must match the same record
+		for i := range e.children {
+
+			if e.children[i].isRecordTerm() {
+
+				c, err := compileRecord(e.children[i])
+				if err != nil {
+					return nil, false, err
+				}
+
+				records = append(records, c)
+
+				continue
+			}
+
+			f, a, err := e.children[i].compile()
+			if err != nil {
+				return nil, false, err
+			}
+
+			fs = append(fs, f)
+			anchored = anchored || a
+		}
+
+		if len(records) > 0 {
+			fs = append(fs, bson.M{"records": bson.M{"$elemMatch": bson.M{"$and": records}}})
+		}
+
+		return bson.M{"$and": fs}, anchored, nil
+
+	case "or":
+
+		fs := make(bson.A, 0, len(e.children))
+		anchored := true
+
+		for i := range e.children {
+
+			f, a, err := e.children[i].compile()
+			if err != nil {
+				return nil, false, err
+			}
+
+			fs = append(fs, f)
+			anchored = anchored && a
+		}
+
+		return bson.M{"$or": fs}, anchored, nil
+
+	case "not":
+
+		f, _, err := e.children[0].compile()
+		if err != nil {
+			return nil, false, err
+		}
+
+		return bson.M{"$nor": bson.A{f}}, false, nil
+	}
+
+	switch e.field {
+	case "":
+		return compileHostname(e.value)
+	case "domain":
+		m, anchored := searchMatch(strings.ToLower(e.value))
+		return bson.M{"domain": m}, anchored, nil
+	case "tld", "sub":
+		m, _ := searchMatch(strings.ToLower(e.value))
+		return bson.M{e.field: m}, false, nil
+	case "updated":
+		t, err := ParseTime(e.value)
+		if err != nil {
+			return nil, false, err
+		}
+		if e.cmp == '>' {
+			return bson.M{"updated": bson.M{"$gt": t}}, false, nil
+		}
+		return bson.M{"updated": bson.M{"$lt": t}}, false, nil
+	default:
+		c, err := compileRecord(e)
+		if err != nil {
+			return nil, false, err
+		}
+		return bson.M{"records": bson.M{"$elemMatch": c}}, false, nil
+	}
+}
+
+// ParseSearch parses the query q and returns the Mongo filter.
+//
+// The query is a list of terms joined by AND (implicit), OR and NOT, terms can be grouped with parenthesis:
+//   - hostname pattern: "*.dev.*.example.com", a "*" label matches exactly one label, "*" inside a label matches any characters except dot
+//   - domain:, tld:, sub: matches the part of the hostname, "*" can be used as a wildcard
+//   - type: matches the record type (eg.: "type:A", "type:28")
+//   - value: matches the record value, "*" can be used as a wildcard
+//   - updated>, updated<: the time of the last update
+//   - seen>, seen<: the time when the record last seen
+//
+// The time can be a date (eg.: "2023-01-02"), a RFC3339 time, a Unix timestamp or relative in days/hours (eg.: "30d", "12h").
+// The record conditions (type, value, seen) in the same AND must match the same record.
+//
+// Every branch of the query must restrict the domain to use the index (eg.: "example.com", "domain:hp", "domain:exa*"),
+// a wildcard domain must have a literal prefix of at least SearchMinPrefix characters,
+// otherwise returns fault.ErrQueryTooExpensive.
+// If q is invalid, returns fault.ErrInvalidQuery.
+func ParseSearch(q string) (bson.M, error) {
+
+	if len(q) > SearchMaxLength {
+		return nil, fmt.Errorf("%w: longer than %d characters", fault.ErrQueryTooExpensive, SearchMaxLength)
+	}
+
+	tokens, err := searchTokenize(q)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("%w: empty query", fault.ErrInvalidQuery)
+	}
+
+	p := &searchParser{tokens: tokens}
+
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("%w: unexpected %s", fault.ErrInvalidQuery, p.peek())
+	}
+
+	filter, anchored, err := e.compile()
+	if err != nil {
+		return nil, err
+	}
+
+	if !anchored {
+		return nil, fmt.Errorf("%w: every branch must restrict the domain (eg.: example.com or domain:exa*)", fault.ErrQueryTooExpensive)
+	}
+
+	return filter, nil
+}
+
+// DomainsSearch returns the hostnames matching the query q (see ParseSearch()).
+// skip and limit are used to paginate the result, the hostnames are sorted by domain, tld and sub so the index supplies the order and the pages are stable.
+//
+// If q is invalid, returns fault.ErrInvalidQuery.
+// If q is too expensive, returns fault.ErrQueryTooExpensive.
+func DomainsSearch(ctx context.Context, q string, skip int64, limit int64) ([]string, error) {
+
+	filter, err := ParseSearch(q)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := options.Find().
+		SetProjection(bson.M{"domain": 1, "tld": 1, "sub": 1}).
+		SetSort(bson.D{{Key: "domain", Value: 1}, {Key: "tld", Value: 1}, {Key: "sub", Value: 1}}).
+		SetSkip(skip).
+		SetLimit(limit)
+
+	cursor, err := Domains.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	ds := make([]string, 0)
+
+	for cursor.Next(ctx) {
+
+		var d FastDomain
+
+		err = cursor.Decode(&d)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode: %w", err)
+		}
+
+		ds = append(ds, d.String())
+	}
+
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("cursor failed: %w", err)
+	}
+
+	return ds, nil
+}
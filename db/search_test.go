@@ -0,0 +1,105 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/elmasy-com/columbus/fault"
+)
+
+func TestParseSearch(t *testing.T) {
+
+	cases := []struct {
+		query string
+		want  string
+	}{
+		{"example.com", `map[domain:example sub: tld:com]`},
+		{"www.example.co.uk", `map[domain:example sub:www tld:co.uk]`},
+		{"*.dev.*.example.com", `map[domain:example sub:map[$regex:^[^.]+\.dev\.[^.]+$] tld:com]`},
+		{"exa*.com", `map[domain:map[$regex:^exa[^.]*$] sub: tld:com]`},
+		{"domain:example tld:com", `map[$and:[map[domain:example] map[tld:com]]]`},
+		{"domain:exa.mple", `map[domain:exa.mple]`},
+		{"example.com OR example.net", `map[$or:[map[domain:example sub: tld:com] map[domain:example sub: tld:net]]]`},
+		{"domain:example NOT tld:com", `map[$and:[map[domain:example] map[$nor:[map[tld:com]]]]]`},
+		{"domain:example type:A value:203.0.113.*", `map[$and:[map[domain:example] map[records:map[$elemMatch:map[$and:[map[type:1] map[value:map[$regex:^203\.0\.113\.[^.]+$]]]]]]]]`},
+		{"domain:example updated>1700000000", `map[$and:[map[domain:example] map[updated:map[$gt:1700000000]]]]`},
+		{"domain:example AND (sub:www OR sub:mail)", `map[$and:[map[domain:example] map[$or:[map[sub:www] map[sub:mail]]]]]`},
+		{`domain:example value:"v=spf1 -all"`, `map[$and:[map[domain:example] map[records:map[$elemMatch:map[$and:[map[value:v=spf1 -all]]]]]]]`},
+	}
+
+	for i := range cases {
+
+		f, err := ParseSearch(cases[i].query)
+		if err != nil {
+			t.Fatalf("FAIL: %s: %s\n", cases[i].query, err)
+		}
+
+		if got := fmt.Sprint(f); got != cases[i].want {
+			t.Fatalf("FAIL: %s:\nwant %s\n got %s\n", cases[i].query, cases[i].want, got)
+		}
+	}
+}
+
+// TestParseSearchShortApex tests that the exact short apex domains are anchored regardless of SearchMinPrefix.
+func TestParseSearchShortApex(t *testing.T) {
+
+	cases := []struct {
+		query string
+		want  string
+	}{
+		{"x.com", `map[domain:x sub: tld:com]`},
+		{"t.co", `map[domain:t sub: tld:co]`},
+		{"hp.com", `map[domain:hp sub: tld:com]`},
+		{"*.hp.com", `map[domain:hp sub:map[$regex:^[^.]+$] tld:com]`},
+		{"domain:fb", `map[domain:fb]`},
+		{"domain:fb OR x.com", `map[$or:[map[domain:fb] map[domain:x sub: tld:com]]]`},
+	}
+
+	for i := range cases {
+
+		f, err := ParseSearch(cases[i].query)
+		if err != nil {
+			t.Fatalf("FAIL: %s: %s\n", cases[i].query, err)
+		}
+
+		if got := fmt.Sprint(f); got != cases[i].want {
+			t.Fatalf("FAIL: %s:\nwant %s\n got %s\n", cases[i].query, cases[i].want, got)
+		}
+	}
+}
+
+func TestParseSearchInvalid(t *testing.T) {
+
+	cases := []struct {
+		query string
+		want  error
+	}{
+		{"", fault.ErrInvalidQuery},
+		{"example.*", fault.ErrInvalidQuery},
+		{"domain:example foo:bar", fault.ErrInvalidQuery},
+		{"domain:example updated:2023-01-01", fault.ErrInvalidQuery},
+		{"domain:example updated>yesterday", fault.ErrInvalidQuery},
+		{"domain:example type:NOPE", fault.ErrInvalidQuery},
+		{"(domain:example", fault.ErrInvalidQuery},
+		{"domain:example)", fault.ErrInvalidQuery},
+		{`domain:example value:"unterminated`, fault.ErrInvalidQuery},
+		{"domain:example OR", fault.ErrInvalidQuery},
+		{"tld:com", fault.ErrQueryTooExpensive},
+		{"type:A", fault.ErrQueryTooExpensive},
+		{"domain:ex*", fault.ErrQueryTooExpensive},
+		{"x*.com", fault.ErrQueryTooExpensive},
+		{"*.co.uk", fault.ErrQueryTooExpensive},
+		{"NOT example.com", fault.ErrQueryTooExpensive},
+		{"example.com OR tld:net", fault.ErrQueryTooExpensive},
+		{"a.com b.com c.com d.com e.com f.com g.com h.com i.com", fault.ErrQueryTooExpensive},
+	}
+
+	for i := range cases {
+
+		_, err := ParseSearch(cases[i].query)
+		if !errors.Is(err, cases[i].want) {
+			t.Fatalf("FAIL: %q: want %v, got %v\n", cases[i].query, cases[i].want, err)
+		}
+	}
+}
@@ -3,8 +3,8 @@ package db
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"math/rand"
-	"os"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
@@ -14,7 +14,7 @@ import (
 type StatisticSchema struct {
 	Date    int64         `bson:"date" json:"date"`
 	Total   int64         `bson:"total" json:"total"`
-	Updated int64         `bsn:"updated" json:"updated"`
+	Updated int64         `bson:"updated" json:"updated"`
 	Valid   int64         `bson:"valid" json:"valid"`
 	CTLogs  []CTLogSchema `bson:"ctlogs" json:"ctlogs"`
 }
@@ -24,93 +24,93 @@ const (
 )
 
 // StatisticsCountTotal returns the total number of entries in "domain" collection.
-func StatisticsCountTotal() (int64, error) {
+func StatisticsCountTotal(ctx context.Context) (int64, error) {
 
-	return Domains.CountDocuments(context.TODO(), bson.M{})
+	return Domains.CountDocuments(ctx, bson.M{})
 }
 
 // StatisticsCountUpdated returns the total number of entries that updated in "domain" collection.
-func StatisticsCountUpdated() (int64, error) {
+func StatisticsCountUpdated(ctx context.Context) (int64, error) {
 
-	return Domains.CountDocuments(context.TODO(), bson.M{"updated": bson.M{"$exists": true}})
+	return Domains.CountDocuments(ctx, bson.M{"updated": bson.M{"$exists": true}})
 }
 
 // StatisticsCountValid returns the total number of entries that has at least on valid record in the "records" field in "domain" collection.
-func StatisticsCountValid() (int64, error) {
+func StatisticsCountValid(ctx context.Context) (int64, error) {
 
-	return Domains.CountDocuments(context.TODO(), bson.M{"records": bson.M{"$exists": true}})
+	return Domains.CountDocuments(ctx, bson.M{"records": bson.M{"$exists": true}})
 }
 
 // StatisticsInsert get the stats and insert a new entry in the "statistics" collection.
 //
 // This function is **very** slow!
-func StatisticsInsert() error {
+func StatisticsInsert(ctx context.Context) error {
 
 	s := new(StatisticSchema)
 	var err error
 
-	s.Total, err = StatisticsCountTotal()
+	s.Total, err = StatisticsCountTotal(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to count total: %w", err)
 	}
 
-	s.Updated, err = StatisticsCountUpdated()
+	s.Updated, err = StatisticsCountUpdated(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to count updated: %w", err)
 	}
 
-	s.Valid, err = StatisticsCountValid()
+	s.Valid, err = StatisticsCountValid(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to count valid: %w", err)
 	}
 
-	s.CTLogs, err = CTLogsGets()
+	s.CTLogs, err = CTLogsGets(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to get CT logs: %w", err)
 	}
 
 	s.Date = time.Now().Unix()
 
-	_, err = Statistics.InsertOne(context.TODO(), *s)
+	_, err = Statistics.InsertOne(ctx, *s)
 
 	return err
 }
 
-// StatisticsInsertWorker insert a new Statistic entry at the beginning and at a random time in an infinite loop.
+// StatisticsInsertWorker insert a new Statistic entry at the beginning and at a random time in a loop until ctx is done.
 //
 // This function is designed to run as a goroutine in the background.
-// The errors are printed to STDERR.
-func StatisticsInsertWorker() {
+// The errors are logged.
+func StatisticsInsertWorker(ctx context.Context) {
 
-	err := StatisticsInsert()
+	err := StatisticsInsert(ctx)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to insert new statistic entry: %s\n", err)
+		slog.Error("failed to insert new statistic entry", "error", err)
 	}
 
 	for {
 
-		time.Sleep(time.Duration(rand.Int63n(14400)) * time.Second)
+		if !sleepContext(ctx, time.Duration(rand.Int63n(14400))*time.Second) {
+			return
+		}
 
-		err := StatisticsInsert()
+		err := StatisticsInsert(ctx)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to insert new statistics entry: %s\n", err)
+			slog.Error("failed to insert new statistic entry", "error", err)
 		}
 	}
 }
 
-// StatisticsCleanWorker removes entries beyond MaxStatisticsEntry number.
+// StatisticsCleanWorker removes entries beyond MaxStatisticsEntry number until ctx is done.
 //
 // This function is designed to run as a goroutine in the background.
-// The errors are printed to STDERR.
-func StatisticsCleanWorker() {
-
-	t := time.Tick(300 * time.Second)
+// The errors are logged.
+func StatisticsCleanWorker(ctx context.Context) {
 
-	for range t {
+	for sleepContext(ctx, 300*time.Second) {
 
-		n, err := Statistics.CountDocuments(context.TODO(), bson.M{})
+		n, err := Statistics.CountDocuments(ctx, bson.M{})
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "StatisticsRemoveOldEntries(): Failed to count total statistic entries: %s\n", err)
+			slog.Error("failed to count statistic entries", "error", err)
 			continue
 		}
 
@@ -120,13 +120,13 @@ func StatisticsCleanWorker() {
 
 		i := 0
 
-		cursor, err := Statistics.Find(context.TODO(), bson.M{}, options.Find().SetSort(bson.M{"date": -1}))
+		cursor, err := Statistics.Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"date": -1}))
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "StatisticsRemoveOldEntries(): Failed to find statistic entries: %s\n", err)
+			slog.Error("failed to find statistic entries", "error", err)
 			continue
 		}
 
-		for cursor.Next(context.TODO()) {
+		for cursor.Next(ctx) {
 
 			if i <= MaxStatisticsEntry {
 				i++
@@ -137,48 +137,48 @@ func StatisticsCleanWorker() {
 
 			err = cursor.Decode(s)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "StatisticsRemoveOldEntries(): Failed to decode: %s\n", err)
+				slog.Error("failed to decode statistic entry", "error", err)
 				continue
 			}
 
-			_, err := Statistics.DeleteOne(context.TODO(), *s)
+			_, err := Statistics.DeleteOne(ctx, *s)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "StatisticsRemoveOldEntries(): Failed to remove entry (date: %d, total: %d, updated: %d, valid: %d): %s\n", s.Date, s.Total, s.Updated, s.Valid, err)
+				slog.Error("failed to remove statistic entry", "date", s.Date, "total", s.Total, "updated", s.Updated, "valid", s.Valid, "error", err)
 			}
 		}
 
 		err = cursor.Err()
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "StatisticsRemoveOldEntries(): Cursor failed: %s\n", err)
+			slog.Error("statistic entries cursor failed", "error", err)
 		}
 
-		cursor.Close(context.TODO())
+		cursor.Close(ctx)
 
 	}
 }
 
 // StatisticsGetNewest returns the newest entry from the "statistics" collection.
-func StatisticsGetNewest() (StatisticSchema, error) {
+func StatisticsGetNewest(ctx context.Context) (StatisticSchema, error) {
 
 	s := new(StatisticSchema)
 
-	err := Statistics.FindOne(context.TODO(), bson.M{}, options.FindOne().SetSort(bson.M{"date": -1})).Decode(s)
+	err := Statistics.FindOne(ctx, bson.M{}, options.FindOne().SetSort(bson.M{"date": -1})).Decode(s)
 
 	return *s, err
 }
 
 // StatisticsGets returns every entry in the "statistics".
-func StatisticsGets() ([]StatisticSchema, error) {
+func StatisticsGets(ctx context.Context) ([]StatisticSchema, error) {
 
-	cursor, err := Statistics.Find(context.TODO(), bson.M{}, options.Find().SetSort(bson.M{"date": -1}))
+	cursor, err := Statistics.Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"date": -1}))
 	if err != nil {
 		return nil, fmt.Errorf("failed to find: %w", err)
 	}
-	defer cursor.Close(context.TODO())
+	defer cursor.Close(ctx)
 
 	r := make([]StatisticSchema, 0, MaxStatisticsEntry)
 
-	for cursor.Next(context.TODO()) {
+	for cursor.Next(ctx) {
 
 		s := new(StatisticSchema)
 
@@ -0,0 +1,323 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/elmasy-com/columbus/fault"
+	"github.com/elmasy-com/elnet/dns"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Subscription is the schema used in the "subscriptions" collection.
+//
+// The events of Apex (and every subdomain of it) are sent to URL, signed with Secret.
+// If Events is empty, every event type is sent.
+type Subscription struct {
+	ID      primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Apex    string             `bson:"apex" json:"apex"`
+	URL     string             `bson:"url" json:"url"`
+	Secret  string             `bson:"secret" json:"-"`
+	Events  []EventType        `bson:"events,omitempty" json:"events,omitempty"`
+	Created int64              `bson:"created" json:"created"`
+}
+
+// Wants returns whether s is subscribed to event type t.
+// The internal event types (eg.: EventRefreshedFQDN) are never sent.
+func (s *Subscription) Wants(t EventType) bool {
+
+	if !t.IsValid() {
+		return false
+	}
+
+	if len(s.Events) == 0 {
+		return true
+	}
+
+	for i := range s.Events {
+		if s.Events[i] == t {
+			return true
+		}
+	}
+
+	return false
+}
+
+// DeliveryState is the state of a Delivery.
+type DeliveryState string
+
+const (
+	DeliveryPending   DeliveryState = "pending"   // Waiting for the next attempt
+	DeliveryDelivered DeliveryState = "delivered" // The receiver accepted the event
+	DeliveryFailed    DeliveryState = "failed"    // Every attempt failed or the subscription is removed
+)
+
+// Delivery is the schema used in the "deliveries" collection, one document per event and subscription.
+//
+// The document holds the retry state of the delivery and the log of the attempts.
+// Payload is the delivered event, kept to retry after the event is removed from the "events" collection.
+// A pending delivery is claimed by the dispatcher until LeaseUntil, if the dispatcher stops (eg.: the server restarts) the delivery is claimed again.
+type Delivery struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Subscription primitive.ObjectID `bson:"subscription" json:"subscription"`
+	Event        primitive.ObjectID `bson:"event" json:"event"`
+	Payload      Event              `bson:"payload" json:"-"`
+	State        DeliveryState      `bson:"state" json:"state"`
+	NextAttempt  int64              `bson:"nextAttempt" json:"nextAttempt,omitempty"` // 0 if the delivery is finished
+	LeaseUntil   int64              `bson:"leaseUntil" json:"-"`
+	Attempts     []DeliveryAttempt  `bson:"attempts" json:"attempts"`
+	Created      int64              `bson:"created" json:"created"`
+}
+
+// DeliveryAttempt is the log of a delivery attempt.
+type DeliveryAttempt struct {
+	Attempt  int    `bson:"attempt" json:"attempt"`
+	Status   int    `bson:"status,omitempty" json:"status,omitempty"` // HTTP status code, 0 if the request failed
+	Error    string `bson:"error,omitempty" json:"error,omitempty"`
+	Duration int64  `bson:"duration" json:"duration"` // Milliseconds
+	Time     int64  `bson:"time" json:"time"`
+}
+
+// DeliveryLease is the time while a claimed delivery is hidden from the other dispatchers.
+var DeliveryLease = 5 * time.Minute
+
+// SubscriptionsInsert creates a new subscription for apex domain apex.
+//
+// If apex is invalid or has a subdomain, returns fault.ErrInvalidDomain.
+// If u is not a valid http/https URL, returns fault.ErrInvalidURL.
+// If events contains an unknown type, returns fault.ErrInvalidEvent.
+func SubscriptionsInsert(ctx context.Context, apex string, u string, secret string, events []EventType) (Subscription, error) {
+
+	if !dns.IsValid(apex) {
+		return Subscription{}, fault.ErrInvalidDomain
+	}
+
+	apex = dns.Clean(apex)
+
+	p := dns.GetParts(apex)
+	if p == nil || p.Domain == "" || p.TLD == "" || p.Sub != "" {
+		return Subscription{}, fault.ErrInvalidDomain
+	}
+
+	pu, err := url.Parse(u)
+	if err != nil || (pu.Scheme != "http" && pu.Scheme != "https") || pu.Host == "" {
+		return Subscription{}, fault.ErrInvalidURL
+	}
+
+	for i := range events {
+		if !events[i].IsValid() {
+			return Subscription{}, fault.ErrInvalidEvent
+		}
+	}
+
+	s := Subscription{Apex: apex, URL: u, Secret: secret, Events: events, Created: time.Now().Unix()}
+
+	res, err := Subscriptions.InsertOne(ctx, s)
+	if err != nil {
+		return Subscription{}, fmt.Errorf("failed to insert: %w", err)
+	}
+
+	s.ID = res.InsertedID.(primitive.ObjectID)
+
+	return s, nil
+}
+
+// SubscriptionsGets returns the subscriptions of apex.
+// If apex is empty, returns every subscription.
+func SubscriptionsGets(ctx context.Context, apex string) ([]Subscription, error) {
+
+	filter := bson.M{}
+	if apex != "" {
+		filter["apex"] = apex
+	}
+
+	cursor, err := Subscriptions.Find(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	ss := make([]Subscription, 0)
+
+	for cursor.Next(ctx) {
+
+		s := new(Subscription)
+
+		err = cursor.Decode(s)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode: %w", err)
+		}
+
+		ss = append(ss, *s)
+	}
+
+	return ss, cursor.Err()
+}
+
+// SubscriptionsDelete removes the subscription with the hex ID id.
+//
+// If id is invalid or not found, returns fault.ErrNotFound.
+func SubscriptionsDelete(ctx context.Context, id string) error {
+
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return fault.ErrNotFound
+	}
+
+	res, err := Subscriptions.DeleteOne(ctx, bson.M{"_id": oid})
+	if err != nil {
+		return err
+	}
+
+	if res.DeletedCount == 0 {
+		return fault.ErrNotFound
+	}
+
+	return nil
+}
+
+// SubscriptionsGet returns the subscription with ID id.
+//
+// If not found, returns fault.ErrNotFound.
+func SubscriptionsGet(ctx context.Context, id primitive.ObjectID) (Subscription, error) {
+
+	var s Subscription
+
+	err := Subscriptions.FindOne(ctx, bson.M{"_id": id}).Decode(&s)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return s, fault.ErrNotFound
+	}
+
+	return s, err
+}
+
+// DeliveriesAdd adds a pending delivery of event e to the subscriptions ss, due now.
+// Adding the same event to the same subscription again does nothing, so a batch can be added again after a failure.
+func DeliveriesAdd(ctx context.Context, e Event, ss []Subscription) error {
+
+	if len(ss) == 0 {
+		return nil
+	}
+
+	now := time.Now().Unix()
+
+	models := make([]mongo.WriteModel, 0, len(ss))
+
+	for i := range ss {
+
+		up := bson.M{"$setOnInsert": Delivery{
+			Subscription: ss[i].ID,
+			Event:        e.ID,
+			Payload:      e,
+			State:        DeliveryPending,
+			NextAttempt:  now,
+			Attempts:     []DeliveryAttempt{},
+			Created:      now,
+		}}
+
+		models = append(models, mongo.NewUpdateOneModel().SetFilter(bson.M{"subscription": ss[i].ID, "event": e.ID}).SetUpdate(up).SetUpsert(true))
+	}
+
+	_, err := Deliveries.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+
+	return err
+}
+
+// DeliveriesClaim claims up to n pending deliveries that are due and not leased.
+// The deliveries are leased for DeliveryLease.
+func DeliveriesClaim(ctx context.Context, n int) ([]Delivery, error) {
+
+	ds := make([]Delivery, 0, n)
+
+	for i := 0; i < n; i++ {
+
+		now := time.Now().Unix()
+
+		filter := bson.D{
+			{Key: "state", Value: DeliveryPending},
+			{Key: "nextAttempt", Value: bson.M{"$lte": now}},
+			{Key: "leaseUntil", Value: bson.M{"$lte": now}},
+		}
+		up := bson.M{"$set": bson.M{"leaseUntil": time.Now().Add(DeliveryLease).Unix()}}
+		opts := options.FindOneAndUpdate().SetSort(bson.M{"nextAttempt": 1}).SetReturnDocument(options.After)
+
+		d := new(Delivery)
+
+		err := Deliveries.FindOneAndUpdate(ctx, filter, up, opts).Decode(d)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			break
+		}
+		if err != nil {
+			return ds, fmt.Errorf("failed to claim: %w", err)
+		}
+
+		ds = append(ds, *d)
+	}
+
+	return ds, nil
+}
+
+// DeliveriesUpdate appends attempt a to the log of the delivery with ID id, sets the state to s and releases the lease.
+// next is the time of the next attempt if s is DeliveryPending, otherwise ignored.
+func DeliveriesUpdate(ctx context.Context, id primitive.ObjectID, a DeliveryAttempt, s DeliveryState, next int64) error {
+
+	if s != DeliveryPending {
+		next = 0
+	}
+
+	_, err := Deliveries.UpdateOne(ctx,
+		bson.M{"_id": id},
+		bson.M{
+			"$push": bson.M{"attempts": a},
+			"$set":  bson.M{"state": s, "nextAttempt": next, "leaseUntil": int64(0)},
+		})
+
+	return err
+}
+
+// DeliveriesGets returns the last limit deliveries of the subscription with the hex ID id, the newest first.
+//
+// If id is invalid or the subscription not found, returns fault.ErrNotFound.
+func DeliveriesGets(ctx context.Context, id string, limit int64) ([]Delivery, error) {
+
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fault.ErrNotFound
+	}
+
+	err = Subscriptions.FindOne(ctx, bson.M{"_id": oid}).Err()
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, fault.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find subscription: %w", err)
+	}
+
+	cursor, err := Deliveries.Find(ctx, bson.M{"subscription": oid}, options.Find().SetSort(bson.M{"_id": -1}).SetLimit(limit))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	ds := make([]Delivery, 0)
+
+	for cursor.Next(ctx) {
+
+		d := new(Delivery)
+
+		err = cursor.Decode(d)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode: %w", err)
+		}
+
+		ds = append(ds, *d)
+	}
+
+	return ds, cursor.Err()
+}
@@ -21,7 +21,7 @@ type TopListSchema struct {
 //
 // Returns true if d is new and inserted into the database.
 // If domain is invalid or failed to remove the subdomain, returns fault.ErrInvalidDomain.
-func TopListInsert(d string) (bool, error) {
+func TopListInsert(ctx context.Context, d string) (bool, error) {
 
 	if !validator.Domain(d) {
 		return false, fault.ErrInvalidDomain
@@ -37,7 +37,7 @@ func TopListInsert(d string) (bool, error) {
 	doc := bson.M{"domain": v}
 
 	// UpdateOne will insert the document with $setOnInsert + $inc + upsert or do nothing
-	res, err := TopList.UpdateOne(context.TODO(), doc, bson.M{"$setOnInsert": doc, "$inc": bson.M{"count": 1}}, options.Update().SetUpsert(true))
+	res, err := TopList.UpdateOne(ctx, doc, bson.M{"$setOnInsert": doc, "$inc": bson.M{"count": 1}}, options.Update().SetUpsert(true))
 
 	return res.UpsertedCount != 0, err
 }
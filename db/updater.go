@@ -2,13 +2,14 @@ package db
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"os"
+	"log/slog"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
+	"github.com/elmasy-com/columbus/logger"
 )
 
 type UpdateType uint8
@@ -18,168 +19,222 @@ const (
 	UpdateExistingDomain
 )
 
+// String returns the name of the update type.
+func (t UpdateType) String() string {
+
+	switch t {
+	case InsertNewDomain:
+		return "insert"
+	case UpdateExistingDomain:
+		return "update"
+	default:
+		return "unknown"
+	}
+}
+
 // UpdateableDomain used to distinguish domain coming from /api/insert and domains coming from updater functions.
 type UpdateableDomain struct {
-	Domain string
-	Type   UpdateType
+	Domain    string
+	Type      UpdateType
+	Priority  UpdatePriority // Set by Scheduler.Pop()
+	Queued    bool           // Domain is claimed from the durable queue, must be acked/nacked
+	RequestID string         // ID of the request that pushed the domain, logged by the updater
 }
 
 var (
-	UpdaterChan  chan UpdateableDomain
+	// UpdaterQueue is the queue of the records updater.
+	// Created in RecordsUpdater(), use UpdaterPush() to send domains to the updater.
+	UpdaterQueue *Scheduler
 	updaterLimit int
+
+	updaterRunning atomic.Bool
 )
 
-// recordsUpdaterRoutine reads from DomainChan and internalDomainChan
-// and updates the FQDN coming from the channel.
-func updaterWorker(wg *sync.WaitGroup) {
+// UpdaterPush sends domain d with type t to the UpdaterQueue with priority p.
+// The request ID of ctx (if any) is logged with the update.
+//
+// Returns false if the queue is full or the updater is not started.
+func UpdaterPush(ctx context.Context, d string, t UpdateType, p UpdatePriority) bool {
 
-	defer wg.Done()
+	if UpdaterQueue == nil {
+		return false
+	}
 
-	for dom := range UpdaterChan {
+	return UpdaterQueue.Push(UpdateableDomain{Domain: d, Type: t, RequestID: logger.RequestID(ctx)}, p)
+}
 
-		var err error
+// UpdaterRunning returns whether RecordsUpdater() is running.
+func UpdaterRunning() bool {
+	return updaterRunning.Load()
+}
 
-		switch dom.Type {
-		case InsertNewDomain:
-			fmt.Printf("updater: inserting %s\n", dom.Domain)
-			err = DomainsInsertWithRecord(dom.Domain, false)
-		case UpdateExistingDomain:
-			err = RecordsUpdate(dom.Domain, false)
-		default:
-			err = fmt.Errorf("invalid UpdateType: %d", dom.Type)
-		}
+// UpdaterDepths returns the number of pending domains in every priority class of the UpdaterQueue.
+func UpdaterDepths() map[string]int {
 
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to update DNS records for %s in updaterWorker(): %s\n", dom.Domain, err)
-		}
+	if UpdaterQueue == nil {
+		return map[string]int{}
 	}
+
+	return UpdaterQueue.Depths()
 }
 
-// oldDomainUpdater is a function created to run as goroutine in the background.
-// Updates the old records, that not updated in the last 30 days
-func oldDomainUpdater(wg *sync.WaitGroup) {
+// sleepContext pauses for d or until ctx is done.
+// Returns false if ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) bool {
 
-	defer wg.Done()
+	t := time.NewTimer(d)
+	defer t.Stop()
 
-	for {
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
 
-		matchStage := bson.D{
-			{Key: "$match", Value: bson.D{{Key: "$or", Value: bson.A{
-				bson.M{"updated": bson.M{"$lt": time.Now().Add(-720 * time.Hour).Unix()}},
-				bson.M{"updated": bson.M{"$exists": false}},
-			}},
-			}}}
+// updaterWorker reads from UpdaterQueue and updates the FQDN coming from the queue.
+func updaterWorker(ctx context.Context, wg *sync.WaitGroup) {
 
-		sampleStage := bson.D{{Key: "$sample", Value: bson.M{"size": 1000}}}
+	defer wg.Done()
 
-		// Get domains that not updated in the last 30 days
-		cursor, err := Domains.Aggregate(context.TODO(), mongo.Pipeline{matchStage, sampleStage})
+	for {
 
-		//cursor, err := Domains.Find(context.TODO(), bson.M{"$or": bson.A{bson.M{"updated": bson.M{"$lt": time.Now().Add(-720 * time.Hour).Unix()}}, bson.M{"updated": bson.M{"$exists": false}}}})
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "oldDomainUpdater() failed to find toplist: %s\n", err)
-			// Wait before the next try
-			time.Sleep(600 * time.Second)
-			continue
+		dom, ok := UpdaterQueue.Pop()
+		if !ok {
+			return
 		}
 
-		for cursor.Next(context.TODO()) {
+		var err error
 
-			d := new(Domain)
+		// uctx carries the request ID to the logs of the update
+		uctx := ctx
+		if dom.RequestID != "" {
+			uctx = logger.WithRequestID(ctx, dom.RequestID)
+		}
 
-			err = cursor.Decode(d)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "oldDomainUpdater() failed to find: %s\n", err)
-				break
-			}
+		start := time.Now()
 
-			for len(UpdaterChan) > updaterLimit {
-				time.Sleep(60 * time.Second)
-			}
+		switch dom.Type {
+		case InsertNewDomain:
+			err = DomainsInsertWithRecord(uctx, dom.Domain, false)
+		case UpdateExistingDomain:
+			err = RecordsUpdate(uctx, dom.Domain, false)
+		default:
+			err = fmt.Errorf("invalid UpdateType: %d", dom.Type)
+		}
 
-			UpdaterChan <- UpdateableDomain{Domain: d.String(), Type: UpdateExistingDomain}
+		attrs := []slog.Attr{
+			slog.String("domain", dom.Domain),
+			slog.String("type", dom.Type.String()),
+			slog.String("priority", dom.Priority.String()),
+			slog.Bool("queued", dom.Queued),
+			slog.Duration("duration", time.Since(start)),
+		}
 
+		if err != nil {
+			slog.LogAttrs(uctx, slog.LevelError, "updater: failed to update DNS records", append(attrs, slog.String("error", err.Error()))...)
+		} else {
+			slog.LogAttrs(uctx, slog.LevelDebug, "updater: updated", attrs...)
 		}
 
-		if err = cursor.Err(); err != nil {
-			fmt.Fprintf(os.Stderr, "OldDomainUpdater() cursor failed: %s\n", err)
+		switch {
+		case ctx.Err() != nil:
+			// Stopping, the lease of a queued domain expires and the domain is claimed again
+			return
+		case !dom.Queued:
+			// Lookups and background refresh are not retried, the domain is refreshed again later
+			err = nil
+		case err == nil:
+			err = QueueAck(ctx, dom.Domain)
+		case errors.Is(err, ErrDNSFailure):
+			// Retried with backoff
+			err = QueueNack(ctx, dom.Domain, dom.Type, dom.Priority, err)
+		default:
+			// Permanent error (eg.: invalid domain), retrying does not help
+			err = QueueFail(ctx, dom.Domain, dom.Type, dom.Priority, err)
 		}
 
-		cursor.Close(context.TODO())
+		if err != nil {
+			slog.ErrorContext(uctx, "updater: failed to ack/nack in the durable queue", "domain", dom.Domain, "error", err)
+		}
 	}
 }
 
-// topListUpdater is a function created to run as goroutine in the background.
-// Updates the domains and it subdomains in topList collection by sending every entries into internalRecordsUpdaterDomainChan.
-// This function uses concurrent goroutines and print only/ignores any error.
-func topListUpdater(wg *sync.WaitGroup) {
+// queueFeeder is a function created to run as goroutine in the background.
+// Claims the due items from the durable queue and sends them into UpdaterQueue.
+func queueFeeder(ctx context.Context, wg *sync.WaitGroup) {
 
 	defer wg.Done()
 
 	for {
 
-		sampleStage := bson.D{{Key: "$sample", Value: bson.M{"size": 1000}}}
-
-		cursor, err := TopList.Aggregate(context.TODO(), mongo.Pipeline{sampleStage})
-		//cursor, err := TopList.Find(context.TODO(), bson.M{}, options.Find().SetSort(bson.M{"count": -1}))
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "topListUpdater() failed to find toplist: %s\n", err)
-			continue
+		for UpdaterQueue.Len() > updaterLimit {
+			if !sleepContext(ctx, time.Second) {
+				return
+			}
 		}
 
-		for cursor.Next(context.TODO()) {
-
-			d := new(TopListSchema)
-
-			err = cursor.Decode(d)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "topListUpdater() failed to find: %s\n", err)
-				break
+		qs, err := QueueClaim(ctx, 100)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
 			}
-
-			ds, err := DomainsLookupFull(d.Domain, -1)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "topListUpdater() failed to lookup full for %s: %s\n", d.Domain, err)
-				continue
+			slog.Error("updater: failed to claim from the durable queue", "error", err)
+			// Wait before the next try
+			if !sleepContext(ctx, 60*time.Second) {
+				return
 			}
+			continue
+		}
 
-			for i := range ds {
-
-				for len(UpdaterChan) > updaterLimit {
-					time.Sleep(60 * time.Second)
-				}
-
-				UpdaterChan <- UpdateableDomain{Domain: ds[i], Type: UpdateExistingDomain}
+		if len(qs) == 0 {
+			if !sleepContext(ctx, time.Second) {
+				return
 			}
-
+			continue
 		}
 
-		if err = cursor.Err(); err != nil {
-			fmt.Fprintf(os.Stderr, "topListUpdater() cursor failed: %s\n", err)
+		for i := range qs {
+			UpdaterQueue.Push(UpdateableDomain{Domain: qs[i].Domain, Type: qs[i].Type, Queued: true}, qs[i].Priority)
 		}
-
-		cursor.Close(context.TODO())
 	}
 }
 
-func RecordsUpdater(nworker int, chanSize int) {
+// RecordsUpdater creates the UpdaterQueue with size queueSize and starts nworker updater workers, the durable queue feeder and the refresh scheduler.
+// The durable queue feeder and the refresh scheduler are paused while the queue is more than half full.
+// The DNS queries of the process are limited to dnsBudget queries per second (see SetDNSBudget()), 0 means unlimited.
+//
+// This function blocks until ctx is done and every goroutine is stopped.
+func RecordsUpdater(ctx context.Context, nworker int, queueSize int, dnsBudget int) {
 
-	UpdaterChan = make(chan UpdateableDomain, chanSize)
+	UpdaterQueue = NewScheduler(queueSize)
 
-	updaterLimit = chanSize / 2
+	updaterRunning.Store(true)
+	defer updaterRunning.Store(false)
+
+	updaterLimit = queueSize / 2
+
+	SetDNSBudget(dnsBudget)
 
 	wg := new(sync.WaitGroup)
 
 	for i := 0; i < nworker; i++ {
 		wg.Add(1)
-		go updaterWorker(wg)
+		go updaterWorker(ctx, wg)
 	}
 
 	wg.Add(1)
-	go oldDomainUpdater(wg)
+	go queueFeeder(ctx, wg)
 
 	wg.Add(1)
-	go topListUpdater(wg)
+	go refreshScheduler(ctx, wg)
+
+	// Stop the workers blocked in Pop()
+	go func() {
+		<-ctx.Done()
+		UpdaterQueue.Close()
+	}()
 
 	wg.Wait()
 }
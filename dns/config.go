@@ -5,6 +5,7 @@ import (
 	"os"
 	"strings"
 
+	"github.com/elmasy-com/columbus/logger"
 	"gopkg.in/yaml.v3"
 )
 
@@ -13,7 +14,11 @@ type Config struct {
 	MongoURI      string   `yaml:"MongoURI"`
 	NumWorkers    int      `yaml:"NumWorkers"`
 	BuffSize      int      `yaml:"BuffSize"`
+	DNSBudget     int      `yaml:"DNSBudget"`
 	ListenAddress string   `yaml:"ListenAddress"`
+	AdminAddress  string   `yaml:"AdminAddress"`
+	LogLevel      string   `yaml:"LogLevel"`
+	LogFormat     string   `yaml:"LogFormat"`
 }
 
 // parseConfig parses the config file in path, set the default if needed and return the Config struct.
@@ -52,9 +57,21 @@ func parseConfig(path string) (Config, error) {
 		c.BuffSize = 1000
 	}
 
+	if c.DNSBudget < 0 {
+		return c, fmt.Errorf("DNSBudget is negative")
+	}
+
 	if c.ListenAddress == "" {
 		c.ListenAddress = ":1053"
 	}
 
+	if _, err := logger.ParseLevel(c.LogLevel); err != nil {
+		return c, fmt.Errorf("invalid LogLevel: %w", err)
+	}
+
+	if _, err := logger.ParseFormat(c.LogFormat); err != nil {
+		return c, fmt.Errorf("invalid LogFormat: %w", err)
+	}
+
 	return c, nil
 }
@@ -1,17 +1,22 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"log/slog"
 	"math/rand"
 	"os"
 	"os/signal"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
 
 	"github.com/elmasy-com/columbus/db"
-	eldns "github.com/elmasy-com/elnet/dns"
+	"github.com/elmasy-com/columbus/health"
+	"github.com/elmasy-com/columbus/logger"
 
 	"github.com/miekg/dns"
 )
@@ -60,16 +65,28 @@ func isValidResponse(m dns.RR) bool {
 		return true
 	case *dns.TXT:
 		return true
-
-	//case *dns.CERT:
-	// TODO: Implement more type
-	//return false
-
+	case *dns.HTTPS:
+		return true
+	case *dns.SVCB:
+		return true
+	case *dns.TLSA:
+		return true
+	case *dns.DS:
+		return true
+	case *dns.DNSKEY:
+		return true
+	case *dns.NAPTR:
+		return true
+	case *dns.SSHFP:
+		return true
+	case *dns.RRSIG:
+		// Signatures are sent with the answer if DO bit is set, the signed records are checked
+		return false
 	case *dns.PTR:
 		// PTR records are out of context
 		return false
 	default:
-		fmt.Fprintf(os.Stderr, "Unknown reply type: %T\n", t)
+		slog.Warn("unknown reply type", "type", fmt.Sprintf("%T", t))
 		return false
 	}
 }
@@ -80,6 +97,9 @@ func insertWorker(wg *sync.WaitGroup) {
 
 	defer wg.Done()
 
+	// The worker runs until ReplyChan is closed, the remaining replies are inserted on shutdown
+	ctx := context.Background()
+
 	for r := range ReplyChan {
 
 		switch {
@@ -94,27 +114,53 @@ func insertWorker(wg *sync.WaitGroup) {
 			continue
 		}
 
-		wc, err := eldns.IsWildcard(r.Question[0].Name, r.Question[0].Qtype)
+		wc, err := db.IsWildcard(ctx, r.Question[0].Name, r.Question[0].Qtype)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to check if %s is wildcard: %s\n", r.Question[0].Name, err)
+			slog.Error("failed to check wildcard", "domain", r.Question[0].Name, "error", err)
 			continue
 		}
 		if wc {
 			continue
 		}
 
-		ni, err := db.DomainsInsert(r.Question[0].Name)
+		ni, err := db.DomainsInsert(ctx, r.Question[0].Name)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to insert %s: %s\n", r.Question[0].Name, err)
+			slog.Error("failed to insert domain", "domain", r.Question[0].Name, "error", err)
 			continue
 		}
 		if ni {
-			fmt.Printf("New domain inserted: %s\n", r.Question[0].Name)
+			slog.Info("new domain inserted", "domain", r.Question[0].Name)
 		}
 
-		err = db.RecordsUpdate(r.Question[0].Name, false)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to update records for %s: %s\n", r.Question[0].Name, err)
+		// Store the records from the answer, RecordsUpdate() may skip the domain if updated recently.
+		refreshed := false
+
+		for i := range r.Answer {
+
+			if !strings.EqualFold(r.Answer[i].Header().Name, r.Question[0].Name) {
+				continue
+			}
+
+			for _, rec := range db.RecordsFromRR(r.Answer[i]) {
+
+				ok, err := db.RecordsInsert(ctx, r.Question[0].Name, rec)
+				if err != nil {
+					slog.Error("failed to insert record", "domain", r.Question[0].Name, "type", db.RecordTypeString(rec.Type), "error", err)
+					continue
+				}
+				if !ok {
+					refreshed = true
+				}
+			}
+		}
+
+		if refreshed {
+			db.RecordsRefreshed(ctx, r.Question[0].Name)
+		}
+
+		err = db.RecordsUpdate(ctx, r.Question[0].Name, false)
+		if err != nil && !errors.Is(err, db.ErrDNSFailure) {
+			slog.Error("failed to update records", "domain", r.Question[0].Name, "error", err)
 		}
 	}
 }
@@ -146,14 +192,14 @@ func handleFunc(w dns.ResponseWriter, q *dns.Msg) {
 
 	r, err := dns.Exchange(q, getRandomResolver())
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to exchange message: %s\n", err)
+		slog.Error("failed to exchange message", "error", err)
 		q.MsgHdr.Response = true
 		q.MsgHdr.Rcode = dns.RcodeServerFailure
 		w.WriteMsg(q)
 		return
 	}
 	if r == nil {
-		fmt.Fprintf(os.Stderr, "Error: reply is nil\n")
+		slog.Error("reply is nil")
 		q.MsgHdr.Response = true
 		q.MsgHdr.Rcode = dns.RcodeServerFailure
 		w.WriteMsg(q)
@@ -166,17 +212,37 @@ func handleFunc(w dns.ResponseWriter, q *dns.Msg) {
 
 	err = w.WriteMsg(r)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to write reply: %s\n", err)
+		slog.Error("failed to write reply", "error", err)
 	}
 
-	fmt.Printf("%s -> %s %s %s %s %s\n",
-		w.RemoteAddr().String(),
-		q.Question[0].Name,
-		dns.ClassToString[q.Question[0].Qclass],
-		dns.TypeToString[q.Question[0].Qtype],
-		dns.RcodeToString[r.Rcode],
-		time.Since(start))
+	slog.Info("query",
+		"client", w.RemoteAddr().String(),
+		"name", q.Question[0].Name,
+		"class", dns.ClassToString[q.Question[0].Qclass],
+		"type", dns.TypeToString[q.Question[0].Qtype],
+		"rcode", dns.RcodeToString[r.Rcode],
+		"duration", time.Since(start))
+}
+
+// upstreamCheck is a readiness check, returns nil if any of the resolvers answers a query.
+func upstreamCheck(ctx context.Context) error {
 
+	q := new(dns.Msg)
+	q.SetQuestion(".", dns.TypeNS)
+
+	c := new(dns.Client)
+
+	var err error
+
+	for i := range resolvers {
+
+		_, _, err = c.ExchangeContext(ctx, q, resolvers[i])
+		if err == nil {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("no resolver is reachable: %w", err)
 }
 
 func main() {
@@ -204,23 +270,38 @@ func main() {
 		os.Exit(1)
 	}
 
+	err = logger.Setup(conf.LogLevel, conf.LogFormat)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to set up logging: %s\n", err)
+		os.Exit(1)
+	}
+
 	// Set global resolvers to get random ones
 	resolvers = conf.Resolvers
 	resolversNum = int32(len(resolvers))
 
+	// Limit the wildcard checks and the record updates of the workers, the proxied queries are not limited
+	db.SetDNSBudget(conf.DNSBudget)
+
 	// Create buff channel
 	ReplyChan = make(chan *dns.Msg, conf.BuffSize)
 
 	// Connect to MongoDB
-	fmt.Printf("Connecting to MongoDB...\n")
-	err = db.Connect(conf.MongoURI)
+	slog.Info("connecting to MongoDB")
+	err = db.Connect(context.Background(), conf.MongoURI)
+	if err != nil {
+		slog.Error("failed to connect to MongoDB", "error", err)
+		os.Exit(1)
+	}
+
+	err = db.MigrationsCheck(context.Background())
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to connect to MongoDB: %s\n", err)
+		slog.Error("migrations check failed", "error", err)
 		os.Exit(1)
 	}
-	defer db.Disconnect()
+	defer db.Disconnect(context.Background())
 
-	fmt.Printf("Starting %d workers...\n", conf.NumWorkers)
+	slog.Info("starting workers", "workers", conf.NumWorkers)
 	// Start workers
 	wg := sync.WaitGroup{}
 	for i := 0; i < conf.NumWorkers; i++ {
@@ -231,13 +312,33 @@ func main() {
 	stopSignal := make(chan os.Signal, 1)
 	signal.Notify(stopSignal, os.Interrupt, syscall.SIGTERM)
 
+	// adminCtx stops the admin listener
+	adminCtx, adminCancel := context.WithCancel(context.Background())
+	defer adminCancel()
+
+	if conf.AdminAddress != "" {
+
+		h := health.New("columbus-dns", BuildDate, BuildCommit)
+		h.Add("mongo", db.Ping)
+		h.Add("upstream", upstreamCheck)
+
+		slog.Info("starting admin listener", "address", conf.AdminAddress)
+
+		go func() {
+			if err := h.ListenAndServe(adminCtx, conf.AdminAddress); err != nil {
+				slog.Error("admin listener failed", "error", err)
+			}
+		}()
+	}
+
 	udpServer := UDPStart(conf.ListenAddress, stopSignal)
 
 	tcpServer := TCPStart(conf.ListenAddress, stopSignal)
 
 	// Wait for the SIGTERM
 	<-stopSignal
-	fmt.Printf("Caught a SIGTERM, closing...\n")
+	slog.Info("caught a SIGTERM, closing")
+	adminCancel()
 	udpServer.Shutdown()
 	tcpServer.Shutdown()
 	close(ReplyChan)
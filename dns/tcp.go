@@ -1,7 +1,7 @@
 package main
 
 import (
-	"fmt"
+	"log/slog"
 	"os"
 
 	"github.com/miekg/dns"
@@ -21,10 +21,10 @@ func TCPStart(listen string, stopSignal chan os.Signal) *dns.Server {
 	}
 
 	go func() {
-		fmt.Printf("Starting TCP server...\n")
+		slog.Info("starting TCP server", "address", listen)
 		err := tcpServer.ListenAndServe()
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "TCP server failed: %s\n", err)
+			slog.Error("TCP server failed", "error", err)
 			stopSignal <- os.Interrupt
 		}
 	}()
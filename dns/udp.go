@@ -1,7 +1,7 @@
 package main
 
 import (
-	"fmt"
+	"log/slog"
 	"os"
 
 	"github.com/miekg/dns"
@@ -21,10 +21,10 @@ func UDPStart(listen string, stopSignal chan os.Signal) *dns.Server {
 	}
 
 	go func() {
-		fmt.Printf("Starting UDP server...\n")
+		slog.Info("starting UDP server", "address", listen)
 		err := udpServer.ListenAndServe()
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "UDP server failed: %s\n", err)
+			slog.Error("UDP server failed", "error", err)
 			stopSignal <- os.Interrupt
 		}
 	}()
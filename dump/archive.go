@@ -0,0 +1,234 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/klauspost/compress/zstd"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// archiveFormat is the version of the archive layout.
+// Increase it if the layout of the archive changes in an incompatible way.
+const archiveFormat = 1
+
+// manifestName is the name of the manifest file in the archive directory.
+// The manifest is written once after every collection is dumped, a dump without manifest is incomplete.
+const manifestName = "manifest.json"
+
+// Manifest describes the content of an archive.
+type Manifest struct {
+	Format      int          `json:"format"`      // Version of the archive layout
+	Schema      int          `json:"schema"`      // Applied migration version of the dumped database
+	Created     int64        `json:"created"`     // Unix timestamp of the dump
+	Since       int64        `json:"since"`       // The timestamp of an incremental dump, 0 for a full dump
+	Collections []Collection `json:"collections"` // Dumped collections in order
+}
+
+// Collection is a dumped collection in the manifest.
+type Collection struct {
+	Name      string  `json:"name"`
+	Documents int64   `json:"documents"`
+	Chunks    []Chunk `json:"chunks"`
+}
+
+// Chunk is a file of a dumped collection.
+// The file is the zstd compressed concatenation of the BSON documents, SHA256 is the checksum of the compressed file.
+type Chunk struct {
+	File      string `json:"file"`
+	Documents int64  `json:"documents"`
+	SHA256    string `json:"sha256"`
+}
+
+// chunkWriter writes the documents of a collection into chunks of at most size documents.
+type chunkWriter struct {
+	dir   string
+	size  int64
+	coll  *Collection
+	file  *os.File
+	hash  hash.Hash
+	enc   *zstd.Encoder
+	chunk Chunk
+}
+
+func newChunkWriter(dir string, name string, size int64) *chunkWriter {
+	return &chunkWriter{dir: dir, size: size, coll: &Collection{Name: name, Chunks: []Chunk{}}}
+}
+
+// open creates the next chunk file.
+func (w *chunkWriter) open() error {
+
+	w.chunk = Chunk{File: fmt.Sprintf("%s-%05d.bson.zst", w.coll.Name, len(w.coll.Chunks))}
+
+	var err error
+
+	w.file, err = os.OpenFile(filepath.Join(w.dir, w.chunk.File), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+
+	w.hash = sha256.New()
+
+	w.enc, err = zstd.NewWriter(io.MultiWriter(w.file, w.hash))
+
+	return err
+}
+
+// close finishes the current chunk and adds it to the collection.
+func (w *chunkWriter) close() error {
+
+	if w.file == nil {
+		return nil
+	}
+
+	if err := w.enc.Close(); err != nil {
+		return err
+	}
+
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+
+	w.chunk.SHA256 = hex.EncodeToString(w.hash.Sum(nil))
+	w.coll.Chunks = append(w.coll.Chunks, w.chunk)
+	w.file = nil
+
+	return nil
+}
+
+// Write writes doc to the current chunk, opens a new chunk if the current is full.
+func (w *chunkWriter) Write(doc bson.Raw) error {
+
+	if w.file != nil && w.chunk.Documents >= w.size {
+		if err := w.close(); err != nil {
+			return err
+		}
+	}
+
+	if w.file == nil {
+		if err := w.open(); err != nil {
+			return err
+		}
+	}
+
+	if _, err := w.enc.Write(doc); err != nil {
+		return err
+	}
+
+	w.chunk.Documents++
+	w.coll.Documents++
+
+	return nil
+}
+
+// Close finishes the last chunk and returns the collection.
+func (w *chunkWriter) Close() (Collection, error) {
+
+	err := w.close()
+
+	return *w.coll, err
+}
+
+// writeManifest writes m to dir.
+func writeManifest(dir string, m *Manifest) error {
+
+	out, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(dir, manifestName), out, 0o644)
+}
+
+// readManifest reads and checks the manifest in dir.
+func readManifest(dir string) (*Manifest, error) {
+
+	out, err := os.ReadFile(filepath.Join(dir, manifestName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest (incomplete dump?): %w", err)
+	}
+
+	m := new(Manifest)
+
+	err = json.Unmarshal(out, m)
+	if err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+
+	if m.Format != archiveFormat {
+		return nil, fmt.Errorf("unsupported archive format: %d", m.Format)
+	}
+
+	return m, nil
+}
+
+// verifyChunk checks the checksum of chunk c in dir.
+func verifyChunk(dir string, c Chunk) error {
+
+	file, err := os.Open(filepath.Join(dir, c.File))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	h := sha256.New()
+
+	if _, err := io.Copy(h, file); err != nil {
+		return err
+	}
+
+	if sum := hex.EncodeToString(h.Sum(nil)); sum != c.SHA256 {
+		return fmt.Errorf("checksum mismatch of %s: expected %s, got %s", c.File, c.SHA256, sum)
+	}
+
+	return nil
+}
+
+// readChunk calls fn with every document in chunk c in dir.
+// Returns an error if the number of documents differs from the manifest.
+func readChunk(dir string, c Chunk, fn func(doc bson.Raw) error) error {
+
+	file, err := os.Open(filepath.Join(dir, c.File))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	dec, err := zstd.NewReader(file)
+	if err != nil {
+		return err
+	}
+	defer dec.Close()
+
+	var n int64
+
+	for {
+
+		doc, err := bson.NewFromIOReader(dec)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read document %d of %s: %w", n, c.File, err)
+		}
+
+		n++
+
+		if err := fn(doc); err != nil {
+			return err
+		}
+	}
+
+	if n != c.Documents {
+		return fmt.Errorf("%s: expected %d documents, got %d", c.File, c.Documents, n)
+	}
+
+	return nil
+}
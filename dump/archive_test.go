@@ -0,0 +1,95 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestArchive(t *testing.T) {
+
+	dir := t.TempDir()
+
+	w := newChunkWriter(dir, "domains", 2)
+
+	for i := 0; i < 5; i++ {
+
+		doc, err := bson.Marshal(bson.M{"domain": "example", "tld": "com", "sub": string(rune('a' + i))})
+		if err != nil {
+			t.Fatalf("failed to marshal: %s", err)
+		}
+
+		if err := w.Write(doc); err != nil {
+			t.Fatalf("failed to write: %s", err)
+		}
+	}
+
+	c, err := w.Close()
+	if err != nil {
+		t.Fatalf("failed to close: %s", err)
+	}
+
+	if c.Documents != 5 || len(c.Chunks) != 3 || c.Chunks[2].Documents != 1 {
+		t.Fatalf("unexpected collection: %#v", c)
+	}
+
+	err = writeManifest(dir, &Manifest{Format: archiveFormat, Schema: 8, Collections: []Collection{c}})
+	if err != nil {
+		t.Fatalf("failed to write manifest: %s", err)
+	}
+
+	m, err := readManifest(dir)
+	if err != nil {
+		t.Fatalf("failed to read manifest: %s", err)
+	}
+
+	var subs string
+
+	for _, ch := range m.Collections[0].Chunks {
+
+		if err := verifyChunk(dir, ch); err != nil {
+			t.Fatalf("failed to verify: %s", err)
+		}
+
+		err = readChunk(dir, ch, func(doc bson.Raw) error {
+			subs += doc.Lookup("sub").StringValue()
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("failed to read: %s", err)
+		}
+	}
+
+	if subs != "abcde" {
+		t.Fatalf("unexpected documents: %s", subs)
+	}
+
+	// Corrupt the last chunk
+	err = os.WriteFile(filepath.Join(dir, c.Chunks[2].File), []byte("invalid"), 0o644)
+	if err != nil {
+		t.Fatalf("failed to corrupt: %s", err)
+	}
+
+	if err := verifyChunk(dir, c.Chunks[2]); err == nil {
+		t.Fatalf("expected checksum mismatch")
+	}
+}
+
+func TestReadManifestFormat(t *testing.T) {
+
+	dir := t.TempDir()
+
+	if _, err := readManifest(dir); err == nil {
+		t.Fatalf("expected error for missing manifest")
+	}
+
+	if err := writeManifest(dir, &Manifest{Format: archiveFormat + 1}); err != nil {
+		t.Fatalf("failed to write manifest: %s", err)
+	}
+
+	if _, err := readManifest(dir); err == nil {
+		t.Fatalf("expected error for unsupported format")
+	}
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/elmasy-com/columbus/db"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+var (
+	BuildDate   string
+	BuildCommit string
+	mongoURI    = flag.String("uri", "", "MongoDB connection URI")
+	since       = flag.String("since", "", "Incremental dump, only the documents updated after this time (eg.: \"2023-10-01\", \"7d\")")
+	collections = flag.String("collections", "", "Comma separated list of collections to dump/restore (default: every collection)")
+	chunkSize   = flag.Int64("chunk", 100000, "Number of documents in a chunk")
+	batchSize   = flag.Int("batch", 1000, "Number of documents restored in one bulk write")
+	version     = flag.Bool("version", false, "Print current version")
+)
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-uri <uri>] [options] dump|restore|verify <dir>\n\n", os.Args[0])
+	fmt.Fprintf(flag.CommandLine.Output(), "Commands:\n")
+	fmt.Fprintf(flag.CommandLine.Output(), "  dump\tDump the dataset into the empty or not existing directory dir\n")
+	fmt.Fprintf(flag.CommandLine.Output(), "  restore\tRestore the dump in dir, the documents already in the database are replaced\n")
+	fmt.Fprintf(flag.CommandLine.Output(), "  verify\tCheck the checksums of the dump in dir, does not connect to the database\n\n")
+	flag.PrintDefaults()
+}
+
+// selected returns the collections selected with -collections.
+func selected() ([]db.DumpCollection, error) {
+
+	if *collections == "" {
+		return db.DumpCollections, nil
+	}
+
+	var cs []db.DumpCollection
+
+	for _, name := range strings.Split(*collections, ",") {
+
+		i := slices.IndexFunc(db.DumpCollections, func(c db.DumpCollection) bool { return c.Name == strings.TrimSpace(name) })
+		if i < 0 {
+			return nil, fmt.Errorf("unknown collection: %s", name)
+		}
+
+		cs = append(cs, db.DumpCollections[i])
+	}
+
+	return cs, nil
+}
+
+func dump(ctx context.Context, dir string) error {
+
+	if *chunkSize < 1 {
+		return fmt.Errorf("invalid chunk size: %d", *chunkSize)
+	}
+
+	cs, err := selected()
+	if err != nil {
+		return err
+	}
+
+	m := &Manifest{Format: archiveFormat, Created: time.Now().Unix(), Collections: []Collection{}}
+
+	if *since != "" {
+		m.Since, err = db.ParseTime(*since)
+		if err != nil {
+			return fmt.Errorf("invalid -since: %w", err)
+		}
+	}
+
+	m.Schema, err = db.MigrationsVersion(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get schema version: %w", err)
+	}
+
+	if es, err := os.ReadDir(dir); err == nil && len(es) > 0 {
+		return fmt.Errorf("%s is not empty", dir)
+	}
+
+	err = os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return err
+	}
+
+	for i := range cs {
+
+		fmt.Printf("Dumping %s...\n", cs[i].Name)
+
+		w := newChunkWriter(dir, cs[i].Name, *chunkSize)
+
+		err = db.DumpFind(ctx, cs[i], m.Since, func(doc bson.Raw) error { return w.Write(doc) })
+
+		c, cerr := w.Close()
+		if err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return fmt.Errorf("failed to dump %s: %w", cs[i].Name, err)
+		}
+
+		fmt.Printf("Dumped %d documents from %s in %d chunks\n", c.Documents, c.Name, len(c.Chunks))
+
+		m.Collections = append(m.Collections, c)
+	}
+
+	return writeManifest(dir, m)
+}
+
+func verify(ctx context.Context, dir string) error {
+
+	m, err := readManifest(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range m.Collections {
+		for _, ch := range c.Chunks {
+			if err := verifyChunk(dir, ch); err != nil {
+				return err
+			}
+		}
+	}
+
+	fmt.Printf("Dump created at %s, schema version %d", time.Unix(m.Created, 0).UTC().Format(time.RFC3339), m.Schema)
+	if m.Since != 0 {
+		fmt.Printf(", incremental since %s", time.Unix(m.Since, 0).UTC().Format(time.RFC3339))
+	}
+	fmt.Printf("\n")
+
+	for _, c := range m.Collections {
+		fmt.Printf("%s: %d documents in %d chunks\n", c.Name, c.Documents, len(c.Chunks))
+	}
+
+	return nil
+}
+
+func restore(ctx context.Context, dir string) error {
+
+	if *batchSize < 1 {
+		return fmt.Errorf("invalid batch size: %d", *batchSize)
+	}
+
+	cs, err := selected()
+	if err != nil {
+		return err
+	}
+
+	m, err := readManifest(dir)
+	if err != nil {
+		return err
+	}
+
+	latest := db.MigrationSteps[len(db.MigrationSteps)-1].Version
+	if m.Schema > latest {
+		return fmt.Errorf("dump schema version %d is newer than the supported %d, use a newer version", m.Schema, latest)
+	}
+
+	// Check every chunk before writing anything
+	for _, c := range m.Collections {
+		for _, ch := range c.Chunks {
+			if err := verifyChunk(dir, ch); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, c := range m.Collections {
+
+		i := slices.IndexFunc(cs, func(dc db.DumpCollection) bool { return dc.Name == c.Name })
+		if i < 0 {
+			continue
+		}
+
+		fmt.Printf("Restoring %s...\n", c.Name)
+
+		var (
+			batch    []bson.Raw
+			inserted int64
+			replaced int64
+		)
+
+		flush := func() error {
+
+			ins, rep, err := db.DumpRestore(ctx, cs[i], batch)
+			if err != nil {
+				return err
+			}
+
+			inserted += ins
+			replaced += rep
+			batch = batch[:0]
+
+			return nil
+		}
+
+		for _, ch := range c.Chunks {
+
+			err = readChunk(dir, ch, func(doc bson.Raw) error {
+
+				batch = append(batch, doc)
+
+				if len(batch) >= *batchSize {
+					return flush()
+				}
+
+				return nil
+			})
+			if err == nil {
+				err = flush()
+			}
+			if err != nil {
+				return fmt.Errorf("failed to restore %s: %w", ch.File, err)
+			}
+		}
+
+		fmt.Printf("Restored %s: %d new, %d replaced\n", c.Name, inserted, replaced)
+	}
+
+	fmt.Printf("Run columbus-migrate up to upgrade the restored documents to the current schema\n")
+
+	return nil
+}
+
+func main() {
+
+	flag.Usage = usage
+	flag.Parse()
+
+	if *version {
+		fmt.Printf("Build date: %s\n", BuildDate)
+		fmt.Printf("Git Commit: %s\n", BuildCommit)
+		os.Exit(0)
+	}
+
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	var cmd func(context.Context, string) error
+
+	switch flag.Arg(0) {
+	case "dump":
+		cmd = dump
+	case "restore":
+		cmd = restore
+	case "verify":
+		cmd = verify
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command: %s\n", flag.Arg(0))
+		os.Exit(1)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	connected := flag.Arg(0) != "verify"
+
+	if connected {
+
+		if *mongoURI == "" {
+			fmt.Fprintf(os.Stderr, "-uri is missing!\n")
+			os.Exit(1)
+		}
+
+		err := db.Connect(ctx, *mongoURI)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to connect to MongoDB: %s\n", err)
+			os.Exit(1)
+		}
+	}
+
+	err := cmd(ctx, flag.Arg(1))
+
+	if connected {
+		db.Disconnect(context.Background())
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to %s: %s\n", flag.Arg(0), err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,210 @@
+/*
+export package is used to write the domains in CSV and NDJSON (JSON Lines) formats.
+
+Every record of a domain is a row with the columns: fqdn, type, value, time and updated.
+A domain without records is a single row with empty record columns.
+*/
+package export
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+
+	"github.com/elmasy-com/columbus/db"
+	"github.com/elmasy-com/columbus/fault"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+)
+
+const (
+	CompressionNone = ""
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+)
+
+// Row is a record of a domain.
+// Type is the name of the record type (eg.: "A"), Time is the time when the record last seen, Updated is the time of the last update of the domain.
+type Row struct {
+	FQDN    string `json:"fqdn"`
+	Type    string `json:"type"`
+	Value   string `json:"value"`
+	Time    int64  `json:"time"`
+	Updated int64  `json:"updated"`
+}
+
+// Header is the header of the CSV format.
+var Header = []string{"fqdn", "type", "value", "time", "updated"}
+
+// Rows returns the rows of d.
+func Rows(d *db.Domain) []Row {
+	return RecordRows(d.String(), d.Records, d.Updated)
+}
+
+// RecordRows returns the rows of the records of fqdn, updated is the time of the last update of fqdn.
+func RecordRows(fqdn string, records []db.Record, updated int64) []Row {
+
+	if len(records) == 0 {
+		return []Row{{FQDN: fqdn, Updated: updated}}
+	}
+
+	rs := make([]Row, 0, len(records))
+
+	for i := range records {
+		rs = append(rs, Row{
+			FQDN:    fqdn,
+			Type:    db.RecordTypeString(records[i].Type),
+			Value:   records[i].Value,
+			Time:    records[i].Last(),
+			Updated: updated,
+		})
+	}
+
+	return rs
+}
+
+// Writer writes rows in a format.
+// Flush() must be called after the last row.
+type Writer interface {
+	Write(r Row) error
+	Flush() error
+}
+
+type csvWriter struct {
+	w      *csv.Writer
+	header bool
+}
+
+// writeHeader writes the header before the first row.
+func (w *csvWriter) writeHeader() error {
+
+	if w.header {
+		return nil
+	}
+
+	w.header = true
+
+	return w.w.Write(Header)
+}
+
+func (w *csvWriter) Write(r Row) error {
+
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+
+	return w.w.Write([]string{r.FQDN, r.Type, r.Value, strconv.FormatInt(r.Time, 10), strconv.FormatInt(r.Updated, 10)})
+}
+
+// Flush writes the header if no row was written, so an empty export is a valid CSV.
+func (w *csvWriter) Flush() error {
+
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+
+	w.w.Flush()
+
+	return w.w.Error()
+}
+
+type ndjsonWriter struct {
+	e *json.Encoder
+}
+
+func (w *ndjsonWriter) Write(r Row) error {
+	return w.e.Encode(r)
+}
+
+func (w *ndjsonWriter) Flush() error {
+	return nil
+}
+
+// NewWriter returns a Writer that writes rows to w in format.
+//
+// If format is not FormatCSV or FormatNDJSON, returns fault.ErrInvalidFormat.
+func NewWriter(w io.Writer, format string) (Writer, error) {
+
+	switch format {
+	case FormatCSV:
+		return &csvWriter{w: csv.NewWriter(w)}, nil
+	case FormatNDJSON:
+		e := json.NewEncoder(w)
+		e.SetEscapeHTML(false)
+		return &ndjsonWriter{e: e}, nil
+	default:
+		return nil, fault.ErrInvalidFormat
+	}
+}
+
+// ContentType returns the MIME type of format or compression f.
+func ContentType(f string) string {
+
+	switch f {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatNDJSON:
+		return "application/x-ndjson"
+	case CompressionGzip:
+		return "application/gzip"
+	case CompressionZstd:
+		return "application/zstd"
+	default:
+		return "application/octet-stream"
+	}
+}
+
+// Compressor is a compressing writer.
+// Flush() writes the pending compressed data to the underlying writer, Close() must be called after the last write.
+type Compressor interface {
+	io.WriteCloser
+	Flush() error
+}
+
+type nopCompressor struct {
+	io.Writer
+}
+
+func (nopCompressor) Flush() error { return nil }
+
+func (nopCompressor) Close() error { return nil }
+
+// NewCompressor returns a writer that compresses to w with compression c.
+// If c is CompressionNone, the returned writer writes to w directly.
+// The returned writer must be closed to flush the compressed data.
+//
+// If c is unknown, returns fault.ErrInvalidFormat.
+func NewCompressor(w io.Writer, c string) (Compressor, error) {
+
+	switch c {
+	case CompressionNone:
+		return nopCompressor{w}, nil
+	case CompressionGzip:
+		return gzip.NewWriter(w), nil
+	case CompressionZstd:
+		return zstd.NewWriter(w)
+	default:
+		return nil, fault.ErrInvalidFormat
+	}
+}
+
+// Extension returns the file extension of format with compression c (eg.: ".csv.gz").
+func Extension(format string, c string) string {
+
+	ext := "." + format
+
+	switch c {
+	case CompressionGzip:
+		ext += ".gz"
+	case CompressionZstd:
+		ext += ".zst"
+	}
+
+	return ext
+}
@@ -0,0 +1,152 @@
+package export
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+
+	"github.com/elmasy-com/columbus/db"
+	"github.com/klauspost/compress/zstd"
+)
+
+var testDomain = db.Domain{
+	Domain:  "example",
+	TLD:     "com",
+	Sub:     "www",
+	Updated: 1700000000,
+	Records: []db.Record{
+		{Type: 1, Value: "203.0.113.1", Time: 1690000000},
+		{Type: 16, Value: "\"v=spf1 -all\"", Time: 1690000001, LastSeen: 1695000000},
+	},
+}
+
+func TestRows(t *testing.T) {
+
+	rs := Rows(&testDomain)
+	if len(rs) != 2 {
+		t.Fatalf("expected 2 rows, got %d", len(rs))
+	}
+
+	if rs[0] != (Row{FQDN: "www.example.com", Type: "A", Value: "203.0.113.1", Time: 1690000000, Updated: 1700000000}) {
+		t.Fatalf("unexpected row: %#v", rs[0])
+	}
+
+	if rs[1].Type != "TXT" || rs[1].Time != 1695000000 {
+		t.Fatalf("unexpected row: %#v", rs[1])
+	}
+
+	empty := db.Domain{Domain: "example", TLD: "com", Updated: 1}
+
+	rs = Rows(&empty)
+	if len(rs) != 1 || rs[0] != (Row{FQDN: "example.com", Updated: 1}) {
+		t.Fatalf("unexpected rows of empty domain: %#v", rs)
+	}
+}
+
+func TestWriter(t *testing.T) {
+
+	cases := []struct {
+		Format string
+		Want   string
+	}{
+		{FormatCSV, "fqdn,type,value,time,updated\nwww.example.com,A,203.0.113.1,1690000000,1700000000\nwww.example.com,TXT,\"\"\"v=spf1 -all\"\"\",1695000000,1700000000\n"},
+		{FormatNDJSON, `{"fqdn":"www.example.com","type":"A","value":"203.0.113.1","time":1690000000,"updated":1700000000}` + "\n" +
+			`{"fqdn":"www.example.com","type":"TXT","value":"\"v=spf1 -all\"","time":1695000000,"updated":1700000000}` + "\n"},
+	}
+
+	for i := range cases {
+
+		b := new(bytes.Buffer)
+
+		w, err := NewWriter(b, cases[i].Format)
+		if err != nil {
+			t.Fatalf("%s: %s", cases[i].Format, err)
+		}
+
+		for _, r := range Rows(&testDomain) {
+			if err := w.Write(r); err != nil {
+				t.Fatalf("%s: %s", cases[i].Format, err)
+			}
+		}
+
+		if err := w.Flush(); err != nil {
+			t.Fatalf("%s: %s", cases[i].Format, err)
+		}
+
+		if b.String() != cases[i].Want {
+			t.Fatalf("%s: unexpected output:\n%s", cases[i].Format, b.String())
+		}
+	}
+
+	if _, err := NewWriter(io.Discard, "parquet"); err == nil {
+		t.Fatalf("expected error for invalid format")
+	}
+}
+
+func TestEmptyCSV(t *testing.T) {
+
+	b := new(bytes.Buffer)
+
+	w, _ := NewWriter(b, FormatCSV)
+
+	if err := w.Flush(); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if b.String() != "fqdn,type,value,time,updated\n" {
+		t.Fatalf("unexpected output: %q", b.String())
+	}
+}
+
+func TestCompressor(t *testing.T) {
+
+	for _, c := range []string{CompressionNone, CompressionGzip, CompressionZstd} {
+
+		b := new(bytes.Buffer)
+
+		w, err := NewCompressor(b, c)
+		if err != nil {
+			t.Fatalf("%q: %s", c, err)
+		}
+
+		w.Write([]byte("example.com\n"))
+
+		if err := w.Flush(); err != nil {
+			t.Fatalf("%q: %s", c, err)
+		}
+
+		if b.Len() == 0 {
+			t.Fatalf("%q: nothing written after flush", c)
+		}
+
+		if err := w.Close(); err != nil {
+			t.Fatalf("%q: %s", c, err)
+		}
+
+		var r io.Reader = b
+
+		switch c {
+		case CompressionGzip:
+			r, err = gzip.NewReader(b)
+		case CompressionZstd:
+			r, err = zstd.NewReader(b)
+		}
+		if err != nil {
+			t.Fatalf("%q: %s", c, err)
+		}
+
+		out, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("%q: %s", c, err)
+		}
+
+		if string(out) != "example.com\n" {
+			t.Fatalf("%q: unexpected output: %q", c, out)
+		}
+	}
+
+	if _, err := NewCompressor(io.Discard, "brotli"); err == nil {
+		t.Fatalf("expected error for invalid compression")
+	}
+}
@@ -3,8 +3,13 @@ fault package is used to predefine common errors in across the columbus services
 */
 package fault
 
+import "errors"
+
+// ColumbusError is an error with a machine-readable code.
+// The JSON form is the error body of the API (eg.: {"error": "invalid domain", "code": "invalid_domain"}).
 type ColumbusError struct {
-	Err string `json:"error"`
+	Err  string `json:"error"`
+	Code string `json:"code"`
 }
 
 func (e ColumbusError) Error() string {
@@ -12,31 +17,61 @@ func (e ColumbusError) Error() string {
 }
 
 var (
-	ErrNameEmpty      = ColumbusError{"name is empty"}
-	ErrUserNameEmpty  = ColumbusError{"username is empty"}
-	ErrDefaultUserNil = ColumbusError{"DefaultUser is nil"}
-	ErrUserNil        = ColumbusError{"user is nil"}
-	ErrMissingAPIKey  = ColumbusError{"missing API key"}
-	ErrInvalidAPIKey  = ColumbusError{"invalid API key"}
-	ErrInvalidDomain  = ColumbusError{"invalid domain"}
-	ErrPublicSuffix   = ColumbusError{"domain is a public suffix"}
-	ErrNotAdmin       = ColumbusError{"not admin"}
-	ErrMissingURI     = ColumbusError{"missing URI"}
-	ErrBlocked        = ColumbusError{"blocked"}
-	ErrNotFound       = ColumbusError{"not found"}
-	ErrUserNotFound   = ColumbusError{"user not found"}
-	ErrNameTaken      = ColumbusError{"name is taken"}
-	ErrBadGateway     = ColumbusError{"bad gateway"}
-	ErrGatewayTimeout = ColumbusError{"gateway timeout"}
-	ErrUserNotDeleted = ColumbusError{"user not deleted"}
-	ErrNotModified    = ColumbusError{"not modified"}
-	ErrMultipleUpdate = ColumbusError{"multiple update"}
-	ErrSameName       = ColumbusError{"username and name are the same"}
-	ErrNothingToDo    = ColumbusError{"nothing to do"}
-	ErrConfirmMissing = ColumbusError{"confirmation is missing"}
-	ErrNotConfirmed   = ColumbusError{"not confirmed"}
-	ErrDataBase       = ColumbusError{"Database error"}
-	ErrGetPartsFailed = ColumbusError{"GetParts() failed"}
-	ErrInvalidDays    = ColumbusError{"invalid days"}
-	ErrTLDOnly        = ColumbusError{"TLD only"}
+	ErrNameEmpty          = ColumbusError{"name is empty", "name_empty"}
+	ErrUserNameEmpty      = ColumbusError{"username is empty", "user_name_empty"}
+	ErrDefaultUserNil     = ColumbusError{"DefaultUser is nil", "default_user_nil"}
+	ErrUserNil            = ColumbusError{"user is nil", "user_nil"}
+	ErrMissingAPIKey      = ColumbusError{"missing API key", "missing_api_key"}
+	ErrInvalidAPIKey      = ColumbusError{"invalid API key", "invalid_api_key"}
+	ErrInvalidDomain      = ColumbusError{"invalid domain", "invalid_domain"}
+	ErrPublicSuffix       = ColumbusError{"domain is a public suffix", "public_suffix"}
+	ErrNotAdmin           = ColumbusError{"not admin", "not_admin"}
+	ErrMissingURI         = ColumbusError{"missing URI", "missing_uri"}
+	ErrBlocked            = ColumbusError{"blocked", "blocked"}
+	ErrNotFound           = ColumbusError{"not found", "not_found"}
+	ErrUserNotFound       = ColumbusError{"user not found", "user_not_found"}
+	ErrNameTaken          = ColumbusError{"name is taken", "name_taken"}
+	ErrBadGateway         = ColumbusError{"bad gateway", "bad_gateway"}
+	ErrGatewayTimeout     = ColumbusError{"gateway timeout", "gateway_timeout"}
+	ErrUserNotDeleted     = ColumbusError{"user not deleted", "user_not_deleted"}
+	ErrNotModified        = ColumbusError{"not modified", "not_modified"}
+	ErrMultipleUpdate     = ColumbusError{"multiple update", "multiple_update"}
+	ErrSameName           = ColumbusError{"username and name are the same", "same_name"}
+	ErrNothingToDo        = ColumbusError{"nothing to do", "nothing_to_do"}
+	ErrConfirmMissing     = ColumbusError{"confirmation is missing", "confirm_missing"}
+	ErrNotConfirmed       = ColumbusError{"not confirmed", "not_confirmed"}
+	ErrDataBase           = ColumbusError{"Database error", "database"}
+	ErrGetPartsFailed     = ColumbusError{"GetParts() failed", "get_parts_failed"}
+	ErrInvalidDays        = ColumbusError{"invalid days", "invalid_days"}
+	ErrTLDOnly            = ColumbusError{"TLD only", "tld_only"}
+	ErrUnavailable        = ColumbusError{"service unavailable", "unavailable"}
+	ErrInvalidURL         = ColumbusError{"invalid URL", "invalid_url"}
+	ErrInvalidEvent       = ColumbusError{"invalid event type", "invalid_event"}
+	ErrInvalidIP          = ColumbusError{"invalid IP address or CIDR", "invalid_ip"}
+	ErrInvalidType        = ColumbusError{"invalid record type", "invalid_type"}
+	ErrInvalidQuery       = ColumbusError{"invalid query", "invalid_query"}
+	ErrQueryTooExpensive  = ColumbusError{"query too expensive", "query_too_expensive"}
+	ErrInvalidTime        = ColumbusError{"invalid time range", "invalid_time"}
+	ErrInvalidFormat      = ColumbusError{"invalid format", "invalid_format"}
+	ErrInvalidCompression = ColumbusError{"invalid compression", "invalid_compression"}
+	ErrInvalidSkip        = ColumbusError{"invalid skip", "invalid_skip"}
+	ErrInvalidLimit       = ColumbusError{"invalid limit", "invalid_limit"}
+	ErrInvalidRegex       = ColumbusError{"invalid regex", "invalid_regex"}
+	ErrRegexTooLong       = ColumbusError{"regex is too long", "regex_too_long"}
+	ErrInvalidBody        = ColumbusError{"invalid body", "invalid_body"}
+	ErrSecretEmpty        = ColumbusError{"secret is empty", "secret_empty"}
+	ErrBadRequest         = ColumbusError{"bad request", "bad_request"}
+	ErrInternal           = ColumbusError{"internal server error", "internal"}
 )
+
+// Code returns the code of the ColumbusError in the chain of err, or an empty string if err is not a ColumbusError.
+func Code(err error) string {
+
+	var ce ColumbusError
+
+	if errors.As(err, &ce) {
+		return ce.Code
+	}
+
+	return ""
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/http"
 
+	"github.com/elmasy-com/columbus/fault"
 	"github.com/gin-gonic/gin"
 )
 
@@ -19,7 +20,13 @@ func Get400Text(c *gin.Context, err error) {
 }
 
 func Get400JSON(c *gin.Context, err error) {
-	c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+
+	code := fault.Code(err)
+	if code == "" {
+		code = fault.ErrBadRequest.Code
+	}
+
+	c.JSON(http.StatusBadRequest, fault.ColumbusError{Err: err.Error(), Code: code})
 }
 
 func Get400HTML(c *gin.Context, err error) {
@@ -52,14 +59,14 @@ func Get400(c *gin.Context) {
 
 	switch c.GetHeader("Accept") {
 	case "":
-		Get400JSON(c, fmt.Errorf("bad request"))
+		Get400JSON(c, fault.ErrBadRequest)
 	case "*/*":
-		Get400JSON(c, fmt.Errorf("bad request"))
+		Get400JSON(c, fault.ErrBadRequest)
 	case "text/plain":
-		Get400Text(c, fmt.Errorf("bad request"))
+		Get400Text(c, fault.ErrBadRequest)
 	case "application/json":
-		Get400JSON(c, fmt.Errorf("bad request"))
+		Get400JSON(c, fault.ErrBadRequest)
 	default:
-		Get400HTML(c, fmt.Errorf("bad request"))
+		Get400HTML(c, fault.ErrBadRequest)
 	}
 }
@@ -6,6 +6,7 @@ import (
 	"html/template"
 	"net/http"
 
+	"github.com/elmasy-com/columbus/fault"
 	"github.com/gin-gonic/gin"
 )
 
@@ -18,11 +19,11 @@ type http500Data struct {
 var DefaultGitHubIssue = "?title=Internal%%20Server%%20Error%%20on%%20%s&body=Internal%%20Server%%20Error%%20on%%20%%60%s%%60%%20%%2E"
 
 func Get500Text(c *gin.Context) {
-	c.String(http.StatusInternalServerError, "internal server error")
+	c.String(http.StatusInternalServerError, fault.ErrInternal.Error())
 }
 
 func Get500JSON(c *gin.Context) {
-	c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+	c.JSON(http.StatusInternalServerError, fault.ErrInternal)
 }
 
 // Get500 set context.
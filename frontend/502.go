@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/http"
 
+	"github.com/elmasy-com/columbus/fault"
 	"github.com/gin-gonic/gin"
 )
 
@@ -14,11 +15,11 @@ type http502Data struct {
 }
 
 func Get502Text(c *gin.Context) {
-	c.String(http.StatusBadGateway, "bad gateway")
+	c.String(http.StatusBadGateway, fault.ErrBadGateway.Error())
 }
 
 func Get502JSON(c *gin.Context) {
-	c.JSON(http.StatusBadGateway, gin.H{"error": "bad gateway"})
+	c.JSON(http.StatusBadGateway, fault.ErrBadGateway)
 }
 
 // Get502HTML
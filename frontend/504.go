@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/http"
 
+	"github.com/elmasy-com/columbus/fault"
 	"github.com/gin-gonic/gin"
 )
 
@@ -14,11 +15,11 @@ type http504Data struct {
 }
 
 func Get504Text(c *gin.Context) {
-	c.String(http.StatusGatewayTimeout, "gateway timeout")
+	c.String(http.StatusGatewayTimeout, fault.ErrGatewayTimeout.Error())
 }
 
 func Get504JSON(c *gin.Context) {
-	c.JSON(http.StatusGatewayTimeout, gin.H{"error": "gateway timeout"})
+	c.JSON(http.StatusGatewayTimeout, fault.ErrGatewayTimeout)
 }
 
 // Get504HTML
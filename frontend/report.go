@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/elmasy-com/columbus/server/respond"
 	"github.com/gin-gonic/gin"
 )
 
@@ -34,9 +35,12 @@ func (s *SubData) Add(sub string, domain string) *SubData {
 }
 
 type RecordsData struct {
-	Type  string
-	Value string
-	Time  string
+	Type      string
+	Value     string
+	Details   []string // Structured fields of the record (eg.: "priority: 10")
+	FirstSeen string
+	Time      string // Last seen
+	Removed   string // Empty if the record is still served
 }
 
 type DomainsData struct {
@@ -53,6 +57,21 @@ type ReportStat struct {
 	TotalRecords       int
 }
 
+type ChangesData struct {
+	Domain  string
+	Status  string // "added", "removed" or "changed"
+	Added   []RecordsData
+	Removed []RecordsData
+}
+
+type ReportChangesData struct {
+	Meta     metaData
+	Question string
+	Since    string
+	Until    string
+	Changes  []ChangesData
+}
+
 type ReportData struct {
 	Meta     metaData
 	Question string
@@ -82,5 +101,27 @@ func GetReport(c *gin.Context, dat ReportData) {
 	c.Header("cache-control", "public, max-age=3600, stale-while-revalidate=86400, stale-if-error=604800")
 	c.Header("expires", time.Now().UTC().Add(3600*time.Second).Format(time.RFC1123))
 
+	respond.Data(c, http.StatusOK, "text/html; charset=utf-8", buf.Bytes())
+}
+
+// GetReportChanges renders the "what changed" view of the report.
+// If failed to render the report-changes.html, returns code 500 with a string: "Internal Server Error".
+func GetReportChanges(c *gin.Context, dat ReportChangesData) {
+
+	buf := new(bytes.Buffer)
+
+	dat.Meta = getMetaData(c.Request, "Columbus Project - Changes of "+dat.Question, DefaultDescription)
+
+	err := templates.ExecuteTemplate(buf, "report-changes", dat)
+	if err != nil {
+		c.Error(fmt.Errorf("failed to render report changes: %w", err))
+		Get500(c)
+		return
+	}
+
+	// Cache for 10 minutes, the window is relative to the current time.
+	c.Header("cache-control", "public, max-age=600, stale-if-error=604800")
+	c.Header("expires", time.Now().UTC().Add(600*time.Second).Format(time.RFC1123))
+
 	c.Data(http.StatusOK, "text/html; charset=utf-8", buf.Bytes())
 }
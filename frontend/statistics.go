@@ -2,7 +2,9 @@ package frontend
 
 import (
 	"bytes"
+	"context"
 	_ "embed"
+	"errors"
 	"fmt"
 	"html/template"
 	"net/http"
@@ -52,9 +54,9 @@ type statisticsData struct {
 	Statistics statistics
 }
 
-func parseStatistic() (statistics, error) {
+func parseStatistic(ctx context.Context) (statistics, error) {
 
-	s, err := db.StatisticsGets()
+	s, err := db.StatisticsGets(ctx)
 	if err != nil {
 		return statistics{}, fmt.Errorf("failed to get newset statistic: %w", err)
 	}
@@ -124,10 +126,14 @@ func parseStatistic() (statistics, error) {
 
 func GetStatistics(c *gin.Context) {
 
-	statistics, err := parseStatistic()
+	statistics, err := parseStatistic(c.Request.Context())
 	if err != nil {
 		c.Error(fmt.Errorf("failed to parse statistics: %w", err))
-		Get500(c)
+		if errors.Is(err, context.DeadlineExceeded) {
+			Get504(c)
+		} else {
+			Get500(c)
+		}
 		return
 	}
 
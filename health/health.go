@@ -0,0 +1,182 @@
+/*
+health package is used to expose the health of the columbus services to the orchestrators.
+
+Every service serves three endpoints:
+  - /healthz: the process is alive (always 200).
+  - /readyz: every readiness check (eg.: MongoDB ping) passed (200), or at least one failed (503).
+  - /version: the build informations.
+
+The server mounts the handlers to its router, the scanner and the DNS server use ListenAndServe() on the admin address.
+*/
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// CheckTimeout is the deadline of the readiness checks.
+var CheckTimeout = 2 * time.Second
+
+// Check is a readiness check, returns nil if the component is ready.
+type Check func(ctx context.Context) error
+
+// Build is the build informations of a service.
+type Build struct {
+	Service   string `json:"service"`
+	Date      string `json:"buildDate"`
+	Commit    string `json:"buildCommit"`
+	GoVersion string `json:"goVersion"`
+}
+
+// Status is the result of the readiness checks.
+// Checks is the result of every check, "ok" or the error message.
+type Status struct {
+	Ready  bool              `json:"ready"`
+	Checks map[string]string `json:"checks"`
+}
+
+// Checker holds the readiness checks and the build informations of a service.
+type Checker struct {
+	m      sync.RWMutex
+	build  Build
+	checks map[string]Check
+}
+
+// New creates a new Checker for service with the build date and commit (set with -ldflags, can be empty).
+func New(service string, date string, commit string) *Checker {
+
+	return &Checker{
+		build:  Build{Service: service, Date: date, Commit: commit, GoVersion: runtime.Version()},
+		checks: make(map[string]Check),
+	}
+}
+
+// Add adds the readiness check c with name (eg.: "mongo").
+// A check with the same name is replaced.
+func (h *Checker) Add(name string, c Check) {
+
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	h.checks[name] = c
+}
+
+// Ready runs every check concurrently with CheckTimeout and returns the result.
+func (h *Checker) Ready(ctx context.Context) Status {
+
+	ctx, cancel := context.WithTimeout(ctx, CheckTimeout)
+	defer cancel()
+
+	h.m.RLock()
+	defer h.m.RUnlock()
+
+	s := Status{Ready: true, Checks: make(map[string]string, len(h.checks))}
+
+	var (
+		wg sync.WaitGroup
+		m  sync.Mutex
+	)
+
+	for name, c := range h.checks {
+
+		wg.Add(1)
+
+		go func(name string, c Check) {
+
+			defer wg.Done()
+
+			err := c(ctx)
+
+			m.Lock()
+			defer m.Unlock()
+
+			if err != nil {
+				s.Ready = false
+				s.Checks[name] = err.Error()
+			} else {
+				s.Checks[name] = "ok"
+			}
+		}(name, c)
+	}
+
+	wg.Wait()
+
+	return s
+}
+
+// writeJSON writes v with code.
+func writeJSON(w http.ResponseWriter, code int, v any) {
+
+	w.Header().Set("content-type", "application/json; charset=utf-8")
+	w.Header().Set("cache-control", "no-store")
+	w.WriteHeader(code)
+
+	json.NewEncoder(w).Encode(v)
+}
+
+// Healthz responds with 200 and {"status": "ok"} while the process is alive.
+func (h *Checker) Healthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// Readyz runs the checks and responds with the Status, with 200 if every check passed or 503 if any failed.
+func (h *Checker) Readyz(w http.ResponseWriter, r *http.Request) {
+
+	s := h.Ready(r.Context())
+
+	if s.Ready {
+		writeJSON(w, http.StatusOK, s)
+	} else {
+		writeJSON(w, http.StatusServiceUnavailable, s)
+	}
+}
+
+// Version responds with the Build.
+func (h *Checker) Version(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.build)
+}
+
+// Handler returns a handler that serves /healthz, /readyz and /version.
+func (h *Checker) Handler() http.Handler {
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/healthz", h.Healthz)
+	mux.HandleFunc("/readyz", h.Readyz)
+	mux.HandleFunc("/version", h.Version)
+
+	return mux
+}
+
+// ListenAndServe serves Handler() on addr until ctx is done.
+func (h *Checker) ListenAndServe(ctx context.Context, addr string) error {
+
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           h.Handler(),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+
+		sctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		srv.Shutdown(sctx)
+	}()
+
+	err := srv.ListenAndServe()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,48 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+
+	h := New("test", "01/02/24 15:04", "abc")
+	h.Add("ok", func(ctx context.Context) error { return nil })
+
+	get := func(path string) *httptest.ResponseRecorder {
+
+		w := httptest.NewRecorder()
+		h.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+
+		return w
+	}
+
+	if w := get("/healthz"); w.Code != http.StatusOK {
+		t.Fatalf("/healthz: want 200, got %d", w.Code)
+	}
+
+	var b Build
+
+	if w := get("/version"); w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &b) != nil || b.Service != "test" || b.Commit != "abc" || b.GoVersion == "" {
+		t.Fatalf("/version: unexpected response: %d %s", w.Code, w.Body.String())
+	}
+
+	var s Status
+
+	if w := get("/readyz"); w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &s) != nil || !s.Ready || s.Checks["ok"] != "ok" {
+		t.Fatalf("/readyz: unexpected response: %d %s", w.Code, w.Body.String())
+	}
+
+	h.Add("mongo", func(ctx context.Context) error { return fmt.Errorf("unreachable") })
+
+	s = Status{}
+
+	if w := get("/readyz"); w.Code != http.StatusServiceUnavailable || json.Unmarshal(w.Body.Bytes(), &s) != nil || s.Ready || s.Checks["mongo"] != "unreachable" || s.Checks["ok"] != "ok" {
+		t.Fatalf("/readyz: unexpected response: %d %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/elmasy-com/columbus/db"
+	"github.com/elmasy-com/columbus/fault"
+)
+
+var (
+	BuildDate   string
+	BuildCommit string
+	mongoURI    = flag.String("uri", "", "MongoDB connection URI")
+	format      = flag.String("format", "", "Format of the input: list, zone or csv (default: based on the file extension, list for stdin)")
+	origin      = flag.String("origin", ".", "Origin of the relative names in a zone file")
+	column      = flag.String("column", "", "Name or 1-based index of the column in a CSV file (default: fqdn, domain or name in the header, or the first column)")
+	offset      = flag.Int("offset", 0, "Skip the entries until this line (the record number in a zone file), used to resume an import")
+	batchSize   = flag.Int("batch", 1000, "Number of names inserted in one bulk write")
+	update      = flag.Bool("update", false, "Queue a records update for every imported name")
+	dryRun      = flag.Bool("dry-run", false, "Validate the input and print a report without connecting to the database")
+	version     = flag.Bool("version", false, "Print current version")
+)
+
+// report is the summary of the import.
+type report struct {
+	Read         int
+	Valid        int
+	Invalid      int
+	PublicSuffix int
+	Inserted     int64
+}
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-uri <uri>] [options] <file>\n\n", os.Args[0])
+	fmt.Fprintf(flag.CommandLine.Output(), "Imports the names from file (\"-\" is the standard input) to the domains collection.\n\n")
+	flag.PrintDefaults()
+}
+
+// importer validates the names from src and inserts them in batches.
+// Returns the line of the last entry stored in the database, an import can be resumed from it with -offset.
+func importer(ctx context.Context, src source, r *report) (int, error) {
+
+	var (
+		batch []string
+		seen  = make(map[string]struct{}, *batchSize)
+		last  = *offset
+		line  int
+	)
+
+	flush := func() error {
+
+		if len(batch) == 0 {
+			return nil
+		}
+
+		if !*dryRun {
+
+			n, err := db.DomainsInsertMany(ctx, batch)
+			if err != nil {
+				return fmt.Errorf("failed to insert: %w", err)
+			}
+
+			r.Inserted += n
+
+			if *update {
+				err = db.QueueAddMany(ctx, batch, db.UpdateExistingDomain, db.PriorityRefresh)
+				if err != nil {
+					return fmt.Errorf("failed to queue: %w", err)
+				}
+			}
+
+			fmt.Printf("Line %d: %d valid, %d new\n", line, r.Valid, r.Inserted)
+		}
+
+		last = line
+		batch = batch[:0]
+
+		for k := range seen {
+			delete(seen, k)
+		}
+
+		return nil
+	}
+
+	for {
+
+		if ctx.Err() != nil {
+			return last, ctx.Err()
+		}
+
+		e, err := src.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return last, fmt.Errorf("failed to read after line %d: %w", line, err)
+		}
+
+		if e.Line <= *offset {
+			continue
+		}
+
+		line = e.Line
+		r.Read++
+
+		// Wildcard records are imported as the parent name
+		d, err := db.DomainsValidate(strings.TrimPrefix(e.Name, "*."))
+		switch {
+		case errors.Is(err, fault.ErrPublicSuffix):
+			r.PublicSuffix++
+			fmt.Fprintf(os.Stderr, "Line %d: public suffix: %s\n", e.Line, e.Name)
+			continue
+		case err != nil:
+			r.Invalid++
+			fmt.Fprintf(os.Stderr, "Line %d: invalid: %s\n", e.Line, e.Name)
+			continue
+		}
+
+		r.Valid++
+
+		if _, ok := seen[d]; ok {
+			continue
+		}
+
+		seen[d] = struct{}{}
+		batch = append(batch, d)
+
+		if len(batch) >= *batchSize {
+			if err := flush(); err != nil {
+				return last, err
+			}
+		}
+	}
+
+	if err := flush(); err != nil {
+		return last, err
+	}
+
+	return last, nil
+}
+
+func main() {
+
+	flag.Usage = usage
+	flag.Parse()
+
+	if *version {
+		fmt.Printf("Build date: %s\n", BuildDate)
+		fmt.Printf("Git Commit: %s\n", BuildCommit)
+		os.Exit(0)
+	}
+
+	if *mongoURI == "" && !*dryRun {
+		fmt.Fprintf(os.Stderr, "-uri is missing!\n")
+		os.Exit(1)
+	}
+
+	if *batchSize < 1 {
+		fmt.Fprintf(os.Stderr, "Invalid -batch: %d\n", *batchSize)
+		os.Exit(1)
+	}
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	path := flag.Arg(0)
+
+	var in io.Reader = os.Stdin
+
+	if path != "-" {
+
+		file, err := os.Open(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to open %s: %s\n", path, err)
+			os.Exit(1)
+		}
+		defer file.Close()
+
+		in = file
+
+		if *format == "" {
+			*format = formatFromPath(path)
+		}
+	}
+
+	if *format == "" {
+		*format = formatList
+	}
+
+	src, err := newSource(in, *format, *origin, *column)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	if !*dryRun {
+		err = db.Connect(ctx, *mongoURI)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to connect to MongoDB: %s\n", err)
+			os.Exit(1)
+		}
+	}
+
+	r := new(report)
+
+	last, err := importer(ctx, src, r)
+
+	if !*dryRun {
+		db.Disconnect(context.Background())
+	}
+
+	if *dryRun {
+		fmt.Printf("Dry run, nothing is inserted\n")
+	}
+	fmt.Printf("Read: %d\n", r.Read)
+	fmt.Printf("Valid: %d\n", r.Valid)
+	fmt.Printf("Invalid: %d\n", r.Invalid)
+	fmt.Printf("Public suffix: %d\n", r.PublicSuffix)
+	if !*dryRun {
+		fmt.Printf("New: %d\n", r.Inserted)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to import: %s\n", err)
+		fmt.Fprintf(os.Stderr, "Resume with -offset %d\n", last)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	mdns "github.com/miekg/dns"
+)
+
+// Formats of the input.
+const (
+	formatList = "list"
+	formatZone = "zone"
+	formatCSV  = "csv"
+)
+
+// entry is a name read from the input.
+// Line is the position of the name in the input, used to report invalid names and to resume the import.
+type entry struct {
+	Name string
+	Line int
+}
+
+// source reads the names from the input.
+// Next() returns io.EOF after the last name.
+type source interface {
+	Next() (entry, error)
+}
+
+// listSource reads one name per line.
+// Empty lines and lines starting with "#" are skipped, the text after the first whitespace is ignored.
+type listSource struct {
+	s    *bufio.Scanner
+	line int
+}
+
+func newListSource(r io.Reader) *listSource {
+	return &listSource{s: bufio.NewScanner(r)}
+}
+
+func (s *listSource) Next() (entry, error) {
+
+	for s.s.Scan() {
+
+		s.line++
+
+		fs := strings.Fields(s.s.Text())
+		if len(fs) == 0 || strings.HasPrefix(fs[0], "#") {
+			continue
+		}
+
+		return entry{Name: fs[0], Line: s.line}, nil
+	}
+
+	if err := s.s.Err(); err != nil {
+		return entry{}, err
+	}
+
+	return entry{}, io.EOF
+}
+
+// zoneSource reads the owner names of the records in a BIND zone file.
+// The line of an entry is the number of the record, the zone parser does not expose the line numbers.
+type zoneSource struct {
+	zp *mdns.ZoneParser
+	n  int
+}
+
+func newZoneSource(r io.Reader, origin string) *zoneSource {
+
+	zp := mdns.NewZoneParser(r, mdns.Fqdn(origin), "")
+	zp.SetIncludeAllowed(false)
+
+	return &zoneSource{zp: zp}
+}
+
+func (s *zoneSource) Next() (entry, error) {
+
+	rr, ok := s.zp.Next()
+	if !ok {
+		if err := s.zp.Err(); err != nil {
+			return entry{}, err
+		}
+		return entry{}, io.EOF
+	}
+
+	s.n++
+
+	return entry{Name: rr.Header().Name, Line: s.n}, nil
+}
+
+// csvSource reads the names from a column of a CSV file.
+type csvSource struct {
+	r      *csv.Reader
+	column string
+	index  int
+}
+
+// newCSVSource returns a csvSource that reads column.
+// column is the name of the column in the header or the 1-based index of the column (eg.: "2").
+// If column is empty, the column "fqdn", "domain" or "name" of the header is used if exists, or the first column without header.
+func newCSVSource(r io.Reader, column string) *csvSource {
+
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.Comment = '#'
+	cr.TrimLeadingSpace = true
+
+	return &csvSource{r: cr, column: column, index: -1}
+}
+
+// header sets the index of the column from the first record.
+// Returns true if the first record is a header.
+func (s *csvSource) header(rec []string) (bool, error) {
+
+	if i, err := strconv.Atoi(s.column); err == nil {
+
+		if i < 1 {
+			return false, fmt.Errorf("invalid column: %s", s.column)
+		}
+
+		s.index = i - 1
+
+		return false, nil
+	}
+
+	names := []string{"fqdn", "domain", "name"}
+	if s.column != "" {
+		names = []string{s.column}
+	}
+
+	for _, n := range names {
+		for i := range rec {
+			if strings.EqualFold(strings.TrimSpace(rec[i]), n) {
+				s.index = i
+				return true, nil
+			}
+		}
+	}
+
+	if s.column != "" {
+		return false, fmt.Errorf("column not found in header: %s", s.column)
+	}
+
+	s.index = 0
+
+	return false, nil
+}
+
+func (s *csvSource) Next() (entry, error) {
+
+	for {
+
+		rec, err := s.r.Read()
+		if err != nil {
+			return entry{}, err
+		}
+
+		line, _ := s.r.FieldPos(0)
+
+		if s.index < 0 {
+
+			isHeader, err := s.header(rec)
+			if err != nil {
+				return entry{}, err
+			}
+
+			if isHeader {
+				continue
+			}
+		}
+
+		if s.index >= len(rec) || strings.TrimSpace(rec[s.index]) == "" {
+			continue
+		}
+
+		return entry{Name: strings.TrimSpace(rec[s.index]), Line: line}, nil
+	}
+}
+
+// newSource returns the source of format.
+func newSource(r io.Reader, format string, origin string, column string) (source, error) {
+
+	switch format {
+	case formatList:
+		return newListSource(r), nil
+	case formatZone:
+		return newZoneSource(r, origin), nil
+	case formatCSV:
+		return newCSVSource(r, column), nil
+	default:
+		return nil, errors.New("unknown format: " + format)
+	}
+}
+
+// formatFromPath returns the format based on the extension of path.
+// Returns formatList if the extension is unknown.
+func formatFromPath(path string) string {
+
+	switch {
+	case strings.HasSuffix(path, ".csv"):
+		return formatCSV
+	case strings.HasSuffix(path, ".zone"), strings.HasSuffix(path, ".db"):
+		return formatZone
+	default:
+		return formatList
+	}
+}
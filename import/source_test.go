@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// readAll returns every entry from s.
+func readAll(t *testing.T, s source) []entry {
+
+	var es []entry
+
+	for {
+		e, err := s.Next()
+		if errors.Is(err, io.EOF) {
+			return es
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		es = append(es, e)
+	}
+}
+
+func TestListSource(t *testing.T) {
+
+	in := "www.example.com\n\n# comment\n  mail.example.com  1.2.3.4\n"
+
+	es := readAll(t, newListSource(strings.NewReader(in)))
+
+	want := []entry{{"www.example.com", 1}, {"mail.example.com", 4}}
+
+	if len(es) != len(want) {
+		t.Fatalf("unexpected entries: %v", es)
+	}
+
+	for i := range want {
+		if es[i] != want[i] {
+			t.Fatalf("entry %d: want %v, got %v", i, want[i], es[i])
+		}
+	}
+}
+
+func TestZoneSource(t *testing.T) {
+
+	in := "$TTL 300\n@ IN SOA ns1 hostmaster 1 2 3 4 5\n@ IN NS ns1\nwww IN A 203.0.113.1\n*.dev IN CNAME www\n"
+
+	es := readAll(t, newZoneSource(strings.NewReader(in), "example.com"))
+
+	want := []string{"example.com.", "example.com.", "www.example.com.", "*.dev.example.com."}
+
+	if len(es) != len(want) {
+		t.Fatalf("unexpected entries: %v", es)
+	}
+
+	for i := range want {
+		if es[i].Name != want[i] || es[i].Line != i+1 {
+			t.Fatalf("entry %d: want %s, got %v", i, want[i], es[i])
+		}
+	}
+
+	_, err := newZoneSource(strings.NewReader("www IN A invalid\n"), "example.com").Next()
+	if err == nil || errors.Is(err, io.EOF) {
+		t.Fatalf("expected parse error, got %v", err)
+	}
+}
+
+func TestCSVSource(t *testing.T) {
+
+	cases := []struct {
+		In     string
+		Column string
+		Want   []entry
+	}{
+		{"id,Domain\n1,a.example.com\n2,b.example.com\n", "", []entry{{"a.example.com", 2}, {"b.example.com", 3}}},
+		{"a.example.com,1\nb.example.com,2\n", "", []entry{{"a.example.com", 1}, {"b.example.com", 2}}},
+		{"1,a.example.com\n2,\n3,b.example.com\n", "2", []entry{{"a.example.com", 1}, {"b.example.com", 3}}},
+		{"id,host\n1,a.example.com\n", "host", []entry{{"a.example.com", 2}}},
+	}
+
+	for i := range cases {
+
+		es := readAll(t, newCSVSource(strings.NewReader(cases[i].In), cases[i].Column))
+
+		if len(es) != len(cases[i].Want) {
+			t.Fatalf("case %d: unexpected entries: %v", i, es)
+		}
+
+		for j := range es {
+			if es[j] != cases[i].Want[j] {
+				t.Fatalf("case %d: want %v, got %v", i, cases[i].Want[j], es[j])
+			}
+		}
+	}
+
+	_, err := newCSVSource(strings.NewReader("id,host\n1,a.example.com\n"), "fqdn").Next()
+	if err == nil {
+		t.Fatalf("expected error for missing column")
+	}
+}
@@ -0,0 +1,144 @@
+/*
+logger package is used to set up the structured logging (log/slog) of the columbus services.
+
+The services call Setup() after parsing the config file, and log with the slog functions (eg.: slog.ErrorContext()).
+If the context has a request ID (see WithRequestID()), the ID is added to the record as "requestId".
+*/
+package logger
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// RequestIDKey is the key of the request ID in the log records.
+const RequestIDKey = "requestId"
+
+type requestIDKey struct{}
+
+// ParseLevel parses the log level s ("debug", "info", "warn" or "error").
+// Empty string means "info".
+func ParseLevel(s string) (slog.Level, error) {
+
+	switch strings.ToLower(s) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return slog.LevelInfo, fmt.Errorf("unknown level: %s", s)
+	}
+}
+
+// ParseFormat checks the log format s ("text" or "json").
+// Empty string means "text".
+func ParseFormat(s string) (string, error) {
+
+	switch strings.ToLower(s) {
+	case "", "text":
+		return "text", nil
+	case "json":
+		return "json", nil
+	default:
+		return "", fmt.Errorf("unknown format: %s", s)
+	}
+}
+
+// New returns a logger that writes the records with level or above to w in format.
+func New(w io.Writer, level string, format string) (*slog.Logger, error) {
+
+	l, err := ParseLevel(level)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := ParseFormat(format)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &slog.HandlerOptions{Level: l}
+
+	var h slog.Handler
+
+	if f == "json" {
+		h = slog.NewJSONHandler(w, opts)
+	} else {
+		h = slog.NewTextHandler(w, opts)
+	}
+
+	return slog.New(contextHandler{Handler: h}), nil
+}
+
+// Setup sets the default logger to write the records with level or above to STDERR in format.
+func Setup(level string, format string) error {
+
+	l, err := New(os.Stderr, level, format)
+	if err != nil {
+		return err
+	}
+
+	slog.SetDefault(l)
+
+	return nil
+}
+
+// contextHandler adds the request ID of the context to the records.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String(RequestIDKey, id))
+	}
+
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{Handler: h.Handler.WithGroup(name)}
+}
+
+// NewRequestID returns a new random request ID.
+func NewRequestID() string {
+
+	b := make([]byte, 8)
+
+	// crypto/rand.Read() never returns an error
+	rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
+
+// WithRequestID returns a copy of ctx with the request ID id.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID of ctx, or an empty string if not set.
+func RequestID(ctx context.Context) string {
+
+	if ctx == nil {
+		return ""
+	}
+
+	id, _ := ctx.Value(requestIDKey{}).(string)
+
+	return id
+}
@@ -0,0 +1,56 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+
+	b := new(bytes.Buffer)
+
+	l, err := New(b, "warn", "json")
+	if err != nil {
+		t.Fatalf("failed to create logger: %s", err)
+	}
+
+	l.InfoContext(context.Background(), "dropped")
+
+	if b.Len() != 0 {
+		t.Fatalf("record below level is written: %s", b.String())
+	}
+
+	l.WarnContext(WithRequestID(context.Background(), "abc"), "written", "domain", "example.com")
+
+	var r map[string]any
+
+	if err := json.Unmarshal(b.Bytes(), &r); err != nil {
+		t.Fatalf("failed to unmarshal %s: %s", b.String(), err)
+	}
+
+	if r["msg"] != "written" || r["domain"] != "example.com" || r[RequestIDKey] != "abc" {
+		t.Fatalf("unexpected record: %s", b.String())
+	}
+
+	if _, err := New(b, "verbose", "text"); err == nil {
+		t.Fatalf("invalid level is accepted")
+	}
+
+	if _, err := New(b, "info", "xml"); err == nil {
+		t.Fatalf("invalid format is accepted")
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+
+	cases := map[string]slog.Level{"": slog.LevelInfo, "DEBUG": slog.LevelDebug, "warning": slog.LevelWarn, "error": slog.LevelError}
+
+	for s, want := range cases {
+		if l, err := ParseLevel(s); err != nil || l != want {
+			t.Fatalf("%q: want %s, got %s (%v)", s, want, l, err)
+		}
+	}
+}
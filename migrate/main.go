@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/elmasy-com/columbus/db"
+)
+
+var (
+	BuildDate   string
+	BuildCommit string
+	mongoURI    = flag.String("uri", "", "MongoDB connection URI")
+	dryRun      = flag.Bool("dry-run", false, "Print the pending migrations without applying them")
+	version     = flag.Bool("version", false, "Print current version")
+)
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s -uri <uri> [-dry-run] up|status\n\n", os.Args[0])
+	fmt.Fprintf(flag.CommandLine.Output(), "Commands:\n")
+	fmt.Fprintf(flag.CommandLine.Output(), "  up\tApply the pending migrations in order\n")
+	fmt.Fprintf(flag.CommandLine.Output(), "  status\tPrint the status of every migration\n\n")
+	flag.PrintDefaults()
+}
+
+func status(ctx context.Context) error {
+
+	ss, err := db.MigrationsStatus(ctx)
+	if err != nil {
+		return err
+	}
+
+	for i := range ss {
+		if ss[i].Applied == 0 {
+			fmt.Printf("%3d pending                   %s\n", ss[i].Version, ss[i].Name)
+		} else {
+			fmt.Printf("%3d applied %s %s\n", ss[i].Version, time.Unix(ss[i].Applied, 0).UTC().Format(time.RFC3339), ss[i].Name)
+		}
+	}
+
+	return nil
+}
+
+func up(ctx context.Context) error {
+
+	if *dryRun {
+
+		ms, err := db.MigrationsPending(ctx)
+		if err != nil {
+			return err
+		}
+
+		if len(ms) == 0 {
+			fmt.Printf("Database is up to date\n")
+			return nil
+		}
+
+		for i := range ms {
+			fmt.Printf("Would apply %d: %s\n", ms[i].Version, ms[i].Name)
+		}
+
+		return nil
+	}
+
+	err := db.MigrationsUp(ctx, func(m db.Migration) { fmt.Printf("Applying %d: %s...\n", m.Version, m.Name) })
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Database is up to date\n")
+
+	return nil
+}
+
+func main() {
+
+	flag.Usage = usage
+	flag.Parse()
+
+	if *version {
+		fmt.Printf("Build date: %s\n", BuildDate)
+		fmt.Printf("Git Commit: %s\n", BuildCommit)
+		os.Exit(0)
+	}
+
+	if *mongoURI == "" {
+		fmt.Fprintf(os.Stderr, "-uri is missing!\n")
+		os.Exit(1)
+	}
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	var cmd func(context.Context) error
+
+	switch flag.Arg(0) {
+	case "up":
+		cmd = up
+	case "status":
+		cmd = status
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command: %s\n", flag.Arg(0))
+		os.Exit(1)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	err := db.Connect(ctx, *mongoURI)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to connect to MongoDB: %s\n", err)
+		os.Exit(1)
+	}
+
+	err = cmd(ctx)
+
+	db.Disconnect(context.Background())
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to %s: %s\n", flag.Arg(0), err)
+		os.Exit(1)
+	}
+}
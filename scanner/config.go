@@ -4,16 +4,23 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/elmasy-com/columbus/logger"
 	"github.com/elmasy-com/elnet/ctlog"
 	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
-	LogName       string     `yaml:"LogName"`
-	MongoURI      string     `yaml:"MongoURI"`
-	InsertWorkers int        `yaml:"InsertWorkers"`
-	SkipDomain    bool       `yaml:"SkipDomain"`
-	Log           *ctlog.Log `yaml:"-"`
+	LogName       string       `yaml:"LogName"`
+	MongoURI      string       `yaml:"MongoURI"`
+	InsertWorkers int          `yaml:"InsertWorkers"`
+	SkipDomain    bool         `yaml:"SkipDomain"`
+	FilterRules   FilterConfig `yaml:"Filter"`
+	AdminAddress  string       `yaml:"AdminAddress"`
+	MaxLag        int64        `yaml:"MaxLag"`
+	LogLevel      string       `yaml:"LogLevel"`
+	LogFormat     string       `yaml:"LogFormat"`
+	Log           *ctlog.Log   `yaml:"-"`
+	Filter        *Filter      `yaml:"-"`
 }
 
 var Conf *Config
@@ -52,5 +59,25 @@ func ParseConfig(path string) error {
 		Conf.InsertWorkers = 2
 	}
 
+	if Conf.MaxLag < 0 {
+		return fmt.Errorf("MaxLag is negative")
+	}
+	if Conf.MaxLag == 0 {
+		Conf.MaxLag = 100000
+	}
+
+	if _, err := logger.ParseLevel(Conf.LogLevel); err != nil {
+		return fmt.Errorf("invalid LogLevel: %w", err)
+	}
+
+	if _, err := logger.ParseFormat(Conf.LogFormat); err != nil {
+		return fmt.Errorf("invalid LogFormat: %w", err)
+	}
+
+	Conf.Filter, err = NewFilter(Conf.FilterRules)
+	if err != nil {
+		return fmt.Errorf("invalid Filter: %w", err)
+	}
+
 	return nil
 }
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/elmasy-com/elnet/dns"
+	"github.com/elmasy-com/elnet/validator"
+	"golang.org/x/net/publicsuffix"
+)
+
+// FilterConfig is the include/exclude rules of the scanner in the config file.
+//
+// The exclude rules are checked first, a name matching any of them is dropped.
+// If any include rule is set, a name must match at least one of them.
+// The TLD and domain rules match the name and every subdomain of it (eg.: "example.com" matches "www.example.com").
+// The regexes are matched against the lowercase name without the trailing dot.
+type FilterConfig struct {
+	IncludeTLDs    []string `yaml:"IncludeTLDs"`
+	IncludeDomains []string `yaml:"IncludeDomains"`
+	IncludeRegex   []string `yaml:"IncludeRegex"`
+	ExcludeTLDs    []string `yaml:"ExcludeTLDs"`
+	ExcludeDomains []string `yaml:"ExcludeDomains"`
+	ExcludeRegex   []string `yaml:"ExcludeRegex"`
+
+	// Drop the names under a private suffix of the Public Suffix List (eg.: "*.github.io", "*.cloudfront.net").
+	// The ICANN public suffixes (eg.: "co.uk") are always dropped.
+	ExcludePrivateSuffix bool `yaml:"ExcludePrivateSuffix"`
+}
+
+// Names of the counters of the rules that not configured by the user.
+const (
+	ruleInvalid       = "invalid"
+	rulePublicSuffix  = "public suffix"
+	rulePrivateSuffix = "private suffix"
+	ruleNotIncluded   = "not included"
+)
+
+// filterRule is a compiled TLD, domain or regex rule.
+type filterRule struct {
+	name  string // Used in the stats (eg.: "ExcludeTLDs com")
+	value string
+	re    *regexp.Regexp
+}
+
+// Filter is the compiled FilterConfig.
+// The counters are created in NewFilter(), the map is read only, so Filter is safe for concurrent use.
+type Filter struct {
+	includeTLDs    []filterRule
+	includeDomains []filterRule
+	includeRegex   []filterRule
+	excludeTLDs    []filterRule
+	excludeDomains []filterRule
+	excludeRegex   []filterRule
+	privateSuffix  bool
+	dropped        map[string]*atomic.Int64
+}
+
+// validTLD returns whether every label of tld is valid (eg.: "com", "co.uk").
+func validTLD(tld string) bool {
+
+	tld = strings.TrimSuffix(tld, ".")
+	if tld == "" {
+		return false
+	}
+
+	for _, l := range strings.Split(tld, ".") {
+		if !validator.DomainPart(l) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// newRules returns the TLD/domain rules of values with the name prefix.
+// The values are validated with valid and Clean()-ed.
+func newRules(prefix string, values []string, valid func(string) bool) ([]filterRule, error) {
+
+	rs := make([]filterRule, 0, len(values))
+
+	for _, v := range values {
+
+		if !valid(v) {
+			return nil, fmt.Errorf("%s: invalid value: %s", prefix, v)
+		}
+
+		v = dns.Clean(v)
+
+		rs = append(rs, filterRule{name: prefix + " " + v, value: v})
+	}
+
+	return rs, nil
+}
+
+// newRegexRules returns the regex rules of exprs with the name prefix.
+func newRegexRules(prefix string, exprs []string) ([]filterRule, error) {
+
+	rs := make([]filterRule, 0, len(exprs))
+
+	for _, e := range exprs {
+
+		re, err := regexp.Compile(e)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", prefix, err)
+		}
+
+		rs = append(rs, filterRule{name: prefix + " " + e, re: re})
+	}
+
+	return rs, nil
+}
+
+// NewFilter compiles the rules in c.
+func NewFilter(c FilterConfig) (*Filter, error) {
+
+	f := &Filter{privateSuffix: c.ExcludePrivateSuffix}
+
+	var err error
+
+	if f.includeTLDs, err = newRules("IncludeTLDs", c.IncludeTLDs, validTLD); err != nil {
+		return nil, err
+	}
+	if f.includeDomains, err = newRules("IncludeDomains", c.IncludeDomains, validator.Domain); err != nil {
+		return nil, err
+	}
+	if f.includeRegex, err = newRegexRules("IncludeRegex", c.IncludeRegex); err != nil {
+		return nil, err
+	}
+	if f.excludeTLDs, err = newRules("ExcludeTLDs", c.ExcludeTLDs, validTLD); err != nil {
+		return nil, err
+	}
+	if f.excludeDomains, err = newRules("ExcludeDomains", c.ExcludeDomains, validator.Domain); err != nil {
+		return nil, err
+	}
+	if f.excludeRegex, err = newRegexRules("ExcludeRegex", c.ExcludeRegex); err != nil {
+		return nil, err
+	}
+
+	f.dropped = map[string]*atomic.Int64{
+		ruleInvalid:       new(atomic.Int64),
+		rulePublicSuffix:  new(atomic.Int64),
+		rulePrivateSuffix: new(atomic.Int64),
+		ruleNotIncluded:   new(atomic.Int64),
+	}
+
+	for _, rs := range [][]filterRule{f.excludeTLDs, f.excludeDomains, f.excludeRegex} {
+		for i := range rs {
+			f.dropped[rs[i].name] = new(atomic.Int64)
+		}
+	}
+
+	return f, nil
+}
+
+// isSubOf returns whether d is s or a subdomain of s.
+func isSubOf(d string, s string) bool {
+	return d == s || strings.HasSuffix(d, "."+s)
+}
+
+// match returns the first rule in rs that matches d.
+func match(rs []filterRule, d string) *filterRule {
+
+	for i := range rs {
+
+		if rs[i].re != nil {
+			if rs[i].re.MatchString(d) {
+				return &rs[i]
+			}
+			continue
+		}
+
+		if isSubOf(d, rs[i].value) {
+			return &rs[i]
+		}
+	}
+
+	return nil
+}
+
+// drop increments the counter of rule and returns false.
+func (f *Filter) drop(rule string) bool {
+
+	f.dropped[rule].Add(1)
+
+	return false
+}
+
+// Allow returns whether d passes the rules and should be inserted.
+// The counter of the rule that dropped d is incremented.
+func (f *Filter) Allow(d string) bool {
+
+	if !validator.Domain(d) {
+		return f.drop(ruleInvalid)
+	}
+
+	d = dns.Clean(d)
+
+	p := dns.GetParts(d)
+	if p == nil || p.Domain == "" || p.TLD == "" {
+		return f.drop(rulePublicSuffix)
+	}
+
+	if f.privateSuffix {
+		// Unknown TLDs returns the last label as a non ICANN suffix, private suffixes has at least two labels
+		if s, icann := publicsuffix.PublicSuffix(d); !icann && strings.Contains(s, ".") {
+			return f.drop(rulePrivateSuffix)
+		}
+	}
+
+	// The TLD rules are matched on the TLD, so "uk" matches "example.co.uk"
+	if r := match(f.excludeTLDs, p.TLD); r != nil {
+		return f.drop(r.name)
+	}
+
+	if r := match(f.excludeDomains, d); r != nil {
+		return f.drop(r.name)
+	}
+
+	if r := match(f.excludeRegex, d); r != nil {
+		return f.drop(r.name)
+	}
+
+	if len(f.includeTLDs) == 0 && len(f.includeDomains) == 0 && len(f.includeRegex) == 0 {
+		return true
+	}
+
+	if match(f.includeTLDs, p.TLD) != nil || match(f.includeDomains, d) != nil || match(f.includeRegex, d) != nil {
+		return true
+	}
+
+	return f.drop(ruleNotIncluded)
+}
+
+// Stats returns the number of dropped names by rule in the form of "rule=n, ...".
+// The rules without dropped names are omitted.
+func (f *Filter) Stats() string {
+
+	var ss []string
+
+	for rule, n := range f.dropped {
+		if v := n.Load(); v > 0 {
+			ss = append(ss, fmt.Sprintf("%s=%d", rule, v))
+		}
+	}
+
+	sort.Strings(ss)
+
+	return strings.Join(ss, ", ")
+}
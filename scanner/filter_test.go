@@ -0,0 +1,70 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestFilter(t *testing.T) {
+
+	f, err := NewFilter(FilterConfig{
+		IncludeTLDs:          []string{"com", "uk"},
+		IncludeDomains:       []string{"example.org"},
+		ExcludeDomains:       []string{"cdn.example.com"},
+		ExcludeRegex:         []string{`^ip-\d+`},
+		ExcludePrivateSuffix: true,
+	})
+	if err != nil {
+		t.Fatalf("failed to compile: %s", err)
+	}
+
+	cases := []struct {
+		Domain string
+		Allow  bool
+	}{
+		{"www.example.com", true},
+		{"example.co.uk", true},
+		{"www.example.org", true},
+		{"example.net", false},
+		{"edge1.cdn.example.com", false},
+		{"cdn.example.com", false},
+		{"ip-10-0-0-1.example.com", false},
+		{"co.uk", false},
+		{"example.github.io", false},
+		{"WWW.EXAMPLE.COM.", true},
+	}
+
+	for i := range cases {
+		if got := f.Allow(cases[i].Domain); got != cases[i].Allow {
+			t.Errorf("%s: want %v, got %v", cases[i].Domain, cases[i].Allow, got)
+		}
+	}
+
+	want := "ExcludeDomains cdn.example.com=2, ExcludeRegex ^ip-\\d+=1, not included=1, private suffix=1, public suffix=1"
+
+	if s := f.Stats(); s != want {
+		t.Fatalf("unexpected stats:\nwant: %s\ngot:  %s", want, s)
+	}
+}
+
+func TestFilterEmpty(t *testing.T) {
+
+	f, err := NewFilter(FilterConfig{})
+	if err != nil {
+		t.Fatalf("failed to compile: %s", err)
+	}
+
+	if !f.Allow("example.github.io") || !f.Allow("www.example.net") || f.Allow("com") {
+		t.Fatalf("unexpected result of empty filter")
+	}
+}
+
+func TestFilterInvalid(t *testing.T) {
+
+	if _, err := NewFilter(FilterConfig{ExcludeRegex: []string{"("}}); err == nil {
+		t.Fatalf("expected error for invalid regex")
+	}
+
+	if _, err := NewFilter(FilterConfig{IncludeTLDs: []string{"inv@lid"}}); err == nil {
+		t.Fatalf("expected error for invalid TLD")
+	}
+}
@@ -4,7 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"os"
+	"log/slog"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -20,6 +20,21 @@ var (
 	LogSize  *atomic.Int64
 )
 
+// LogLagCheck is a readiness check, returns an error if the size of the log is unknown or the scanner is more than Conf.MaxLag entries behind.
+func LogLagCheck(ctx context.Context) error {
+
+	size, index := LogSize.Load(), LogIndex.Load()
+
+	switch {
+	case size == 0:
+		return fmt.Errorf("size of %s is unknown", Conf.LogName)
+	case size-index > Conf.MaxLag:
+		return fmt.Errorf("%d entries behind (max %d)", size-index, Conf.MaxLag)
+	default:
+		return nil
+	}
+}
+
 func LogHasNew() bool {
 
 	return LogSize.Load()-LogIndex.Load() > 0
@@ -41,7 +56,7 @@ func LogStatSizeUpdater(ctx context.Context, wg *sync.WaitGroup) {
 				if strings.Contains(err.Error(), "429 Too Many Requests") {
 					time.Sleep(10 * time.Second)
 				} else {
-					fmt.Fprintf(os.Stderr, "Failed to update size: %s\n", err)
+					slog.Error("failed to update the size of the log", "error", err)
 					Cancel()
 					return
 				}
@@ -70,9 +85,9 @@ func LogStatSaver(ctx context.Context, wg *sync.WaitGroup) {
 			return
 		case <-ticker.C:
 
-			err := db.CTLogsUpdate(Conf.LogName, LogIndex.Load(), LogSize.Load())
+			err := db.CTLogsUpdate(ctx, Conf.LogName, LogIndex.Load(), LogSize.Load())
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "Failed to update LogStat in the database: %s\n", err)
+				slog.Error("failed to update LogStat in the database", "error", err)
 				Cancel()
 				return
 			}
@@ -81,9 +96,9 @@ func LogStatSaver(ctx context.Context, wg *sync.WaitGroup) {
 }
 
 // LoadLogStat loads the index/size from the DB or defaiults to 0/0 if not found.
-func LoadLogStat() error {
+func LoadLogStat(ctx context.Context) error {
 
-	s, err := db.CTLogsGet(Conf.LogName)
+	s, err := db.CTLogsGet(ctx, Conf.LogName)
 	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
 		return fmt.Errorf("failed to get: %w", err)
 	}
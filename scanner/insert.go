@@ -1,9 +1,9 @@
 package main
 
 import (
+	"context"
 	"errors"
-	"fmt"
-	"os"
+	"log/slog"
 	"sync"
 
 	"github.com/elmasy-com/columbus/db"
@@ -16,26 +16,38 @@ func InsertWorker(doms <-chan string, wg *sync.WaitGroup) {
 
 	defer wg.Done()
 
+	// The worker runs until doms is closed, the remaining domains are inserted on shutdown
+	ctx := context.Background()
+
 	for dom := range doms {
 
-		_, err := db.DomainsInsert(dom)
+		if !Conf.Filter.Allow(dom) {
+			continue
+		}
+
+		ni, err := db.DomainsInsert(ctx, dom)
 		if err != nil {
 
 			// Failed insert is fatal error. Dont want to miss any domain.
-			fmt.Fprintf(os.Stderr, "Failed to write %s: %s\n", dom, err)
+			slog.Error("failed to insert domain", "domain", dom, "error", err)
 
-			// d is probably a TLD
-			if errors.Is(err, fault.ErrGetPartsFailed) {
+			// d is probably a TLD or a public suffix
+			if errors.Is(err, fault.ErrGetPartsFailed) || errors.Is(err, fault.ErrPublicSuffix) {
 				continue
 			}
 
 			Cancel()
 		}
 
+		if ni {
+			slog.Debug("new domain inserted", "domain", dom)
+		}
+
 		if !Conf.SkipDomain {
 
-			if err := db.RecordsUpdate(dom, false); err != nil {
-				fmt.Fprintf(os.Stderr, "Failed to update records for %s: %s\n", dom, err)
+			// DNS failures are common, dont flood the log
+			if err := db.RecordsUpdate(ctx, dom, false); err != nil && !errors.Is(err, db.ErrDNSFailure) {
+				slog.Error("failed to update records", "domain", dom, "error", err)
 			}
 		}
 	}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"log/slog"
 	"os"
 	"os/signal"
 	"strings"
@@ -13,6 +14,8 @@ import (
 	"time"
 
 	"github.com/elmasy-com/columbus/db"
+	"github.com/elmasy-com/columbus/health"
+	"github.com/elmasy-com/columbus/logger"
 	"github.com/elmasy-com/elnet/ctlog"
 	"github.com/g0rbe/slitu"
 )
@@ -40,21 +43,31 @@ func main() {
 		os.Exit(1)
 	}
 
-	fmt.Printf("Reading config file %s...\n", *configPath)
-
 	err := ParseConfig(*configPath)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to parse config: %s\n", err)
 		os.Exit(1)
 	}
 
-	fmt.Printf("Connecting to MongoDB...\n")
-	err = db.Connect(Conf.MongoURI)
+	err = logger.Setup(Conf.LogLevel, Conf.LogFormat)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to set up logging: %s\n", err)
+		os.Exit(1)
+	}
+
+	slog.Info("connecting to MongoDB")
+	err = db.Connect(context.Background(), Conf.MongoURI)
+	if err != nil {
+		slog.Error("failed to connect to MongoDB", "error", err)
+		os.Exit(1)
+	}
+
+	err = db.MigrationsCheck(context.Background())
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to connect to MongoDB: %s\n", err)
+		slog.Error("migrations check failed", "error", err)
 		os.Exit(1)
 	}
-	defer db.Disconnect()
+	defer db.Disconnect(context.Background())
 
 	LogIndex = new(atomic.Int64)
 	LogSize = new(atomic.Int64)
@@ -65,14 +78,29 @@ func main() {
 	wg := new(sync.WaitGroup)
 	domainChan := make(chan string)
 
-	fmt.Printf("Loading previous LogStat...\n")
-	err = LoadLogStat()
+	slog.Info("loading previous LogStat", "log", Conf.LogName)
+	err = LoadLogStat(ctx)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to load LogStat: %s\n", err)
+		slog.Error("failed to load LogStat", "error", err)
 		os.Exit(1)
 	}
 	if !LogHasNew() {
-		fmt.Printf("%s progress: %d/%d (%.2f%%)\n", Conf.LogName, LogIndex.Load(), LogSize.Load(), float64(LogIndex.Load())/float64(LogSize.Load())*100)
+		slog.Info("progress", "log", Conf.LogName, "index", LogIndex.Load(), "size", LogSize.Load(), "percent", float64(LogIndex.Load())/float64(LogSize.Load())*100)
+	}
+
+	if Conf.AdminAddress != "" {
+
+		h := health.New("columbus-scanner", BuildDate, BuildCommit)
+		h.Add("mongo", db.Ping)
+		h.Add("ctlog", LogLagCheck)
+
+		slog.Info("starting admin listener", "address", Conf.AdminAddress)
+
+		go func() {
+			if err := h.ListenAndServe(ctx, Conf.AdminAddress); err != nil {
+				slog.Error("admin listener failed", "error", err)
+			}
+		}()
 	}
 
 	wg.Add(1)
@@ -99,7 +127,10 @@ infiniteLoop:
 				slitu.Sleep(ctx, 5*time.Second)
 				continue infiniteLoop
 			} else {
-				fmt.Printf("%s progress: %d/%d (%.2f%%)\n", Conf.LogName, LogIndex.Load(), LogSize.Load(), float64(LogIndex.Load())/float64(LogSize.Load())*100)
+				slog.Info("progress", "log", Conf.LogName, "index", LogIndex.Load(), "size", LogSize.Load(), "percent", float64(LogIndex.Load())/float64(LogSize.Load())*100)
+				if s := Conf.Filter.Stats(); s != "" {
+					slog.Info("filtered", "rules", s)
+				}
 			}
 
 			doms, n, err := ctlog.GetDomains(Conf.Log.URI, LogIndex.Load())
@@ -108,14 +139,14 @@ infiniteLoop:
 				switch {
 				case strings.Contains(err.Error(), "NonFatalErrors"):
 					// NonFatalErrors means failed to convert one entry, skip it and continue
-					fmt.Fprintf(os.Stderr, "Non fatal error occurred while getting domains at index %d (continue from index %d): %s\n", LogIndex.Load()+n, LogIndex.Load()+n+1, err)
+					slog.Warn("non fatal error occurred while getting domains", "index", LogIndex.Load()+n, "continue", LogIndex.Load()+n+1, "error", err)
 					// Add +1 to n to skip the failed entry
 					n += 1
 				case strings.Contains(err.Error(), "429 Too Many Requests"):
-					fmt.Printf("Sleeping for 60 seconds because of too many request...\n")
+					slog.Warn("too many requests, sleeping for 60 seconds")
 					time.Sleep(60 * time.Second)
 				default:
-					fmt.Fprintf(os.Stderr, "Failed to get domains at index %d: %s\n", LogIndex.Load()+n, err)
+					slog.Error("failed to get domains", "index", LogIndex.Load()+n, "error", err)
 					Cancel()
 					break infiniteLoop
 				}
@@ -129,10 +160,13 @@ infiniteLoop:
 		}
 	}
 
-	fmt.Printf("Waiting to close...\n")
+	slog.Info("waiting to close")
 	close(domainChan)
 	wg.Wait()
-	fmt.Printf("Closed!\n")
-	db.Disconnect()
+	if s := Conf.Filter.Stats(); s != "" {
+		slog.Info("filtered", "rules", s)
+	}
+	slog.Info("closed")
+	db.Disconnect(context.Background())
 	os.Exit(1)
 }
@@ -0,0 +1,23 @@
+package common
+
+import (
+	"context"
+	"errors"
+
+	"github.com/elmasy-com/columbus/server/config"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Context returns the context of the request with the deadline of operation op (see config.Timeout()).
+// The context is cancelled if the client disconnects.
+func Context(c *gin.Context, op string) (context.Context, context.CancelFunc) {
+
+	return context.WithTimeout(c.Request.Context(), config.Timeout(op))
+}
+
+// IsTimeout returns whether err is caused by an exceeded deadline.
+func IsTimeout(err error) bool {
+
+	return errors.Is(err, context.DeadlineExceeded) || mongo.IsTimeout(err)
+}
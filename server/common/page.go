@@ -0,0 +1,29 @@
+package common
+
+import (
+	"strconv"
+
+	"github.com/elmasy-com/columbus/fault"
+	"github.com/gin-gonic/gin"
+)
+
+// MaxSkip is the maximum of the "skip" query parameter, the skipped documents are still scanned by the database.
+const MaxSkip = 10000
+
+// ParseQueryPage returns the "skip" and "limit" query parameters (eg.: "/api/reverse/203.0.113.0/24?skip=100&limit=100").
+// If skip is not set, returns 0. If limit is not set, returns def.
+// Returns fault.ErrInvalidSkip if skip is not in range 0-MaxSkip, fault.ErrInvalidLimit if limit is not in range 1-max.
+func ParseQueryPage(c *gin.Context, def int64, max int64) (int64, int64, error) {
+
+	skip, err := strconv.ParseInt(c.DefaultQuery("skip", "0"), 10, 64)
+	if err != nil || skip < 0 || skip > MaxSkip {
+		return 0, 0, fault.ErrInvalidSkip
+	}
+
+	limit, err := strconv.ParseInt(c.DefaultQuery("limit", strconv.FormatInt(def, 10)), 10, 64)
+	if err != nil || limit < 1 || limit > max {
+		return 0, 0, fault.ErrInvalidLimit
+	}
+
+	return skip, limit, nil
+}
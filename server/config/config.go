@@ -6,25 +6,33 @@ import (
 	"runtime"
 	"time"
 
+	"github.com/elmasy-com/columbus/logger"
 	"github.com/elmasy-com/elnet/blocklist"
 	"github.com/elmasy-com/elnet/dns"
 	"gopkg.in/yaml.v3"
 )
 
 type conf struct {
-	MongoURI       string   `yaml:"MongoURI"`
-	Address        string   `yaml:"Address"`
-	TrustedProxies []string `yaml:"TrustedProxies"`
-	SSLCert        string   `yaml:"SSLCert"`
-	SSLKey         string   `yaml:"SSLKey"`
-	LogErrorOnly   bool     `yaml:"LogErrorOnly"`
-	DNSServers     []string `yaml:"DNSServers"`
-	DomainWorker   int      `yaml:"DomainWorker"`
-	DomainBuffer   int      `yaml:"DomainBuffer"`
-	InsertWorker   int      `yaml:"InsertWorker"`
-	InsertBuffer   int      `yaml:"InsertBuffer"`
-	BlocklistSize  int      `yaml:"BlocklistSize"`
-	BlockTime      int      `yaml:"BlockTime"`
+	MongoURI       string         `yaml:"MongoURI"`
+	Address        string         `yaml:"Address"`
+	TrustedProxies []string       `yaml:"TrustedProxies"`
+	SSLCert        string         `yaml:"SSLCert"`
+	SSLKey         string         `yaml:"SSLKey"`
+	LogErrorOnly   bool           `yaml:"LogErrorOnly"`
+	LogLevel       string         `yaml:"LogLevel"`
+	LogFormat      string         `yaml:"LogFormat"`
+	DNSServers     []string       `yaml:"DNSServers"`
+	DomainWorker   int            `yaml:"DomainWorker"`
+	DomainBuffer   int            `yaml:"DomainBuffer"`
+	DNSBudget      int            `yaml:"DNSBudget"`
+	InsertWorker   int            `yaml:"InsertWorker"`
+	InsertBuffer   int            `yaml:"InsertBuffer"`
+	BlocklistSize  int            `yaml:"BlocklistSize"`
+	BlockTime      int            `yaml:"BlockTime"`
+	AdminKey       string         `yaml:"AdminKey"`
+	Timeouts       map[string]int `yaml:"Timeouts"`
+	CacheSize      int            `yaml:"CacheSize"`
+	CacheTTL       int            `yaml:"CacheTTL"`
 }
 
 var (
@@ -34,16 +42,41 @@ var (
 	SSLCert        string
 	SSLKey         string
 	LogErrorOnly   bool
+	LogLevel       string // Minimum level of the logged records ("debug", "info", "warn" or "error")
+	LogFormat      string // Format of the log ("text" or "json")
 	DNSServers     []string
 	DomainWorker   int
 	DomainBuffer   int
+	DNSBudget      int // DNS queries per second of the records updater, 0 means unlimited
 	InsertWorker   int
 	InsertBuffer   int
 	BlocklistSize  int
 	BlockTime      time.Duration
 	Blocklist      *blocklist.Blocklist
+	AdminKey       string                   // API key of the admin endpoints, the admin endpoints are disabled if empty
+	Timeouts       map[string]time.Duration // Deadline of the operations, use Timeout()
+	CacheSize      int                      // Number of results in the lookup cache, the cache is disabled if -1
+	CacheTTL       time.Duration            // Expiration of the results in the lookup cache
 )
 
+// DefaultTimeout is the deadline of the operations not set in Timeouts.
+var DefaultTimeout = 10 * time.Second
+
+// Timeout returns the deadline of operation op (eg.: "lookup").
+// If op is not configured, returns the "default" value or DefaultTimeout.
+func Timeout(op string) time.Duration {
+
+	if t, ok := Timeouts[op]; ok {
+		return t
+	}
+
+	if t, ok := Timeouts["default"]; ok {
+		return t
+	}
+
+	return DefaultTimeout
+}
+
 // Parse parses the config file in path and gill the global variables.
 func Parse(path string) error {
 
@@ -76,6 +109,18 @@ func Parse(path string) error {
 
 	LogErrorOnly = c.LogErrorOnly
 
+	if _, err := logger.ParseLevel(c.LogLevel); err != nil {
+		return fmt.Errorf("invalid LogLevel: %w", err)
+	}
+
+	LogLevel = c.LogLevel
+
+	if _, err := logger.ParseFormat(c.LogFormat); err != nil {
+		return fmt.Errorf("invalid LogFormat: %w", err)
+	}
+
+	LogFormat = c.LogFormat
+
 	DNSServers = c.DNSServers
 
 	servers, err := dns.NewServersStr(dns.DefaultMaxRetries, time.Duration(dns.DefaultQueryTimeoutSec)*time.Second, c.DNSServers...)
@@ -97,6 +142,12 @@ func Parse(path string) error {
 
 	DomainBuffer = c.DomainBuffer
 
+	if c.DNSBudget < 0 {
+		return fmt.Errorf("DNSBudget is negative")
+	}
+
+	DNSBudget = c.DNSBudget
+
 	if c.InsertWorker == 0 {
 		c.InsertWorker = runtime.NumCPU()
 	}
@@ -123,5 +174,38 @@ func Parse(path string) error {
 
 	Blocklist = blocklist.NewBlocklist(BlockTime, int64(BlocklistSize))
 
+	AdminKey = c.AdminKey
+
+	Timeouts = make(map[string]time.Duration, len(c.Timeouts))
+
+	for op, sec := range c.Timeouts {
+
+		if sec <= 0 {
+			return fmt.Errorf("invalid timeout for %s: %d", op, sec)
+		}
+
+		Timeouts[op] = time.Duration(sec) * time.Second
+	}
+
+	if c.CacheSize < -1 {
+		return fmt.Errorf("invalid CacheSize: %d", c.CacheSize)
+	}
+
+	if c.CacheSize == 0 {
+		c.CacheSize = 1000
+	}
+
+	CacheSize = c.CacheSize
+
+	if c.CacheTTL < 0 {
+		return fmt.Errorf("CacheTTL is negative")
+	}
+
+	if c.CacheTTL == 0 {
+		c.CacheTTL = 600
+	}
+
+	CacheTTL = time.Duration(c.CacheTTL) * time.Second
+
 	return nil
 }
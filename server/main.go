@@ -1,12 +1,20 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
+	"log/slog"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/elmasy-com/columbus/db"
+	"github.com/elmasy-com/columbus/health"
+	"github.com/elmasy-com/columbus/logger"
 	"github.com/elmasy-com/columbus/server/config"
+	"github.com/elmasy-com/columbus/server/route/probe"
+	"github.com/elmasy-com/columbus/webhook"
 )
 
 var (
@@ -33,33 +41,64 @@ func main() {
 		os.Exit(1)
 	}
 
-	fmt.Printf("Parsing config file...\n")
 	if err := config.Parse(*path); err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to parse config file: %s\n", err)
 		os.Exit(1)
 	}
 
-	fmt.Printf("Connecting to MongoDB...\n")
-	if err := db.Connect(config.MongoURI); err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to connect to MongoDB: %s\n", err)
+	if err := logger.Setup(config.LogLevel, config.LogFormat); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to set up logging: %s\n", err)
 		os.Exit(1)
 	}
-	defer db.Disconnect()
 
-	fmt.Printf("Starting db.StatisticsInsertWorker...\n")
-	go db.StatisticsInsertWorker()
+	// ctx is cancelled on SIGINT/SIGTERM, the workers and the HTTP server stops
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	fmt.Printf("Starting db.StatisticsCleanWorker...\n")
-	go db.StatisticsCleanWorker()
+	slog.Info("connecting to MongoDB")
+	if err := db.Connect(ctx, config.MongoURI); err != nil {
+		slog.Error("failed to connect to MongoDB", "error", err)
+		os.Exit(1)
+	}
+	defer db.Disconnect(context.Background())
+
+	// The indexes are created by the migrations, the server must not run on an outdated schema
+	if err := db.MigrationsCheck(ctx); err != nil {
+		slog.Error("migrations check failed", "error", err)
+		os.Exit(1)
+	}
+
+	if config.CacheSize > 0 {
+		db.CacheSetBackend(db.NewMemoryCache(config.CacheSize, config.CacheTTL))
+
+		slog.Info("starting cache invalidation worker")
+		go db.CacheInvalidateWorker(ctx)
+	}
+
+	slog.Info("starting statistics workers")
+	go db.StatisticsInsertWorker(ctx)
+	go db.StatisticsCleanWorker(ctx)
+
+	slog.Info("starting records updater", "workers", config.DomainWorker, "buffer", config.DomainBuffer, "dnsBudget", config.DNSBudget)
+	go db.RecordsUpdater(ctx, config.DomainWorker, config.DomainBuffer, config.DNSBudget)
+
+	slog.Info("starting webhook dispatcher")
+	go webhook.NewDispatcher().Run(ctx)
 
-	fmt.Printf("Starting RecordUpdater...\n")
-	go db.RecordsUpdater(config.DomainWorker, config.DomainBuffer)
+	probe.Checker = health.New("columbus-server", BuildDate, BuildCommit)
+	probe.Checker.Add("mongo", db.Ping)
+	probe.Checker.Add("updater", func(ctx context.Context) error {
+		if !db.UpdaterRunning() {
+			return fmt.Errorf("records updater is not running")
+		}
+		return nil
+	})
 
-	fmt.Printf("Starting HTTP server...\n")
-	if err := ServerRun(); err != nil {
-		fmt.Fprintf(os.Stderr, "Server failed: %s\n", err)
+	slog.Info("starting HTTP server", "address", config.Address)
+	if err := ServerRun(ctx); err != nil {
+		slog.Error("server failed", "error", err)
 		os.Exit(1)
 	} else {
-		fmt.Printf("HTTP server stopped!\n")
+		slog.Info("HTTP server stopped")
 	}
 }
@@ -0,0 +1,266 @@
+/*
+openapi package is used to describe the API of the server.
+
+Every API handler declares its Operation (parameters, query options, response schemas and error codes) next to the handler,
+the router collects them into a Document (see router.Spec()) and frontend/static/openapi.yaml is generated from it.
+*/
+package openapi
+
+import (
+	"strconv"
+)
+
+type Document struct {
+	OpenAPI    string              `yaml:"openapi"`
+	Info       Info                `yaml:"info"`
+	Servers    []Server            `yaml:"servers,omitempty"`
+	Tags       []Tag               `yaml:"tags,omitempty"`
+	Paths      map[string]PathItem `yaml:"paths"`
+	Components Components          `yaml:"components"`
+}
+
+type Info struct {
+	Title       string  `yaml:"title"`
+	Description string  `yaml:"description,omitempty"`
+	Contact     Contact `yaml:"contact"`
+	License     License `yaml:"license"`
+	Version     string  `yaml:"version"`
+}
+
+type Contact struct {
+	Email string `yaml:"email"`
+}
+
+type License struct {
+	Name string `yaml:"name"`
+	URL  string `yaml:"url"`
+}
+
+type Server struct {
+	URL string `yaml:"url"`
+}
+
+type Tag struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description,omitempty"`
+}
+
+// PathItem is the operations of a path keyed by the lowercase HTTP method.
+type PathItem map[string]*Operation
+
+type Operation struct {
+	Tags        []string              `yaml:"tags,omitempty"`
+	ID          string                `yaml:"operationId"`
+	Summary     string                `yaml:"summary,omitempty"`
+	Description string                `yaml:"description,omitempty"`
+	Security    []map[string][]string `yaml:"security,omitempty"`
+	Parameters  []Parameter           `yaml:"parameters,omitempty"`
+	RequestBody *RequestBody          `yaml:"requestBody,omitempty"`
+	Responses   Responses             `yaml:"responses"`
+}
+
+type Parameter struct {
+	Name        string  `yaml:"name"`
+	In          string  `yaml:"in"`
+	Description string  `yaml:"description,omitempty"`
+	Required    bool    `yaml:"required"`
+	Schema      *Schema `yaml:"schema"`
+	Example     string  `yaml:"example,omitempty"`
+}
+
+type RequestBody struct {
+	Required bool                 `yaml:"required"`
+	Content  map[string]MediaType `yaml:"content"`
+}
+
+// Responses is the responses of an Operation keyed by the HTTP status code.
+type Responses map[int]Response
+
+type Response struct {
+	Description string               `yaml:"description"`
+	Content     map[string]MediaType `yaml:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `yaml:"schema"`
+}
+
+type Schema struct {
+	Ref                  string             `yaml:"$ref,omitempty"`
+	Type                 string             `yaml:"type,omitempty"`
+	Format               string             `yaml:"format,omitempty"`
+	Description          string             `yaml:"description,omitempty"`
+	Enum                 []string           `yaml:"enum,omitempty,flow"`
+	Default              any                `yaml:"default,omitempty"`
+	Maximum              int                `yaml:"maximum,omitempty"`
+	Required             []string           `yaml:"required,omitempty"`
+	Items                *Schema            `yaml:"items,omitempty"`
+	Properties           map[string]*Schema `yaml:"properties,omitempty"`
+	AdditionalProperties *Schema            `yaml:"additionalProperties,omitempty"`
+}
+
+type Components struct {
+	SecuritySchemes map[string]SecurityScheme `yaml:"securitySchemes"`
+	Schemas         map[string]*Schema        `yaml:"schemas"`
+}
+
+type SecurityScheme struct {
+	Type string `yaml:"type"`
+	In   string `yaml:"in"`
+	Name string `yaml:"name"`
+}
+
+// MarshalYAML writes the status codes as strings (eg.: '200'), as required by the OpenAPI specification.
+func (r Responses) MarshalYAML() (interface{}, error) {
+
+	m := make(map[string]Response, len(r))
+
+	for k, v := range r {
+		m[strconv.Itoa(k)] = v
+	}
+
+	return m, nil
+}
+
+// APIKey is the security requirement of the admin endpoints.
+var APIKey = []map[string][]string{{"ApiKey": {}}}
+
+// New returns the Document of the Columbus API without paths.
+// version is the version of the server (eg.: "dev").
+func New(version string) *Document {
+
+	return &Document{
+		OpenAPI: "3.0.3",
+		Info: Info{
+			Title: "Columbus API",
+			Description: "A fast, API-first subdomain discovery service with advanced queries.\n\n" +
+				"The `Access-Control-Allow-Origin` header on the API endpoints is always set to `*` to allow integration into other sites.\n\n" +
+				"The successful responses have an `ETag` header (and `Last-Modified` where the time of the last change is known), " +
+				"send it back in `If-None-Match` (or `If-Modified-Since`) to get a `304 Not Modified` response without body if nothing changed.\n",
+			Contact: Contact{Email: "columbus@elmasy.com"},
+			License: License{Name: "Apache 2.0", URL: "http://www.apache.org/licenses/LICENSE-2.0.html"},
+			Version: version,
+		},
+		Servers: []Server{{URL: "https://columbus.elmasy.com"}},
+		Tags: []Tag{
+			{Name: "domain", Description: "Lookup domain."},
+			{Name: "info", Description: "Server informations."},
+			{Name: "tools", Description: "Helper APIs."},
+			{Name: "admin", Description: "Administration of the server, requires the admin API key."},
+		},
+		Paths: make(map[string]PathItem),
+		Components: Components{
+			SecuritySchemes: map[string]SecurityScheme{
+				"ApiKey": {Type: "apiKey", In: "header", Name: "X-Api-Key"},
+			},
+			Schemas: Schemas,
+		},
+	}
+}
+
+// Add adds op to the document with method and path in OpenAPI syntax (eg.: "/api/lookup/{domain}").
+func (d *Document) Add(method string, path string, op *Operation) {
+
+	if d.Paths[path] == nil {
+		d.Paths[path] = make(PathItem)
+	}
+
+	d.Paths[path][method] = op
+}
+
+// Ref returns a reference to the component schema name.
+func Ref(name string) *Schema {
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// String returns a string schema.
+func String() *Schema {
+	return &Schema{Type: "string"}
+}
+
+// Integer returns an integer schema.
+func Integer() *Schema {
+	return &Schema{Type: "integer"}
+}
+
+// ArrayOf returns an array schema of items.
+func ArrayOf(items *Schema) *Schema {
+	return &Schema{Type: "array", Items: items}
+}
+
+// PathParam returns a required path parameter.
+func PathParam(name string, description string, s *Schema) Parameter {
+	return Parameter{Name: name, In: "path", Description: description, Required: true, Schema: s}
+}
+
+// QueryParam returns an optional query parameter.
+func QueryParam(name string, description string, s *Schema) Parameter {
+	return Parameter{Name: name, In: "query", Description: description, Schema: s}
+}
+
+// HeaderParam returns an optional header parameter.
+func HeaderParam(name string, description string, s *Schema) Parameter {
+	return Parameter{Name: name, In: "header", Description: description, Schema: s}
+}
+
+// JSONBody returns a required JSON request body.
+func JSONBody(s *Schema) *RequestBody {
+	return &RequestBody{Required: true, Content: map[string]MediaType{"application/json": {Schema: s}}}
+}
+
+// Empty returns a response without body.
+func Empty(description string) Response {
+	return Response{Description: description}
+}
+
+// JSON returns a response with a JSON body.
+func JSON(description string, s *Schema) Response {
+	return Content(description, map[string]*Schema{"application/json": s})
+}
+
+// Negotiated returns a response with a JSON body, or a plain text body if the "Accept" header prefers "text/plain".
+func Negotiated(description string, s *Schema) Response {
+	return Content(description, map[string]*Schema{"application/json": s, "text/plain": Ref("String")})
+}
+
+// NotModified returns the response of a conditional request if the client has the current response (see respond.Data()).
+func NotModified() Response {
+	return Empty("Not Modified. The `If-None-Match` or `If-Modified-Since` header matches the current response.")
+}
+
+// List returns a response with a list of strings in JSON, plain text (a value per line), NDJSON (a JSON string per line) or CSV (a value per line), selected by the "Accept" header.
+func List(description string) Response {
+	return Content(description, map[string]*Schema{
+		"application/json":     Ref("StringArray"),
+		"text/plain":           Ref("String"),
+		"application/x-ndjson": Ref("String"),
+		"text/csv":             Ref("String"),
+	})
+}
+
+// Records returns a response with FQDNs and the records in JSON, plain text (the FQDNs), NDJSON or CSV (see ExportRow), selected by the "Accept" header.
+func Records(description string) Response {
+	return Content(description, map[string]*Schema{
+		"application/json":     Ref("History"),
+		"text/plain":           Ref("String"),
+		"application/x-ndjson": Ref("ExportRow"),
+		"text/csv":             Ref("String"),
+	})
+}
+
+// Content returns a response with the content types and schemas in content.
+func Content(description string, content map[string]*Schema) Response {
+
+	r := Response{Description: description, Content: make(map[string]MediaType, len(content))}
+
+	for k, v := range content {
+		r.Content[k] = MediaType{Schema: v}
+	}
+
+	return r
+}
+
+// Error returns an error response with a JSON Error, or the plain error message if the "Accept" header prefers "text/plain".
+func Error(description string) Response {
+	return Negotiated(description, Ref("Error"))
+}
@@ -0,0 +1,190 @@
+package openapi
+
+// Schemas is the component schemas shared by the operations, referenced with Ref().
+var Schemas = map[string]*Schema{
+	"StringArray": ArrayOf(String()),
+	"String":      String(),
+	"Result": {
+		Type:       "object",
+		Properties: map[string]*Schema{"result": String()},
+	},
+	"ResultBool": {
+		Type:       "object",
+		Properties: map[string]*Schema{"result": {Type: "boolean"}},
+	},
+	"Error": {
+		Type:     "object",
+		Required: []string{"error", "code"},
+		Properties: map[string]*Schema{
+			"error": {Type: "string", Description: "Human-readable message."},
+			"code":  {Type: "string", Description: "Machine-readable code of the error (eg.: `invalid_domain`)."},
+		},
+	},
+	"Stat": {
+		Type: "object",
+		Properties: map[string]*Schema{
+			"date":    Integer(),
+			"total":   Integer(),
+			"updated": Integer(),
+			"valid":   Integer(),
+			"ctlogs": ArrayOf(&Schema{
+				Type: "object",
+				Properties: map[string]*Schema{
+					"name":  String(),
+					"index": Integer(),
+					"size":  Integer(),
+				},
+			}),
+		},
+	},
+	"CacheStat": {
+		Type: "object",
+		Properties: map[string]*Schema{
+			"enabled":       {Type: "boolean"},
+			"entries":       Integer(),
+			"hits":          Integer(),
+			"misses":        Integer(),
+			"shared":        Integer(),
+			"invalidations": Integer(),
+		},
+	},
+	"Health": {
+		Type:       "object",
+		Properties: map[string]*Schema{"status": String()},
+	},
+	"Ready": {
+		Type: "object",
+		Properties: map[string]*Schema{
+			"ready":  {Type: "boolean"},
+			"checks": {Type: "object", Description: "Result of the checks, `ok` or the error message.", AdditionalProperties: String()},
+		},
+	},
+	"Version": {
+		Type: "object",
+		Properties: map[string]*Schema{
+			"service":     String(),
+			"buildDate":   String(),
+			"buildCommit": String(),
+			"goVersion":   String(),
+		},
+	},
+	"Record": {
+		Type: "object",
+		Properties: map[string]*Schema{
+			"type":      Integer(),
+			"value":     String(),
+			"time":      {Type: "integer", Description: "Same as `lastSeen`, kept for compatibility."},
+			"firstSeen": {Type: "integer", Description: "Unix timestamp when the record first found. Missing for records stored before, use `time`."},
+			"lastSeen":  {Type: "integer", Description: "Unix timestamp when the record last found. Missing for records stored before, use `time`."},
+			"removed":   {Type: "integer", Description: "Unix timestamp when a refresh first not returned the record. Missing if the record is still served."},
+			"removals": ArrayOf(&Schema{
+				Type:        "object",
+				Description: "A previous removal of the record, the record was found again later.",
+				Properties: map[string]*Schema{
+					"removed":  {Type: "integer", Description: "Unix timestamp when the record removed."},
+					"restored": {Type: "integer", Description: "Unix timestamp when the record found again."},
+				},
+			}),
+			"priority": {Type: "integer", Description: "Preference of MX, priority of SRV, HTTPS and SVCB, order of NAPTR."},
+			"target":   {Type: "string", Description: "Target of CNAME, DNAME, MX, NS, SOA, SRV, HTTPS, SVCB and NAPTR, the queried name of TLSA."},
+			"params": {
+				Type:                 "object",
+				Description:          "Every other field of the record (eg.:`alpn` of HTTPS, `port` of SRV, `digest` of DS).",
+				AdditionalProperties: String(),
+			},
+		},
+	},
+	"Records": ArrayOf(Ref("Record")),
+	"History": ArrayOf(&Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"domain":  String(),
+			"records": Ref("Records"),
+		},
+	}),
+	"ExportRow": {
+		Type: "object",
+		Properties: map[string]*Schema{
+			"fqdn":    String(),
+			"type":    {Type: "string", Description: "Type of the record (eg.: `A`), empty if the subdomain has no records."},
+			"value":   String(),
+			"time":    {Type: "integer", Description: "Unix timestamp when the record last seen."},
+			"updated": {Type: "integer", Description: "Unix timestamp of the last update of the subdomain."},
+		},
+	},
+	"Diff": {
+		Type: "object",
+		Properties: map[string]*Schema{
+			"domain": String(),
+			"since":  Integer(),
+			"until":  Integer(),
+			"changes": ArrayOf(&Schema{
+				Type: "object",
+				Properties: map[string]*Schema{
+					"domain":  String(),
+					"status":  {Type: "string", Enum: []string{"added", "removed", "changed"}},
+					"added":   Ref("Records"),
+					"removed": Ref("Records"),
+				},
+			}),
+		},
+	},
+	"QueueItem": {
+		Type: "object",
+		Properties: map[string]*Schema{
+			"domain":      String(),
+			"type":        {Type: "integer", Description: "0 is insert new domain, 1 is update existing domain."},
+			"priority":    Integer(),
+			"attempts":    Integer(),
+			"nextAttempt": Integer(),
+			"leaseUntil":  Integer(),
+			"lastError":   String(),
+			"created":     Integer(),
+			"failed":      Integer(),
+		},
+	},
+	"Event": {
+		Type: "object",
+		Properties: map[string]*Schema{
+			"id":     String(),
+			"seq":    {Type: "integer", Description: "Sequence number of the event, increasing in emit order."},
+			"type":   {Type: "string", Enum: []string{"newFQDN", "newRecord", "removedRecord"}},
+			"domain": String(),
+			"apex":   String(),
+			"record": Ref("Record"),
+			"time":   Integer(),
+		},
+	},
+	"Subscription": {
+		Type: "object",
+		Properties: map[string]*Schema{
+			"id":      String(),
+			"apex":    String(),
+			"url":     String(),
+			"events":  ArrayOf(String()),
+			"created": Integer(),
+		},
+	},
+	"Delivery": {
+		Type: "object",
+		Properties: map[string]*Schema{
+			"id":           String(),
+			"subscription": String(),
+			"event":        String(),
+			"state":        {Type: "string", Enum: []string{"pending", "delivered", "failed"}},
+			"nextAttempt":  {Type: "integer", Description: "Time of the next attempt, missing if the delivery is finished."},
+			"attempts":     ArrayOf(Ref("DeliveryAttempt")),
+			"created":      Integer(),
+		},
+	},
+	"DeliveryAttempt": {
+		Type: "object",
+		Properties: map[string]*Schema{
+			"attempt":  Integer(),
+			"status":   {Type: "integer", Description: "HTTP status code, missing if the request failed."},
+			"error":    String(),
+			"duration": {Type: "integer", Description: "Milliseconds."},
+			"time":     Integer(),
+		},
+	},
+}
@@ -0,0 +1,188 @@
+package openapi
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"mime"
+	"slices"
+	"strings"
+)
+
+// ValidateResponse validates a response of op against the document.
+// The status code and the content type must be declared in op,
+// JSON and NDJSON bodies must match the schema of the content type.
+func (d *Document) ValidateResponse(op *Operation, status int, contentType string, body []byte) error {
+
+	r, ok := op.Responses[status]
+	if !ok {
+		return fmt.Errorf("status %d is not declared", status)
+	}
+
+	if len(body) == 0 {
+		return nil
+	}
+
+	if len(r.Content) == 0 {
+		return fmt.Errorf("status %d has no content declared, got %d bytes of %q", status, len(body), contentType)
+	}
+
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return fmt.Errorf("invalid content type %q: %w", contentType, err)
+	}
+
+	m, ok := r.Content[mt]
+	if !ok {
+		return fmt.Errorf("content type %s is not declared for status %d", mt, status)
+	}
+
+	switch mt {
+	case "application/json":
+
+		var v any
+
+		err = json.Unmarshal(body, &v)
+		if err != nil {
+			return fmt.Errorf("invalid JSON: %w", err)
+		}
+
+		return d.Validate(m.Schema, v)
+
+	case "application/x-ndjson":
+
+		s := bufio.NewScanner(bytes.NewReader(body))
+
+		for i := 1; s.Scan(); i++ {
+
+			var v any
+
+			err = json.Unmarshal(s.Bytes(), &v)
+			if err != nil {
+				return fmt.Errorf("line %d: invalid JSON: %w", i, err)
+			}
+
+			err = d.Validate(m.Schema, v)
+			if err != nil {
+				return fmt.Errorf("line %d: %w", i, err)
+			}
+		}
+
+		return s.Err()
+	}
+
+	return nil
+}
+
+// Validate validates v against s.
+// v must be decoded by encoding/json into an interface value (eg.: map[string]any, []any, float64).
+// The references are resolved from the component schemas of the document.
+func (d *Document) Validate(s *Schema, v any) error {
+	return d.validate(s, v, "$")
+}
+
+func (d *Document) validate(s *Schema, v any, path string) error {
+
+	if s == nil {
+		return nil
+	}
+
+	if s.Ref != "" {
+
+		r, ok := d.Components.Schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+		if !ok {
+			return fmt.Errorf("%s: unknown schema %s", path, s.Ref)
+		}
+
+		return d.validate(r, v, path)
+	}
+
+	if s.Type == "" {
+		return nil
+	}
+
+	if v == nil {
+		return fmt.Errorf("%s: expected %s, got null", path, s.Type)
+	}
+
+	switch s.Type {
+	case "object":
+
+		o, ok := v.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: expected object, got %T", path, v)
+		}
+
+		for _, k := range s.Required {
+			if _, ok := o[k]; !ok {
+				return fmt.Errorf("%s: missing required property %q", path, k)
+			}
+		}
+
+		for k, e := range o {
+
+			p, ok := s.Properties[k]
+			if !ok {
+				p = s.AdditionalProperties
+			}
+
+			err := d.validate(p, e, path+"."+k)
+			if err != nil {
+				return err
+			}
+		}
+
+	case "array":
+
+		a, ok := v.([]any)
+		if !ok {
+			return fmt.Errorf("%s: expected array, got %T", path, v)
+		}
+
+		for i := range a {
+			err := d.validate(s.Items, a[i], fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return err
+			}
+		}
+
+	case "string":
+
+		str, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("%s: expected string, got %T", path, v)
+		}
+
+		if len(s.Enum) > 0 && !slices.Contains(s.Enum, str) {
+			return fmt.Errorf("%s: %q is not one of %v", path, str, s.Enum)
+		}
+
+	case "integer", "number":
+
+		n, ok := v.(float64)
+		if !ok {
+			return fmt.Errorf("%s: expected %s, got %T", path, s.Type, v)
+		}
+
+		if s.Type == "integer" && n != math.Trunc(n) {
+			return fmt.Errorf("%s: expected integer, got %v", path, n)
+		}
+
+		if s.Maximum != 0 && n > float64(s.Maximum) {
+			return fmt.Errorf("%s: %v is greater than %d", path, n, s.Maximum)
+		}
+
+	case "boolean":
+
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("%s: expected boolean, got %T", path, v)
+		}
+
+	default:
+		return fmt.Errorf("%s: unknown type %s", path, s.Type)
+	}
+
+	return nil
+}
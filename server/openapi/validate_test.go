@@ -0,0 +1,68 @@
+package openapi
+
+import (
+	"testing"
+)
+
+func TestValidateResponse(t *testing.T) {
+
+	d := New("test")
+
+	op := &Operation{
+		Responses: Responses{
+			200: Negotiated("Success.", Ref("Records")),
+			400: Error("Invalid domain."),
+			504: Empty("Gateway Timeout."),
+		},
+	}
+
+	cases := []struct {
+		Status      int
+		ContentType string
+		Body        string
+		Valid       bool
+	}{
+		{200, "application/json; charset=utf-8", `[{"type":1,"value":"203.0.113.1","time":1700000000}]`, true},
+		{200, "application/json", `[{"type":1,"params":{"port":"443"}}]`, true},
+		{200, "text/plain; charset=utf-8", "one\ntwo", true},
+		{200, "application/json", `[{"type":"A"}]`, false},
+		{200, "application/json", `[{"type":1.5}]`, false},
+		{200, "application/json", `[{"params":{"port":443}}]`, false},
+		{200, "application/json", `null`, false},
+		{200, "text/csv", "one", false},
+		{400, "application/json", `{"error":"invalid domain","code":"invalid_domain"}`, true},
+		{400, "application/json", `{"error":"invalid domain"}`, false},
+		{400, "application/json", `{"message":"invalid domain"}`, false},
+		{404, "application/json", `{"error":"not found","code":"not_found"}`, false},
+		{504, "", "", true},
+		{504, "application/json", `{"error":"gateway timeout","code":"gateway_timeout"}`, false},
+	}
+
+	for i := range cases {
+
+		err := d.ValidateResponse(op, cases[i].Status, cases[i].ContentType, []byte(cases[i].Body))
+
+		if cases[i].Valid && err != nil {
+			t.Errorf("case %d: unexpected error: %s", i, err)
+		}
+
+		if !cases[i].Valid && err == nil {
+			t.Errorf("case %d: expected error", i)
+		}
+	}
+}
+
+func TestValidateEnum(t *testing.T) {
+
+	d := New("test")
+
+	err := d.Validate(Ref("Diff"), map[string]any{"changes": []any{map[string]any{"status": "added"}}})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	err = d.Validate(Ref("Diff"), map[string]any{"changes": []any{map[string]any{"status": "moved"}}})
+	if err == nil {
+		t.Fatalf("expected error for invalid enum")
+	}
+}
@@ -0,0 +1,107 @@
+package respond
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// lastModifiedKey is the key of the time set by LastModified() in the context.
+const lastModifiedKey = "respond.lastModified"
+
+// LastModified sets the time of the last modification of the response (the "last-modified" header).
+// The time is used to answer If-Modified-Since, it must be set before the response is written.
+// A zero t is ignored.
+func LastModified(c *gin.Context, t time.Time) {
+
+	if t.IsZero() {
+		return
+	}
+
+	// HTTP dates have second precision
+	t = t.UTC().Truncate(time.Second)
+
+	c.Set(lastModifiedKey, t)
+	c.Header("last-modified", t.Format(http.TimeFormat))
+}
+
+// etag returns the strong entity tag of body with the content type ct.
+// The content type is part of the tag, because the representations of a resource (see Negotiate()) can have the same body.
+func etag(ct string, body []byte) string {
+
+	h := sha256.New()
+	h.Write([]byte(ct))
+	h.Write([]byte{0})
+	h.Write(body)
+
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// matchETag returns whether the If-None-Match header h matches tag.
+// The weak comparison is used (eg.: W/"abc" matches "abc").
+func matchETag(h string, tag string) bool {
+
+	for _, t := range strings.Split(h, ",") {
+
+		t = strings.TrimSpace(t)
+
+		if t == "*" || strings.TrimPrefix(t, "W/") == tag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// notModified returns whether the request is a conditional GET that can be answered with 304 Not Modified.
+// If-None-Match takes precedence over If-Modified-Since, If-Modified-Since is used only if LastModified() was called.
+func notModified(c *gin.Context, tag string) bool {
+
+	if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+		return false
+	}
+
+	if h := c.GetHeader("If-None-Match"); h != "" {
+		return matchETag(h, tag)
+	}
+
+	h := c.GetHeader("If-Modified-Since")
+	if h == "" {
+		return false
+	}
+
+	since, err := http.ParseTime(h)
+	if err != nil {
+		return false
+	}
+
+	v, ok := c.Get(lastModifiedKey)
+	if !ok {
+		return false
+	}
+
+	return !v.(time.Time).After(since)
+}
+
+// Data writes body with code and the content type ct.
+// A successful response has an ETag, if the request is conditional and the client has the same response, answers with 304 Not Modified without body.
+func Data(c *gin.Context, code int, ct string, body []byte) {
+
+	if code == http.StatusOK {
+
+		tag := etag(ct, body)
+
+		c.Header("etag", tag)
+
+		if notModified(c, tag) {
+			c.Status(http.StatusNotModified)
+			return
+		}
+	}
+
+	c.Data(code, ct, body)
+}
@@ -0,0 +1,75 @@
+package respond
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestData(t *testing.T) {
+
+	gin.SetMode(gin.TestMode)
+
+	modified := time.Unix(1700000000, 0)
+
+	serve := func(header map[string]string) *httptest.ResponseRecorder {
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+
+		for k, v := range header {
+			c.Request.Header.Set(k, v)
+		}
+
+		LastModified(c, modified)
+		Data(c, http.StatusOK, MIMEText, []byte("one\ntwo"))
+		c.Writer.WriteHeaderNow()
+
+		return w
+	}
+
+	w := serve(nil)
+
+	tag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || tag == "" || w.Body.String() != "one\ntwo" {
+		t.Fatalf("unconditional: got %d, etag %q, body %q", w.Code, tag, w.Body.String())
+	}
+
+	if lm := w.Header().Get("Last-Modified"); lm != modified.UTC().Format(http.TimeFormat) {
+		t.Fatalf("unexpected Last-Modified: %s", lm)
+	}
+
+	cases := []struct {
+		Header map[string]string
+		Code   int
+	}{
+		{map[string]string{"If-None-Match": tag}, http.StatusNotModified},
+		{map[string]string{"If-None-Match": "W/" + tag}, http.StatusNotModified},
+		{map[string]string{"If-None-Match": `"other", ` + tag}, http.StatusNotModified},
+		{map[string]string{"If-None-Match": "*"}, http.StatusNotModified},
+		{map[string]string{"If-None-Match": `"other"`}, http.StatusOK},
+		{map[string]string{"If-Modified-Since": modified.UTC().Format(http.TimeFormat)}, http.StatusNotModified},
+		{map[string]string{"If-Modified-Since": modified.Add(time.Hour).UTC().Format(http.TimeFormat)}, http.StatusNotModified},
+		{map[string]string{"If-Modified-Since": modified.Add(-time.Hour).UTC().Format(http.TimeFormat)}, http.StatusOK},
+		{map[string]string{"If-Modified-Since": "invalid"}, http.StatusOK},
+		// If-None-Match takes precedence
+		{map[string]string{"If-None-Match": `"other"`, "If-Modified-Since": modified.UTC().Format(http.TimeFormat)}, http.StatusOK},
+	}
+
+	for i := range cases {
+
+		w := serve(cases[i].Header)
+
+		if w.Code != cases[i].Code {
+			t.Errorf("case %d: want %d, got %d", i, cases[i].Code, w.Code)
+		}
+
+		if w.Code == http.StatusNotModified && w.Body.Len() != 0 {
+			t.Errorf("case %d: 304 with body %q", i, w.Body.String())
+		}
+	}
+}
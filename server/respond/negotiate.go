@@ -0,0 +1,130 @@
+package respond
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Media types of the API responses.
+const (
+	MIMEJSON   = "application/json"
+	MIMEText   = "text/plain"
+	MIMENDJSON = "application/x-ndjson"
+	MIMECSV    = "text/csv"
+	MIMEHTML   = "text/html"
+)
+
+// mediaRange is an element of the Accept header (eg.: "text/*;q=0.5").
+type mediaRange struct {
+	Type    string
+	Subtype string
+	Q       float64
+}
+
+// matches returns whether r matches the media type t and the specificity of the match (0 for "*/*", 1 for "type/*", 2 for exact match).
+// Returns -1 if r does not match t.
+func (r mediaRange) matches(t string) int {
+
+	typ, sub, _ := strings.Cut(t, "/")
+
+	switch {
+	case r.Type == "*" && r.Subtype == "*":
+		return 0
+	case r.Type == typ && r.Subtype == "*":
+		return 1
+	case r.Type == typ && r.Subtype == sub:
+		return 2
+	default:
+		return -1
+	}
+}
+
+// parseAccept parses the Accept header h.
+// The invalid elements are ignored, the missing q-value is 1.
+func parseAccept(h string) []mediaRange {
+
+	rs := make([]mediaRange, 0)
+
+	for _, e := range strings.Split(h, ",") {
+
+		params := strings.Split(e, ";")
+
+		typ, sub, ok := strings.Cut(strings.ToLower(strings.TrimSpace(params[0])), "/")
+		if !ok || typ == "" || sub == "" || (typ == "*" && sub != "*") {
+			continue
+		}
+
+		r := mediaRange{Type: typ, Subtype: sub, Q: 1}
+
+		for _, p := range params[1:] {
+
+			k, v, _ := strings.Cut(strings.TrimSpace(p), "=")
+			if !strings.EqualFold(k, "q") {
+				continue
+			}
+
+			q, err := strconv.ParseFloat(v, 64)
+			if err != nil || q < 0 || q > 1 {
+				q = 0
+			}
+
+			r.Q = q
+		}
+
+		rs = append(rs, r)
+	}
+
+	return rs
+}
+
+// quality returns the q-value of the media type t in rs.
+// The q-value of the most specific matching range is used (eg.: "text/plain;q=0.1" overrides "text/*").
+func quality(rs []mediaRange, t string) float64 {
+
+	q := 0.0
+	specificity := -1
+
+	for i := range rs {
+		if s := rs[i].matches(t); s > specificity {
+			q = rs[i].Q
+			specificity = s
+		}
+	}
+
+	return q
+}
+
+// negotiate returns the element of offers with the highest q-value in the Accept header h.
+// On a tie, the first offer wins, so the offers must be in the order of preference of the server.
+// If h is empty or none of the offers is acceptable, returns the first offer.
+func negotiate(h string, offers []string) string {
+
+	if len(offers) == 0 {
+		return ""
+	}
+
+	rs := parseAccept(h)
+	if len(rs) == 0 {
+		return offers[0]
+	}
+
+	best := offers[0]
+	bestQ := 0.0
+
+	for _, o := range offers {
+		if q := quality(rs, o); q > bestQ {
+			best = o
+			bestQ = q
+		}
+	}
+
+	return best
+}
+
+// Negotiate returns the media type from offers preferred by the Accept header of the request.
+// The first offer is the default, returned if the header is missing or none of the offers is acceptable.
+func Negotiate(c *gin.Context, offers ...string) string {
+	return negotiate(c.GetHeader("Accept"), offers)
+}
@@ -0,0 +1,77 @@
+package respond
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/elmasy-com/columbus/fault"
+	"github.com/gin-gonic/gin"
+)
+
+func TestNegotiate(t *testing.T) {
+
+	offers := []string{MIMEJSON, MIMEText, MIMENDJSON, MIMECSV}
+
+	cases := map[string]string{
+		"":                                       MIMEJSON,
+		"*/*":                                    MIMEJSON,
+		"application/json":                       MIMEJSON,
+		"text/plain":                             MIMEText,
+		"TEXT/PLAIN":                             MIMEText,
+		"text/*":                                 MIMEText,
+		"text/csv":                               MIMECSV,
+		"application/x-ndjson":                   MIMENDJSON,
+		"text/html":                              MIMEJSON,
+		"invalid":                                MIMEJSON,
+		"text/plain;q=0.5, application/json":     MIMEJSON,
+		"application/json;q=0.5, text/plain":     MIMEText,
+		"text/*;q=0.9, text/plain;q=0.1":         MIMECSV,
+		"*/*;q=0.1, text/csv":                    MIMECSV,
+		"application/json;q=0, */*":              MIMEText,
+		"text/plain;q=invalid, application/*":    MIMEJSON,
+		"text/csv;q=0.8, application/x-ndjson":   MIMENDJSON,
+		"text/plain; charset=utf-8":              MIMEText,
+		"application/x-ndjson;q=1, text/csv;q=1": MIMENDJSON,
+	}
+
+	for h, want := range cases {
+		if got := negotiate(h, offers); got != want {
+			t.Errorf("%q: want %s, got %s", h, want, got)
+		}
+	}
+}
+
+func TestError(t *testing.T) {
+
+	gin.SetMode(gin.TestMode)
+
+	cases := []struct {
+		Err  error
+		Code string
+	}{
+		{fault.ErrInvalidDomain, "invalid_domain"},
+		{fault.ErrNotFound, "not_found"},
+		{http.ErrHandlerTimeout, "internal"},
+	}
+
+	for _, tc := range cases {
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+
+		Error(c, http.StatusBadRequest, tc.Err)
+
+		var body fault.ColumbusError
+
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("%s: invalid body: %s", tc.Err, err)
+		}
+
+		if body.Err != tc.Err.Error() || body.Code != tc.Code {
+			t.Errorf("%s: want %s/%s, got %s/%s", tc.Err, tc.Err, tc.Code, body.Err, body.Code)
+		}
+	}
+}
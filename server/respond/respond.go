@@ -0,0 +1,202 @@
+/*
+respond package is the response layer of the API handlers.
+
+The format of the response is negotiated from the Accept header with q-values (see Negotiate()).
+JSON is the default, plain text, NDJSON (JSON Lines) and CSV are available where it makes sense.
+
+The successful responses have an ETag and the conditional requests (If-None-Match, If-Modified-Since) are answered with 304 Not Modified (see Data()).
+
+Errors have the same body everywhere: {"error": "invalid domain", "code": "invalid_domain"} in JSON, the message in plain text.
+The code is the machine-readable code of the fault value (see fault.ColumbusError).
+*/
+package respond
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/elmasy-com/columbus/db"
+	"github.com/elmasy-com/columbus/export"
+	"github.com/elmasy-com/columbus/fault"
+	"github.com/gin-gonic/gin"
+)
+
+// staleIfError is the time while a stale response can be served by the caches if the server fails.
+const staleIfError = 7 * 24 * time.Hour
+
+// Cache sets the caching headers of a successful response, the response is fresh for maxAge.
+func Cache(c *gin.Context, maxAge time.Duration) {
+
+	c.Header("cache-control", fmt.Sprintf("public, max-age=%d, must-revalidate, stale-if-error=%d", int(maxAge.Seconds()), int(staleIfError.Seconds())))
+	c.Header("expires", time.Now().UTC().Add(maxAge).Format(http.TimeFormat))
+}
+
+// Error writes err with code.
+// The code of the body is the code of the fault.ColumbusError in err, or the code of fault.ErrInternal if err is not a fault.
+// The message of err is sent to the client, so it must not contain internal details (eg.: use fault.ErrInternal).
+func Error(c *gin.Context, code int, err error) {
+
+	body := fault.ColumbusError{Err: err.Error(), Code: fault.Code(err)}
+	if body.Code == "" {
+		body.Code = fault.ErrInternal.Code
+	}
+
+	c.Header("vary", "Accept")
+
+	if Negotiate(c, MIMEJSON, MIMEText) == MIMEText {
+		Text(c, code, body.Err)
+	} else {
+		JSON(c, code, body)
+	}
+}
+
+// Abort writes err with code (see Error()) and stops the remaining handlers of the request.
+// Used by the middlewares.
+func Abort(c *gin.Context, code int, err error) {
+
+	Error(c, code, err)
+	c.Abort()
+}
+
+// JSON writes v with code in JSON (see Data()).
+func JSON(c *gin.Context, code int, v any) {
+
+	body, err := json.Marshal(v)
+	if err != nil {
+		c.Error(fmt.Errorf("failed to marshal response: %w", err))
+		c.JSON(http.StatusInternalServerError, fault.ErrInternal)
+		return
+	}
+
+	Data(c, code, "application/json; charset=utf-8", body)
+}
+
+// Text writes s with code in plain text (see Data()).
+func Text(c *gin.Context, code int, s string) {
+	Data(c, code, "text/plain; charset=utf-8", []byte(s))
+}
+
+// List writes list (eg.: subdomains) with code as a JSON array, newline separated text, NDJSON (a JSON string per line) or CSV (a value per line).
+func List(c *gin.Context, code int, list []string) {
+
+	c.Header("vary", "Accept")
+
+	switch Negotiate(c, MIMEJSON, MIMEText, MIMENDJSON, MIMECSV) {
+	case MIMEText:
+
+		Text(c, code, strings.Join(list, "\n"))
+
+	case MIMENDJSON:
+
+		buf := new(bytes.Buffer)
+
+		enc := json.NewEncoder(buf)
+		enc.SetEscapeHTML(false)
+
+		for i := range list {
+			enc.Encode(list[i])
+		}
+
+		Data(c, code, export.ContentType(export.FormatNDJSON), buf.Bytes())
+
+	case MIMECSV:
+
+		buf := new(bytes.Buffer)
+
+		w := csv.NewWriter(buf)
+
+		for i := range list {
+			w.Write([]string{list[i]})
+		}
+
+		w.Flush()
+
+		Data(c, code, export.ContentType(export.FormatCSV), buf.Bytes())
+
+	default:
+		JSON(c, code, list)
+	}
+}
+
+// Result writes the result of a tool with code as {"result": v} in JSON or v in plain text.
+func Result(c *gin.Context, code int, v any) {
+
+	c.Header("vary", "Accept")
+
+	if Negotiate(c, MIMEJSON, MIMEText) == MIMEText {
+		Text(c, code, fmt.Sprint(v))
+	} else {
+		JSON(c, code, gin.H{"result": v})
+	}
+}
+
+// Texter is implemented by the responses with a plain text form.
+type Texter interface {
+	Text() string
+}
+
+// Value writes v with code in JSON or the Text() of v in plain text.
+func Value(c *gin.Context, code int, v Texter) {
+
+	c.Header("vary", "Accept")
+
+	if Negotiate(c, MIMEJSON, MIMEText) == MIMEText {
+		Text(c, code, v.Text())
+	} else {
+		JSON(c, code, v)
+	}
+}
+
+// FQDN is a FQDN with records in a response (eg.: history, reverse lookup).
+type FQDN struct {
+	Domain  string      `json:"domain"`
+	Records []db.Record `json:"records"`
+	Updated int64       `json:"-"` // Time of the last update of the FQDN, used in the NDJSON and CSV formats (0 if unknown)
+}
+
+// FQDNs writes fs with code as a JSON array, newline separated text of the FQDNs, or the records in NDJSON or CSV (see export.Row).
+func FQDNs(c *gin.Context, code int, fs []FQDN) {
+
+	c.Header("vary", "Accept")
+
+	format := ""
+
+	switch Negotiate(c, MIMEJSON, MIMEText, MIMENDJSON, MIMECSV) {
+	case MIMEText:
+
+		ds := make([]string, 0, len(fs))
+		for i := range fs {
+			ds = append(ds, fs[i].Domain)
+		}
+
+		Text(c, code, strings.Join(ds, "\n"))
+		return
+
+	case MIMENDJSON:
+		format = export.FormatNDJSON
+	case MIMECSV:
+		format = export.FormatCSV
+	default:
+		JSON(c, code, fs)
+		return
+	}
+
+	buf := new(bytes.Buffer)
+
+	w, _ := export.NewWriter(buf, format)
+
+	for i := range fs {
+		for _, r := range export.RecordRows(fs[i].Domain, fs[i].Records, fs[i].Updated) {
+			w.Write(r)
+		}
+	}
+
+	w.Flush()
+
+	Data(c, code, export.ContentType(format), buf.Bytes())
+}
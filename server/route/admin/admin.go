@@ -0,0 +1,41 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/elmasy-com/columbus/fault"
+	"github.com/elmasy-com/columbus/server/config"
+	"github.com/elmasy-com/columbus/server/respond"
+	"github.com/gin-gonic/gin"
+)
+
+// Auth is a middleware to authenticate the admin with the "X-Api-Key" header.
+// If config.AdminKey is empty, every admin request is refused.
+func Auth(c *gin.Context) {
+
+	if config.Blocklist.IsBlocked(c.ClientIP()) {
+		respond.Abort(c, http.StatusForbidden, fault.ErrBlocked)
+		return
+	}
+
+	if config.AdminKey == "" {
+		respond.Abort(c, http.StatusForbidden, fault.ErrNotAdmin)
+		return
+	}
+
+	key := c.GetHeader("X-Api-Key")
+	if key == "" {
+		respond.Abort(c, http.StatusUnauthorized, fault.ErrMissingAPIKey)
+		return
+	}
+
+	if subtle.ConstantTimeCompare([]byte(key), []byte(config.AdminKey)) != 1 {
+		config.Blocklist.Block(c.ClientIP())
+		c.Error(fault.ErrInvalidAPIKey)
+		respond.Abort(c, http.StatusUnauthorized, fault.ErrInvalidAPIKey)
+		return
+	}
+
+	c.Next()
+}
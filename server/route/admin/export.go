@@ -0,0 +1,154 @@
+package admin
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/elmasy-com/columbus/db"
+	"github.com/elmasy-com/columbus/export"
+	"github.com/elmasy-com/columbus/fault"
+	"github.com/elmasy-com/columbus/server/openapi"
+	"github.com/elmasy-com/columbus/server/respond"
+	"github.com/elmasy-com/elnet/dns"
+	"github.com/gin-gonic/gin"
+)
+
+// Number of rows written between flushes of the bulk export.
+const exportChunkSize = 1000
+
+// The trailer of the bulk export, "complete" if every FQDN is written, "failed" if the export stopped on an error.
+// A missing trailer means the stream is truncated.
+const (
+	exportStatusTrailer  = "X-Columbus-Export-Status"
+	exportStatusComplete = "complete"
+	exportStatusFailed   = "failed"
+)
+
+// GetExportDoc is the OpenAPI operation of GetExport.
+var GetExportDoc = &openapi.Operation{
+	ID:      "GetAdminExport",
+	Tags:    []string{"admin"},
+	Summary: "Bulk export",
+	Description: "Streams every subdomain of a TLD, or the full dataset if `tld` is empty, in the same format as `/api/export/{domain}`.\n" +
+		"\n" +
+		"The response is compressed and flushed in chunks. The export has no deadline.\n" +
+		"If the export fails after the response started, the stream stops and the compressed file is truncated.\n" +
+		"\n" +
+		"The end of the stream is marked by the `X-Columbus-Export-Status` HTTP trailer: `complete` if every subdomain is written, `failed` if the export stopped on an error.\n" +
+		"A missing trailer means the stream is truncated. Check the trailer to detect an incomplete export, especially with `compression=none`.\n",
+	Security: openapi.APIKey,
+	Parameters: []openapi.Parameter{
+		openapi.QueryParam("tld", "TLD to export (eg.: `com`, `co.uk`).", openapi.String()),
+		openapi.QueryParam("format", "", &openapi.Schema{Type: "string", Enum: []string{"csv", "ndjson"}, Default: "csv"}),
+		openapi.QueryParam("compression", "", &openapi.Schema{Type: "string", Enum: []string{"gzip", "zstd", "none"}, Default: "gzip"}),
+	},
+	Responses: openapi.Responses{
+		200: openapi.Content("Success.", map[string]*openapi.Schema{
+			"application/gzip": {Type: "string", Format: "binary"},
+			"application/zstd": {Type: "string", Format: "binary"},
+		}),
+		400: openapi.Error("Invalid TLD, format or compression."),
+		401: openapi.Error("Missing or invalid API key."),
+		403: openapi.Error("Admin endpoints are disabled or client IP blocked."),
+	},
+}
+
+// GET /api/admin/export?tld=com&format=csv&compression=gzip
+// Streams every FQDN in tld with the records in CSV or NDJSON format.
+// If tld is empty, exports the full dataset.
+// The format is "csv" (default) or "ndjson", compression is "gzip" (default), "zstd" or "none".
+//
+// The export has no deadline, it runs until every FQDN is written or the client disconnects.
+// The response is flushed after every exportChunkSize rows.
+// After the first chunk the status is already sent, so an error stops the stream and the body is incomplete.
+// The result is sent in the exportStatusTrailer trailer, so the client can detect the incomplete body without compression.
+func GetExport(c *gin.Context) {
+
+	tld := c.Query("tld")
+	if tld != "" && !dns.IsValid(tld) {
+		c.Error(fault.ErrInvalidDomain)
+		respond.Error(c, http.StatusBadRequest, fault.ErrInvalidDomain)
+		return
+	}
+
+	format := c.DefaultQuery("format", export.FormatCSV)
+	if format != export.FormatCSV && format != export.FormatNDJSON {
+		c.Error(fault.ErrInvalidFormat)
+		respond.Error(c, http.StatusBadRequest, fault.ErrInvalidFormat)
+		return
+	}
+
+	compression := c.DefaultQuery("compression", export.CompressionGzip)
+	if compression == "none" {
+		compression = export.CompressionNone
+	}
+
+	cw, err := export.NewCompressor(c.Writer, compression)
+	if err != nil {
+		c.Error(err)
+		respond.Error(c, http.StatusBadRequest, fault.ErrInvalidCompression)
+		return
+	}
+
+	name := "columbus"
+	if tld != "" {
+		name += "-" + dns.Clean(tld)
+	}
+
+	c.Header("trailer", exportStatusTrailer)
+	c.Header("cache-control", "no-store")
+	c.Header("content-disposition", fmt.Sprintf("attachment; filename=\"%s%s\"", name, export.Extension(format, compression)))
+	if compression == export.CompressionNone {
+		c.Header("content-type", export.ContentType(format))
+	} else {
+		c.Header("content-type", export.ContentType(compression))
+	}
+	c.Status(http.StatusOK)
+
+	w, _ := export.NewWriter(cw, format)
+
+	n := 0
+
+	err = db.DomainsExport(c.Request.Context(), tld, func(d *db.Domain) error {
+
+		for _, r := range export.Rows(d) {
+			if err := w.Write(r); err != nil {
+				return err
+			}
+		}
+
+		n++
+
+		if n%exportChunkSize == 0 {
+			if err := w.Flush(); err != nil {
+				return err
+			}
+			if err := cw.Flush(); err != nil {
+				return err
+			}
+			c.Writer.Flush()
+		}
+
+		return nil
+	})
+	if err != nil {
+		// Dont close the compressor, a truncated archive shows the failure to the client
+		c.Error(fmt.Errorf("export failed after %d domains: %w", n, err))
+		c.Writer.Header().Set(exportStatusTrailer, exportStatusFailed)
+		return
+	}
+
+	if err := w.Flush(); err != nil {
+		c.Error(err)
+		c.Writer.Header().Set(exportStatusTrailer, exportStatusFailed)
+		return
+	}
+
+	if err := cw.Close(); err != nil {
+		c.Error(err)
+		c.Writer.Header().Set(exportStatusTrailer, exportStatusFailed)
+		return
+	}
+
+	c.Writer.Header().Set(exportStatusTrailer, exportStatusComplete)
+}
@@ -0,0 +1,197 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/elmasy-com/columbus/db"
+	"github.com/elmasy-com/columbus/fault"
+	"github.com/elmasy-com/columbus/server/common"
+	"github.com/elmasy-com/columbus/server/openapi"
+	"github.com/elmasy-com/columbus/server/respond"
+	"github.com/gin-gonic/gin"
+)
+
+// GetQueueDoc is the OpenAPI operation of GetQueue.
+var GetQueueDoc = &openapi.Operation{
+	ID:      "GetAdminQueue",
+	Tags:    []string{"admin"},
+	Summary: "Durable queue statistics",
+	Description: "Returns the number of items in the durable queue (`queued`), the currently leased items (`leased`),\n" +
+		"the number of items in the dead-letter collection (`deadLetter`) and the depths of the records updater (`scheduler`).\n",
+	Security: openapi.APIKey,
+	Responses: openapi.Responses{
+		200: openapi.JSON("Success.", &openapi.Schema{
+			Type: "object",
+			Properties: map[string]*openapi.Schema{
+				"queued":     openapi.Integer(),
+				"leased":     openapi.Integer(),
+				"deadLetter": openapi.Integer(),
+				"scheduler":  {Type: "object", AdditionalProperties: openapi.Integer()},
+			},
+		}),
+		401: openapi.Error("Missing or invalid API key."),
+		403: openapi.Error("Admin endpoints are disabled or client IP blocked."),
+		500: openapi.Error("Internal Server Error."),
+		504: openapi.Error("Gateway Timeout. The database query takes too long."),
+	},
+}
+
+// GET /api/admin/queue
+// Returns the number of items in the durable queue and in the dead-letter collection.
+func GetQueue(c *gin.Context) {
+
+	ctx, cancel := common.Context(c, "admin")
+	defer cancel()
+
+	total, leased, err := db.QueueCount(ctx)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	dead, err := db.DeadLetter.EstimatedDocumentCount(ctx)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	respond.JSON(c, http.StatusOK, gin.H{"queued": total, "leased": leased, "deadLetter": dead, "scheduler": db.UpdaterDepths()})
+}
+
+// GetDeadLetterDoc is the OpenAPI operation of GetDeadLetter.
+var GetDeadLetterDoc = &openapi.Operation{
+	ID:          "GetAdminDeadLetter",
+	Tags:        []string{"admin"},
+	Summary:     "List the dead-letter collection",
+	Description: "Returns the domains that failed to update too many times because of DNS failures, or failed with a permanent error (eg.: invalid domain), the newest first.",
+	Security:    openapi.APIKey,
+	Parameters: []openapi.Parameter{
+		openapi.QueryParam("skip", "", &openapi.Schema{Type: "integer", Default: 0}),
+		openapi.QueryParam("limit", "", &openapi.Schema{Type: "integer", Default: 100, Maximum: 1000}),
+	},
+	Responses: openapi.Responses{
+		200: openapi.JSON("Success.", openapi.ArrayOf(openapi.Ref("QueueItem"))),
+		400: openapi.Error("Invalid skip or limit."),
+		401: openapi.Error("Missing or invalid API key."),
+		403: openapi.Error("Admin endpoints are disabled or client IP blocked."),
+		500: openapi.Error("Internal Server Error."),
+		504: openapi.Error("Gateway Timeout. The database query takes too long."),
+	},
+}
+
+// GET /api/admin/deadletter?skip=0&limit=100
+// Returns the items in the dead-letter collection, the newest first.
+func GetDeadLetter(c *gin.Context) {
+
+	ctx, cancel := common.Context(c, "admin")
+	defer cancel()
+
+	skip, err := strconv.ParseInt(c.DefaultQuery("skip", "0"), 10, 64)
+	if err != nil || skip < 0 {
+		respond.Error(c, http.StatusBadRequest, fault.ErrInvalidSkip)
+		return
+	}
+
+	limit, err := strconv.ParseInt(c.DefaultQuery("limit", "100"), 10, 64)
+	if err != nil || limit < 1 || limit > 1000 {
+		respond.Error(c, http.StatusBadRequest, fault.ErrInvalidLimit)
+		return
+	}
+
+	qs, err := db.DeadLetterGets(ctx, skip, limit)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	respond.JSON(c, http.StatusOK, qs)
+}
+
+// PostDeadLetterRetryDoc is the OpenAPI operation of PostDeadLetterRetry.
+var PostDeadLetterRetryDoc = &openapi.Operation{
+	ID:          "PostAdminDeadLetterRetry",
+	Tags:        []string{"admin"},
+	Summary:     "Retry a dead-lettered domain",
+	Description: "Moves the domain back to the durable queue with zero attempts.",
+	Security:    openapi.APIKey,
+	Parameters: []openapi.Parameter{
+		openapi.PathParam("domain", "", openapi.String()),
+	},
+	Responses: openapi.Responses{
+		200: openapi.Empty("OK"),
+		400: openapi.Error("Invalid domain."),
+		401: openapi.Error("Missing or invalid API key."),
+		403: openapi.Error("Admin endpoints are disabled or client IP blocked."),
+		404: openapi.Error("Domain is not in the dead-letter collection."),
+		500: openapi.Error("Internal Server Error."),
+		504: openapi.Error("Gateway Timeout. The database query takes too long."),
+	},
+}
+
+// POST /api/admin/deadletter/:domain/retry
+// Moves domain back from the dead-letter collection to the durable queue.
+func PostDeadLetterRetry(c *gin.Context) {
+
+	ctx, cancel := common.Context(c, "admin")
+	defer cancel()
+
+	deadLetterResponse(c, db.DeadLetterRetry(ctx, c.Param("domain")))
+}
+
+// DeleteDeadLetterDoc is the OpenAPI operation of DeleteDeadLetter.
+var DeleteDeadLetterDoc = &openapi.Operation{
+	ID:       "DeleteAdminDeadLetter",
+	Tags:     []string{"admin"},
+	Summary:  "Remove a dead-lettered domain",
+	Security: openapi.APIKey,
+	Parameters: []openapi.Parameter{
+		openapi.PathParam("domain", "", openapi.String()),
+	},
+	Responses: openapi.Responses{
+		200: openapi.Empty("OK"),
+		400: openapi.Error("Invalid domain."),
+		401: openapi.Error("Missing or invalid API key."),
+		403: openapi.Error("Admin endpoints are disabled or client IP blocked."),
+		404: openapi.Error("Domain is not in the dead-letter collection."),
+		500: openapi.Error("Internal Server Error."),
+		504: openapi.Error("Gateway Timeout. The database query takes too long."),
+	},
+}
+
+// DELETE /api/admin/deadletter/:domain
+// Removes domain from the dead-letter collection.
+func DeleteDeadLetter(c *gin.Context) {
+
+	ctx, cancel := common.Context(c, "admin")
+	defer cancel()
+
+	deadLetterResponse(c, db.DeadLetterDelete(ctx, c.Param("domain")))
+}
+
+// internalError responds with 504 if err is a timeout, otherwise with 500.
+func internalError(c *gin.Context, err error) {
+
+	c.Error(err)
+
+	if common.IsTimeout(err) {
+		respond.Error(c, http.StatusGatewayTimeout, fault.ErrGatewayTimeout)
+	} else {
+		respond.Error(c, http.StatusInternalServerError, fault.ErrInternal)
+	}
+}
+
+func deadLetterResponse(c *gin.Context, err error) {
+
+	switch {
+	case err == nil:
+		c.Status(http.StatusOK)
+	case errors.Is(err, fault.ErrInvalidDomain):
+		respond.Error(c, http.StatusBadRequest, fault.ErrInvalidDomain)
+	case errors.Is(err, fault.ErrNotFound):
+		respond.Error(c, http.StatusNotFound, fault.ErrNotFound)
+	default:
+		internalError(c, err)
+	}
+}
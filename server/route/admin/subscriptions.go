@@ -0,0 +1,212 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/elmasy-com/columbus/db"
+	"github.com/elmasy-com/columbus/fault"
+	"github.com/elmasy-com/columbus/server/common"
+	"github.com/elmasy-com/columbus/server/openapi"
+	"github.com/elmasy-com/columbus/server/respond"
+	"github.com/gin-gonic/gin"
+)
+
+type subscriptionRequest struct {
+	Apex   string         `json:"apex"`
+	URL    string         `json:"url"`
+	Secret string         `json:"secret"`
+	Events []db.EventType `json:"events"`
+}
+
+// PostSubscriptionDoc is the OpenAPI operation of PostSubscription.
+var PostSubscriptionDoc = &openapi.Operation{
+	ID:      "PostAdminSubscription",
+	Tags:    []string{"admin"},
+	Summary: "Create a webhook subscription",
+	Description: "Subscribe to the change events of an apex domain and every subdomain of it.\n" +
+		"\n" +
+		"The events are sent as JSON (see `Event`) with a `POST` request to `url`.\n" +
+		"The payload is signed with HMAC-SHA256 using `secret`, the signature is in the `X-Columbus-Signature` header in the format `sha256=<hex>`.\n" +
+		"The type of the event is in the `X-Columbus-Event` header, the ID of the event is in the `X-Columbus-Event-Id` header.\n" +
+		"The ID of the delivery (the same in every retry, listed in `/api/admin/subscriptions/{id}/deliveries`) is in the `X-Columbus-Delivery` header.\n" +
+		"\n" +
+		"Failed deliveries (network error or non 2xx status code) are retried with an exponential backoff, the retries continue after a restart.\n" +
+		"The events are delivered at least once in `seq` order, the receiver should ignore the already seen `X-Columbus-Delivery`.\n" +
+		"\n" +
+		"Event types: `newFQDN`, `newRecord`, `removedRecord`. If `events` is empty, every type is sent.\n",
+	Security: openapi.APIKey,
+	RequestBody: openapi.JSONBody(&openapi.Schema{
+		Type:     "object",
+		Required: []string{"apex", "url", "secret"},
+		Properties: map[string]*openapi.Schema{
+			"apex":   openapi.String(),
+			"url":    openapi.String(),
+			"secret": openapi.String(),
+			"events": openapi.ArrayOf(openapi.String()),
+		},
+	}),
+	Responses: openapi.Responses{
+		201: openapi.JSON("Created.", openapi.Ref("Subscription")),
+		400: openapi.Error("Invalid body, apex, URL or event type."),
+		401: openapi.Error("Missing or invalid API key."),
+		403: openapi.Error("Admin endpoints are disabled or client IP blocked."),
+		500: openapi.Error("Internal Server Error."),
+		504: openapi.Error("Gateway Timeout. The database query takes too long."),
+	},
+}
+
+// POST /api/admin/subscriptions
+// Creates a new webhook subscription for an apex domain.
+func PostSubscription(c *gin.Context) {
+
+	ctx, cancel := common.Context(c, "admin")
+	defer cancel()
+
+	var req subscriptionRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respond.Error(c, http.StatusBadRequest, fault.ErrInvalidBody)
+		return
+	}
+
+	if req.Secret == "" {
+		respond.Error(c, http.StatusBadRequest, fault.ErrSecretEmpty)
+		return
+	}
+
+	s, err := db.SubscriptionsInsert(ctx, req.Apex, req.URL, req.Secret, req.Events)
+	if err != nil {
+
+		switch {
+		case errors.Is(err, fault.ErrInvalidDomain), errors.Is(err, fault.ErrInvalidURL), errors.Is(err, fault.ErrInvalidEvent):
+			respond.Error(c, http.StatusBadRequest, err)
+		default:
+			internalError(c, err)
+		}
+
+		return
+	}
+
+	respond.JSON(c, http.StatusCreated, s)
+}
+
+// GetSubscriptionsDoc is the OpenAPI operation of GetSubscriptions.
+var GetSubscriptionsDoc = &openapi.Operation{
+	ID:       "GetAdminSubscriptions",
+	Tags:     []string{"admin"},
+	Summary:  "List webhook subscriptions",
+	Security: openapi.APIKey,
+	Parameters: []openapi.Parameter{
+		openapi.QueryParam("apex", "Return only the subscriptions of this apex domain.", openapi.String()),
+	},
+	Responses: openapi.Responses{
+		200: openapi.JSON("Success.", openapi.ArrayOf(openapi.Ref("Subscription"))),
+		401: openapi.Error("Missing or invalid API key."),
+		403: openapi.Error("Admin endpoints are disabled or client IP blocked."),
+		500: openapi.Error("Internal Server Error."),
+		504: openapi.Error("Gateway Timeout. The database query takes too long."),
+	},
+}
+
+// GET /api/admin/subscriptions?apex=example.com
+// Returns the webhook subscriptions. If apex is set, returns only the subscriptions of apex.
+func GetSubscriptions(c *gin.Context) {
+
+	ctx, cancel := common.Context(c, "admin")
+	defer cancel()
+
+	ss, err := db.SubscriptionsGets(ctx, c.Query("apex"))
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	respond.JSON(c, http.StatusOK, ss)
+}
+
+// DeleteSubscriptionDoc is the OpenAPI operation of DeleteSubscription.
+var DeleteSubscriptionDoc = &openapi.Operation{
+	ID:       "DeleteAdminSubscription",
+	Tags:     []string{"admin"},
+	Summary:  "Remove a webhook subscription",
+	Security: openapi.APIKey,
+	Parameters: []openapi.Parameter{
+		openapi.PathParam("id", "", openapi.String()),
+	},
+	Responses: openapi.Responses{
+		200: openapi.Empty("OK"),
+		401: openapi.Error("Missing or invalid API key."),
+		403: openapi.Error("Admin endpoints are disabled or client IP blocked."),
+		404: openapi.Error("Subscription not found."),
+		500: openapi.Error("Internal Server Error."),
+		504: openapi.Error("Gateway Timeout. The database query takes too long."),
+	},
+}
+
+// DELETE /api/admin/subscriptions/:id
+// Removes the webhook subscription.
+func DeleteSubscription(c *gin.Context) {
+
+	ctx, cancel := common.Context(c, "admin")
+	defer cancel()
+
+	err := db.SubscriptionsDelete(ctx, c.Param("id"))
+
+	switch {
+	case err == nil:
+		c.Status(http.StatusOK)
+	case errors.Is(err, fault.ErrNotFound):
+		respond.Error(c, http.StatusNotFound, fault.ErrNotFound)
+	default:
+		internalError(c, err)
+	}
+}
+
+// GetDeliveriesDoc is the OpenAPI operation of GetDeliveries.
+var GetDeliveriesDoc = &openapi.Operation{
+	ID:          "GetAdminDeliveries",
+	Tags:        []string{"admin"},
+	Summary:     "Webhook deliveries",
+	Description: "Returns the last deliveries of the subscription with the state and the attempts, the newest first.",
+	Security:    openapi.APIKey,
+	Parameters: []openapi.Parameter{
+		openapi.PathParam("id", "", openapi.String()),
+		openapi.QueryParam("limit", "", &openapi.Schema{Type: "integer", Default: 100, Maximum: 1000}),
+	},
+	Responses: openapi.Responses{
+		200: openapi.JSON("Success.", openapi.ArrayOf(openapi.Ref("Delivery"))),
+		400: openapi.Error("Invalid limit."),
+		401: openapi.Error("Missing or invalid API key."),
+		403: openapi.Error("Admin endpoints are disabled or client IP blocked."),
+		404: openapi.Error("Subscription not found."),
+		500: openapi.Error("Internal Server Error."),
+		504: openapi.Error("Gateway Timeout. The database query takes too long."),
+	},
+}
+
+// GET /api/admin/subscriptions/:id/deliveries?limit=100
+// Returns the last deliveries of the subscription, the newest first.
+func GetDeliveries(c *gin.Context) {
+
+	ctx, cancel := common.Context(c, "admin")
+	defer cancel()
+
+	limit, err := strconv.ParseInt(c.DefaultQuery("limit", "100"), 10, 64)
+	if err != nil || limit < 1 || limit > 1000 {
+		respond.Error(c, http.StatusBadRequest, fault.ErrInvalidLimit)
+		return
+	}
+
+	ds, err := db.DeliveriesGets(ctx, c.Param("id"), limit)
+
+	switch {
+	case err == nil:
+		respond.JSON(c, http.StatusOK, ds)
+	case errors.Is(err, fault.ErrNotFound):
+		respond.Error(c, http.StatusNotFound, fault.ErrNotFound)
+	default:
+		internalError(c, err)
+	}
+}
@@ -0,0 +1,139 @@
+package diff
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/elmasy-com/columbus/db"
+	"github.com/elmasy-com/columbus/fault"
+	"github.com/elmasy-com/columbus/server/common"
+	"github.com/elmasy-com/columbus/server/openapi"
+	"github.com/elmasy-com/columbus/server/respond"
+	"github.com/elmasy-com/elnet/dns"
+	"github.com/gin-gonic/gin"
+)
+
+// Diff is the response of GET /api/diff/:domain.
+type Diff struct {
+	Domain  string          `json:"domain"`
+	Since   int64           `json:"since"`
+	Until   int64           `json:"until"`
+	Changes []db.DiffSchema `json:"changes"`
+}
+
+// statusSign returns the sign of s used in the text form.
+func statusSign(s db.DiffStatus) string {
+
+	switch s {
+	case db.DiffAdded:
+		return "+"
+	case db.DiffRemoved:
+		return "-"
+	default:
+		return "~"
+	}
+}
+
+// Text returns the text form of d.
+// Every FQDN is a line with the sign of the status ("+", "-" or "~"),
+// followed by the added ("+") and removed ("-") records of the FQDN (eg.: "+ www.example.com A 203.0.113.1").
+func (d *Diff) Text() string {
+
+	var b strings.Builder
+
+	for i := range d.Changes {
+
+		fmt.Fprintf(&b, "%s %s\n", statusSign(d.Changes[i].Status), d.Changes[i].Domain)
+
+		for _, r := range d.Changes[i].Added {
+			fmt.Fprintf(&b, "+ %s %s %s\n", d.Changes[i].Domain, db.RecordTypeString(r.Type), r.Value)
+		}
+
+		for _, r := range d.Changes[i].Removed {
+			fmt.Fprintf(&b, "- %s %s %s\n", d.Changes[i].Domain, db.RecordTypeString(r.Type), r.Value)
+		}
+	}
+
+	return b.String()
+}
+
+// GetApiDiffDoc is the OpenAPI operation of GetApiDiff.
+var GetApiDiffDoc = &openapi.Operation{
+	ID:      "GetDiff",
+	Tags:    []string{"domain"},
+	Summary: "Changes of the subdomains in a time window.",
+	Description: "Returns the subdomains of `domain` and their records that were added, removed or changed between `since` and `until`.\n" +
+		"\n" +
+		"The `status` of a subdomain is:\n" +
+		"- `added`: the subdomain inserted in the window (even without records) or every record of the subdomain first seen in the window.\n" +
+		"- `removed`: every record of the subdomain removed, the last one in the window.\n" +
+		"- `changed`: some records added or removed in the window.\n" +
+		"\n" +
+		"The `added` field contains the records first seen in the window, the `removed` field contains the records removed in the window.\n" +
+		"\n" +
+		"The time can be a date (eg.: `2023-01-02`), a RFC3339 time, a Unix timestamp or relative in days/hours (eg.: `30d`, `12h`).\n" +
+		"\n" +
+		"The text form lists the subdomains with the sign of the status (`+`, `-` or `~`), followed by the added (`+`) and removed (`-`) records (eg.: `+ www.example.com A 203.0.113.1`).\n" +
+		"\n" +
+		"# Note\n" +
+		"- The subdomain part will be trimmed (eg.: `/api/diff/www.example.com` will be the same as `/api/diff/example.com`).\n",
+	Parameters: []openapi.Parameter{
+		openapi.PathParam("domain", "Domain to diff.", openapi.String()),
+		openapi.QueryParam("since", "Start of the window (default `7d`, 7 days ago).", openapi.String()),
+		openapi.QueryParam("until", "End of the window (default now).", openapi.String()),
+	},
+	Responses: openapi.Responses{
+		200: openapi.Negotiated("success", openapi.Ref("Diff")),
+		304: openapi.NotModified(),
+		400: openapi.Error("Invalid domain or time range."),
+		500: openapi.Error("Internal Server Error."),
+		504: openapi.Error("Gateway Timeout. The database query takes too long."),
+	},
+}
+
+// GET /api/diff/:domain?since=7d&until=
+// Returns the FQDNs and records of domain added, removed or changed between since and until.
+func GetApiDiff(c *gin.Context) {
+
+	ctx, cancel := common.Context(c, "diff")
+	defer cancel()
+
+	d := c.Param("domain")
+
+	since, until, err := db.DiffWindow(c.Query("since"), c.Query("until"))
+	if err != nil {
+		c.Error(err)
+		respond.Error(c, http.StatusBadRequest, fault.ErrInvalidTime)
+		return
+	}
+
+	changes, err := db.DomainsDiff(ctx, d, since, until)
+	if err != nil {
+
+		c.Error(err)
+
+		switch {
+		case errors.Is(err, fault.ErrInvalidDomain):
+			respond.Error(c, http.StatusBadRequest, fault.ErrInvalidDomain)
+		case errors.Is(err, fault.ErrTLDOnly):
+			respond.Error(c, http.StatusBadRequest, fault.ErrTLDOnly)
+		case errors.Is(err, fault.ErrGetPartsFailed):
+			respond.Error(c, http.StatusBadRequest, fault.ErrInvalidDomain)
+		case errors.Is(err, fault.ErrInvalidTime):
+			respond.Error(c, http.StatusBadRequest, fault.ErrInvalidTime)
+		case common.IsTimeout(err):
+			respond.Error(c, http.StatusGatewayTimeout, fault.ErrGatewayTimeout)
+		default:
+			respond.Error(c, http.StatusInternalServerError, fault.ErrInternal)
+		}
+		return
+	}
+
+	diff := Diff{Domain: dns.GetDomain(dns.Clean(d)), Since: since, Until: until, Changes: changes}
+
+	respond.Cache(c, 10*time.Minute)
+	respond.Value(c, http.StatusOK, &diff)
+}
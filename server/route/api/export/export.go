@@ -0,0 +1,132 @@
+package export
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/elmasy-com/columbus/db"
+	"github.com/elmasy-com/columbus/export"
+	"github.com/elmasy-com/columbus/fault"
+	"github.com/elmasy-com/columbus/server/common"
+	"github.com/elmasy-com/columbus/server/openapi"
+	"github.com/elmasy-com/columbus/server/respond"
+	"github.com/elmasy-com/elnet/dns"
+	"github.com/gin-gonic/gin"
+)
+
+// GetApiExportDoc is the OpenAPI operation of GetApiExport.
+var GetApiExportDoc = &openapi.Operation{
+	ID:      "GetExport",
+	Tags:    []string{"domain"},
+	Summary: "Export the subdomains with the records.",
+	Description: "Returns every subdomain of `domain` with the records as a downloadable file in CSV or NDJSON (JSON Lines) format.\n" +
+		"\n" +
+		"Every record is a row with the columns `fqdn`, `type`, `value`, `time` and `updated`.\n" +
+		"A subdomain without records is a single row with empty `type` and `value` and `0` time.\n" +
+		"The CSV has a header line.\n" +
+		"\n" +
+		"If `format` is omitted, the format is negotiated from the `Accept` header (`text/csv` or `application/x-ndjson`, CSV by default).\n" +
+		"\n" +
+		"# Note\n" +
+		"- The subdomain part will be trimmed (eg.: `/api/export/www.example.com` will be the same as `/api/export/example.com`).\n",
+	Parameters: []openapi.Parameter{
+		openapi.PathParam("domain", "Domain to export.", openapi.String()),
+		openapi.QueryParam("format", "Format of the file, overrides the `Accept` header.", &openapi.Schema{Type: "string", Enum: []string{"csv", "ndjson"}}),
+		openapi.QueryParam("days", "Same as in `/api/lookup`.", &openapi.Schema{Type: "integer", Default: -1}),
+	},
+	Responses: openapi.Responses{
+		200: openapi.Content("success", map[string]*openapi.Schema{
+			"text/csv":             openapi.String(),
+			"application/x-ndjson": openapi.Ref("ExportRow"),
+		}),
+		400: openapi.Error("Invalid domain, format or days."),
+		404: openapi.Error("Not Found."),
+		500: openapi.Error("Internal Server Error."),
+		504: openapi.Error("Gateway Timeout. The database query takes too long."),
+	},
+}
+
+// GET /api/export/:domain?format=csv&days=-1
+// Returns every FQDN of domain with the records in CSV or NDJSON format.
+// The format is "csv" (default) or "ndjson".
+func GetApiExport(c *gin.Context) {
+
+	ctx, cancel := common.Context(c, "export")
+	defer cancel()
+
+	format, ok := c.GetQuery("format")
+	if !ok {
+		// Without the format parameter, the format is negotiated from the Accept header.
+		if respond.Negotiate(c, respond.MIMECSV, respond.MIMENDJSON) == respond.MIMENDJSON {
+			format = export.FormatNDJSON
+		} else {
+			format = export.FormatCSV
+		}
+	}
+
+	if format != export.FormatCSV && format != export.FormatNDJSON {
+		c.Error(fault.ErrInvalidFormat)
+		respond.Error(c, http.StatusBadRequest, fault.ErrInvalidFormat)
+		return
+	}
+
+	days, err := common.ParseQueryDays(c)
+	if err != nil {
+		c.Error(err)
+		respond.Error(c, http.StatusBadRequest, fault.ErrInvalidDays)
+		return
+	}
+
+	doms, err := db.DomainsDomains(ctx, c.Param("domain"), days)
+	if err != nil {
+
+		c.Error(err)
+
+		switch {
+		case errors.Is(err, fault.ErrInvalidDomain):
+			respond.Error(c, http.StatusBadRequest, fault.ErrInvalidDomain)
+		case errors.Is(err, fault.ErrTLDOnly):
+			respond.Error(c, http.StatusBadRequest, fault.ErrTLDOnly)
+		case errors.Is(err, fault.ErrGetPartsFailed):
+			respond.Error(c, http.StatusBadRequest, fault.ErrInvalidDomain)
+		case errors.Is(err, fault.ErrInvalidDays):
+			respond.Error(c, http.StatusBadRequest, fault.ErrInvalidDays)
+		case common.IsTimeout(err):
+			respond.Error(c, http.StatusGatewayTimeout, fault.ErrGatewayTimeout)
+		default:
+			respond.Error(c, http.StatusInternalServerError, fault.ErrInternal)
+		}
+		return
+	}
+
+	if len(doms) == 0 {
+		c.Error(fault.ErrNotFound)
+		respond.Error(c, http.StatusNotFound, fault.ErrNotFound)
+		return
+	}
+
+	name := dns.GetDomain(dns.Clean(c.Param("domain")))
+
+	respond.Cache(c, 10*time.Minute)
+	c.Header("vary", "Accept")
+	c.Header("content-disposition", fmt.Sprintf("attachment; filename=\"%s%s\"", name, export.Extension(format, export.CompressionNone)))
+	c.Header("content-type", export.ContentType(format))
+	c.Status(http.StatusOK)
+
+	w, _ := export.NewWriter(c.Writer, format)
+
+	for i := range doms {
+		for _, r := range export.Rows(&doms[i]) {
+			if err := w.Write(r); err != nil {
+				c.Error(err)
+				return
+			}
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		c.Error(err)
+	}
+}
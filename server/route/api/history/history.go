@@ -9,16 +9,58 @@ import (
 	"github.com/elmasy-com/columbus/db"
 	"github.com/elmasy-com/columbus/fault"
 	"github.com/elmasy-com/columbus/server/common"
+	"github.com/elmasy-com/columbus/server/openapi"
+	"github.com/elmasy-com/columbus/server/respond"
 	"github.com/gin-gonic/gin"
 )
 
-type History struct {
-	Domain  string
-	Records []db.Record
+// GetApiHistoryDoc is the OpenAPI operation of GetApiHistory.
+var GetApiHistoryDoc = &openapi.Operation{
+	ID:      "GetHistory",
+	Tags:    []string{"domain"},
+	Summary: "DNS record history.",
+	Description: "Returns the DNS history for the given domain and its subdomains.\n" +
+		"\n" +
+		"The `type` codes can be found here: [https://www.iana.org/assignments/dns-parameters/dns-parameters.xhtml](https://www.iana.org/assignments/dns-parameters/dns-parameters.xhtml).\n" +
+		"\n" +
+		"The `time` field is the time in Unix timestamp when the record last seen.\n" +
+		"\n" +
+		"The `firstSeen` and `lastSeen` fields are the time in Unix timestamp when the record started and last seen served.\n" +
+		"The `removed` field is set when a refresh no longer returned the record (eg.: the IP address changed).\n" +
+		"\n" +
+		"The `priority`, `target` and `params` fields contains the structured fields of the record if the type has them (eg.: MX, SRV, HTTPS, SVCB, TLSA, DS).\n" +
+		"\n" +
+		"If `Accept` header prefers `text/plain`, returns a newline delimetered text of the FQDNs.\n" +
+		"`application/x-ndjson` and `text/csv` returns a record per line, same as `/api/export`.\n" +
+		"\n" +
+		"# Note\n" +
+		"- **EXPERIMENTAL FEATURE!**\n" +
+		"- The subdomain part will be trimmed (eg.: `/api/history/totally.invalid.elmasy.com` will be the same as `/api/lookup/elmasy.com`).\n" +
+		"- If `domain` not found, the server saves for later process.\n",
+	Parameters: []openapi.Parameter{
+		openapi.PathParam("domain", "Domain to search.", openapi.String()),
+		openapi.QueryParam("days", "- If `days` greater than 0, returns domains that has a valid DNS record in the last `days` days.\n"+
+			"- If `days` is 0 or -1, returns domains that has a valid DNS record regardless of the age.\n"+
+			"- If `days` is -1, returns every known domain with possible empty records.\n"+
+			"\n"+
+			"If omitted, returns every records (aka the default `days` is -1).\n", openapi.Integer()),
+	},
+	Responses: openapi.Responses{
+		200: openapi.Records("success"),
+		304: openapi.NotModified(),
+		400: openapi.Error("Invalid domain or days"),
+		404: openapi.Error("No records found."),
+		500: openapi.Error("Internal Server Error."),
+		502: openapi.Error("Bad Gateway. Upstream failed."),
+		504: openapi.Error("Gateway Timeout. Upstream response takes too long."),
+	},
 }
 
 func GetApiHistory(c *gin.Context) {
 
+	ctx, cancel := common.Context(c, "history")
+	defer cancel()
+
 	var err error
 
 	// Parse domain param
@@ -28,31 +70,27 @@ func GetApiHistory(c *gin.Context) {
 	days, err := common.ParseQueryDays(c)
 	if err != nil {
 		c.Error(fault.ErrInvalidDays)
-		c.JSON(http.StatusBadRequest, fault.ErrInvalidDays)
+		respond.Error(c, http.StatusBadRequest, fault.ErrInvalidDays)
 		return
 	}
 
-	doms, err := db.DomainsDomains(d, days)
+	doms, cached, err := db.DomainsDomainsCached(ctx, d, days)
 	if err != nil {
 
 		c.Error(err)
 
-		respCode := 0
-
 		switch {
 		case errors.Is(err, fault.ErrInvalidDomain):
-			respCode = http.StatusBadRequest
+			respond.Error(c, http.StatusBadRequest, fault.ErrInvalidDomain)
 		case errors.Is(err, fault.ErrInvalidDays):
-			respCode = http.StatusBadRequest
+			respond.Error(c, http.StatusBadRequest, fault.ErrInvalidDays)
 		case errors.Is(err, fault.ErrTLDOnly):
-			respCode = http.StatusBadRequest
+			respond.Error(c, http.StatusBadRequest, fault.ErrTLDOnly)
+		case common.IsTimeout(err):
+			respond.Error(c, http.StatusGatewayTimeout, fault.ErrGatewayTimeout)
 		default:
-			respCode = http.StatusInternalServerError
-			err = fmt.Errorf("internal server error")
+			respond.Error(c, http.StatusInternalServerError, fault.ErrInternal)
 		}
-
-		c.JSON(respCode, gin.H{"error": err.Error()})
-
 		return
 	}
 
@@ -60,37 +98,44 @@ func GetApiHistory(c *gin.Context) {
 
 		c.Error(fault.ErrNotFound)
 
-		_, err = db.NotFoundInsert(d)
+		_, err = db.NotFoundInsert(ctx, d)
 		if err != nil {
 			c.Error(fmt.Errorf("failed to insert notFound: %w", err))
 		}
 
-		c.JSON(http.StatusNotFound, fault.ErrNotFound)
+		respond.Error(c, http.StatusNotFound, fault.ErrNotFound)
 		return
 	}
 
-	_, err = db.TopListInsert(d)
+	_, err = db.TopListInsert(ctx, d)
 	if err != nil {
 		c.Error(fmt.Errorf("failed to insert topList: %w", err))
 	}
 
-	hs := make([]History, 0, len(doms))
+	fs := make([]respond.FQDN, 0, len(doms))
+	dropped := 0
 
 	for i := range doms {
 
-		// Send domains to db.UpdaterChan channel if not full to update the DNS records.
-		if len(db.UpdaterChan) < cap(db.UpdaterChan) {
-			db.UpdaterChan <- db.UpdateableDomain{Domain: doms[i].String(), Type: db.UpdateExistingDomain}
+		// Send domains to the updater to update the DNS records.
+		// A cached result is already sent.
+		if !cached && !db.UpdaterPush(ctx, doms[i].String(), db.UpdateExistingDomain, db.PriorityLookup) {
+			dropped++
 		}
 
-		hs = append(hs, History{Domain: doms[i].String(), Records: doms[i].Records})
+		fs = append(fs, respond.FQDN{Domain: doms[i].String(), Records: doms[i].Records, Updated: doms[i].Updated})
+	}
+
+	if dropped > 0 {
+		c.Error(fmt.Errorf("failed to queue %d domains: updater queue is full", dropped))
 	}
 
 	// Cache for 10 minutes, domains are not updated this often,
 	// but caching saves a lot of processing power.
-	c.Header("cache-control", "public, max-age=600, must-revalidate, stale-if-error=604800")
-	c.Header("expires", time.Now().UTC().Add(600*time.Second).Format(time.RFC1123))
-
-	c.JSON(http.StatusOK, hs)
-
+	respond.Cache(c, 10*time.Minute)
+	// With days the result can shrink while the latest time is the same, so only the ETag is used
+	if days <= 0 {
+		respond.LastModified(c, time.Unix(db.DomainsModified(doms), 0))
+	}
+	respond.FQDNs(c, http.StatusOK, fs)
 }
@@ -5,16 +5,47 @@ import (
 	"net/http"
 
 	"github.com/elmasy-com/columbus/db"
+	"github.com/elmasy-com/columbus/fault"
+	"github.com/elmasy-com/columbus/server/common"
 	"github.com/elmasy-com/columbus/server/config"
+	"github.com/elmasy-com/columbus/server/openapi"
+	"github.com/elmasy-com/columbus/server/respond"
 	"github.com/elmasy-com/elnet/dns"
 	"github.com/elmasy-com/elnet/validator"
 	"github.com/gin-gonic/gin"
 )
 
+// PutApiInsertDoc is the OpenAPI operation of PutApiInsert.
+var PutApiInsertDoc = &openapi.Operation{
+	ID:      "PutAPIInsert",
+	Tags:    []string{"domain"},
+	Summary: "Insert domain into the database.",
+	Description: "This endpoint technically suggest a domain to the server.\n" +
+		"\n" +
+		"It is required to have at least one valid DNS record for the domain to insert (eg.: `A` or `AAAA`).\n" +
+		"The domain is stored in a durable queue before the response, so a submitted domain is not lost if the server restarts.\n" +
+		"The records updater processes the durable queue with the highest priority, so returns fast, but the client will not get informed about the result.\n" +
+		"Failed updates (eg.: `SERVFAIL`) are retried with an exponential backoff, and moved to a dead-letter collection after too many attempts.\n" +
+		"\n" +
+		"This endpoint uses blacklist and rate limiter to prevent garbage and resource exhaustion\n" +
+		"(eg.: sending invalid domain results a block for some time).\n",
+	Parameters: []openapi.Parameter{
+		openapi.PathParam("domain", "Domain to insert.", openapi.String()),
+	},
+	Responses: openapi.Responses{
+		200: openapi.Empty("OK"),
+		400: openapi.Error("Invalid domain."),
+		403: openapi.Error("Client IP blocked."),
+		500: openapi.Error("Internal Server Error. Failed to store the domain in the durable queue."),
+		502: openapi.Error("Bad Gateway. Upstream failed."),
+		504: openapi.Error("Gateway Timeout. Upstream response takes too long."),
+	},
+}
+
 func PutApiInsert(c *gin.Context) {
 
 	if config.Blocklist.IsBlocked(c.ClientIP()) {
-		c.Status(http.StatusForbidden)
+		respond.Error(c, http.StatusForbidden, fault.ErrBlocked)
 		return
 	}
 
@@ -23,11 +54,24 @@ func PutApiInsert(c *gin.Context) {
 	if !validator.Domain(d) {
 		config.Blocklist.Block(c.ClientIP())
 		c.Error(fmt.Errorf("invalid domain: %s", d))
-		c.Status(http.StatusBadRequest)
+		respond.Error(c, http.StatusBadRequest, fault.ErrInvalidDomain)
 		return
 	}
 
-	db.UpdaterChan <- db.UpdateableDomain{Domain: d, Type: db.InsertNewDomain}
+	ctx, cancel := common.Context(c, "insert")
+	defer cancel()
+
+	// Store in the durable queue before the response, the domain must not be lost if the server restarts
+	err := db.QueueAdd(ctx, d, db.InsertNewDomain, db.PriorityInsert)
+	if err != nil {
+		c.Error(fmt.Errorf("failed to queue %s: %w", d, err))
+		if common.IsTimeout(err) {
+			respond.Error(c, http.StatusGatewayTimeout, fault.ErrGatewayTimeout)
+		} else {
+			respond.Error(c, http.StatusInternalServerError, fault.ErrInternal)
+		}
+		return
+	}
 
 	c.Status(http.StatusOK)
 }
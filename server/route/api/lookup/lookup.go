@@ -4,18 +4,59 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
-	"strings"
 	"time"
 
 	"github.com/elmasy-com/columbus/db"
 	"github.com/elmasy-com/columbus/fault"
 	"github.com/elmasy-com/columbus/server/common"
+	"github.com/elmasy-com/columbus/server/openapi"
+	"github.com/elmasy-com/columbus/server/respond"
 	"github.com/elmasy-com/elnet/dns"
 	"github.com/gin-gonic/gin"
 )
 
+// GetApiLookupDoc is the OpenAPI operation of GetApiLookup.
+var GetApiLookupDoc = &openapi.Operation{
+	ID:      "GetLookup",
+	Tags:    []string{"domain"},
+	Summary: "Lookup subdomains for domain.",
+	Description: "Returns an array of subdomains.\n" +
+		"\n" +
+		"The response contains the subdomains only, the domain not included (eg.: `[\"one\", \"two\", ...]`).\n" +
+		"\n" +
+		"If a FQDN is requested than the domain name will be taken out and used in the lookup (eg.: `/api/lookup/columbus.elmasy.com` will be the same as `/api/lookup/elmasy.com`)\n" +
+		"\n" +
+		"If `Accept` header prefers `text/plain`, this endpoint returns a newline delimetered text of the list (eg.: `one\\ntwo\\nthree`).\n" +
+		"`application/x-ndjson` and `text/csv` returns a subdomain per line.\n" +
+		"\n" +
+		"# Note\n" +
+		"- The subdomain part will be trimmed (eg.: `/api/lookup/totally.invalid.elmasy.com` will be the same as `/api/lookup/elmasy.com`).\n" +
+		"- If `domain` not found, the server saves for later process.\n" +
+		"- Only the subdomains are sent in the response to save CPU and RAM on the server side and save bandwith on both client- and server-side.\n",
+	Parameters: []openapi.Parameter{
+		openapi.PathParam("domain", "Domain to get the subdomains.", openapi.String()),
+		openapi.QueryParam("days", "- If `days` greater than 0, returns subdomains that has a valid DNS record in the last `days` days.\n"+
+			"- If `days` is 0, returns subdomains that has a valid DNS record regardless of the age.\n"+
+			"- If `days` is -1, returns every known subdomains.\n"+
+			"\n"+
+			"If omitted, returns every subdomain including historical and invalid ones (aka the default `days` is -1).\n", openapi.Integer()),
+	},
+	Responses: openapi.Responses{
+		200: openapi.List("success"),
+		304: openapi.NotModified(),
+		400: openapi.Error("Invalid domain or days"),
+		404: openapi.Error("Domain not found."),
+		500: openapi.Error("Internal Server Error."),
+		502: openapi.Error("Bad Gateway. Upstream failed."),
+		504: openapi.Error("Gateway Timeout. Upstream response takes too long."),
+	},
+}
+
 func GetApiLookup(c *gin.Context) {
 
+	ctx, cancel := common.Context(c, "lookup")
+	defer cancel()
+
 	var err error
 
 	// Parse domain param
@@ -25,87 +66,70 @@ func GetApiLookup(c *gin.Context) {
 	days, err := common.ParseQueryDays(c)
 	if err != nil {
 		c.Error(err)
-		if c.GetHeader("Accept") == "text/plain" {
-			c.String(http.StatusBadRequest, fault.ErrInvalidDays.Err)
-		} else {
-			c.JSON(http.StatusBadRequest, fault.ErrInvalidDays)
-		}
+		respond.Error(c, http.StatusBadRequest, fault.ErrInvalidDays)
 		return
 	}
 
-	subs, err := db.DomainsLookup(d, days)
+	doms, cached, err := db.DomainsDomainsCached(ctx, d, days)
 	if err != nil {
 
 		c.Error(err)
 
-		respCode := 0
-
 		switch {
 		case errors.Is(err, fault.ErrInvalidDomain):
-			respCode = http.StatusBadRequest
+			respond.Error(c, http.StatusBadRequest, fault.ErrInvalidDomain)
 		case errors.Is(err, fault.ErrInvalidDays):
-			respCode = http.StatusBadRequest
+			respond.Error(c, http.StatusBadRequest, fault.ErrInvalidDays)
 		case errors.Is(err, fault.ErrTLDOnly):
-			respCode = http.StatusBadRequest
+			respond.Error(c, http.StatusBadRequest, fault.ErrTLDOnly)
+		case common.IsTimeout(err):
+			respond.Error(c, http.StatusGatewayTimeout, fault.ErrGatewayTimeout)
 		default:
-			respCode = http.StatusInternalServerError
-			err = fmt.Errorf("internal server error")
-		}
-
-		if c.GetHeader("Accept") == "text/plain" {
-			c.String(respCode, err.Error())
-		} else {
-			c.JSON(respCode, gin.H{"error": err.Error()})
+			respond.Error(c, http.StatusInternalServerError, fault.ErrInternal)
 		}
 		return
 	}
 
-	if len(subs) == 0 {
+	if len(doms) == 0 {
 
 		c.Error(fault.ErrNotFound)
 
-		_, err = db.NotFoundInsert(d)
+		_, err = db.NotFoundInsert(ctx, d)
 		if err != nil {
 			c.Error(fmt.Errorf("failed to insert notFound: %w", err))
 		}
 
-		if c.GetHeader("Accept") == "text/plain" {
-			c.String(http.StatusNotFound, fault.ErrNotFound.Err)
-		} else {
-			c.JSON(http.StatusNotFound, fault.ErrNotFound)
-		}
+		respond.Error(c, http.StatusNotFound, fault.ErrNotFound)
 		return
 	}
 
-	for i := range subs {
+	subs := make([]string, 0, len(doms))
+	dropped := 0
 
-		var dom string
+	for i := range doms {
 
-		if subs[i] == "" {
-			dom = d
-		} else {
-			dom = fmt.Sprintf("%s.%s", subs[i], d)
-		}
+		subs = append(subs, doms[i].Sub)
 
-		// Send domains to db.UpdaterChan channel if not full to update the DNS records.
-		if len(db.UpdaterChan) < cap(db.UpdaterChan) {
-			db.UpdaterChan <- db.UpdateableDomain{Domain: dom, Type: db.UpdateExistingDomain}
+		// Send domains to the updater to update the DNS records.
+		// A cached result is already sent.
+		if !cached && !db.UpdaterPush(ctx, doms[i].String(), db.UpdateExistingDomain, db.PriorityLookup) {
+			dropped++
 		}
 	}
 
-	_, err = db.TopListInsert(d)
+	if dropped > 0 {
+		c.Error(fmt.Errorf("failed to queue %d domains: updater queue is full", dropped))
+	}
+
+	_, err = db.TopListInsert(ctx, d)
 	if err != nil {
 		c.Error(fmt.Errorf("failed to insert topList: %w", err))
 	}
 
-	// Cache for 10 minutes.
-	c.Header("cache-control", "public, max-age=600, must-revalidate, stale-if-error=604800")
-	c.Header("expires", time.Now().UTC().Add(600*time.Second).Format(time.RFC1123))
-	c.Header("vary", "Accept")
-
-	if c.GetHeader("Accept") == "text/plain" {
-		c.String(http.StatusOK, strings.Join(subs, "\n"))
-	} else {
-		c.JSON(http.StatusOK, subs)
+	respond.Cache(c, 10*time.Minute)
+	// With days the result can shrink while the latest time is the same, so only the ETag is used
+	if days <= 0 {
+		respond.LastModified(c, time.Unix(db.DomainsModified(doms), 0))
 	}
+	respond.List(c, http.StatusOK, subs)
 }
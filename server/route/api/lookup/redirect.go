@@ -4,13 +4,14 @@ import (
 	"fmt"
 	"net/http"
 
+	"github.com/elmasy-com/columbus/server/respond"
 	"github.com/gin-gonic/gin"
 )
 
 func RedirectLookup(c *gin.Context) {
 
-	accept := c.Request.Header.Get("Accept")
-	if accept == "" || accept == "*/*" || accept == "application/json" || accept == "text/plain" {
+	// Browsers prefer HTML, everything else is an API client
+	if respond.Negotiate(c, respond.MIMEJSON, respond.MIMEText, respond.MIMENDJSON, respond.MIMECSV, respond.MIMEHTML) != respond.MIMEHTML {
 		c.Header("location", fmt.Sprintf("/api/lookup/%s", c.Param("domain")))
 		c.Status(http.StatusMovedPermanently)
 	} else {
@@ -0,0 +1,255 @@
+package reverse
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/elmasy-com/columbus/db"
+	"github.com/elmasy-com/columbus/fault"
+	"github.com/elmasy-com/columbus/server/common"
+	"github.com/elmasy-com/columbus/server/openapi"
+	"github.com/elmasy-com/columbus/server/respond"
+	"github.com/gin-gonic/gin"
+	mdns "github.com/miekg/dns"
+)
+
+// findFunc is the db query of a reverse lookup.
+type findFunc func(ctx context.Context, days int, skip int64, limit int64) ([]db.ReverseSchema, error)
+
+// The query options of every reverse lookup.
+var (
+	daysParam = openapi.QueryParam("days", "- If `days` greater than 0, returns records found in the last `days` days.\n"+
+		"- If `days` is 0 or -1, returns records regardless of the age.\n"+
+		"\n"+
+		"If omitted, returns every records (aka the default `days` is -1).\n", openapi.Integer())
+	skipParam  = openapi.QueryParam("skip", "Number of domains to skip, 0-10000 (default 0).", openapi.Integer())
+	limitParam = openapi.QueryParam("limit", "Maximum number of domains to return, 1-1000 (default 100).", openapi.Integer())
+)
+
+// GetApiReverseDoc is the OpenAPI operation of GetApiReverse.
+var GetApiReverseDoc = &openapi.Operation{
+	ID:      "GetReverse",
+	Tags:    []string{"domain"},
+	Summary: "Reverse lookup by IP address.",
+	Description: "Returns the domains with an A or AAAA record pointing to `ip` and the matching records.\n" +
+		"The removed records (not returned by the last refresh) are skipped.\n" +
+		"\n" +
+		"The result is paginated with `skip` and `limit`, the domains are in the order of the matching records (not sorted by name).\n" +
+		"\n" +
+		"The format is selected by the `Accept` header, same as in `/api/history`.\n",
+	Parameters: []openapi.Parameter{
+		openapi.PathParam("ip", "IPv4 or IPv6 address (the network address if `bits` is set).", openapi.String()),
+		daysParam, skipParam, limitParam,
+	},
+	Responses: openapi.Responses{
+		200: openapi.Records("success"),
+		304: openapi.NotModified(),
+		400: openapi.Error("Invalid IP, CIDR, days, skip or limit"),
+		404: openapi.Error("No records found."),
+		500: openapi.Error("Internal Server Error."),
+		504: openapi.Error("Gateway Timeout. The database query takes too long."),
+	},
+}
+
+// GetApiReverseCIDRDoc is the OpenAPI operation of GetApiReverse with a CIDR.
+var GetApiReverseCIDRDoc = &openapi.Operation{
+	ID:      "GetReverseCIDR",
+	Tags:    []string{"domain"},
+	Summary: "Reverse lookup by CIDR.",
+	Description: "Returns the domains with an A or AAAA record pointing to an address in `ip`/`bits` (eg.: `/api/reverse/203.0.113.0/24`) and the matching records.\n" +
+		"The removed records (not returned by the last refresh) are skipped.\n" +
+		"\n" +
+		"The result is paginated with `skip` and `limit`, the domains are in the order of the matching records (not sorted by name).\n",
+	Parameters: []openapi.Parameter{
+		openapi.PathParam("ip", "IPv4 or IPv6 address (the network address if `bits` is set).", openapi.String()),
+		openapi.PathParam("bits", "Prefix length of the CIDR (at least 8 for IPv4 and 32 for IPv6).", openapi.Integer()),
+		daysParam, skipParam, limitParam,
+	},
+	Responses: openapi.Responses{
+		200: openapi.Records("success"),
+		304: openapi.NotModified(),
+		400: openapi.Error("Invalid IP, CIDR, days, skip or limit"),
+		404: openapi.Error("No records found."),
+		500: openapi.Error("Internal Server Error."),
+		504: openapi.Error("Gateway Timeout. The database query takes too long."),
+	},
+}
+
+// GET /api/reverse/:ip
+// GET /api/reverse/:ip/:bits
+// Returns the FQDNs with an A or AAAA record pointing to ip or to an address in ip/bits (eg.: /api/reverse/203.0.113.0/24).
+func GetApiReverse(c *gin.Context) {
+
+	s := c.Param("ip")
+	if bits := c.Param("bits"); bits != "" {
+		s = s + "/" + bits
+	}
+
+	p, err := db.ParsePrefix(s)
+	if err != nil {
+		c.Error(err)
+		respond.Error(c, http.StatusBadRequest, fault.ErrInvalidIP)
+		return
+	}
+
+	reverse(c, func(ctx context.Context, days int, skip int64, limit int64) ([]db.ReverseSchema, error) {
+		return db.DomainsReverse(ctx, p, days, skip, limit)
+	})
+}
+
+// GetApiReverseCNAMEDoc is the OpenAPI operation of GetApiReverseCNAME.
+var GetApiReverseCNAMEDoc = &openapi.Operation{
+	ID:      "GetReverseCNAME",
+	Tags:    []string{"domain"},
+	Summary: "Reverse lookup by CNAME target.",
+	Description: "Returns the domains with a CNAME record pointing to `target` or to a subdomain of `target` and the matching records.\n" +
+		"The removed records (not returned by the last refresh) are skipped.\n" +
+		"\n" +
+		"A leading wildcard label is ignored (eg.: `*.herokudns.com` is the same as `herokudns.com`).\n" +
+		"\n" +
+		"The result is paginated with `skip` and `limit`, the domains are in the order of the matching records (not sorted by name).\n",
+	Parameters: []openapi.Parameter{
+		openapi.PathParam("target", "Target domain (eg.: `herokudns.com`).", openapi.String()),
+		daysParam, skipParam, limitParam,
+	},
+	Responses: openapi.Responses{
+		200: openapi.Records("success"),
+		304: openapi.NotModified(),
+		400: openapi.Error("Invalid target, days, skip or limit"),
+		404: openapi.Error("No records found."),
+		500: openapi.Error("Internal Server Error."),
+		504: openapi.Error("Gateway Timeout. The database query takes too long."),
+	},
+}
+
+// GET /api/reverse/cname/:target
+// Returns the FQDNs with a CNAME record pointing to target or to a subdomain of target (eg.: /api/reverse/cname/herokudns.com).
+func GetApiReverseCNAME(c *gin.Context) {
+	reverseTarget(c, mdns.TypeCNAME)
+}
+
+// GetApiReverseMXDoc is the OpenAPI operation of GetApiReverseMX.
+var GetApiReverseMXDoc = &openapi.Operation{
+	ID:      "GetReverseMX",
+	Tags:    []string{"domain"},
+	Summary: "Reverse lookup by MX target.",
+	Description: "Returns the domains with an MX record pointing to `target` or to a subdomain of `target` and the matching records.\n" +
+		"The removed records (not returned by the last refresh) are skipped.\n" +
+		"\n" +
+		"A leading wildcard label is ignored (eg.: `*.herokudns.com` is the same as `herokudns.com`).\n" +
+		"\n" +
+		"The result is paginated with `skip` and `limit`, the domains are in the order of the matching records (not sorted by name).\n",
+	Parameters: []openapi.Parameter{
+		openapi.PathParam("target", "Target domain (eg.: `google.com`).", openapi.String()),
+		daysParam, skipParam, limitParam,
+	},
+	Responses: openapi.Responses{
+		200: openapi.Records("success"),
+		304: openapi.NotModified(),
+		400: openapi.Error("Invalid target, days, skip or limit"),
+		404: openapi.Error("No records found."),
+		500: openapi.Error("Internal Server Error."),
+		504: openapi.Error("Gateway Timeout. The database query takes too long."),
+	},
+}
+
+// GET /api/reverse/mx/:target
+// Returns the FQDNs with an MX record pointing to target or to a subdomain of target.
+func GetApiReverseMX(c *gin.Context) {
+	reverseTarget(c, mdns.TypeMX)
+}
+
+// GetApiReverseNSDoc is the OpenAPI operation of GetApiReverseNS.
+var GetApiReverseNSDoc = &openapi.Operation{
+	ID:      "GetReverseNS",
+	Tags:    []string{"domain"},
+	Summary: "Reverse lookup by NS target.",
+	Description: "Returns the domains with an NS record pointing to `target` or to a subdomain of `target` and the matching records.\n" +
+		"The removed records (not returned by the last refresh) are skipped.\n" +
+		"\n" +
+		"A leading wildcard label is ignored (eg.: `*.herokudns.com` is the same as `herokudns.com`).\n" +
+		"\n" +
+		"The result is paginated with `skip` and `limit`, the domains are in the order of the matching records (not sorted by name).\n",
+	Parameters: []openapi.Parameter{
+		openapi.PathParam("target", "Target domain (eg.: `cloudflare.com`).", openapi.String()),
+		daysParam, skipParam, limitParam,
+	},
+	Responses: openapi.Responses{
+		200: openapi.Records("success"),
+		304: openapi.NotModified(),
+		400: openapi.Error("Invalid target, days, skip or limit"),
+		404: openapi.Error("No records found."),
+		500: openapi.Error("Internal Server Error."),
+		504: openapi.Error("Gateway Timeout. The database query takes too long."),
+	},
+}
+
+// GET /api/reverse/ns/:target
+// Returns the FQDNs with an NS record pointing to target or to a subdomain of target.
+func GetApiReverseNS(c *gin.Context) {
+	reverseTarget(c, mdns.TypeNS)
+}
+
+func reverseTarget(c *gin.Context, t uint16) {
+
+	target := c.Param("target")
+
+	reverse(c, func(ctx context.Context, days int, skip int64, limit int64) ([]db.ReverseSchema, error) {
+		return db.DomainsReverseTarget(ctx, t, target, days, skip, limit)
+	})
+}
+
+// reverse parses the days, skip and limit query parameters, calls find and writes the result.
+func reverse(c *gin.Context, find findFunc) {
+
+	ctx, cancel := common.Context(c, "reverse")
+	defer cancel()
+
+	days, err := common.ParseQueryDays(c)
+	if err != nil {
+		c.Error(err)
+		respond.Error(c, http.StatusBadRequest, fault.ErrInvalidDays)
+		return
+	}
+
+	skip, limit, err := common.ParseQueryPage(c, 100, 1000)
+	if err != nil {
+		c.Error(err)
+		respond.Error(c, http.StatusBadRequest, err)
+		return
+	}
+
+	rs, err := find(ctx, days, skip, limit)
+	if err != nil {
+
+		c.Error(err)
+
+		switch {
+		case errors.Is(err, fault.ErrInvalidDays):
+			respond.Error(c, http.StatusBadRequest, fault.ErrInvalidDays)
+		case errors.Is(err, fault.ErrInvalidDomain):
+			respond.Error(c, http.StatusBadRequest, fault.ErrInvalidDomain)
+		case common.IsTimeout(err):
+			respond.Error(c, http.StatusGatewayTimeout, fault.ErrGatewayTimeout)
+		default:
+			respond.Error(c, http.StatusInternalServerError, fault.ErrInternal)
+		}
+		return
+	}
+
+	if len(rs) == 0 {
+		c.Error(fault.ErrNotFound)
+		respond.Error(c, http.StatusNotFound, fault.ErrNotFound)
+		return
+	}
+
+	fs := make([]respond.FQDN, 0, len(rs))
+	for i := range rs {
+		fs = append(fs, respond.FQDN{Domain: rs[i].Domain, Records: rs[i].Records})
+	}
+
+	respond.Cache(c, 10*time.Minute)
+	respond.FQDNs(c, http.StatusOK, fs)
+}
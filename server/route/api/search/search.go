@@ -0,0 +1,93 @@
+package search
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/elmasy-com/columbus/db"
+	"github.com/elmasy-com/columbus/fault"
+	"github.com/elmasy-com/columbus/server/common"
+	"github.com/elmasy-com/columbus/server/openapi"
+	"github.com/elmasy-com/columbus/server/respond"
+	"github.com/gin-gonic/gin"
+)
+
+// GetApiSearchDoc is the OpenAPI operation of GetApiSearch.
+var GetApiSearchDoc = &openapi.Operation{
+	ID:      "GetSearch",
+	Tags:    []string{"domain"},
+	Summary: "Search with a query language.",
+	Description: "Returns the hostnames matching the query `q`.\n" +
+		"\n" +
+		"The query is a list of terms joined by `AND` (implicit), `OR` and `NOT`, terms can be grouped with parenthesis:\n" +
+		"- Hostname pattern (eg.: `*.dev.*.example.com`): a `*` label matches exactly one label, `*` inside a label matches any characters except dot.\n" +
+		"- `domain:`, `tld:`, `sub:`: matches the part of the hostname, `*` can be used as a wildcard (eg.: `domain:exa*`).\n" +
+		"- `type:`: matches the record type (eg.: `type:A`, `type:28`).\n" +
+		"- `value:`: matches the record value, `*` can be used as a wildcard, values with space must be quoted (eg.: `value:\"v=spf1 -all\"`).\n" +
+		"- `updated>`, `updated<`: the time of the last update.\n" +
+		"- `seen>`, `seen<`: the time when the record last seen.\n" +
+		"\n" +
+		"The time can be a date (eg.: `2023-01-02`), a RFC3339 time, a Unix timestamp or relative in days/hours (eg.: `30d`, `12h`).\n" +
+		"The record conditions (`type`, `value`, `seen`) in the same `AND` must match the same record.\n" +
+		"\n" +
+		"# Note\n" +
+		"- Every branch of the query must restrict the domain (eg.: `example.com`, `x.com`, `domain:hp`, or `domain:exa*` with at least 3 characters before the wildcard), otherwise the query is rejected as too expensive.\n" +
+		"- The query can be at most 256 characters with at most 8 terms.\n" +
+		"\n" +
+		"Example: `example.com OR (domain:example tld:net type:CNAME value:*.herokudns.com)`\n",
+	Parameters: []openapi.Parameter{
+		{Name: "q", In: "query", Description: "The query.", Required: true, Schema: openapi.String()},
+		openapi.QueryParam("skip", "Number of hostnames to skip, 0-10000 (default 0).", openapi.Integer()),
+		openapi.QueryParam("limit", "Maximum number of hostnames to return, 1-1000 (default 100).", openapi.Integer()),
+	},
+	Responses: openapi.Responses{
+		200: openapi.List("success"),
+		304: openapi.NotModified(),
+		400: openapi.Error("Invalid or too expensive query, invalid skip or limit."),
+		404: openapi.Error("No hostname found."),
+		500: openapi.Error("Internal Server Error."),
+		504: openapi.Error("Gateway Timeout. The database query takes too long."),
+	},
+}
+
+// GET /api/search?q=<query>&skip=0&limit=100
+// Returns the hostnames matching the query (see db.ParseSearch()).
+func GetApiSearch(c *gin.Context) {
+
+	ctx, cancel := common.Context(c, "search")
+	defer cancel()
+
+	skip, limit, err := common.ParseQueryPage(c, 100, 1000)
+	if err != nil {
+		c.Error(err)
+		respond.Error(c, http.StatusBadRequest, err)
+		return
+	}
+
+	ds, err := db.DomainsSearch(ctx, c.Query("q"), skip, limit)
+	if err != nil {
+
+		c.Error(err)
+
+		switch {
+		case errors.Is(err, fault.ErrInvalidQuery), errors.Is(err, fault.ErrQueryTooExpensive):
+			// The message has the details of the error (eg.: "invalid query: unexpected ')'")
+			respond.Error(c, http.StatusBadRequest, err)
+		case common.IsTimeout(err):
+			respond.Error(c, http.StatusGatewayTimeout, fault.ErrGatewayTimeout)
+		default:
+			respond.Error(c, http.StatusInternalServerError, fault.ErrInternal)
+		}
+		return
+	}
+
+	if len(ds) == 0 {
+		c.Error(fault.ErrNotFound)
+		respond.Error(c, http.StatusNotFound, fault.ErrNotFound)
+		return
+	}
+
+	respond.Cache(c, 10*time.Minute)
+	respond.List(c, http.StatusOK, ds)
+}
@@ -2,71 +2,83 @@ package starts
 
 import (
 	"errors"
-	"fmt"
 	"net/http"
-	"strings"
 	"time"
 
 	"github.com/elmasy-com/columbus/db"
 	"github.com/elmasy-com/columbus/fault"
+	"github.com/elmasy-com/columbus/server/common"
+	"github.com/elmasy-com/columbus/server/openapi"
+	"github.com/elmasy-com/columbus/server/respond"
 	"github.com/gin-gonic/gin"
 )
 
+// GetApiStartsDoc is the OpenAPI operation of GetApiStarts.
+var GetApiStartsDoc = &openapi.Operation{
+	ID:      "GetStarts",
+	Tags:    []string{"domain"},
+	Summary: "Find domains that start with the given string.",
+	Description: "Return an array of Second Level Domains thats start with `domain`.\n" +
+		"\n" +
+		"The `domain` parameter must be a Second Level Domain (eg.: `example`)\n" +
+		"\n" +
+		"Example: `/api/starts/reddit` returns `[\"reddit\", \"redditmedia\", \"redditstatistic\", ...]`.\n" +
+		"\n" +
+		"If `Accept` header prefers `text/plain`, this endpoint returns a newline delimetered text of the list.\n" +
+		"`application/x-ndjson` and `text/csv` returns a domain per line.\n" +
+		"\n" +
+		"# Note\n" +
+		"- The `domain`'s length mist be greater than 4 character.\n" +
+		"- Only the SLDs are sent in the response to save CPU and RAM on the server side and save bandwith on both client- and server-side.\n",
+	Parameters: []openapi.Parameter{
+		{Name: "domain", In: "path", Description: "Domain to get the TLDs.", Required: true, Schema: openapi.String(), Example: "reddit"},
+	},
+	Responses: openapi.Responses{
+		200: openapi.List("success"),
+		304: openapi.NotModified(),
+		400: openapi.Error("Invalid domain"),
+		404: openapi.Error("Domain not found."),
+		500: openapi.Error("Internal Server Error."),
+		502: openapi.Error("Bad Gateway. Upstream failed."),
+		504: openapi.Error("Gateway Timeout. Upstream response takes too long."),
+	},
+}
+
 func GetApiStarts(c *gin.Context) {
 
+	ctx, cancel := common.Context(c, "starts")
+	defer cancel()
+
 	dom := c.Param("domain")
 
 	if len(dom) < 5 {
-
-		if c.GetHeader("Accept") == "text/plain" {
-			c.String(http.StatusBadRequest, fault.ErrInvalidDomain.Error())
-		} else {
-			c.JSON(http.StatusBadRequest, fault.ErrInvalidDomain)
-		}
+		c.Error(fault.ErrInvalidDomain)
+		respond.Error(c, http.StatusBadRequest, fault.ErrInvalidDomain)
 		return
 	}
 
-	domains, err := db.DomainsStarts(dom)
+	domains, err := db.DomainsStarts(ctx, dom)
 	if err != nil {
 
 		c.Error(err)
-		code := 0
-
-		if errors.Is(err, fault.ErrInvalidDomain) {
-			code = http.StatusBadRequest
-		} else {
-			code = http.StatusInternalServerError
-			err = fmt.Errorf("internal server error")
-		}
 
-		if c.GetHeader("Accept") == "text/plain" {
-			c.String(code, err.Error())
-		} else {
-			c.JSON(code, err)
+		switch {
+		case errors.Is(err, fault.ErrInvalidDomain):
+			respond.Error(c, http.StatusBadRequest, fault.ErrInvalidDomain)
+		case common.IsTimeout(err):
+			respond.Error(c, http.StatusGatewayTimeout, fault.ErrGatewayTimeout)
+		default:
+			respond.Error(c, http.StatusInternalServerError, fault.ErrInternal)
 		}
 		return
 	}
 
 	if len(domains) == 0 {
-
 		c.Error(fault.ErrNotFound)
-
-		if c.GetHeader("Accept") == "text/plain" {
-			c.String(http.StatusNotFound, fault.ErrNotFound.Err)
-		} else {
-			c.JSON(http.StatusNotFound, fault.ErrNotFound)
-		}
+		respond.Error(c, http.StatusNotFound, fault.ErrNotFound)
 		return
 	}
 
-	// Cache for 10 minutes.
-	c.Header("cache-control", "public, max-age=600, must-revalidate, stale-if-error=604800")
-	c.Header("expires", time.Now().UTC().Add(600*time.Second).Format(time.RFC1123))
-	c.Header("vary", "Accept")
-
-	if c.GetHeader("Accept") == "text/plain" {
-		c.String(http.StatusOK, strings.Join(domains, "\n"))
-	} else {
-		c.JSON(http.StatusOK, domains)
-	}
+	respond.Cache(c, 10*time.Minute)
+	respond.List(c, http.StatusOK, domains)
 }
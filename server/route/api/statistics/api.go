@@ -5,22 +5,56 @@ import (
 	"time"
 
 	"github.com/elmasy-com/columbus/db"
+	"github.com/elmasy-com/columbus/fault"
+	"github.com/elmasy-com/columbus/server/common"
+	"github.com/elmasy-com/columbus/server/openapi"
+	"github.com/elmasy-com/columbus/server/respond"
 	"github.com/gin-gonic/gin"
 )
 
+// GetApiStatDoc is the OpenAPI operation of GetApiStat.
+var GetApiStatDoc = &openapi.Operation{
+	ID:      "GetStatistics",
+	Tags:    []string{"info"},
+	Summary: "Basic domain statistic",
+	Description: "Basic domain statistic that holds the total number of domains and\n" +
+		"the total number of valid domains.\n" +
+		"\n" +
+		"Fields:\n" +
+		"- The `date` field is the last update date in Unix time format.\n" +
+		"- The `total` field is the number of total entries in the database.\n" +
+		"- The `updated` field is the number of total entries updated (including entries that updated, but no valid DNS record found).\n" +
+		"- The `valid` field is the number of total domains that has at least one known DNS record.\n" +
+		"- The `ctlogs` field is an array that holds the stats of the crawled Certificate Transparency Logs.\n" +
+		"  - The `name` is the name of the log.\n" +
+		"  - The `index` is the current index of the crawler.\n" +
+		"  - The `size` is the total number of entries in the log.\n",
+	Responses: openapi.Responses{
+		200: openapi.JSON("Success.", openapi.Ref("Stat")),
+		304: openapi.NotModified(),
+		500: openapi.Error("Internal Server Error"),
+		504: openapi.Error("Gateway Timeout. The database query takes too long."),
+	},
+}
+
 func GetApiStat(c *gin.Context) {
 
-	s, err := db.StatisticsGetNewest()
+	ctx, cancel := common.Context(c, "stat")
+	defer cancel()
+
+	s, err := db.StatisticsGetNewest(ctx)
 	if err != nil {
 		c.Error(err)
 
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		if common.IsTimeout(err) {
+			respond.Error(c, http.StatusGatewayTimeout, fault.ErrGatewayTimeout)
+		} else {
+			respond.Error(c, http.StatusInternalServerError, fault.ErrInternal)
+		}
 		return
 	}
 
-	// Cache for 10 minutes.
-	c.Header("cache-control", "public, max-age=600, stale-while-revalidate=600, stale-if-error=604800")
-	c.Header("expires", time.Now().UTC().Add(600*time.Second).Format(time.RFC1123))
-
-	c.JSON(http.StatusOK, s)
+	respond.Cache(c, 10*time.Minute)
+	respond.LastModified(c, time.Unix(s.Date, 0))
+	respond.JSON(c, http.StatusOK, s)
 }
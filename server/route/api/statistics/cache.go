@@ -0,0 +1,39 @@
+package statistics
+
+import (
+	"net/http"
+
+	"github.com/elmasy-com/columbus/db"
+	"github.com/elmasy-com/columbus/server/openapi"
+	"github.com/elmasy-com/columbus/server/respond"
+	"github.com/gin-gonic/gin"
+)
+
+// GetApiStatCacheDoc is the OpenAPI operation of GetApiStatCache.
+var GetApiStatCacheDoc = &openapi.Operation{
+	ID:      "GetStatisticsCache",
+	Tags:    []string{"info"},
+	Summary: "Lookup cache metrics",
+	Description: "Returns the metrics of the server-side cache of the lookup, history and report results.\n" +
+		"\n" +
+		"Fields:\n" +
+		"- `enabled`: whether the cache is enabled.\n" +
+		"- `entries`: the number of cached results.\n" +
+		"- `hits`: the number of results served from the cache.\n" +
+		"- `misses`: the number of results queried from the database.\n" +
+		"- `shared`: the number of results shared with a concurrent identical query.\n" +
+		"- `invalidations`: the number of times the results of an apex domain was invalidated by a change.\n" +
+		"\n" +
+		"The counters are reset when the server restarts.\n",
+	Responses: openapi.Responses{
+		200: openapi.JSON("Success.", openapi.Ref("CacheStat")),
+	},
+}
+
+// GetApiStatCache returns the metrics of the lookup cache.
+func GetApiStatCache(c *gin.Context) {
+
+	c.Header("cache-control", "no-cache")
+
+	respond.JSON(c, http.StatusOK, db.CacheStatistics())
+}
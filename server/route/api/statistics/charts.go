@@ -2,6 +2,7 @@ package statistics
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"time"
 
@@ -12,9 +13,9 @@ import (
 	"github.com/go-echarts/go-echarts/v2/types"
 )
 
-func CreateHistoryChart() ([]byte, error) {
+func CreateHistoryChart(ctx context.Context) ([]byte, error) {
 
-	datas, err := db.StatisticsGets()
+	datas, err := db.StatisticsGets(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get statistics: %w", err)
 	}
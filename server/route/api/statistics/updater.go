@@ -0,0 +1,34 @@
+package statistics
+
+import (
+	"net/http"
+
+	"github.com/elmasy-com/columbus/db"
+	"github.com/elmasy-com/columbus/server/openapi"
+	"github.com/elmasy-com/columbus/server/respond"
+	"github.com/gin-gonic/gin"
+)
+
+// GetApiStatUpdaterDoc is the OpenAPI operation of GetApiStatUpdater.
+var GetApiStatUpdaterDoc = &openapi.Operation{
+	ID:      "GetStatisticsUpdater",
+	Tags:    []string{"info"},
+	Summary: "Records updater queue depths",
+	Description: "Returns the number of domains waiting for a DNS records update in every priority class.\n" +
+		"\n" +
+		"Classes (served in this order):\n" +
+		"- `insert`: domains submitted with `/api/insert`.\n" +
+		"- `lookup`: domains returned by a lookup.\n" +
+		"- `refresh`: domains refreshed in the background.\n",
+	Responses: openapi.Responses{
+		200: openapi.JSON("Success.", &openapi.Schema{Type: "object", AdditionalProperties: openapi.Integer()}),
+	},
+}
+
+// GetApiStatUpdater returns the number of pending domains in every priority class of the records updater.
+func GetApiStatUpdater(c *gin.Context) {
+
+	c.Header("cache-control", "no-cache")
+
+	respond.JSON(c, http.StatusOK, db.UpdaterDepths())
+}
@@ -0,0 +1,168 @@
+package stream
+
+import (
+	"context"
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/elmasy-com/columbus/db"
+)
+
+const (
+	// maxClients is the maximum number of concurrent stream clients.
+	maxClients = 1000
+
+	// clientBuffer is the number of FQDNs buffered for a client.
+	// If the buffer is full, the new FQDNs are dropped for the client.
+	clientBuffer = 256
+)
+
+// Filter is used to select the FQDNs sent to a client.
+// Empty fields matches everything.
+type Filter struct {
+	Apex  string         // Apex domain (eg.: "example.com")
+	TLD   string         // TLD (eg.: "com")
+	Regex *regexp.Regexp // Matched against the FQDN
+}
+
+// Match returns whether e matches the filter.
+func (f *Filter) Match(e *db.Event) bool {
+
+	if f.Apex != "" && e.Apex != f.Apex {
+		return false
+	}
+
+	if f.TLD != "" && !strings.HasSuffix(e.Apex, "."+f.TLD) {
+		return false
+	}
+
+	if f.Regex != nil && !f.Regex.MatchString(e.Domain) {
+		return false
+	}
+
+	return true
+}
+
+type client struct {
+	filter  Filter
+	events  chan db.Event
+	dropped int // Number of dropped events, protected by hub.m
+}
+
+// hub distributes the new FQDNs to the clients.
+// The new FQDNs are read from the "events" collection, so the FQDNs inserted by the scanner and the DNS proxy are sent too.
+type hub struct {
+	m       sync.Mutex
+	clients map[*client]struct{}
+	start   sync.Once
+}
+
+var defaultHub = &hub{clients: make(map[*client]struct{})}
+
+// subscribe adds a new client with filter f.
+// Returns nil if the number of clients reached maxClients.
+func (h *hub) subscribe(f Filter) *client {
+
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	if len(h.clients) >= maxClients {
+		return nil
+	}
+
+	c := &client{filter: f, events: make(chan db.Event, clientBuffer)}
+
+	h.clients[c] = struct{}{}
+
+	return c
+}
+
+// unsubscribe removes client c.
+func (h *hub) unsubscribe(c *client) {
+
+	h.m.Lock()
+	delete(h.clients, c)
+	h.m.Unlock()
+}
+
+// takeDropped returns and resets the number of dropped events of c.
+func (h *hub) takeDropped(c *client) int {
+
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	n := c.dropped
+	c.dropped = 0
+
+	return n
+}
+
+// broadcast sends e to every matching client.
+// The send never blocks, if the buffer of the client is full, the event is dropped and counted.
+func (h *hub) broadcast(e db.Event) {
+
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	for c := range h.clients {
+
+		if !c.filter.Match(&e) {
+			continue
+		}
+
+		select {
+		case c.events <- e:
+		default:
+			c.dropped++
+		}
+	}
+}
+
+// run reads the new FQDN events in order and broadcasts them.
+// The hub runs while the server runs, it is not stopped.
+//
+// This function blocks.
+func (h *hub) run() {
+
+	ctx := context.Background()
+
+	last, err := db.EventsLastSeq(ctx)
+	for err != nil {
+		slog.Error("stream: failed to get the last event", "error", err)
+		time.Sleep(10 * time.Second)
+		last, err = db.EventsLastSeq(ctx)
+	}
+
+	r := &db.EventReader{Last: last}
+
+	for {
+
+		es, err := r.Next(ctx, 1000)
+		if err != nil {
+			slog.Error("stream: failed to get events", "error", err)
+			// Wait before the next try
+			time.Sleep(10 * time.Second)
+			continue
+		}
+
+		if len(es) == 0 {
+			time.Sleep(time.Second)
+			continue
+		}
+
+		for i := range es {
+			if es[i].Type == db.EventNewFQDN {
+				h.broadcast(es[i])
+			}
+		}
+	}
+}
+
+// ensureRunning starts run() in the background on the first call.
+func (h *hub) ensureRunning() {
+
+	h.start.Do(func() { go h.run() })
+}
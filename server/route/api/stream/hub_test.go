@@ -0,0 +1,68 @@
+package stream
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/elmasy-com/columbus/db"
+)
+
+func TestFilterMatch(t *testing.T) {
+
+	e := &db.Event{Type: db.EventNewFQDN, Domain: "api.example.com", Apex: "example.com"}
+
+	cases := []struct {
+		f    Filter
+		want bool
+	}{
+		{Filter{}, true},
+		{Filter{Apex: "example.com"}, true},
+		{Filter{Apex: "example.org"}, false},
+		{Filter{TLD: "com"}, true},
+		{Filter{TLD: "org"}, false},
+		{Filter{Regex: regexp.MustCompile(`^api\.`)}, true},
+		{Filter{Regex: regexp.MustCompile(`^www\.`)}, false},
+		{Filter{Apex: "example.com", Regex: regexp.MustCompile(`^www\.`)}, false},
+	}
+
+	for i := range cases {
+		if got := cases[i].f.Match(e); got != cases[i].want {
+			t.Errorf("case %d: want %v, got %v", i, cases[i].want, got)
+		}
+	}
+}
+
+func TestHubBroadcastDrop(t *testing.T) {
+
+	h := &hub{clients: make(map[*client]struct{})}
+
+	c := h.subscribe(Filter{Apex: "example.com"})
+	other := h.subscribe(Filter{Apex: "example.org"})
+
+	for i := 0; i < clientBuffer+10; i++ {
+		h.broadcast(db.Event{Type: db.EventNewFQDN, Domain: "www.example.com", Apex: "example.com"})
+	}
+
+	if len(c.events) != clientBuffer {
+		t.Fatalf("want %d buffered events, got %d", clientBuffer, len(c.events))
+	}
+
+	if n := h.takeDropped(c); n != 10 {
+		t.Fatalf("want 10 dropped events, got %d", n)
+	}
+
+	if n := h.takeDropped(c); n != 0 {
+		t.Fatalf("dropped counter is not reset: %d", n)
+	}
+
+	if len(other.events) != 0 {
+		t.Fatalf("event sent to a not matching client")
+	}
+
+	h.unsubscribe(c)
+	h.unsubscribe(other)
+
+	if len(h.clients) != 0 {
+		t.Fatalf("clients not removed")
+	}
+}
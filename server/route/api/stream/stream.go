@@ -0,0 +1,246 @@
+package stream
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/elmasy-com/columbus/db"
+	"github.com/elmasy-com/columbus/fault"
+	"github.com/elmasy-com/columbus/server/common"
+	"github.com/elmasy-com/columbus/server/openapi"
+	"github.com/elmasy-com/columbus/server/respond"
+	"github.com/elmasy-com/elnet/dns"
+	"github.com/gin-gonic/gin"
+)
+
+// heartbeat is the interval of the heartbeat comments, used to keep the connection open through the proxies.
+var heartbeat = 15 * time.Second
+
+// maxReplay is the maximum number of events read from the database when a client resumes with Last-Event-ID.
+var maxReplay int64 = 10000
+
+type fqdnData struct {
+	Domain string `json:"domain"`
+	Time   int64  `json:"time"`
+}
+
+// parseFilter parses the "domain", "tld" and "regex" query parameters.
+func parseFilter(c *gin.Context) (Filter, error) {
+
+	var f Filter
+
+	if d := c.Query("domain"); d != "" {
+
+		if !dns.IsValid(d) {
+			return f, fault.ErrInvalidDomain
+		}
+
+		p := dns.GetParts(dns.Clean(d))
+		if p == nil || p.Domain == "" || p.TLD == "" {
+			return f, fault.ErrInvalidDomain
+		}
+
+		f.Apex = p.Domain + "." + p.TLD
+	}
+
+	if t := c.Query("tld"); t != "" {
+		f.TLD = dns.Clean(t)
+	}
+
+	if r := c.Query("regex"); r != "" {
+
+		if len(r) > 256 {
+			return f, fault.ErrRegexTooLong
+		}
+
+		re, err := regexp.Compile(r)
+		if err != nil {
+			return f, fault.ErrInvalidRegex
+		}
+
+		f.Regex = re
+	}
+
+	return f, nil
+}
+
+// GetApiStreamDoc is the OpenAPI operation of GetApiStream.
+var GetApiStreamDoc = &openapi.Operation{
+	ID:      "GetStream",
+	Tags:    []string{"domain"},
+	Summary: "Live stream of the new FQDNs.",
+	Description: "Streams the newly inserted FQDNs (from the scanner, the DNS server and the records updater) with [Server-Sent Events](https://html.spec.whatwg.org/multipage/server-sent-events.html).\n" +
+		"\n" +
+		"Events:\n" +
+		"- `fqdn`: a new FQDN, the data is `{\"domain\": \"www.example.com\", \"time\": 1700000000}`.\n" +
+		"- `dropped`: the client is too slow to read the stream, the data is the number of dropped FQDNs.\n" +
+		"\n" +
+		"The `id` of a `fqdn` event is the sequence number of the event.\n" +
+		"A client that reconnects with the `Last-Event-ID` header (sent by `EventSource` automatically) resumes after that event,\n" +
+		"the missed FQDNs are sent first (at most 10000 events are read, the events are kept for 30 days).\n" +
+		"\n" +
+		"A heartbeat comment (`: heartbeat`) is sent in every 15 seconds.\n" +
+		"\n" +
+		"Example: `curl -N 'https://columbus.elmasy.com/api/stream?tld=com'`\n",
+	Parameters: []openapi.Parameter{
+		openapi.QueryParam("domain", "Stream only the FQDNs of this apex domain (eg.:`example.com`).", openapi.String()),
+		openapi.QueryParam("tld", "Stream only the FQDNs with this TLD (eg.:`com`).", openapi.String()),
+		openapi.QueryParam("regex", "Stream only the FQDNs matching this regular expression (RE2 syntax, max 256 characters).", openapi.String()),
+		openapi.HeaderParam("Last-Event-ID", "Resume after the event with this `id`.", openapi.Integer()),
+	},
+	Responses: openapi.Responses{
+		200: openapi.Content("Event stream.", map[string]*openapi.Schema{
+			"text/event-stream": openapi.String(),
+		}),
+		400: openapi.Error("Invalid domain or regex."),
+		503: openapi.Error("Too many stream clients."),
+	},
+}
+
+// GET /api/stream?domain=example.com&tld=com&regex=^api\.
+// Streams the newly inserted FQDNs with Server-Sent Events.
+//
+// Events:
+//   - "fqdn": a new FQDN, the data is {"domain": "www.example.com", "time": 1700000000}
+//   - "dropped": the client is too slow, the data is the number of dropped FQDNs
+//
+// A heartbeat comment is sent periodically.
+// If the Last-Event-ID header is set, the missed FQDNs are sent first.
+func GetApiStream(c *gin.Context) {
+
+	f, err := parseFilter(c)
+	if err != nil {
+		c.Error(err)
+		respond.Error(c, http.StatusBadRequest, err)
+		return
+	}
+
+	defaultHub.ensureRunning()
+
+	cl := defaultHub.subscribe(f)
+	if cl == nil {
+		c.Error(fmt.Errorf("too many stream clients: %w", fault.ErrUnavailable))
+		respond.Error(c, http.StatusServiceUnavailable, fault.ErrUnavailable)
+		return
+	}
+	defer defaultHub.unsubscribe(cl)
+
+	c.Header("content-type", "text/event-stream")
+	c.Header("cache-control", "no-cache")
+	c.Header("connection", "keep-alive")
+	// Disable the buffering of nginx
+	c.Header("x-accel-buffering", "no")
+
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	// The client is subscribed before the replay, so the events inserted during the replay are buffered.
+	// The replayed events are skipped from the buffer.
+	after, _ := strconv.ParseInt(c.GetHeader("last-event-id"), 10, 64)
+
+	sent, err := replay(c, f, after)
+	if err != nil {
+		c.Error(fmt.Errorf("failed to replay events: %w", err))
+		return
+	}
+
+	c.Writer.Flush()
+
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+
+	for {
+
+		select {
+
+		case <-c.Request.Context().Done():
+			return
+
+		case <-ticker.C:
+
+			if _, err := fmt.Fprintf(c.Writer, ": heartbeat\n\n"); err != nil {
+				return
+			}
+
+		case e := <-cl.events:
+
+			if n := defaultHub.takeDropped(cl); n > 0 {
+				if _, err := fmt.Fprintf(c.Writer, "event: dropped\ndata: %d\n\n", n); err != nil {
+					return
+				}
+			}
+
+			if _, ok := sent[e.Seq]; ok || e.Seq <= after {
+				continue
+			}
+
+			if err := writeFQDN(c, e); err != nil {
+				return
+			}
+		}
+
+		c.Writer.Flush()
+	}
+}
+
+// writeFQDN writes e as a "fqdn" event.
+func writeFQDN(c *gin.Context, e db.Event) error {
+
+	data, err := json.Marshal(fqdnData{Domain: e.Domain, Time: e.Time})
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(c.Writer, "id: %d\nevent: fqdn\ndata: %s\n\n", e.Seq, data)
+
+	return err
+}
+
+// replay writes the new FQDN events after Seq after matching f, up to maxReplay events are read.
+// Returns the Seq of the written events.
+// If after is not positive, nothing is written.
+func replay(c *gin.Context, f Filter, after int64) (map[int64]struct{}, error) {
+
+	sent := make(map[int64]struct{})
+
+	if after <= 0 {
+		return sent, nil
+	}
+
+	ctx, cancel := common.Context(c, "stream")
+	defer cancel()
+
+	for n := int64(0); n < maxReplay; {
+
+		es, err := db.EventsAfter(ctx, after, 1000)
+		if err != nil {
+			return sent, err
+		}
+
+		if len(es) == 0 {
+			break
+		}
+
+		for i := range es {
+
+			if es[i].Type != db.EventNewFQDN || !f.Match(&es[i]) {
+				continue
+			}
+
+			if err := writeFQDN(c, es[i]); err != nil {
+				return sent, err
+			}
+
+			sent[es[i].Seq] = struct{}{}
+		}
+
+		after = es[len(es)-1].Seq
+		n += int64(len(es))
+	}
+
+	return sent, nil
+}
@@ -2,64 +2,78 @@ package tld
 
 import (
 	"net/http"
-	"strings"
 	"time"
 
 	"github.com/elmasy-com/columbus/db"
 	"github.com/elmasy-com/columbus/fault"
+	"github.com/elmasy-com/columbus/server/common"
+	"github.com/elmasy-com/columbus/server/openapi"
+	"github.com/elmasy-com/columbus/server/respond"
 	"github.com/elmasy-com/elnet/dns"
 	"github.com/gin-gonic/gin"
 )
 
+// GetApiTLDDoc is the OpenAPI operation of GetApiTLD.
+var GetApiTLDDoc = &openapi.Operation{
+	ID:      "GetTLD",
+	Tags:    []string{"domain"},
+	Summary: "Find TLDs for the given domain.",
+	Description: "Returns a list of all known Top Level Domains for the given domain.\n" +
+		"\n" +
+		"The domain parameter must be a Second Level Domain (eg.: example).\n" +
+		"\n" +
+		"Example: `/api/tld/example` returns `[\"com\", \"org\", \"net\"]`.\n" +
+		"\n" +
+		"If `Accept` header prefers `text/plain`, this endpoint returns a newline delimetered text of the list (eg.: `com\\norg\\nnet`).\n" +
+		"`application/x-ndjson` and `text/csv` returns a TLD per line.\n",
+	Parameters: []openapi.Parameter{
+		{Name: "domain", In: "path", Description: "Domain to get the TLDs.", Required: true, Schema: openapi.String(), Example: "example"},
+	},
+	Responses: openapi.Responses{
+		200: openapi.List("success"),
+		304: openapi.NotModified(),
+		400: openapi.Error("Invalid domain"),
+		404: openapi.Error("Domain not found."),
+		500: openapi.Error("Internal Server Error."),
+		502: openapi.Error("Bad Gateway. Upstream failed."),
+		504: openapi.Error("Gateway Timeout. Upstream response takes too long."),
+	},
+}
+
 func GetApiTLD(c *gin.Context) {
 
+	ctx, cancel := common.Context(c, "tld")
+	defer cancel()
+
 	dom := c.Param("domain")
 
 	if !dns.IsValidSLD(dom) {
-
 		c.Error(fault.ErrInvalidDomain)
-
-		if c.GetHeader("Accept") == "text/plain" {
-			c.String(http.StatusBadRequest, fault.ErrInvalidDomain.Error())
-		} else {
-			c.JSON(http.StatusBadRequest, fault.ErrInvalidDomain)
-		}
+		respond.Error(c, http.StatusBadRequest, fault.ErrInvalidDomain)
 		return
 	}
 
 	dom = dns.Clean(dom)
 
-	tlds, err := db.DomainsTLD(dom)
+	tlds, err := db.DomainsTLD(ctx, dom)
 	if err != nil {
 
 		c.Error(err)
 
-		if c.GetHeader("Accept") == "text/plain" {
-			c.String(http.StatusInternalServerError, "internal server error")
+		if common.IsTimeout(err) {
+			respond.Error(c, http.StatusGatewayTimeout, fault.ErrGatewayTimeout)
 		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			respond.Error(c, http.StatusInternalServerError, fault.ErrInternal)
 		}
 		return
 	}
 
 	if len(tlds) == 0 {
 		c.Error(fault.ErrNotFound)
-		if c.GetHeader("Accept") == "text/plain" {
-			c.String(http.StatusNotFound, fault.ErrNotFound.Err)
-		} else {
-			c.JSON(http.StatusNotFound, fault.ErrNotFound)
-		}
+		respond.Error(c, http.StatusNotFound, fault.ErrNotFound)
 		return
 	}
 
-	// Cache for 10 minutes.
-	c.Header("cache-control", "public, max-age=600, must-revalidate, stale-if-error=604800")
-	c.Header("expires", time.Now().UTC().Add(600*time.Second).Format(time.RFC1123))
-	c.Header("vary", "Accept")
-
-	if c.GetHeader("Accept") == "text/plain" {
-		c.String(http.StatusOK, strings.Join(tlds, "\n"))
-	} else {
-		c.JSON(http.StatusOK, tlds)
-	}
+	respond.Cache(c, 10*time.Minute)
+	respond.List(c, http.StatusOK, tlds)
 }
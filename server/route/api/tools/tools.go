@@ -1,153 +1,145 @@
 package tools
 
 import (
-	"fmt"
 	"net/http"
 	"time"
 
 	"github.com/elmasy-com/columbus/fault"
+	"github.com/elmasy-com/columbus/server/openapi"
+	"github.com/elmasy-com/columbus/server/respond"
 	"github.com/elmasy-com/elnet/dns"
 	"github.com/gin-gonic/gin"
 )
 
+// ToolsTLDGetDoc is the OpenAPI operation of ToolsTLDGet.
+var ToolsTLDGetDoc = &openapi.Operation{
+	ID:      "GetToolsTLD",
+	Tags:    []string{"tools"},
+	Summary: "Get the TLD from a FQDN.",
+	Description: "Get the TLD part (eg.: `com`) from a FQDN (eg.: `columbus.elmasy.com`).\n" +
+		"\n" +
+		"**IMPORTANT**: Only ICANN managed TLDs are returned, the private ones are only returned in the Top Level Domain.\n" +
+		"(eg.: `columbus.elmasy.co.uk` -> `co.uk` or `columbus.elmasy.local` -> `local`)\n" +
+		"\n" +
+		"If `Accept` header prefers `text/plain`, returns the result as a string.\n",
+	Parameters: []openapi.Parameter{
+		openapi.PathParam("fqdn", "FQDN to get the TLD.", openapi.String()),
+	},
+	Responses: openapi.Responses{
+		200: openapi.Negotiated("Success", openapi.Ref("Result")),
+		304: openapi.NotModified(),
+		400: openapi.Error("Bad Request. See the error message."),
+		404: openapi.Error("Domain not found"),
+		502: openapi.Error("Bad Gateway. Upstream failed."),
+		504: openapi.Error("Gateway Timeout. Upstream response takes too long."),
+	},
+}
+
 // GET /tools/tld/{fqdn}
 // Returns the TLD part of a FQDN.
 func ToolsTLDGet(c *gin.Context) {
+	part(c, dns.GetTLD)
+}
 
-	fqdn := c.Param("fqdn")
-
-	if !dns.IsValid(fqdn) || fqdn == "." {
-		c.Error(fault.ErrInvalidDomain)
-		if c.GetHeader("Accept") == "text/plain" {
-			c.String(http.StatusBadRequest, fault.ErrInvalidDomain.Err)
-		} else {
-			c.JSON(http.StatusBadRequest, fault.ErrInvalidDomain)
-		}
-		return
-	}
-
-	fqdn = dns.Clean(fqdn)
-
-	d := dns.GetTLD(fqdn)
-	if d == "" {
-		c.Error(fault.ErrNotFound)
-		if c.GetHeader("Accept") == "text/plain" {
-			c.String(http.StatusNotFound, fault.ErrInvalidDomain.Err)
-		} else {
-			c.JSON(http.StatusNotFound, fault.ErrInvalidDomain)
-		}
-		return
-	}
-
-	// Cache for 60 minutes.
-	c.Header("cache-control", "public, max-age=600")
-	c.Header("expires", time.Now().UTC().Add(600*time.Second).Format(time.RFC1123))
-	c.Header("vary", "Accept")
-
-	if c.GetHeader("Accept") == "text/plain" {
-		c.String(http.StatusOK, d)
-	} else {
-		c.JSON(http.StatusOK, gin.H{"result": d})
-	}
+// ToolsDomainGetDoc is the OpenAPI operation of ToolsDomainGet.
+var ToolsDomainGetDoc = &openapi.Operation{
+	ID:      "GetToolsDomain",
+	Tags:    []string{"tools"},
+	Summary: "Get the domain from a FQDN.",
+	Description: "Get the domain part (eg.: `elmasy.com`) from a FQDN (eg.: `columbus.elmasy.com`).\n" +
+		"\n" +
+		"If `Accept` header prefers `text/plain`, returns the result as a string.\n",
+	Parameters: []openapi.Parameter{
+		openapi.PathParam("fqdn", "FQDN to get the domain.", openapi.String()),
+	},
+	Responses: openapi.Responses{
+		200: openapi.Negotiated("Success", openapi.Ref("Result")),
+		304: openapi.NotModified(),
+		400: openapi.Error("Bad Request. See the error message."),
+		404: openapi.Error("Domain not found"),
+		502: openapi.Error("Bad Gateway. Upstream failed."),
+		504: openapi.Error("Gateway Timeout. Upstream response takes too long."),
+	},
 }
 
 // GET /tools/domain/{fqdn}
 // Returns the domain part of a FQDN.
 func ToolsDomainGet(c *gin.Context) {
+	part(c, dns.GetDomain)
+}
 
-	fqdn := c.Param("fqdn")
+// ToolsSubdomainGetDoc is the OpenAPI operation of ToolsSubdomainGet.
+var ToolsSubdomainGetDoc = &openapi.Operation{
+	ID:      "GetToolsSubdomain",
+	Tags:    []string{"tools"},
+	Summary: "Get the subdomain from a FQDN.",
+	Description: "Get the subdomain part (eg.: `columbus`) from a FQDN (eg.: `columbus.elmasy.com`).\n" +
+		"\n" +
+		"If `Accept` header prefers `text/plain`, returns the result as a string.\n",
+	Parameters: []openapi.Parameter{
+		openapi.PathParam("fqdn", "FQDN to get the subdomain.", openapi.String()),
+	},
+	Responses: openapi.Responses{
+		200: openapi.Negotiated("Success", openapi.Ref("Result")),
+		304: openapi.NotModified(),
+		400: openapi.Error("Bad Request. See the error message."),
+		404: openapi.Error("Subdomain not found"),
+		502: openapi.Error("Bad Gateway. Upstream failed."),
+		504: openapi.Error("Gateway Timeout. Upstream response takes too long."),
+	},
+}
 
-	if !dns.IsValid(fqdn) || fqdn == "." {
-		c.Error(fault.ErrInvalidDomain)
-		if c.GetHeader("Accept") == "text/plain" {
-			c.String(http.StatusBadRequest, fault.ErrInvalidDomain.Err)
-		} else {
-			c.JSON(http.StatusBadRequest, fault.ErrInvalidDomain)
-		}
-		return
-	}
+// GET /tools/subdomain/{fqdn}
+// Returns the subdomain part of a FQDN.
+func ToolsSubdomainGet(c *gin.Context) {
+	part(c, dns.GetSub)
+}
 
-	fqdn = dns.Clean(fqdn)
+// ToolsIsValidGetDoc is the OpenAPI operation of ToolsIsValidGet.
+var ToolsIsValidGetDoc = &openapi.Operation{
+	ID:          "GetToolsIsValid",
+	Tags:        []string{"tools"},
+	Summary:     "Returns whether FQDN is a valid domain.",
+	Description: "Returns whether FQDN is a valid domain.",
+	Parameters: []openapi.Parameter{
+		openapi.PathParam("fqdn", "FQDN to check.", openapi.String()),
+	},
+	Responses: openapi.Responses{
+		200: openapi.Negotiated("Success", openapi.Ref("ResultBool")),
+		304: openapi.NotModified(),
+		502: openapi.Error("Bad Gateway. Upstream failed."),
+		504: openapi.Error("Gateway Timeout. Upstream response takes too long."),
+	},
+}
 
-	d := dns.GetDomain(fqdn)
-	if d == "" {
-		c.Error(fault.ErrNotFound)
-		if c.GetHeader("Accept") == "text/plain" {
-			c.String(http.StatusNotFound, fault.ErrInvalidDomain.Err)
-		} else {
-			c.JSON(http.StatusNotFound, fault.ErrInvalidDomain)
-		}
-		return
-	}
+// GET /tools/isvalid/{fqdn}
+// Returns wether fqdn is valid.
+func ToolsIsValidGet(c *gin.Context) {
 
-	// Cache for 60 minutes.
-	c.Header("cache-control", "public, max-age=600")
-	c.Header("expires", time.Now().UTC().Add(600*time.Second).Format(time.RFC1123))
-	c.Header("vary", "Accept")
+	fqdn := dns.Clean(c.Param("fqdn"))
 
-	if c.GetHeader("Accept") == "text/plain" {
-		c.String(http.StatusOK, d)
-	} else {
-		c.JSON(http.StatusOK, gin.H{"result": d})
-	}
+	respond.Cache(c, 10*time.Minute)
+	respond.Result(c, http.StatusOK, dns.IsValid(fqdn))
 }
 
-// GET /tools/subdomain/{fqdn}
-// Returns the subdomain part of a FQDN.
-func ToolsSubdomainGet(c *gin.Context) {
+// part writes the part of the fqdn parameter returned by get.
+func part(c *gin.Context, get func(string) string) {
 
 	fqdn := c.Param("fqdn")
 
 	if !dns.IsValid(fqdn) || fqdn == "." {
 		c.Error(fault.ErrInvalidDomain)
-		if c.GetHeader("Accept") == "text/plain" {
-			c.String(http.StatusBadRequest, fault.ErrInvalidDomain.Err)
-		} else {
-			c.JSON(http.StatusBadRequest, fault.ErrInvalidDomain)
-		}
+		respond.Error(c, http.StatusBadRequest, fault.ErrInvalidDomain)
 		return
 	}
 
-	fqdn = dns.Clean(fqdn)
-
-	d := dns.GetSub(fqdn)
-	if d == "" {
+	p := get(dns.Clean(fqdn))
+	if p == "" {
 		c.Error(fault.ErrNotFound)
-		if c.GetHeader("Accept") == "text/plain" {
-			c.String(http.StatusNotFound, fault.ErrInvalidDomain.Err)
-		} else {
-			c.JSON(http.StatusNotFound, fault.ErrInvalidDomain)
-		}
+		respond.Error(c, http.StatusNotFound, fault.ErrNotFound)
 		return
 	}
 
-	// Cache for 60 minutes.
-	c.Header("cache-control", "public, max-age=600")
-	c.Header("expires", time.Now().UTC().Add(600*time.Second).Format(time.RFC1123))
-	c.Header("vary", "Accept")
-
-	if c.GetHeader("Accept") == "text/plain" {
-		c.String(http.StatusOK, d)
-	} else {
-		c.JSON(http.StatusOK, gin.H{"result": d})
-	}
-}
-
-// GET /tools/isvalid/{fqdn}
-// Returns wether fqdn is valid.
-func ToolsIsValidGet(c *gin.Context) {
-
-	fqdn := c.Param("fqdn")
-	fqdn = dns.Clean(fqdn)
-
-	// Cache for 60 minutes.
-	c.Header("cache-control", "public, max-age=600")
-	c.Header("expires", time.Now().UTC().Add(600*time.Second).Format(time.RFC1123))
-	c.Header("vary", "Accept")
-
-	if c.GetHeader("Accept") == "text/plain" {
-		c.String(http.StatusOK, fmt.Sprintf("%v", dns.IsValid(fqdn)))
-	} else {
-		c.JSON(http.StatusOK, gin.H{"result": dns.IsValid(fqdn)})
-	}
+	respond.Cache(c, 10*time.Minute)
+	respond.Result(c, http.StatusOK, p)
 }
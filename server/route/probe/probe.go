@@ -0,0 +1,62 @@
+package probe
+
+import (
+	"github.com/elmasy-com/columbus/health"
+	"github.com/elmasy-com/columbus/server/openapi"
+	"github.com/gin-gonic/gin"
+)
+
+// Checker is the health checker of the server, replaced in main with the build informations and the readiness checks.
+var Checker = health.New("columbus-server", "", "")
+
+// GetHealthzDoc is the OpenAPI operation of GetHealthz.
+var GetHealthzDoc = &openapi.Operation{
+	ID:          "GetHealthz",
+	Tags:        []string{"info"},
+	Summary:     "Liveness probe",
+	Description: "Returns `{\"status\": \"ok\"}` while the server process is alive.\n",
+	Responses: openapi.Responses{
+		200: openapi.JSON("Alive.", openapi.Ref("Health")),
+	},
+}
+
+// GET /healthz
+func GetHealthz(c *gin.Context) {
+	Checker.Healthz(c.Writer, c.Request)
+}
+
+// GetReadyzDoc is the OpenAPI operation of GetReadyz.
+var GetReadyzDoc = &openapi.Operation{
+	ID:      "GetReadyz",
+	Tags:    []string{"info"},
+	Summary: "Readiness probe",
+	Description: "Runs the readiness checks of the server and returns the result of every check (`ok` or the error message).\n" +
+		"\n" +
+		"Checks:\n" +
+		"- `mongo`: the database is reachable.\n" +
+		"- `updater`: the records updater is running.\n",
+	Responses: openapi.Responses{
+		200: openapi.JSON("Ready.", openapi.Ref("Ready")),
+		503: openapi.JSON("Not ready, at least one check failed.", openapi.Ref("Ready")),
+	},
+}
+
+// GET /readyz
+func GetReadyz(c *gin.Context) {
+	Checker.Readyz(c.Writer, c.Request)
+}
+
+// GetVersionDoc is the OpenAPI operation of GetVersion.
+var GetVersionDoc = &openapi.Operation{
+	ID:      "GetVersion",
+	Tags:    []string{"info"},
+	Summary: "Build informations",
+	Responses: openapi.Responses{
+		200: openapi.JSON("Success.", openapi.Ref("Version")),
+	},
+}
+
+// GET /version
+func GetVersion(c *gin.Context) {
+	Checker.Version(c.Writer, c.Request)
+}
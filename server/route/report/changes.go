@@ -0,0 +1,82 @@
+package report
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/elmasy-com/columbus/db"
+	"github.com/elmasy-com/columbus/fault"
+	"github.com/elmasy-com/columbus/frontend"
+	"github.com/elmasy-com/columbus/server/common"
+	"github.com/elmasy-com/elnet/dns"
+	"github.com/elmasy-com/elnet/validator"
+	"github.com/gin-gonic/gin"
+)
+
+// GET /report/:domain/changes?since=7d&until=
+// Renders the FQDNs and records of domain added, removed or changed between since and until.
+func GetReportChanges(c *gin.Context) {
+
+	d := c.Param("domain")
+
+	// Redirect client to the clean base domain
+	if validator.Domain(d) && (d != dns.Clean(d) || dns.HasSub(d)) {
+		c.Redirect(http.StatusMovedPermanently, fmt.Sprintf("/report/%s/changes", dns.GetDomain(dns.Clean(d))))
+		return
+	}
+
+	since, until, err := db.DiffWindow(c.Query("since"), c.Query("until"))
+	if err != nil {
+		c.Error(err)
+		frontend.Get400HTML(c, fault.ErrInvalidTime)
+		return
+	}
+
+	ctx, cancel := common.Context(c, "report")
+	defer cancel()
+
+	diffs, err := db.DomainsDiff(ctx, d, since, until)
+	if err != nil {
+
+		c.Error(fmt.Errorf("failed to diff: %w", err))
+
+		switch {
+		case errors.Is(err, fault.ErrInvalidDomain), errors.Is(err, fault.ErrGetPartsFailed):
+			frontend.Get400HTML(c, fault.ErrInvalidDomain)
+		case errors.Is(err, fault.ErrTLDOnly):
+			frontend.Get400HTML(c, fault.ErrTLDOnly)
+		case common.IsTimeout(err):
+			frontend.Get504HTML(c)
+		default:
+			frontend.Get500HTML(c)
+		}
+
+		return
+	}
+
+	data := frontend.ReportChangesData{
+		Question: d,
+		Since:    time.Unix(since, 0).UTC().Format(time.DateTime),
+		Until:    time.Unix(until, 0).UTC().Format(time.DateTime),
+		Changes:  make([]frontend.ChangesData, 0, len(diffs)),
+	}
+
+	for i := range diffs {
+
+		cd := frontend.ChangesData{Domain: diffs[i].Domain, Status: string(diffs[i].Status)}
+
+		for _, r := range diffs[i].Added {
+			cd.Added = append(cd.Added, getRecordsData(r))
+		}
+
+		for _, r := range diffs[i].Removed {
+			cd.Removed = append(cd.Removed, getRecordsData(r))
+		}
+
+		data.Changes = append(data.Changes, cd)
+	}
+
+	frontend.GetReportChanges(c, data)
+}
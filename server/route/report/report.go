@@ -13,6 +13,8 @@ import (
 	"github.com/elmasy-com/columbus/db"
 	"github.com/elmasy-com/columbus/fault"
 	"github.com/elmasy-com/columbus/frontend"
+	"github.com/elmasy-com/columbus/server/common"
+	"github.com/elmasy-com/columbus/server/respond"
 	"github.com/elmasy-com/elnet/dns"
 	"github.com/elmasy-com/elnet/validator"
 	"github.com/gin-gonic/gin"
@@ -39,15 +41,63 @@ func getReportDataStat(doms []db.Domain) frontend.ReportStat {
 	return rs
 }
 
-func getReportDataDomains(doms []db.Domain) []frontend.DomainsData {
+// getRecordDetails returns the structured fields of r (eg.: ["priority: 1", "target: svc.example.com.", "alpn: h2,h3"]).
+// The params are sorted by key.
+func getRecordDetails(r db.Record) []string {
+
+	var ds []string
+
+	if r.Priority != 0 {
+		ds = append(ds, fmt.Sprintf("priority: %d", r.Priority))
+	}
+
+	if r.Target != "" {
+		ds = append(ds, fmt.Sprintf("target: %s", r.Target))
+	}
+
+	keys := make([]string, 0, len(r.Params))
+	for k := range r.Params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for i := range keys {
+		ds = append(ds, fmt.Sprintf("%s: %s", keys[i], r.Params[keys[i]]))
+	}
+
+	return ds
+}
+
+// getRecordsData converts r to the format used in the templates.
+func getRecordsData(r db.Record) frontend.RecordsData {
+
+	rd := frontend.RecordsData{
+		Type:      db.RecordTypeString(r.Type),
+		Value:     r.Value,
+		Details:   getRecordDetails(r),
+		FirstSeen: time.Unix(r.First(), 0).UTC().Format(time.DateTime),
+		Time:      time.Unix(r.Last(), 0).UTC().Format(time.DateTime),
+	}
+
+	if r.Removed > 0 {
+		rd.Removed = time.Unix(r.Removed, 0).UTC().Format(time.DateTime)
+	}
+
+	return rd
+}
+
+// getReportDataDomains converts doms to the format used in the templates.
+// If update is true, sends the domains to the updater to update the DNS records.
+func getReportDataDomains(c *gin.Context, doms []db.Domain, update bool) []frontend.DomainsData {
 
 	dds := make([]frontend.DomainsData, 0, len(doms)/2)
+	dropped := 0
 
 	for i := range doms {
 
-		// Send domains to db.UpdaterChan channel if not full to update the DNS records.
-		if len(db.UpdaterChan) < cap(db.UpdaterChan) {
-			db.UpdaterChan <- db.UpdateableDomain{Domain: doms[i].String(), Type: db.UpdateExistingDomain}
+		// Send domains to the updater to update the DNS records.
+		if update && !db.UpdaterPush(c.Request.Context(), doms[i].String(), db.UpdateExistingDomain, db.PriorityLookup) {
+			dropped++
 		}
 
 		dd := frontend.DomainsData{
@@ -62,16 +112,16 @@ func getReportDataDomains(doms []db.Domain) []frontend.DomainsData {
 
 			dd.RecordsNum++
 
-			dd.Records = append(dd.Records, frontend.RecordsData{
-				Type:  dns.TypeToString(doms[i].Records[ii].Type),
-				Value: doms[i].Records[ii].Value,
-				Time:  time.Unix(doms[i].Records[ii].Time, 0).UTC().Format(time.DateTime),
-			})
+			dd.Records = append(dd.Records, getRecordsData(doms[i].Records[ii]))
 		}
 
 		dds = append(dds, dd)
 	}
 
+	if dropped > 0 {
+		c.Error(fmt.Errorf("failed to queue %d domains: updater queue is full", dropped))
+	}
+
 	return dds
 }
 
@@ -92,7 +142,10 @@ func GetReport(c *gin.Context) {
 		return
 	}
 
-	doms, err := db.DomainsDomains(d, -1)
+	ctx, cancel := common.Context(c, "report")
+	defer cancel()
+
+	doms, cached, err := db.DomainsDomainsCached(ctx, d, -1)
 	if err != nil {
 
 		c.Error(fmt.Errorf("fail to lookup full: %w", err))
@@ -104,6 +157,8 @@ func GetReport(c *gin.Context) {
 			frontend.Get400HTML(c, fault.ErrInvalidDays)
 		case errors.Is(err, fault.ErrTLDOnly):
 			frontend.Get400HTML(c, fault.ErrTLDOnly)
+		case common.IsTimeout(err):
+			frontend.Get504HTML(c)
 		default:
 			frontend.Get500HTML(c)
 		}
@@ -113,7 +168,7 @@ func GetReport(c *gin.Context) {
 
 	if len(doms) == 0 {
 
-		_, err = db.NotFoundInsert(d)
+		_, err = db.NotFoundInsert(ctx, d)
 		if err != nil {
 			c.Error(fmt.Errorf("failed to insert notFound: %w", err))
 		}
@@ -171,17 +226,19 @@ func GetReport(c *gin.Context) {
 		})
 	}
 
-	_, err = db.TopListInsert(d)
+	_, err = db.TopListInsert(ctx, d)
 	if err != nil {
 		c.Error(fmt.Errorf("failed to insert topList: %w", err))
 	}
 
+	respond.LastModified(c, time.Unix(db.DomainsModified(doms), 0))
+
 	reportData := frontend.ReportData{}
 
 	reportData.SubList = buildSubList(doms)
 	reportData.Question = d
 	reportData.Stat = getReportDataStat(doms)
-	reportData.Domains = getReportDataDomains(doms)
+	reportData.Domains = getReportDataDomains(c, doms, !cached)
 
 	frontend.GetReport(c, reportData)
 }
@@ -0,0 +1,34 @@
+// gen writes the OpenAPI specification generated from the route registry (see router.Spec()).
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/elmasy-com/columbus/server/router"
+)
+
+func main() {
+
+	out := flag.String("out", "", "Path of the output file (default stdout).")
+	version := flag.String("version", "dev", "Version of the API in the specification.")
+	flag.Parse()
+
+	spec, err := router.SpecYAML(*version)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to generate the specification: %s\n", err)
+		os.Exit(1)
+	}
+
+	if *out == "" {
+		os.Stdout.Write(spec)
+		return
+	}
+
+	err = os.WriteFile(*out, spec, 0644)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write %s: %s\n", *out, err)
+		os.Exit(1)
+	}
+}
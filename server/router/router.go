@@ -0,0 +1,215 @@
+/*
+router package is used to create the gin router of the server with every route.
+The router is used by the server and by the tests (eg.: with httptest).
+*/
+package router
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/elmasy-com/columbus/frontend"
+	"github.com/elmasy-com/columbus/logger"
+	"github.com/elmasy-com/columbus/server/config"
+	"github.com/elmasy-com/columbus/server/openapi"
+	"github.com/elmasy-com/columbus/server/route/admin"
+	"github.com/elmasy-com/columbus/server/route/api"
+	"github.com/elmasy-com/columbus/server/route/api/diff"
+	"github.com/elmasy-com/columbus/server/route/api/export"
+	"github.com/elmasy-com/columbus/server/route/api/history"
+	"github.com/elmasy-com/columbus/server/route/api/insert"
+	"github.com/elmasy-com/columbus/server/route/api/lookup"
+	"github.com/elmasy-com/columbus/server/route/api/reverse"
+	"github.com/elmasy-com/columbus/server/route/api/search"
+	"github.com/elmasy-com/columbus/server/route/api/starts"
+	"github.com/elmasy-com/columbus/server/route/api/statistics"
+	"github.com/elmasy-com/columbus/server/route/api/stream"
+	"github.com/elmasy-com/columbus/server/route/api/tld"
+	"github.com/elmasy-com/columbus/server/route/api/tools"
+	"github.com/elmasy-com/columbus/server/route/probe"
+	"github.com/elmasy-com/columbus/server/route/report"
+
+	"github.com/gin-gonic/gin"
+)
+
+// validRequestID returns whether the request ID sent by the client can be used (max 64 letter, digit, '-' or '_').
+func validRequestID(id string) bool {
+
+	if id == "" || len(id) > 64 {
+		return false
+	}
+
+	for _, c := range id {
+		if (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (c < '0' || c > '9') && c != '-' && c != '_' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Logger is the middleware that sets the request ID and logs the requests.
+//
+// The request ID is taken from the "X-Request-Id" header, or a new one is generated if missing or invalid.
+// The ID is sent back in the "X-Request-Id" header and set in the request context, so the db calls log it too.
+// If config.LogErrorOnly is true, the requests with 2XX status code and below 1 second are not logged.
+func Logger() gin.HandlerFunc {
+
+	return func(c *gin.Context) {
+
+		start := time.Now()
+
+		id := c.GetHeader("x-request-id")
+		if !validRequestID(id) {
+			id = logger.NewRequestID()
+		}
+
+		c.Header("x-request-id", id)
+		c.Request = c.Request.WithContext(logger.WithRequestID(c.Request.Context(), id))
+
+		c.Next()
+
+		latency := time.Since(start)
+		status := c.Writer.Status()
+
+		if status >= 200 && status < 300 && latency < time.Second && config.LogErrorOnly {
+			return
+		}
+
+		level := slog.LevelInfo
+
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
+		}
+
+		attrs := []slog.Attr{
+			slog.String("client", c.ClientIP()),
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.Int("status", status),
+			slog.Int("size", c.Writer.Size()),
+			slog.String("userAgent", c.Request.UserAgent()),
+			slog.Duration("latency", latency),
+		}
+
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, slog.String("error", c.Errors.String()))
+		}
+
+		slog.LogAttrs(c.Request.Context(), level, "request", attrs...)
+	}
+}
+
+// Route is an entry of the route registry.
+type Route struct {
+	Method   string
+	Path     string // Path in gin syntax (eg.: "/api/lookup/:domain")
+	Handlers []gin.HandlerFunc
+	Doc      *openapi.Operation // OpenAPI operation of the route, nil if the route is not part of the API (eg.: frontend, redirects).
+}
+
+// Routes is the registry of every route of the server.
+// The OpenAPI specification is generated from the Doc of the routes (see Spec()).
+var Routes = []Route{
+	{Method: http.MethodGet, Path: "/", Handlers: handlers(frontend.GetSearch)},
+	{Method: http.MethodGet, Path: "/search", Handlers: handlers(frontend.GetSearch)},
+
+	{Method: http.MethodGet, Path: "/api", Handlers: handlers(frontend.GetAPI)},
+
+	{Method: http.MethodGet, Path: "/about", Handlers: handlers(frontend.GetAbout)},
+	{Method: http.MethodGet, Path: "/dns-server", Handlers: handlers(frontend.GetDNSServer)},
+	{Method: http.MethodGet, Path: "/privacy-policy", Handlers: handlers(frontend.GetPrivacyPolicy)},
+	{Method: http.MethodGet, Path: "/contact", Handlers: handlers(frontend.GetContact)},
+
+	{Method: http.MethodGet, Path: "/api/lookup/:domain", Handlers: handlers(lookup.GetApiLookup), Doc: lookup.GetApiLookupDoc},
+	{Method: http.MethodGet, Path: "/api/starts/:domain", Handlers: handlers(starts.GetApiStarts), Doc: starts.GetApiStartsDoc},
+	{Method: http.MethodGet, Path: "/api/tld/:domain", Handlers: handlers(tld.GetApiTLD), Doc: tld.GetApiTLDDoc},
+	{Method: http.MethodGet, Path: "/api/history/:domain", Handlers: handlers(history.GetApiHistory), Doc: history.GetApiHistoryDoc},
+	{Method: http.MethodGet, Path: "/api/search", Handlers: handlers(search.GetApiSearch), Doc: search.GetApiSearchDoc},
+	{Method: http.MethodGet, Path: "/api/diff/:domain", Handlers: handlers(diff.GetApiDiff), Doc: diff.GetApiDiffDoc},
+	{Method: http.MethodGet, Path: "/api/export/:domain", Handlers: handlers(export.GetApiExport), Doc: export.GetApiExportDoc},
+	{Method: http.MethodGet, Path: "/api/reverse/:ip", Handlers: handlers(reverse.GetApiReverse), Doc: reverse.GetApiReverseDoc},
+	{Method: http.MethodGet, Path: "/api/reverse/:ip/:bits", Handlers: handlers(reverse.GetApiReverse), Doc: reverse.GetApiReverseCIDRDoc},
+	{Method: http.MethodGet, Path: "/api/reverse/cname/:target", Handlers: handlers(reverse.GetApiReverseCNAME), Doc: reverse.GetApiReverseCNAMEDoc},
+	{Method: http.MethodGet, Path: "/api/reverse/mx/:target", Handlers: handlers(reverse.GetApiReverseMX), Doc: reverse.GetApiReverseMXDoc},
+	{Method: http.MethodGet, Path: "/api/reverse/ns/:target", Handlers: handlers(reverse.GetApiReverseNS), Doc: reverse.GetApiReverseNSDoc},
+
+	{Method: http.MethodGet, Path: "/api/stat", Handlers: handlers(statistics.GetApiStat), Doc: statistics.GetApiStatDoc},
+	{Method: http.MethodGet, Path: "/api/stat/updater", Handlers: handlers(statistics.GetApiStatUpdater), Doc: statistics.GetApiStatUpdaterDoc},
+	{Method: http.MethodGet, Path: "/api/stat/cache", Handlers: handlers(statistics.GetApiStatCache), Doc: statistics.GetApiStatCacheDoc},
+	{Method: http.MethodGet, Path: "/healthz", Handlers: handlers(probe.GetHealthz), Doc: probe.GetHealthzDoc},
+	{Method: http.MethodGet, Path: "/readyz", Handlers: handlers(probe.GetReadyz), Doc: probe.GetReadyzDoc},
+	{Method: http.MethodGet, Path: "/version", Handlers: handlers(probe.GetVersion), Doc: probe.GetVersionDoc},
+	{Method: http.MethodGet, Path: "/statistics", Handlers: handlers(frontend.GetStatistics)},
+	{Method: http.MethodGet, Path: "/stat", Handlers: handlers(frontend.RedirectStatToStatistics)},
+
+	{Method: http.MethodGet, Path: "/search/:domain", Handlers: handlers(frontend.GetSearchRedirect)},
+	{Method: http.MethodGet, Path: "/report/:domain", Handlers: handlers(report.GetReport)},
+	{Method: http.MethodGet, Path: "/report/:domain/changes", Handlers: handlers(report.GetReportChanges)},
+	{Method: http.MethodGet, Path: "/report", Handlers: handlers(report.RedirectDomainParam)},
+
+	{Method: http.MethodGet, Path: "/api/tools/tld/:fqdn", Handlers: handlers(tools.ToolsTLDGet), Doc: tools.ToolsTLDGetDoc},
+	{Method: http.MethodGet, Path: "/api/tools/domain/:fqdn", Handlers: handlers(tools.ToolsDomainGet), Doc: tools.ToolsDomainGetDoc},
+	{Method: http.MethodGet, Path: "/api/tools/subdomain/:fqdn", Handlers: handlers(tools.ToolsSubdomainGet), Doc: tools.ToolsSubdomainGetDoc},
+	{Method: http.MethodGet, Path: "/api/tools/isvalid/:fqdn", Handlers: handlers(tools.ToolsIsValidGet), Doc: tools.ToolsIsValidGetDoc},
+
+	{Method: http.MethodPut, Path: "/api/insert/:domain", Handlers: handlers(insert.PutApiInsert), Doc: insert.PutApiInsertDoc},
+	{Method: http.MethodGet, Path: "/api/stream", Handlers: handlers(stream.GetApiStream), Doc: stream.GetApiStreamDoc},
+
+	{Method: http.MethodGet, Path: "/api/admin/queue", Handlers: handlers(admin.Auth, admin.GetQueue), Doc: admin.GetQueueDoc},
+	{Method: http.MethodGet, Path: "/api/admin/deadletter", Handlers: handlers(admin.Auth, admin.GetDeadLetter), Doc: admin.GetDeadLetterDoc},
+	{Method: http.MethodPost, Path: "/api/admin/deadletter/:domain/retry", Handlers: handlers(admin.Auth, admin.PostDeadLetterRetry), Doc: admin.PostDeadLetterRetryDoc},
+	{Method: http.MethodDelete, Path: "/api/admin/deadletter/:domain", Handlers: handlers(admin.Auth, admin.DeleteDeadLetter), Doc: admin.DeleteDeadLetterDoc},
+	{Method: http.MethodGet, Path: "/api/admin/subscriptions", Handlers: handlers(admin.Auth, admin.GetSubscriptions), Doc: admin.GetSubscriptionsDoc},
+	{Method: http.MethodPost, Path: "/api/admin/subscriptions", Handlers: handlers(admin.Auth, admin.PostSubscription), Doc: admin.PostSubscriptionDoc},
+	{Method: http.MethodDelete, Path: "/api/admin/subscriptions/:id", Handlers: handlers(admin.Auth, admin.DeleteSubscription), Doc: admin.DeleteSubscriptionDoc},
+	{Method: http.MethodGet, Path: "/api/admin/subscriptions/:id/deliveries", Handlers: handlers(admin.Auth, admin.GetDeliveries), Doc: admin.GetDeliveriesDoc},
+	{Method: http.MethodGet, Path: "/api/admin/export", Handlers: handlers(admin.Auth, admin.GetExport), Doc: admin.GetExportDoc},
+
+	// Redirect to /search/:domain
+	{Method: http.MethodGet, Path: "/lookup/:domain", Handlers: handlers(lookup.RedirectLookup)},
+
+	// Permanent Redirect
+	{Method: http.MethodGet, Path: "/tld/:domain", Handlers: handlers(api.RedirectOldRoutes)},
+	{Method: http.MethodGet, Path: "/tools/tld/:fqdn", Handlers: handlers(api.RedirectOldRoutes)},
+	{Method: http.MethodGet, Path: "/tools/domain/:fqdn", Handlers: handlers(api.RedirectOldRoutes)},
+	{Method: http.MethodGet, Path: "/tools/subdomain/:fqdn", Handlers: handlers(api.RedirectOldRoutes)},
+	{Method: http.MethodGet, Path: "/tools/isvalid/:fqdn", Handlers: handlers(api.RedirectOldRoutes)},
+
+	{Method: http.MethodGet, Path: "/400", Handlers: handlers(frontend.Get400)},
+	{Method: http.MethodGet, Path: "/404", Handlers: handlers(frontend.Get404)},
+	{Method: http.MethodGet, Path: "/500", Handlers: handlers(frontend.Get500)},
+	{Method: http.MethodGet, Path: "/502", Handlers: handlers(frontend.Get502)},
+	{Method: http.MethodGet, Path: "/504", Handlers: handlers(frontend.Get504)},
+
+	{Method: http.MethodGet, Path: "/sitemap.xml", Handlers: handlers(frontend.GetSitemapXML)},
+	{Method: http.MethodGet, Path: "/robots.txt", Handlers: handlers(frontend.GetRobotsTxt)},
+}
+
+func handlers(h ...gin.HandlerFunc) []gin.HandlerFunc {
+	return h
+}
+
+// New returns the router with the middlewares and every route in Routes.
+// The gin mode must be set before calling New().
+func New() *gin.Engine {
+
+	router := gin.New()
+
+	router.Use(Logger())
+	router.Use(gin.Recovery())
+
+	router.NoRoute(frontend.GetStatic)
+
+	router.SetTrustedProxies(config.TrustedProxies)
+
+	for i := range Routes {
+		router.Handle(Routes[i].Method, Routes[i].Path, Routes[i].Handlers...)
+	}
+
+	return router
+}
@@ -0,0 +1,435 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elmasy-com/columbus/db"
+	"github.com/elmasy-com/columbus/server/config"
+	"github.com/elmasy-com/elnet/blocklist"
+	"github.com/gin-gonic/gin"
+	mdns "github.com/miekg/dns"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// examples is the value of the path and required query parameters without Example in the contract test.
+var examples = map[string]string{
+	"domain": "example.com",
+	"fqdn":   "www.example.com",
+	"ip":     "203.0.113.1",
+	"bits":   "24",
+	"target": "herokudns.com",
+	"id":     seedSubscription,
+	"q":      "example.com",
+}
+
+// seedEnv is the environment variable of the MongoDB URI used by the seeded contract test (eg.: "mongodb://127.0.0.1:27017").
+const seedEnv = "COLUMBUS_TEST_MONGO"
+
+// seedSubscription is the ID of the subscription in the seeded database.
+const seedSubscription = "650000000000000000000001"
+
+// seedBodies are the request bodies of the operations in the seeded contract test.
+var seedBodies = map[string]string{
+	"PostSubscription": `{"apex": "example.com", "url": "https://hooks.example.net/columbus", "secret": "contract", "events": ["newFQDN"]}`,
+}
+
+// setupGlobals sets the global state of the server except the database.
+func setupGlobals(timeout time.Duration) {
+
+	gin.SetMode(gin.TestMode)
+	gin.DefaultWriter = io.Discard
+	gin.DefaultErrorWriter = io.Discard
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	config.AdminKey = "contract"
+	config.Blocklist = blocklist.NewBlocklist(time.Minute, 100)
+	config.Timeouts = map[string]time.Duration{"default": timeout}
+}
+
+// setup sets the global state of the server for the contract test.
+// The database is unreachable, so the handlers must respond with a documented error.
+func setup(t *testing.T) *gin.Engine {
+
+	t.Helper()
+
+	setupGlobals(time.Second)
+
+	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI("mongodb://127.0.0.1:1").SetServerSelectionTimeout(100*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Failed to create client: %s", err)
+	}
+
+	t.Cleanup(func() { client.Disconnect(context.Background()) })
+
+	db.Client = client
+	db.Use("columbus")
+
+	return New()
+}
+
+// setupSeeded sets the global state of the server for the contract test with a new database seeded with example.com,
+// the database is dropped after the test.
+// Returns the router and the function to restore the documents changed by the admin routes.
+//
+// Skips the test if COLUMBUS_TEST_MONGO is not set.
+func setupSeeded(t *testing.T) (*gin.Engine, func(t *testing.T)) {
+
+	t.Helper()
+
+	uri := os.Getenv(seedEnv)
+	if uri == "" {
+		t.Skipf("%s is not set", seedEnv)
+	}
+
+	setupGlobals(5 * time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	t.Cleanup(cancel)
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatalf("Failed to create client: %s", err)
+	}
+
+	if err = client.Ping(ctx, nil); err != nil {
+		t.Fatalf("Failed to ping %s: %s", uri, err)
+	}
+
+	name := fmt.Sprintf("columbus_contract_%d", time.Now().UnixNano())
+
+	t.Cleanup(func() {
+		client.Database(name).Drop(context.Background())
+		client.Disconnect(context.Background())
+	})
+
+	db.Client = client
+	db.Use(name)
+
+	if err = db.MigrationsUp(ctx, nil); err != nil {
+		t.Fatalf("Failed to migrate: %s", err)
+	}
+
+	for _, d := range []string{"example.com", "www.example.com", "app.example.com", "new.example.com", "reddit.com"} {
+		if _, err = db.DomainsInsert(ctx, d); err != nil {
+			t.Fatalf("Failed to insert %s: %s", d, err)
+		}
+	}
+
+	records := []struct {
+		domain string
+		record db.Record
+	}{
+		{"www.example.com", db.Record{Type: mdns.TypeA, Value: "203.0.113.1"}},
+		{"app.example.com", db.Record{Type: mdns.TypeCNAME, Value: "example.herokudns.com", Target: "example.herokudns.com"}},
+		{"example.com", db.Record{Type: mdns.TypeMX, Value: "10 mx.herokudns.com", Priority: 10, Target: "mx.herokudns.com"}},
+		{"example.com", db.Record{Type: mdns.TypeNS, Value: "ns1.herokudns.com", Target: "ns1.herokudns.com"}},
+		{"reddit.com", db.Record{Type: mdns.TypeA, Value: "203.0.113.2"}},
+	}
+
+	for i := range records {
+		if _, err = db.RecordsInsert(ctx, records[i].domain, records[i].record); err != nil {
+			t.Fatalf("Failed to insert record of %s: %s", records[i].domain, err)
+		}
+	}
+
+	if err = db.StatisticsInsert(ctx); err != nil {
+		t.Fatalf("Failed to insert statistics: %s", err)
+	}
+
+	if err = db.CTLogsUpdate(ctx, "contract", 10, 100); err != nil {
+		t.Fatalf("Failed to insert CT log: %s", err)
+	}
+
+	oid, _ := primitive.ObjectIDFromHex(seedSubscription)
+	sub := db.Subscription{ID: oid, Apex: "example.com", URL: "https://hooks.example.net/columbus", Secret: "contract", Created: time.Now().Unix()}
+
+	// The dead letter and the subscription are removed by the admin routes
+	reseed := func(t *testing.T) {
+
+		t.Helper()
+
+		err := db.QueueFail(ctx, "example.com", db.UpdateExistingDomain, db.PriorityInsert, errors.New("contract"))
+		if err != nil {
+			t.Fatalf("Failed to insert dead letter: %s", err)
+		}
+
+		_, err = db.Subscriptions.ReplaceOne(ctx, bson.M{"_id": oid}, sub, options.Replace().SetUpsert(true))
+		if err != nil {
+			t.Fatalf("Failed to insert subscription: %s", err)
+		}
+
+		err = db.DeliveriesAdd(ctx, db.Event{Seq: 1, Type: db.EventNewFQDN, Domain: "www.example.com", Apex: "example.com", Time: sub.Created}, []db.Subscription{sub})
+		if err != nil {
+			t.Fatalf("Failed to insert delivery: %s", err)
+		}
+	}
+
+	return New(), reseed
+}
+
+// request returns the request of r built from the parameters of the documented operation.
+func request(t *testing.T, r Route, accept string) *http.Request {
+
+	t.Helper()
+
+	path := OpenAPIPath(r.Path)
+	query := make([]string, 0)
+
+	for _, p := range r.Doc.Parameters {
+
+		if !p.Required {
+			continue
+		}
+
+		v, ok := p.Example, p.Example != ""
+		if !ok {
+			v, ok = examples[p.Name]
+		}
+		if !ok {
+			t.Fatalf("%s %s: no example for parameter %s", r.Method, r.Path, p.Name)
+		}
+
+		switch p.In {
+		case "path":
+			path = strings.Replace(path, "{"+p.Name+"}", v, 1)
+		case "query":
+			query = append(query, p.Name+"="+v)
+		default:
+			t.Fatalf("%s %s: unsupported parameter location %s", r.Method, r.Path, p.In)
+		}
+	}
+
+	if len(query) > 0 {
+		path += "?" + strings.Join(query, "&")
+	}
+
+	var body io.Reader
+
+	if r.Doc.RequestBody != nil {
+		body = strings.NewReader("{}")
+	}
+
+	// The stream never ends, the context stops it.
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	t.Cleanup(cancel)
+
+	req := httptest.NewRequest(r.Method, path, body).WithContext(ctx)
+	req.Header.Set("Accept", accept)
+
+	if r.Doc.Security != nil {
+		req.Header.Set("X-Api-Key", config.AdminKey)
+	}
+
+	return req
+}
+
+// TestContract hits every documented route and validates the response against the spec.
+func TestContract(t *testing.T) {
+
+	router := setup(t)
+	spec := Spec("dev")
+
+	for _, r := range Routes {
+
+		if r.Doc == nil {
+			continue
+		}
+
+		for _, accept := range []string{"application/json", "text/plain", "application/x-ndjson", "text/csv"} {
+
+			t.Run(r.Doc.ID+"/"+accept, func(t *testing.T) {
+
+				w := httptest.NewRecorder()
+
+				router.ServeHTTP(w, request(t, r, accept))
+
+				err := spec.ValidateResponse(r.Doc, w.Code, w.Header().Get("Content-Type"), w.Body.Bytes())
+				if err != nil {
+					t.Fatalf("%s %s: %s (body: %q)", r.Method, r.Path, err, w.Body.String())
+				}
+			})
+		}
+	}
+}
+
+// TestContractSeeded hits every documented route on a seeded database and validates the success response against the spec.
+// Requires a MongoDB server, skipped if COLUMBUS_TEST_MONGO is not set.
+// The contract workflow in .github/workflows runs it against a MongoDB service container.
+func TestContractSeeded(t *testing.T) {
+
+	router, reseed := setupSeeded(t)
+	spec := Spec("dev")
+
+	for _, r := range Routes {
+
+		if r.Doc == nil {
+			continue
+		}
+
+		for _, accept := range []string{"application/json", "text/plain", "application/x-ndjson", "text/csv"} {
+
+			t.Run(r.Doc.ID+"/"+accept, func(t *testing.T) {
+
+				reseed(t)
+
+				req := request(t, r, accept)
+
+				if b, ok := seedBodies[r.Doc.ID]; ok {
+					req.Body = io.NopCloser(strings.NewReader(b))
+					req.ContentLength = int64(len(b))
+					req.Header.Set("Content-Type", "application/json")
+				}
+
+				w := httptest.NewRecorder()
+
+				router.ServeHTTP(w, req)
+
+				// The other formats may be rejected by a JSON only route
+				if accept == "application/json" && (w.Code < 200 || w.Code > 299) {
+					t.Fatalf("%s %s: want success, got %d (body: %q)", r.Method, r.Path, w.Code, w.Body.String())
+				}
+
+				err := spec.ValidateResponse(r.Doc, w.Code, w.Header().Get("Content-Type"), w.Body.Bytes())
+				if err != nil {
+					t.Fatalf("%s %s: %s (body: %q)", r.Method, r.Path, err, w.Body.String())
+				}
+			})
+		}
+	}
+}
+
+// TestContractAdminUnauthorized tests the documented response of the admin routes without API key.
+func TestContractAdminUnauthorized(t *testing.T) {
+
+	router := setup(t)
+	spec := Spec("dev")
+
+	for _, r := range Routes {
+
+		if r.Doc == nil || r.Doc.Security == nil {
+			continue
+		}
+
+		req := request(t, r, "application/json")
+		req.Header.Del("X-Api-Key")
+
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("%s %s: want %d, got %d", r.Method, r.Path, http.StatusUnauthorized, w.Code)
+		}
+
+		err := spec.ValidateResponse(r.Doc, w.Code, w.Header().Get("Content-Type"), w.Body.Bytes())
+		if err != nil {
+			t.Errorf("%s %s: %s", r.Method, r.Path, err)
+		}
+	}
+}
+
+// TestRequestID tests that the valid request ID of the client is sent back and an invalid one is replaced.
+func TestRequestID(t *testing.T) {
+
+	router := setup(t)
+
+	for id, keep := range map[string]bool{"abc-123_DEF": true, "": false, "bad id": false, strings.Repeat("a", 65): false} {
+
+		req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+		req.Header.Set("X-Request-Id", id)
+
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		got := w.Header().Get("X-Request-Id")
+
+		if keep && got != id || !keep && (got == id || !validRequestID(got)) {
+			t.Errorf("%q: unexpected request ID: %q", id, got)
+		}
+	}
+}
+
+// TestRoutesDocumented tests that every API route has an OpenAPI operation with a unique ID, valid path parameters and a success response.
+func TestRoutesDocumented(t *testing.T) {
+
+	ids := make(map[string]bool)
+
+	for _, r := range Routes {
+
+		if !strings.HasPrefix(r.Path, "/api/") {
+			continue
+		}
+
+		if r.Doc == nil {
+			t.Errorf("%s %s is not documented", r.Method, r.Path)
+			continue
+		}
+
+		if ids[r.Doc.ID] {
+			t.Errorf("%s %s: duplicate operation ID %s", r.Method, r.Path, r.Doc.ID)
+		}
+		ids[r.Doc.ID] = true
+
+		for _, p := range r.Doc.Parameters {
+			if p.In == "path" && !strings.Contains(r.Path, ":"+p.Name) {
+				t.Errorf("%s %s: path parameter %s is not in the path", r.Method, r.Path, p.Name)
+			}
+		}
+
+		_, ok200 := r.Doc.Responses[http.StatusOK]
+		_, ok201 := r.Doc.Responses[http.StatusCreated]
+		if !ok200 && !ok201 {
+			t.Errorf("%s %s: no success response", r.Method, r.Path)
+		}
+	}
+}
+
+// TestSpecUpToDate tests that frontend/static/openapi.yaml is generated from the current registry.
+func TestSpecUpToDate(t *testing.T) {
+
+	want, err := SpecYAML("dev")
+	if err != nil {
+		t.Fatalf("Failed to generate: %s", err)
+	}
+
+	got, err := os.ReadFile("../../frontend/static/openapi.yaml")
+	if err != nil {
+		t.Fatalf("Failed to read: %s", err)
+	}
+
+	if !bytes.Equal(got, want) {
+		t.Fatalf("frontend/static/openapi.yaml is outdated, run \"go generate ./server/router\"")
+	}
+}
+
+func TestOpenAPIPath(t *testing.T) {
+
+	cases := map[string]string{
+		"/api/stat":                    "/api/stat",
+		"/api/lookup/:domain":          "/api/lookup/{domain}",
+		"/api/reverse/:ip/:bits":       "/api/reverse/{ip}/{bits}",
+		"/api/admin/subscriptions/:id": "/api/admin/subscriptions/{id}",
+		"/static/*path":                "/static/{path}",
+	}
+
+	for in, want := range cases {
+		if got := OpenAPIPath(in); got != want {
+			t.Errorf("%s: want %s, got %s", in, want, got)
+		}
+	}
+}
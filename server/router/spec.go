@@ -0,0 +1,66 @@
+package router
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/elmasy-com/columbus/server/openapi"
+	"gopkg.in/yaml.v3"
+)
+
+//go:generate go run ./gen -out ../../frontend/static/openapi.yaml
+
+// Spec returns the OpenAPI document generated from the documented routes in Routes.
+func Spec(version string) *openapi.Document {
+
+	doc := openapi.New(version)
+
+	for i := range Routes {
+
+		if Routes[i].Doc == nil {
+			continue
+		}
+
+		doc.Add(strings.ToLower(Routes[i].Method), OpenAPIPath(Routes[i].Path), Routes[i].Doc)
+	}
+
+	return doc
+}
+
+// SpecYAML returns the YAML encoded Spec().
+func SpecYAML(version string) ([]byte, error) {
+
+	buf := new(bytes.Buffer)
+
+	buf.WriteString("# Code generated by \"go generate ./server/router\". DO NOT EDIT.\n\n")
+
+	enc := yaml.NewEncoder(buf)
+	enc.SetIndent(2)
+
+	err := enc.Encode(Spec(version))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode: %w", err)
+	}
+
+	err = enc.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to close encoder: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// OpenAPIPath converts the gin path p to OpenAPI syntax (eg.: "/api/lookup/:domain" -> "/api/lookup/{domain}").
+func OpenAPIPath(p string) string {
+
+	parts := strings.Split(p, "/")
+
+	for i := range parts {
+		if strings.HasPrefix(parts[i], ":") || strings.HasPrefix(parts[i], "*") {
+			parts[i] = "{" + parts[i][1:] + "}"
+		}
+	}
+
+	return strings.Join(parts, "/")
+}
@@ -0,0 +1,372 @@
+/*
+webhook package is used to deliver the change events to the subscribed URLs.
+
+The payload is the JSON encoded db.Event, sent with a POST request.
+The payload is signed with HMAC-SHA256 using the secret of the subscription,
+the signature is in the "X-Columbus-Signature" header in the format "sha256=<hex>".
+
+The "X-Columbus-Delivery" header is the ID of the delivery, the same in every retry of the delivery,
+the "X-Columbus-Event-Id" header is the ID of the event, the same in the deliveries of the event to the different subscriptions.
+*/
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/elmasy-com/columbus/db"
+	"github.com/elmasy-com/columbus/fault"
+)
+
+const (
+	HeaderSignature = "X-Columbus-Signature"
+	HeaderEvent     = "X-Columbus-Event"    // Type of the event
+	HeaderEventID   = "X-Columbus-Event-Id" // ID of the event
+	HeaderDelivery  = "X-Columbus-Delivery" // ID of the delivery
+)
+
+// Sign returns the signature of body with secret in the format "sha256=<hex>".
+func Sign(secret string, body []byte) string {
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify returns whether signature is a valid signature of body with secret.
+func Verify(secret string, body []byte, signature string) bool {
+
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
+
+// CursorName is the name of the event cursor of the dispatcher in the database (see db.EventsCursorGet()).
+const CursorName = "webhook"
+
+// Dispatcher delivers the events to the subscriptions.
+//
+// The dispatcher reads the events in order from the last stored cursor, so the events emitted while the server is down are delivered after the start.
+// Every matching subscription gets a pending delivery in the "deliveries" collection, that holds the retry state,
+// so the pending retries continue after a restart.
+type Dispatcher struct {
+	Client      *http.Client
+	MaxAttempts int           // Number of attempts before the delivery fails
+	Backoff     time.Duration // Delay before the first retry, doubled after every failed attempt
+}
+
+// NewDispatcher returns a Dispatcher with the default values.
+func NewDispatcher() *Dispatcher {
+
+	return &Dispatcher{
+		Client:      &http.Client{Timeout: 10 * time.Second},
+		MaxAttempts: 5,
+		Backoff:     10 * time.Second,
+	}
+}
+
+// send sends body to the URL of s once.
+// Returns the HTTP status code (0 if the request failed) and an error if the delivery failed.
+func (d *Dispatcher) send(ctx context.Context, s db.Subscription, dl db.Delivery, body []byte) (int, error) {
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Columbus-Webhook")
+	req.Header.Set(HeaderEvent, string(dl.Payload.Type))
+	req.Header.Set(HeaderEventID, dl.Payload.ID.Hex())
+	req.Header.Set(HeaderDelivery, dl.ID.Hex())
+	req.Header.Set(HeaderSignature, Sign(s.Secret, body))
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	// Read the body to reuse the connection
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// Deliver sends the event of delivery dl to the subscription s once.
+// The attempt fails on network error or non 2xx status code, the retry is scheduled by the caller.
+//
+// Returns the log of the attempt and the error if the attempt failed.
+func (d *Dispatcher) Deliver(ctx context.Context, s db.Subscription, dl db.Delivery) (db.DeliveryAttempt, error) {
+
+	body, err := json.Marshal(dl.Payload)
+	if err != nil {
+		return db.DeliveryAttempt{}, fmt.Errorf("failed to marshal: %w", err)
+	}
+
+	start := time.Now()
+
+	status, err := d.send(ctx, s, dl, body)
+
+	a := db.DeliveryAttempt{
+		Status:   status,
+		Duration: time.Since(start).Milliseconds(),
+		Time:     start.Unix(),
+	}
+
+	if err != nil {
+		a.Error = err.Error()
+	}
+
+	return a, err
+}
+
+// next returns the state of a delivery after attempt n failed with err (nil if succeeded) at now,
+// and the time of the next attempt if the delivery is pending.
+// The delay is Backoff after the first attempt, doubled after every failed attempt.
+func (d *Dispatcher) next(n int, err error, now time.Time) (db.DeliveryState, int64) {
+
+	if err == nil {
+		return db.DeliveryDelivered, 0
+	}
+
+	if n >= d.MaxAttempts {
+		return db.DeliveryFailed, 0
+	}
+
+	backoff := d.Backoff
+
+	for i := 1; i < n; i++ {
+		backoff *= 2
+	}
+
+	return db.DeliveryPending, now.Add(backoff).Unix()
+}
+
+// Run reads the new events from the "events" collection and delivers them to the matching subscriptions.
+// On the first start (no stored cursor) the events emitted before Run() are not delivered.
+//
+// This function blocks until ctx is done.
+func (d *Dispatcher) Run(ctx context.Context) {
+
+	var wg sync.WaitGroup
+
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+		d.feed(ctx)
+	}()
+
+	go func() {
+		defer wg.Done()
+		d.deliver(ctx)
+	}()
+
+	wg.Wait()
+}
+
+// cursor returns the reader of the events starting after the stored cursor.
+// If the cursor is not stored, starts after the last event.
+func cursor(ctx context.Context) (*db.EventReader, error) {
+
+	last, ok, err := db.EventsCursorGet(ctx, CursorName)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		last, err = db.EventsLastSeq(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &db.EventReader{Last: last}, nil
+}
+
+// feed reads the new events and adds the pending deliveries of the matching subscriptions.
+// The cursor is stored after the deliveries of a batch are added.
+func (d *Dispatcher) feed(ctx context.Context) {
+
+	r, err := cursor(ctx)
+	for err != nil {
+		slog.Error("webhook: failed to get the cursor", "error", err)
+		if !sleep(ctx, 60*time.Second) {
+			return
+		}
+		r, err = cursor(ctx)
+	}
+
+	for {
+
+		prev := r.Last
+
+		es, err := r.Next(ctx, 1000)
+		if err == nil && len(es) > 0 {
+			err = d.enqueue(ctx, es)
+		}
+		if err == nil && len(es) > 0 {
+			err = db.EventsCursorSet(ctx, CursorName, r.Last)
+		}
+
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			slog.Error("webhook: failed to read events", "error", err)
+			// Read the batch again, adding the deliveries again does nothing
+			r.Last = prev
+			if !sleep(ctx, 60*time.Second) {
+				return
+			}
+			continue
+		}
+
+		if len(es) == 0 {
+			if !sleep(ctx, time.Second) {
+				return
+			}
+		}
+	}
+}
+
+// enqueue adds the pending deliveries of es to the matching subscriptions.
+func (d *Dispatcher) enqueue(ctx context.Context, es []db.Event) error {
+
+	subs := make(map[string][]db.Subscription)
+
+	for i := range es {
+
+		ss, ok := subs[es[i].Apex]
+		if !ok {
+
+			var err error
+
+			ss, err = db.SubscriptionsGets(ctx, es[i].Apex)
+			if err != nil {
+				return fmt.Errorf("failed to get subscriptions for %s: %w", es[i].Apex, err)
+			}
+
+			subs[es[i].Apex] = ss
+		}
+
+		wants := make([]db.Subscription, 0, len(ss))
+
+		for ii := range ss {
+			if ss[ii].Wants(es[i].Type) {
+				wants = append(wants, ss[ii])
+			}
+		}
+
+		if err := db.DeliveriesAdd(ctx, es[i], wants); err != nil {
+			return fmt.Errorf("failed to add deliveries of %s: %w", es[i].ID.Hex(), err)
+		}
+	}
+
+	return nil
+}
+
+// deliver claims the due deliveries and attempts them.
+// Every attempt of a batch runs in its own goroutine, a slow receiver does not block the others.
+func (d *Dispatcher) deliver(ctx context.Context) {
+
+	for {
+
+		ds, err := db.DeliveriesClaim(ctx, 100)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			slog.Error("webhook: failed to claim deliveries", "error", err)
+		}
+
+		if len(ds) == 0 {
+			if !sleep(ctx, time.Second) {
+				return
+			}
+			continue
+		}
+
+		var wg sync.WaitGroup
+
+		for i := range ds {
+
+			wg.Add(1)
+
+			go func(dl db.Delivery) {
+				defer wg.Done()
+				d.attempt(ctx, dl)
+			}(ds[i])
+		}
+
+		wg.Wait()
+	}
+}
+
+// attempt sends the event of delivery dl and stores the result.
+// If the subscription is removed, the delivery fails without sending.
+func (d *Dispatcher) attempt(ctx context.Context, dl db.Delivery) {
+
+	var a db.DeliveryAttempt
+
+	s, err := db.SubscriptionsGet(ctx, dl.Subscription)
+
+	switch {
+	case errors.Is(err, fault.ErrNotFound):
+		a = db.DeliveryAttempt{Error: "subscription is removed", Time: time.Now().Unix()}
+		err = fault.ErrNotFound
+	case err != nil:
+		// Database error, the lease expires and the delivery is claimed again
+		slog.Error("webhook: failed to get subscription", "subscription", dl.Subscription.Hex(), "error", err)
+		return
+	default:
+		a, err = d.Deliver(ctx, s, dl)
+	}
+
+	if ctx.Err() != nil {
+		// Stopping, the lease expires and the delivery is claimed again
+		return
+	}
+
+	a.Attempt = len(dl.Attempts) + 1
+
+	state, next := d.next(a.Attempt, err, time.Now())
+	if errors.Is(err, fault.ErrNotFound) {
+		state = db.DeliveryFailed
+	}
+
+	if state == db.DeliveryFailed {
+		slog.Warn("webhook: failed to deliver", "event", dl.Event.Hex(), "subscription", dl.Subscription.Hex(), "attempts", a.Attempt, "error", err)
+	}
+
+	if err := db.DeliveriesUpdate(ctx, dl.ID, a, state, next); err != nil {
+		slog.Error("webhook: failed to update delivery", "delivery", dl.ID.Hex(), "error", err)
+	}
+}
+
+// sleep pauses for t or until ctx is done.
+// Returns false if ctx is done.
+func sleep(ctx context.Context, t time.Duration) bool {
+
+	select {
+	case <-time.After(t):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
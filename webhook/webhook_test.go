@@ -0,0 +1,140 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/elmasy-com/columbus/db"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func testEvent() db.Event {
+
+	return db.Event{
+		ID:     primitive.NewObjectID(),
+		Seq:    1,
+		Type:   db.EventNewRecord,
+		Domain: "www.example.com",
+		Apex:   "example.com",
+		Record: &db.Record{Type: 1, Value: "192.0.2.1"},
+		Time:   time.Now().Unix(),
+	}
+}
+
+func testDispatcher() *Dispatcher {
+
+	return &Dispatcher{
+		Client:      &http.Client{Timeout: time.Second},
+		MaxAttempts: 3,
+		Backoff:     time.Minute,
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+
+	body := []byte(`{"type":"newFQDN"}`)
+
+	sig := Sign("secret", body)
+
+	if !Verify("secret", body, sig) {
+		t.Fatalf("valid signature is not verified: %s", sig)
+	}
+
+	if Verify("other", body, sig) {
+		t.Fatalf("signature is verified with an other secret")
+	}
+
+	if Verify("secret", []byte(`{"type":"newRecord"}`), sig) {
+		t.Fatalf("signature is verified with an other body")
+	}
+}
+
+func TestDeliver(t *testing.T) {
+
+	var (
+		got     db.Event
+		valid   bool
+		headers http.Header
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		body, _ := io.ReadAll(r.Body)
+
+		valid = Verify("secret", body, r.Header.Get(HeaderSignature)) && r.Header.Get(HeaderEvent) == string(db.EventNewRecord)
+		headers = r.Header.Clone()
+
+		json.Unmarshal(body, &got)
+	}))
+	defer srv.Close()
+
+	e := testEvent()
+	dl := db.Delivery{ID: primitive.NewObjectID(), Event: e.ID, Payload: e}
+
+	a, err := testDispatcher().Deliver(context.Background(), db.Subscription{ID: primitive.NewObjectID(), URL: srv.URL, Secret: "secret"}, dl)
+	if err != nil {
+		t.Fatalf("FAIL: %s", err)
+	}
+
+	if !valid {
+		t.Fatalf("invalid signature or event header")
+	}
+
+	if headers.Get(HeaderDelivery) != dl.ID.Hex() || headers.Get(HeaderEventID) != e.ID.Hex() {
+		t.Fatalf("invalid delivery or event ID header: %v", headers)
+	}
+
+	if got.ID != e.ID || got.Seq != e.Seq || got.Domain != e.Domain || got.Record == nil || got.Record.Value != e.Record.Value {
+		t.Fatalf("invalid payload: %#v", got)
+	}
+
+	if a.Status != http.StatusOK || a.Error != "" {
+		t.Fatalf("invalid delivery attempt: %#v", a)
+	}
+}
+
+func TestDeliverFail(t *testing.T) {
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGone)
+	}))
+	defer srv.Close()
+
+	a, err := testDispatcher().Deliver(context.Background(), db.Subscription{URL: srv.URL, Secret: "secret"}, db.Delivery{ID: primitive.NewObjectID(), Payload: testEvent()})
+	if err == nil {
+		t.Fatalf("FAIL: expected error")
+	}
+
+	if a.Status != http.StatusGone || a.Error == "" {
+		t.Fatalf("invalid delivery attempt: %#v", a)
+	}
+}
+
+func TestNext(t *testing.T) {
+
+	d := testDispatcher()
+	now := time.Unix(1000, 0)
+	fail := errors.New("unexpected status")
+
+	if s, next := d.next(1, nil, now); s != db.DeliveryDelivered || next != 0 {
+		t.Fatalf("successful attempt: %s, %d", s, next)
+	}
+
+	if s, next := d.next(1, fail, now); s != db.DeliveryPending || next != now.Add(time.Minute).Unix() {
+		t.Fatalf("first failed attempt: %s, %d", s, next)
+	}
+
+	if s, next := d.next(2, fail, now); s != db.DeliveryPending || next != now.Add(2*time.Minute).Unix() {
+		t.Fatalf("second failed attempt: %s, %d", s, next)
+	}
+
+	if s, next := d.next(3, fail, now); s != db.DeliveryFailed || next != 0 {
+		t.Fatalf("last failed attempt: %s, %d", s, next)
+	}
+}